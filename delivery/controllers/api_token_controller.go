@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ginNewAPIToken struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,max=3,dive,oneof=tasks:read tasks:write admin"`
+	// ExpiresAt is omitted for tokens that do not expire.
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty,future"`
+}
+
+// ginAPIToken describes a token. Token, the secret, is only set in the
+// response that creates it.
+type ginAPIToken struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// WorkspaceID is the workspace the token acts in.
+	WorkspaceID string     `json:"workspace_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	Token       string     `json:"token,omitempty"`
+}
+
+func fromDomainAPIToken(token *domain.APIToken) *ginAPIToken {
+	out := &ginAPIToken{
+		ID:          token.ID,
+		Name:        token.Name,
+		Scopes:      token.Scopes,
+		WorkspaceID: token.WorkspaceID,
+		CreatedAt:   token.CreatedAt,
+	}
+	if !token.ExpiresAt.IsZero() {
+		out.ExpiresAt = &token.ExpiresAt
+	}
+	if !token.LastUsedAt.IsZero() {
+		out.LastUsedAt = &token.LastUsedAt
+	}
+	return out
+}
+
+// CreateAPIToken handles POST api/me/tokens requests. The response is the
+// only place the token itself is shown.
+func (ac *AppController) CreateAPIToken(c *gin.Context) {
+	var req ginNewAPIToken
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	// tokens act in the workspace they were created in
+	token := &domain.APIToken{Name: req.Name, Scopes: req.Scopes, WorkspaceID: currentUser(c).WorkspaceID}
+	if req.ExpiresAt != nil {
+		token.ExpiresAt = *req.ExpiresAt
+	}
+	secret, err := ac.userUsecase.CreateAPIToken(currentUser(c).ID, token)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := fromDomainAPIToken(token)
+	out.Token = secret
+	c.JSON(http.StatusCreated, out)
+}
+
+// ListAPITokens handles GET api/me/tokens requests.
+func (ac *AppController) ListAPITokens(c *gin.Context) {
+	tokens, err := ac.userUsecase.ListAPITokens(currentUser(c).ID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := make([]*ginAPIToken, 0, len(tokens))
+	for _, token := range tokens {
+		out = append(out, fromDomainAPIToken(token))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeAPIToken handles DELETE api/me/tokens/{id} requests.
+func (ac *AppController) RevokeAPIToken(c *gin.Context) {
+	if err := ac.userUsecase.RevokeAPIToken(currentUser(c).ID, c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"task-manager/domain"
+	"task-manager/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginBulkOperation is validated like the task endpoints: its task has the
+// rules of ginTask, and creates need a title as ginNewTask does.
+type ginBulkOperation struct {
+	Op   string  `json:"op" binding:"required,oneof=create update delete"`
+	ID   string  `json:"id,omitempty"`
+	Task ginTask `json:"task"`
+}
+
+// ginBulkRequest either lists operations or gives a filter query and a patch
+// to apply to every matching task.
+type ginBulkRequest struct {
+	Atomic     bool               `json:"atomic"`
+	Operations []ginBulkOperation `json:"operations" binding:"max=1000,dive"`
+	Filter     string             `json:"filter"`
+	Patch      *ginTask           `json:"patch"`
+}
+
+type ginBulkResult struct {
+	Index  int      `json:"index"`
+	Op     string   `json:"op"`
+	ID     string   `json:"id,omitempty"`
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Task   *ginTask `json:"task,omitempty"`
+}
+
+func fromDomainBulkResult(result *domain.BulkResult) *ginBulkResult {
+	gResult := &ginBulkResult{
+		Index:  result.Index,
+		Op:     result.Op,
+		ID:     result.ID,
+		Status: result.Status,
+	}
+	if result.Err != nil {
+		gResult.Error = result.Err.Error()
+		if errors.Is(result.Err, errs.ErrUnexpected) {
+			gResult.Error = errs.ErrUnexpected.Error()
+		}
+	}
+	if result.Task != nil {
+		gResult.Task = fromDomainTask(result.Task)
+	}
+	return gResult
+}
+
+// BulkTasks handles POST api/tasks/bulk requests.
+func (ac *AppController) BulkTasks(c *gin.Context) {
+	var request ginBulkRequest
+	if err := bindJSON(c, &request); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if request.Filter != "" || request.Patch != nil {
+		if len(request.Operations) > 0 || request.Patch == nil {
+			handleError(c, fmt.Errorf("%w: give either operations or a filter with a patch", errs.ErrInvalidBulkRequest))
+			return
+		}
+		matched, modified, err := ac.taskUsecase.PatchTasks(currentUser(c), request.Filter, *toDomainTask(request.Patch))
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"matched": matched, "modified": modified})
+		return
+	}
+
+	ops := make([]domain.BulkOperation, 0, len(request.Operations))
+	for _, op := range request.Operations {
+		ops = append(ops, domain.BulkOperation{Op: op.Op, ID: op.ID, Task: *toDomainTask(&op.Task)})
+	}
+
+	results, err := ac.taskUsecase.BulkTasks(currentUser(c), ops, request.Atomic)
+	if err != nil && !errors.Is(err, errs.ErrBulkAborted) {
+		handleError(c, err)
+		return
+	}
+
+	succeeded, failed := 0, 0
+	ginResults := make([]*ginBulkResult, 0, len(results))
+	for _, result := range results {
+		switch result.Status {
+		case domain.BulkStatusOK:
+			succeeded++
+		case domain.BulkStatusFailed:
+			failed++
+		}
+		ginResults = append(ginResults, fromDomainBulkResult(result))
+	}
+
+	body := gin.H{"results": ginResults, "succeeded": succeeded, "failed": failed}
+	if err != nil {
+		body["error"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, body)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCalendarToken handles POST api/me/calendar requests. It returns a
+// new feed URL for the current user and workspace; any previous URL for
+// them stops working.
+func (ac *AppController) CreateCalendarToken(c *gin.Context) {
+	user := currentUser(c)
+
+	token, err := ac.userUsecase.CreateCalendarToken(user.WorkspaceID, user.ID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token": token,
+		"url":   fmt.Sprintf("%s://%s/ical/%s.ics", scheme, c.Request.Host, token),
+	})
+}
+
+// RevokeCalendarToken handles DELETE api/me/calendar requests.
+func (ac *AppController) RevokeCalendarToken(c *gin.Context) {
+	user := currentUser(c)
+
+	if err := ac.userUsecase.RevokeCalendarToken(user.WorkspaceID, user.ID); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CalendarFeed handles GET ical/{token}.ics requests for the user that
+// CalendarFeedAuth found by the token.
+func (ac *AppController) CalendarFeed(c *gin.Context) {
+	var component string
+	switch c.DefaultQuery("as", "todo") {
+	case "todo":
+		component = icalTodo
+	case "event":
+		component = icalEvent
+	default:
+		handleError(c, queryParamError("as", "todo event"))
+		return
+	}
+
+	user := currentUser(c)
+	tasks, err := ac.taskUsecase.CalendarTasks(user, c.Query("q"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := writeCalendar(&buf, "Tasks ("+user.Username+")", component, tasks, time.Now()); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// ImportCalendar handles POST api/tasks/import/ics requests, creating a task
+// for every VTODO and VEVENT in the uploaded file. Times without a time zone
+// are read in the zone given by ?tz=, if any.
+func (ac *AppController) ImportCalendar(c *gin.Context) {
+	body, _, ok := importBody(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	var loc *time.Location
+	if tz := c.Query("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			handleError(c, fmt.Errorf("%w: unknown time zone %q", errs.ErrInvalidImport, tz))
+			return
+		}
+	}
+
+	rows, err := readICSRows(body, loc)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	opts := domain.ImportOptions{DryRun: c.Query("dry_run") == "true"}
+	report, err := ac.taskUsecase.ImportTasks(currentUser(c), rows, opts)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainImportReport(report, opts.DryRun))
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"log"
 	"net/http"
+	"strconv"
 	"task-manager/domain"
 	"task-manager/usecases"
 	"time"
@@ -18,10 +19,20 @@ type AppController struct {
 
 type ginTask struct {
 	ID          string    `json:"id,omitempty"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	DueDate     time.Time `json:"due_date"`
-	Status      string    `json:"status"`
+	Title       string    `json:"title" binding:"max=200"`
+	Description string    `json:"description" binding:"max=2000"`
+	DueDate     time.Time `json:"due_date" binding:"duedate"`
+	Status      string    `json:"status" binding:"omitempty,oneof=Pending 'In Progress' Completed"`
+	Labels      []string  `json:"labels,omitempty" binding:"max=20,dive,min=1,max=50"`
+	Assignee    string    `json:"assignee,omitempty" binding:"max=100"`
+	Overdue     bool      `json:"overdue"`
+}
+
+// ginNewTask is a ginTask that must have a title. Updates may leave the
+// title out to keep the current one.
+type ginNewTask struct {
+	ginTask
+	Title string `json:"title" binding:"required,max=200"`
 }
 
 func fromDomainTask(task *domain.Task) *ginTask {
@@ -31,6 +42,9 @@ func fromDomainTask(task *domain.Task) *ginTask {
 		Description: task.Description,
 		DueDate:     task.DueDate,
 		Status:      task.Status,
+		Labels:      task.Labels,
+		Assignee:    task.Assignee,
+		Overdue:     task.Overdue,
 	}
 }
 func toDomainTask(gtask *ginTask) *domain.Task {
@@ -40,6 +54,8 @@ func toDomainTask(gtask *ginTask) *domain.Task {
 		Description: gtask.Description,
 		DueDate:     gtask.DueDate,
 		Status:      gtask.Status,
+		Labels:      gtask.Labels,
+		Assignee:    gtask.Assignee,
 	}
 }
 
@@ -52,32 +68,33 @@ func NewAppController(tu usecases.TaskUsecase, uu usecases.UserUsecase) *AppCont
 // CreateTask handles POST api/tasks requests.
 func (ac *AppController) CreateTask(c *gin.Context) {
 
-	var newTask ginTask
-
-	if err := c.ShouldBindJSON(&newTask); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
-		return
-	}
-
-	// Basic validation
-	if newTask.Title == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
+	var newTask ginNewTask
+	if err := bindJSON(c, &newTask); err != nil {
+		handleError(c, err)
 		return
 	}
+	newTask.ginTask.Title = newTask.Title
 
-	createdTask, err := ac.taskUsecase.CreateTask(toDomainTask(&newTask))
+	createdTask, err := ac.taskUsecase.CreateTask(currentUser(c), toDomainTask(&newTask.ginTask))
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		handleError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, createdTask)
+	c.JSON(http.StatusCreated, fromDomainTask(createdTask))
 }
 
-// GetTasks handles GET api/tasks requests.
+// GetTasks handles GET api/tasks requests. An optional q parameter filters
+// the tasks with the query language, e.g. ?q=status:Pending AND label:api.
 func (ac *AppController) GetTasks(c *gin.Context) {
-	tasks, err := ac.taskUsecase.GetTasks()
+	var tasks []*domain.Task
+	var err error
+	if query := c.Query("q"); query != "" {
+		tasks, err = ac.taskUsecase.FilterTasks(currentUser(c), query)
+	} else {
+		tasks, err = ac.taskUsecase.GetTasks(currentUser(c))
+	}
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		handleError(c, err)
 		return
 	}
 
@@ -93,10 +110,9 @@ func (ac *AppController) GetTasks(c *gin.Context) {
 func (ac *AppController) GetTaskByID(c *gin.Context) {
 
 	id := c.Param("id")
-	task, err := ac.taskUsecase.GetTaskByID(id)
+	task, err := ac.taskUsecase.GetTaskByID(currentUser(c), id)
 	if err != nil {
-		// Task not found
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		handleError(c, err)
 		return
 	}
 	c.IndentedJSON(http.StatusOK, fromDomainTask(task))
@@ -107,15 +123,14 @@ func (ac *AppController) UpdateTask(c *gin.Context) {
 
 	id := c.Param("id")
 	var updatedTask ginTask
-	if err := c.ShouldBindJSON(&updatedTask); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+	if err := bindJSON(c, &updatedTask); err != nil {
+		handleError(c, err)
 		return
 	}
 
-	task, err := ac.taskUsecase.UpdateTask(id, *toDomainTask(&updatedTask))
+	task, err := ac.taskUsecase.UpdateTask(currentUser(c), id, *toDomainTask(&updatedTask))
 	if err != nil {
-		// Task not found
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		handleError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, fromDomainTask(task))
@@ -124,7 +139,7 @@ func (ac *AppController) UpdateTask(c *gin.Context) {
 // DeleteTask handles DELETE api/tasks/:id requests.
 func (ac *AppController) DeleteTask(c *gin.Context) {
 	id := c.Param("id")
-	err := ac.taskUsecase.DeleteTask(id)
+	err := ac.taskUsecase.DeleteTask(currentUser(c), id)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -133,29 +148,59 @@ func (ac *AppController) DeleteTask(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+type ginSearchResult struct {
+	Task       *ginTask          `json:"task"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchTasks handles GET api/search?q= requests.
+func (ac *AppController) SearchTasks(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	results, err := ac.taskUsecase.SearchTasks(currentUser(c), c.Query("q"), limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	ginResults := make([]*ginSearchResult, 0, len(results))
+	for _, result := range results {
+		ginResults = append(ginResults, &ginSearchResult{
+			Task:       fromDomainTask(result.Task),
+			Score:      result.Score,
+			Highlights: result.Highlights,
+		})
+	}
+	c.JSON(http.StatusOK, ginResults)
+}
+
 // User Handlers
 
+// ginUser is the registration payload. The password policy is configurable
+// and enforced by the user usecase, as is whether an invitation is needed.
 type ginUser struct {
-	ID       string `json:"id,omitempty"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	Username   string `json:"username" binding:"required,min=3,max=32,username"`
+	Password   string `json:"password" binding:"required"`
+	Invitation string `json:"invitation" binding:"max=100"`
 }
 
-func fromDomainUser(user *domain.User) *ginUser {
-	return &ginUser{
-		ID:       user.ID,
-		Username: user.Username,
-		Password: user.Password,
-		Role:     user.Role,
-	}
+type ginInvitationRequest struct {
+	Email string `json:"email" binding:"required,email,max=254"`
 }
+
+// ginCredentials is the login payload. The password policy is not checked
+// here so that accounts created before it can still log in.
+type ginCredentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// toDomainUser leaves the role to the user usecase.
 func toDomainUser(gUser *ginUser) *domain.User {
 	return &domain.User{
-		ID:       gUser.ID,
 		Username: gUser.Username,
 		Password: gUser.Password,
-		Role:     gUser.Password,
 	}
 }
 
@@ -163,12 +208,12 @@ func toDomainUser(gUser *ginUser) *domain.User {
 func (ac *AppController) Register(c *gin.Context) {
 
 	var user ginUser
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+	if err := bindJSON(c, &user); err != nil {
+		handleError(c, err)
 		return
 	}
 
-	err := ac.userUsecase.Register(toDomainUser(&user))
+	err := ac.userUsecase.Register(toDomainUser(&user), user.Invitation)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -176,27 +221,43 @@ func (ac *AppController) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
 }
 
+// RequestInvitation handles POST /register/invitation requests, which email
+// an invitation to addresses at the allowed domains.
+func (ac *AppController) RequestInvitation(c *gin.Context) {
+	var req ginInvitationRequest
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := ac.userUsecase.RequestInvitation(req.Email); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "An invitation was sent to the email address"})
+}
+
 // Login handles POST /login requests.
 func (ac *AppController) Login(c *gin.Context) {
-	var creds ginUser
-	if err := c.ShouldBindJSON(&creds); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+	var creds ginCredentials
+	if err := bindJSON(c, &creds); err != nil {
+		handleError(c, err)
 		return
 	}
 
-	token, err := ac.userUsecase.Login(creds.Username, creds.Password)
+	result, err := ac.userUsecase.Login(creds.Username, creds.Password, c.ClientIP())
 	if err != nil {
 		handleError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, fromDomainLoginResult(result))
 }
 
 // Promote handles POST /api/promote requests.
 func (ac *AppController) Promote(c *gin.Context) {
 	userID := c.Param("id")
 	log.Println("Attempting to promote user", userID)
-	err := ac.userUsecase.Promote(userID)
+	err := ac.userUsecase.Promote(currentUser(c).WorkspaceID, userID)
 	if err != nil {
 		handleError(c, err)
 		return
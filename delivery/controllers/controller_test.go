@@ -3,11 +3,15 @@ package controllers_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"task-manager/delivery/controllers"
 	"task-manager/domain"
 	"task-manager/errs"
+	"task-manager/infrastructure"
 	"task-manager/usecases/mocks"
 	"testing"
 	"time"
@@ -21,7 +25,15 @@ type ControllerTestSuite struct {
 	suite.Suite
 	mockTaskUsecase *mocks.TaskUsecase
 	mockUserUsecase *mocks.UserUsecase
+	mockSSOUsecase  *mocks.SSOUsecase
+	mockRoleUsecase *mocks.RoleUsecase
+	mockUserAdmin   *mocks.UserAdminUsecase
+	mockWorkspaces  *mocks.WorkspaceUsecase
 	controller      *controllers.AppController
+	ssoController   *controllers.SSOController
+	roleController  *controllers.RoleController
+	userController  *controllers.UserController
+	wsController    *controllers.WorkspaceController
 	router          *gin.Engine
 }
 
@@ -31,8 +43,21 @@ func (s *ControllerTestSuite) SetupTest() {
 	s.mockTaskUsecase = new(mocks.TaskUsecase)
 	s.mockUserUsecase = new(mocks.UserUsecase)
 	s.controller = controllers.NewAppController(s.mockTaskUsecase, s.mockUserUsecase)
+	s.mockSSOUsecase = new(mocks.SSOUsecase)
+	s.ssoController = controllers.NewSSOController(s.mockSSOUsecase)
+	s.mockRoleUsecase = new(mocks.RoleUsecase)
+	s.roleController = controllers.NewRoleController(s.mockRoleUsecase)
+	s.mockUserAdmin = new(mocks.UserAdminUsecase)
+	s.userController = controllers.NewUserController(s.mockUserAdmin)
+	s.mockWorkspaces = new(mocks.WorkspaceUsecase)
+	s.wsController = controllers.NewWorkspaceController(s.mockWorkspaces)
 
 	s.router = gin.Default()
+	s.router.Use(infrastructure.ErrorMiddleware())
+	// tests of authenticated routes may call asUser with someone else
+	s.asUser(&domain.User{ID: "u0", Username: "tester", WorkspaceID: "ws1", Role: domain.RoleAdmin, Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleAdmin},
+	}})
 }
 
 func TestAppController(t *testing.T) {
@@ -54,24 +79,28 @@ func (s *ControllerTestSuite) TestCreateTask_Success() {
 
 	dueDate := time.Now()
 	taskToCreate := &domain.Task{Title: "Test Task", Description: "something", DueDate: dueDate, Status: "Pending"}
-	createdTask := &domain.Task{ID: "123", Title: "Test Task", Description: "something", DueDate: dueDate, Status: "Pending"}
+	createdTask := &domain.Task{ID: "123", WorkspaceID: "ws1", Title: "Test Task", Description: "something", DueDate: dueDate, Status: "Pending", EscalatedAt: dueDate}
 
 	requestBody, _ := json.Marshal(taskToCreate)
 
-	s.mockTaskUsecase.On("CreateTask", mock.AnythingOfType("*domain.Task")).Return(createdTask, nil).Once()
+	s.mockTaskUsecase.On("CreateTask", mock.Anything, mock.AnythingOfType("*domain.Task")).Return(createdTask, nil).Once()
 
 	w := s.performRequest(http.MethodPost, "/tasks", requestBody)
 
 	s.Require().Equal(http.StatusCreated, w.Code)
 
-	var responseTask domain.Task
+	var responseTask map[string]any
 	err := json.Unmarshal(w.Body.Bytes(), &responseTask)
 	s.Require().NoError(err)
-	s.Assert().Equal(createdTask.ID, responseTask.ID)
-	s.Assert().Equal(createdTask.Title, responseTask.Title)
-	s.Assert().Equal(createdTask.Description, responseTask.Description)
-	s.Assert().Equal(createdTask.DueDate.UnixNano(), responseTask.DueDate.UnixNano())
-	s.Assert().Equal(createdTask.Status, responseTask.Status)
+	// the same fields as every other task response, and none of the internal ones
+	s.Assert().Equal(map[string]any{
+		"id":          "123",
+		"title":       "Test Task",
+		"description": "something",
+		"due_date":    dueDate.Format(time.RFC3339Nano),
+		"status":      "Pending",
+		"overdue":     false,
+	}, responseTask)
 	s.mockTaskUsecase.AssertExpectations(s.T())
 }
 
@@ -83,7 +112,7 @@ func (s *ControllerTestSuite) TestCreateTask_BindingError() {
 
 	s.Assert().Equal(http.StatusBadRequest, w.Code)
 
-	s.mockTaskUsecase.AssertNotCalled(s.T(), "CreateTask")
+	s.mockTaskUsecase.AssertNotCalled(s.T(), "CreateTask", mock.Anything, mock.Anything)
 }
 
 func (s *ControllerTestSuite) TestGetTasks_Success() {
@@ -92,7 +121,7 @@ func (s *ControllerTestSuite) TestGetTasks_Success() {
 		{ID: "1", Title: "Task One"},
 		{ID: "2", Title: "Task Two"},
 	}
-	s.mockTaskUsecase.On("GetTasks").Return(mockTasks, nil).Once()
+	s.mockTaskUsecase.On("GetTasks", mock.Anything, mock.Anything).Return(mockTasks, nil).Once()
 
 	w := s.performRequest(http.MethodGet, "/tasks", nil)
 
@@ -110,26 +139,93 @@ func (s *ControllerTestSuite) TestGetTaskByID_NotFound() {
 	taskID := "nonexistent"
 	s.router.GET("/tasks/:id", s.controller.GetTaskByID)
 
-	s.mockTaskUsecase.On("GetTaskByID", taskID).Return(nil, errs.ErrTaskNotFound).Once()
+	s.mockTaskUsecase.On("GetTaskByID", mock.Anything, taskID).Return(nil, errs.ErrTaskNotFound).Once()
 
 	w := s.performRequest(http.MethodGet, "/tasks/"+taskID, nil)
 
 	s.Require().Equal(http.StatusNotFound, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("task_not_found", p.Code)
+	s.Assert().Equal("/problems/task-not-found", p.Type)
+	s.Assert().Equal("/tasks/"+taskID, p.Instance)
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
 
-	var errorResponse map[string]string
-	err := json.Unmarshal(w.Body.Bytes(), &errorResponse)
-	s.Require().NoError(err)
-	s.Assert().Equal(errs.ErrTaskNotFound.Error(), errorResponse["error"])
+func (s *ControllerTestSuite) TestGetTaskByID_InvalidID() {
+	s.router.GET("/tasks/:id", s.controller.GetTaskByID)
+
+	s.mockTaskUsecase.On("GetTaskByID", mock.Anything, "not-an-id").Return(nil, errs.ErrInvalidTaskId).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks/not-an-id", nil)
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_task_id", p.Code)
 	s.mockTaskUsecase.AssertExpectations(s.T())
 }
 
+func (s *ControllerTestSuite) TestGetTaskByID_DatabaseError() {
+	s.router.GET("/tasks/:id", s.controller.GetTaskByID)
+
+	dbErr := fmt.Errorf("%w: connection refused", errs.ErrUnexpected)
+	s.mockTaskUsecase.On("GetTaskByID", mock.Anything, "task123").Return(nil, dbErr).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks/task123", nil)
+
+	s.Require().Equal(http.StatusInternalServerError, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("internal_error", p.Code)
+	s.Assert().Empty(p.Detail)
+	s.Assert().NotContains(w.Body.String(), "connection refused")
+}
+
+func (s *ControllerTestSuite) TestGetTasks_DatabaseError() {
+	s.router.GET("/tasks", s.controller.GetTasks)
+
+	s.mockTaskUsecase.On("GetTasks", mock.Anything, mock.Anything).Return(nil, errors.New("server selection timeout")).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks", nil)
+
+	s.Require().Equal(http.StatusInternalServerError, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("internal_error", p.Code)
+	s.Assert().NotContains(w.Body.String(), "server selection timeout")
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestGetTasks_InvalidQuery() {
+	s.router.GET("/tasks", s.controller.GetTasks)
+
+	s.mockTaskUsecase.On("FilterTasks", mock.Anything, "nope:1").Return(nil, fmt.Errorf("%w: unknown field", errs.ErrInvalidQuery)).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks?q=nope:1", nil)
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_query", p.Code)
+	s.Assert().Contains(p.Detail, "unknown field")
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestHandlerPanic_RendersProblem() {
+	s.router.GET("/tasks/:id", s.controller.GetTaskByID)
+
+	s.mockTaskUsecase.On("GetTaskByID", mock.Anything, "boom").Run(func(mock.Arguments) { panic("boom") }).Return(nil, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks/boom", nil)
+
+	s.Require().Equal(http.StatusInternalServerError, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("internal_error", p.Code)
+}
+
 func (s *ControllerTestSuite) TestUpdateTask_Success() {
 	s.router.PUT("/tasks/:id", s.controller.UpdateTask)
 	taskID := "task123"
 	updatePayload := domain.Task{Title: "Updated Title"}
 	requestBody, _ := json.Marshal(updatePayload)
 
-	s.mockTaskUsecase.On("UpdateTask", taskID, updatePayload).Return(&updatePayload, nil).Once()
+	s.mockTaskUsecase.On("UpdateTask", mock.Anything, taskID, updatePayload).Return(&updatePayload, nil).Once()
 
 	w := s.performRequest(http.MethodPut, "/tasks/"+taskID, requestBody)
 
@@ -143,18 +239,34 @@ func (s *ControllerTestSuite) TestUpdateTask_NotFound() {
 	updatePayload := domain.Task{Title: "Updated Title"}
 	requestBody, _ := json.Marshal(updatePayload)
 
-	s.mockTaskUsecase.On("UpdateTask", taskID, updatePayload).Return(nil, errs.ErrTaskNotFound).Once()
+	s.mockTaskUsecase.On("UpdateTask", mock.Anything, taskID, updatePayload).Return(nil, errs.ErrTaskNotFound).Once()
 
 	w := s.performRequest(http.MethodPut, "/tasks/"+taskID, requestBody)
 
-	s.Assert().Equal(http.StatusNotFound, w.Code)
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("task_not_found", p.Code)
 	s.mockTaskUsecase.AssertExpectations(s.T())
 }
 
+func (s *ControllerTestSuite) TestUpdateTask_InvalidID() {
+	s.router.PUT("/tasks/:id", s.controller.UpdateTask)
+	updatePayload := domain.Task{Title: "Updated Title"}
+	requestBody, _ := json.Marshal(updatePayload)
+
+	s.mockTaskUsecase.On("UpdateTask", mock.Anything, "bad", updatePayload).Return(nil, errs.ErrInvalidTaskId).Once()
+
+	w := s.performRequest(http.MethodPut, "/tasks/bad", requestBody)
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_task_id", p.Code)
+}
+
 func (s *ControllerTestSuite) TestDeleteTask_Success() {
 	taskID := "taskToDelete"
 	s.router.DELETE("/tasks/:id", s.controller.DeleteTask)
-	s.mockTaskUsecase.On("DeleteTask", taskID).Return(nil).Once()
+	s.mockTaskUsecase.On("DeleteTask", mock.Anything, taskID).Return(nil).Once()
 
 	w := s.performRequest(http.MethodDelete, "/tasks/"+taskID, nil)
 
@@ -170,7 +282,7 @@ func (s *ControllerTestSuite) TestLogin_Success() {
 	requestBody, _ := json.Marshal(loginCreds)
 	expectedToken := "a.valid.jwt"
 
-	s.mockUserUsecase.On("Login", "testuser", "password").Return(expectedToken, nil).Once()
+	s.mockUserUsecase.On("Login", "testuser", "password", "").Return(&domain.LoginResult{Token: expectedToken}, nil).Once()
 
 	w := s.performRequest(http.MethodPost, "/login", requestBody)
 
@@ -188,7 +300,7 @@ func (s *ControllerTestSuite) TestLogin_InvalidCredentials() {
 	loginCreds := map[string]string{"username": "testuser", "password": "wrongpassword"}
 	requestBody, _ := json.Marshal(loginCreds)
 
-	s.mockUserUsecase.On("Login", "testuser", "wrongpassword").Return("", errs.ErrIncorrectPassword).Once()
+	s.mockUserUsecase.On("Login", "testuser", "wrongpassword", "").Return(nil, errs.ErrIncorrectPassword).Once()
 
 	w := s.performRequest(http.MethodPost, "/login", requestBody)
 
@@ -201,7 +313,7 @@ func (s *ControllerTestSuite) TestRegister_Success() {
 	userPayload := gin.H{"username": "newuser", "password": "password123"}
 	requestBody, _ := json.Marshal(userPayload)
 
-	s.mockUserUsecase.On("Register", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+	s.mockUserUsecase.On("Register", mock.AnythingOfType("*domain.User"), "").Return(nil).Once()
 
 	w := s.performRequest(http.MethodPost, "/register", requestBody)
 
@@ -209,12 +321,47 @@ func (s *ControllerTestSuite) TestRegister_Success() {
 	s.mockUserUsecase.AssertExpectations(s.T())
 }
 
+func (s *ControllerTestSuite) TestRegister_IgnoresRole() {
+	s.router.POST("/register", s.controller.Register)
+	s.mockUserUsecase.On("Register", mock.MatchedBy(func(u *domain.User) bool {
+		return u.Username == "newuser" && u.Role == ""
+	}), "").Return(nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/register", []byte(`{"username": "newuser", "password": "password123", "role": "admin"}`))
+
+	s.Require().Equal(http.StatusCreated, w.Code)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestRegister_WithInvitation() {
+	s.router.POST("/register", s.controller.Register)
+	s.mockUserUsecase.On("Register", mock.AnythingOfType("*domain.User"), "invite").Return(errs.ErrInvalidInvitation).Once()
+
+	w := s.performRequest(http.MethodPost, "/register", []byte(`{"username": "newuser", "password": "password123", "invitation": "invite"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_invitation", p.Code)
+}
+
+func (s *ControllerTestSuite) TestRequestInvitation() {
+	s.router.POST("/register/invitation", s.controller.RequestInvitation)
+	s.mockUserUsecase.On("RequestInvitation", "alice@example.com").Return(nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/register/invitation", []byte(`{"email": "alice@example.com"}`))
+	s.Assert().Equal(http.StatusAccepted, w.Code)
+
+	w = s.performRequest(http.MethodPost, "/register/invitation", []byte(`{"email": "alice"}`))
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
 func (s *ControllerTestSuite) TestRegister_UsernameExists() {
 	s.router.POST("/register", s.controller.Register)
 	userPayload := gin.H{"username": "existinguser", "password": "password123"}
 	requestBody, _ := json.Marshal(userPayload)
 
-	s.mockUserUsecase.On("Register", mock.AnythingOfType("*domain.User")).Return(errs.ErrUsernameExists).Once()
+	s.mockUserUsecase.On("Register", mock.AnythingOfType("*domain.User"), "").Return(errs.ErrUsernameExists).Once()
 
 	w := s.performRequest(http.MethodPost, "/register", requestBody)
 
@@ -226,7 +373,7 @@ func (s *ControllerTestSuite) TestPromote_Success() {
 	s.router.POST("/promote/:id", s.controller.Promote)
 	userID := "user123"
 
-	s.mockUserUsecase.On("Promote", userID).Return(nil).Once()
+	s.mockUserUsecase.On("Promote", "ws1", userID).Return(nil).Once()
 
 	w := s.performRequest(http.MethodPost, "/promote/"+userID, nil)
 
@@ -243,10 +390,1028 @@ func (s *ControllerTestSuite) TestPromote_UserNotFound() {
 	s.router.POST("/promote/:id", s.controller.Promote)
 	userID := "nonexistent"
 
-	s.mockUserUsecase.On("Promote", userID).Return(errs.ErrUserNotFound).Once()
+	s.mockUserUsecase.On("Promote", "ws1", userID).Return(errs.ErrUserNotFound).Once()
 
 	w := s.performRequest(http.MethodPost, "/promote/"+userID, nil)
 
 	s.Assert().Equal(http.StatusNotFound, w.Code)
 	s.mockUserUsecase.AssertExpectations(s.T())
 }
+
+func (s *ControllerTestSuite) TestSearchTasks_Success() {
+	s.router.GET("/search", s.controller.SearchTasks)
+	results := []*domain.SearchResult{{
+		Task:       &domain.Task{ID: "1", Title: "Deploy backend"},
+		Score:      3,
+		Highlights: map[string]string{"title": "<mark>Deploy</mark> backend"},
+	}}
+	s.mockTaskUsecase.On("SearchTasks", mock.Anything, "deploy", 5).Return(results, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/search?q=deploy&limit=5", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	var response []map[string]any
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	s.Require().Len(response, 1)
+	s.Assert().Equal("<mark>Deploy</mark> backend", response[0]["highlights"].(map[string]any)["title"])
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestSearchTasks_EmptyQuery() {
+	s.router.GET("/search", s.controller.SearchTasks)
+	s.mockTaskUsecase.On("SearchTasks", mock.Anything, "", 0).Return(nil, errs.ErrEmptySearchQuery).Once()
+
+	w := s.performRequest(http.MethodGet, "/search", nil)
+
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestBulkTasks_AtomicFailureIsUnprocessable() {
+	s.router.POST("/tasks/bulk", s.controller.BulkTasks)
+	requestBody := []byte(`{"atomic": true, "operations": [{"op": "create", "task": {"title": "New"}}, {"op": "delete", "id": "gone"}]}`)
+	ops := []domain.BulkOperation{
+		{Op: domain.BulkCreate, Task: domain.Task{Title: "New"}},
+		{Op: domain.BulkDelete, ID: "gone"},
+	}
+	s.mockTaskUsecase.On("BulkTasks", mock.Anything, ops, true).Return([]*domain.BulkResult{
+		{Index: 0, Op: domain.BulkCreate, Status: domain.BulkStatusSkipped},
+		{Index: 1, Op: domain.BulkDelete, ID: "gone", Status: domain.BulkStatusFailed, Err: errs.ErrTaskNotFound},
+	}, errs.ErrBulkAborted).Once()
+
+	w := s.performRequest(http.MethodPost, "/tasks/bulk", requestBody)
+
+	s.Require().Equal(http.StatusUnprocessableEntity, w.Code)
+	var response struct {
+		Failed  int `json:"failed"`
+		Results []struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	s.Assert().Equal(1, response.Failed)
+	s.Assert().Equal(domain.BulkStatusSkipped, response.Results[0].Status)
+	s.Assert().Equal(errs.ErrTaskNotFound.Error(), response.Results[1].Error)
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestBulkTasks_ValidatesEachOperation() {
+	s.router.POST("/tasks/bulk", s.controller.BulkTasks)
+	requestBody, _ := json.Marshal(map[string]any{"operations": []map[string]any{
+		{"op": "create", "task": map[string]any{"title": strings.Repeat("a", 5000)}},
+		{"op": "create", "task": map[string]any{"description": "untitled"}},
+		{"op": "archive", "id": "t1"},
+	}})
+
+	w := s.performRequest(http.MethodPost, "/tasks/bulk", requestBody)
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{
+		"operations[0].task.title": "max",
+		"operations[1].task.title": "required",
+		"operations[2].op":         "oneof",
+	}, fields)
+	s.mockTaskUsecase.AssertNotCalled(s.T(), "BulkTasks", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestBulkTasks_FilterAndPatch() {
+	s.router.POST("/tasks/bulk", s.controller.BulkTasks)
+	requestBody := []byte(`{"filter": "label:sprint-1", "patch": {"status": "Completed"}}`)
+	s.mockTaskUsecase.On("PatchTasks", mock.Anything, "label:sprint-1", domain.Task{Status: domain.StatusCompleted}).Return(int64(2), int64(2), nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/tasks/bulk", requestBody)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"matched": 2, "modified": 2}`, w.Body.String())
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestExportTasks_CSV() {
+	s.router.GET("/tasks/export", s.controller.ExportTasks)
+	tasks := []*domain.Task{
+		{ID: "1", Title: "Ship, release", Status: domain.StatusPending, Labels: []string{"a", "b"}, DueDate: time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)},
+		{ID: "2", Title: "Docs", Status: domain.StatusCompleted},
+	}
+	s.mockTaskUsecase.On("ExportTasks", mock.Anything, "label:a", mock.Anything).Return(tasks, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks/export?format=csv&q=label:a", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Equal("text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	s.Assert().Equal("id,title,description,due_date,status,labels,overdue\n"+
+		"1,\"Ship, release\",,2026-11-01T09:00:00Z,Pending,a;b,false\n"+
+		"2,Docs,,,Completed,,false\n", w.Body.String())
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestExportTasks_InvalidQueryBeforeStreaming() {
+	s.router.GET("/tasks/export", s.controller.ExportTasks)
+	s.mockTaskUsecase.On("ExportTasks", mock.Anything, "label<a", mock.Anything).Return(nil, errs.ErrInvalidQuery).Once()
+
+	w := s.performRequest(http.MethodGet, "/tasks/export?format=ndjson&q=label<a", nil)
+
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestImportTasks_CSVDryRun() {
+	s.router.POST("/tasks/import", s.controller.ImportTasks)
+	body := []byte("Name,Deadline\nShip release,2026-11-03\n")
+	rows := []domain.ImportRow{{Line: 2, Values: map[string]string{"Name": "Ship release", "Deadline": "2026-11-03"}}}
+	opts := domain.ImportOptions{Mapping: map[string]string{"Name": "title", "Deadline": "due_date"}, DryRun: true}
+	report := &domain.ImportReport{Total: 1, Valid: 1, Rows: []*domain.ImportRowResult{{Line: 2, Status: domain.ImportRowValid}}}
+	s.mockTaskUsecase.On("ImportTasks", mock.Anything, rows, opts).Return(report, nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/tasks/import?dry_run=true&mapping=Name:title,Deadline:due_date", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "text/csv")
+	s.router.ServeHTTP(w, req)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"dry_run": true, "total": 1, "created": 0, "valid": 1, "duplicates": 0, "failed": 0, "rows": [{"line": 2, "status": "valid"}]}`, w.Body.String())
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestImportTasks_JSONExportRoundTrip() {
+	s.router.GET("/tasks/export", s.controller.ExportTasks)
+	s.router.POST("/tasks/import", s.controller.ImportTasks)
+	tasks := []*domain.Task{{ID: "1", Title: "Someday", Status: domain.StatusPending}}
+	s.mockTaskUsecase.On("ExportTasks", mock.Anything, "", mock.Anything).Return(tasks, nil).Once()
+	var rows []domain.ImportRow
+	s.mockTaskUsecase.On("ImportTasks", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		rows = args.Get(1).([]domain.ImportRow)
+	}).Return(&domain.ImportReport{}, nil).Once()
+
+	exported := s.performRequest(http.MethodGet, "/tasks/export?format=json", nil)
+	s.Require().Equal(http.StatusOK, exported.Code)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/tasks/import?format=json", exported.Body)
+	s.router.ServeHTTP(w, req)
+
+	// the zero time an undated task exports with reaches the usecase as is,
+	// which reads it as no due date
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().Len(rows, 1)
+	s.Assert().Equal("Someday", rows[0].Values["title"])
+	s.Assert().Equal("0001-01-01T00:00:00Z", rows[0].Values["due_date"])
+}
+
+func (s *ControllerTestSuite) TestCalendarFeed_RendersFoldedTodos() {
+	s.router.GET("/ical/:feed", infrastructure.CalendarFeedAuth(s.mockUserUsecase), s.controller.CalendarFeed)
+	description := strings.Repeat("Ünïcode, semicolons; and\nnew lines ", 4)
+	tasks := []*domain.Task{{
+		ID:          "t1",
+		Title:       "Ship release",
+		Description: description,
+		DueDate:     time.Date(2026, 11, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600)),
+		Status:      domain.StatusInProgress,
+		Labels:      []string{"backend", "a,b"},
+	}}
+	s.mockUserUsecase.On("GetUserByCalendarToken", "secret").Return(&domain.User{ID: "u1", Username: "alice"}, nil).Once()
+	s.mockTaskUsecase.On("CalendarTasks", mock.Anything, "").Return(tasks, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/ical/secret.ics", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Equal("text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		s.Assert().LessOrEqual(len(line), 75, line)
+	}
+	unfolded := strings.ReplaceAll(body, "\r\n ", "")
+	s.Assert().Contains(unfolded, "BEGIN:VTODO\r\nUID:t1@task-manager\r\n")
+	s.Assert().Contains(unfolded, "DUE:20261101T090000Z\r\n")
+	s.Assert().Contains(unfolded, "STATUS:IN-PROCESS\r\n")
+	s.Assert().Contains(unfolded, `DESCRIPTION:Ünïcode\, semicolons\; and\nnew lines Ünïcode`)
+	s.Assert().Contains(unfolded, `CATEGORIES:backend,a\,b`+"\r\n")
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestCalendarFeed_UnknownToken() {
+	s.router.GET("/ical/:feed", infrastructure.CalendarFeedAuth(s.mockUserUsecase), s.controller.CalendarFeed)
+	s.mockUserUsecase.On("GetUserByCalendarToken", "revoked").Return(nil, errs.ErrInvalidCalendarToken).Once()
+
+	w := s.performRequest(http.MethodGet, "/ical/revoked.ics", nil)
+
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+	s.mockTaskUsecase.AssertNotCalled(s.T(), "CalendarTasks", mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestImportCalendar_ParsesComponents() {
+	s.router.POST("/tasks/import/ics", s.controller.ImportCalendar)
+	body := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"X-WR-TIMEZONE:America/New_York",
+		"BEGIN:VTODO",
+		"SUMMARY:Write the quarterly report for the finance team\\, including the ",
+		" appendix",
+		"DUE;TZID=/freeassociation.sourceforge.net/Tzfile/Europe/Berlin:20261101T100000",
+		"STATUS:COMPLETED",
+		"CATEGORIES:finance,q4\\,2026",
+		"BEGIN:VALARM",
+		"DESCRIPTION:Reminder",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"SUMMARY:Offsite",
+		"DESCRIPTION:Line one\\nLine two",
+		"DTSTART;VALUE=DATE:20261205",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Standup",
+		"DTSTART:20261102T090000",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"SUMMARY:Broken",
+		"DUE;TZID=Mars/Olympus:20261101T100000",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	rows := []domain.ImportRow{
+		{Line: 4, Values: map[string]string{
+			"title":    "Write the quarterly report for the finance team, including the appendix",
+			"due_date": "2026-11-01T09:00:00Z",
+			"status":   domain.StatusCompleted,
+			"labels":   "finance;q4,2026",
+		}},
+		{Line: 14, Values: map[string]string{"title": "Offsite", "description": "Line one\nLine two", "due_date": "2026-12-05"}},
+		{Line: 19, Values: map[string]string{"title": "Standup", "due_date": "2026-11-02T14:00:00Z"}},
+		{Line: 23, Values: map[string]string{"title": "Broken", "due_date": ""}, Errors: []string{`DUE: unknown time zone "Mars/Olympus"`}},
+	}
+	report := &domain.ImportReport{Total: 4}
+	s.mockTaskUsecase.On("ImportTasks", mock.Anything, rows, domain.ImportOptions{DryRun: true}).Return(report, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/tasks/import/ics?dry_run=true", []byte(body))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestImportCalendar_NotACalendar() {
+	s.router.POST("/tasks/import/ics", s.controller.ImportCalendar)
+
+	w := s.performRequest(http.MethodPost, "/tasks/import/ics", []byte("title,due_date\n"))
+
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+}
+
+type problemResponse struct {
+	Type     string `json:"type"`
+	Status   int    `json:"status"`
+	Code     string `json:"code"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance"`
+	Errors   []struct {
+		Field string `json:"field"`
+		Code  string `json:"code"`
+	} `json:"errors"`
+}
+
+func (s *ControllerTestSuite) decodeProblem(w *httptest.ResponseRecorder) (problemResponse, map[string]string) {
+	s.Require().Equal("application/problem+json", w.Header().Get("Content-Type"))
+	var p problemResponse
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &p))
+	fields := make(map[string]string)
+	for _, fe := range p.Errors {
+		fields[fe.Field] = fe.Code
+	}
+	return p, fields
+}
+
+func (s *ControllerTestSuite) TestCreateTask_ReportsEveryInvalidField() {
+	s.router.POST("/tasks", s.controller.CreateTask)
+	body := []byte(`{"title": "", "description": "ok", "status": "Done", "due_date": "1990-01-01T00:00:00Z", "labels": ["api", ""]}`)
+
+	w := s.performRequest(http.MethodPost, "/tasks", body)
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, fields := s.decodeProblem(w)
+	s.Assert().Equal(http.StatusBadRequest, p.Status)
+	s.Assert().Equal(map[string]string{
+		"title":     "required",
+		"status":    "oneof",
+		"due_date":  "duedate",
+		"labels[1]": "min",
+	}, fields)
+	s.mockTaskUsecase.AssertNotCalled(s.T(), "CreateTask", mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestCreateTask_WrongTypeNamesField() {
+	s.router.POST("/tasks", s.controller.CreateTask)
+
+	w := s.performRequest(http.MethodPost, "/tasks", []byte(`{"title": 123}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"title": "type"}, fields)
+}
+
+func (s *ControllerTestSuite) TestUpdateTask_TitleIsOptional() {
+	s.router.PUT("/tasks/:id", s.controller.UpdateTask)
+	s.mockTaskUsecase.On("UpdateTask", mock.Anything, "t1", domain.Task{Status: domain.StatusInProgress}).Return(&domain.Task{ID: "t1"}, nil).Once()
+
+	w := s.performRequest(http.MethodPut, "/tasks/t1", []byte(`{"status": "In Progress"}`))
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+	s.mockTaskUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestRegister_ValidatesUsernameAndPassword() {
+	s.router.POST("/register", s.controller.Register)
+
+	w := s.performRequest(http.MethodPost, "/register", []byte(`{"username": "bad name"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"username": "username", "password": "required"}, fields)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "Register", mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestRegister_PasswordPolicy() {
+	s.router.POST("/register", s.controller.Register)
+	policyErr := &errs.ValidationError{Fields: []errs.FieldError{
+		{Field: "password", Code: "breached", Message: "appears in a known data breach, choose another one"},
+	}}
+	s.mockUserUsecase.On("Register", mock.AnythingOfType("*domain.User"), "").Return(policyErr).Once()
+
+	w := s.performRequest(http.MethodPost, "/register", []byte(`{"username": "newuser", "password": "password1"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"password": "breached"}, fields)
+}
+
+func (s *ControllerTestSuite) TestLogin_Locked() {
+	s.router.POST("/login", s.controller.Login)
+	lockErr := &errs.RetryError{Err: errs.ErrAccountLocked, After: 2 * time.Minute}
+	s.mockUserUsecase.On("Login", "testuser", "password", "").Return(nil, lockErr).Once()
+
+	w := s.performRequest(http.MethodPost, "/login", []byte(`{"username": "testuser", "password": "password"}`))
+
+	s.Require().Equal(http.StatusLocked, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("account_locked", p.Code)
+	s.Assert().Equal("120", w.Header().Get("Retry-After"))
+}
+
+func (s *ControllerTestSuite) TestUnlockUser() {
+	s.router.DELETE("/users/:id/lockout", s.controller.UnlockUser)
+	s.mockUserUsecase.On("UnlockUser", "ws1", "u1").Return(nil).Once()
+	s.mockUserUsecase.On("UnlockUser", "ws1", "missing").Return(errs.ErrUserNotFound).Once()
+
+	s.Assert().Equal(http.StatusNoContent, s.performRequest(http.MethodDelete, "/users/u1/lockout", nil).Code)
+	s.Assert().Equal(http.StatusNotFound, s.performRequest(http.MethodDelete, "/users/missing/lockout", nil).Code)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestLogin_RequiresBothFields() {
+	s.router.POST("/login", s.controller.Login)
+
+	w := s.performRequest(http.MethodPost, "/login", []byte(`{"username": "testuser"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"password": "required"}, fields)
+}
+
+// asUser makes the handlers see user as the authenticated user.
+func (s *ControllerTestSuite) asUser(user *domain.User) {
+	s.router.Use(func(c *gin.Context) {
+		c.Set("user", user)
+		c.Next()
+	})
+}
+
+func (s *ControllerTestSuite) TestLogin_MFARequired() {
+	s.router.POST("/login", s.controller.Login)
+	s.mockUserUsecase.On("Login", "testuser", "password", "").Return(&domain.LoginResult{ChallengeToken: "challenge"}, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/login", []byte(`{"username": "testuser", "password": "password"}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"mfa_required": true, "challenge_token": "challenge"}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestLoginMFA() {
+	s.router.POST("/login/mfa", s.controller.LoginMFA)
+	s.mockUserUsecase.On("VerifyMFALogin", "challenge", "123456", "").Return("a.valid.jwt", nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/login/mfa", []byte(`{"challenge_token": "challenge", "code": "123456"}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"token": "a.valid.jwt"}`, w.Body.String())
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestLoginMFA_InvalidCode() {
+	s.router.POST("/login/mfa", s.controller.LoginMFA)
+	s.mockUserUsecase.On("VerifyMFALogin", "challenge", "000000", "").Return("", errs.ErrInvalidMFACode).Once()
+
+	w := s.performRequest(http.MethodPost, "/login/mfa", []byte(`{"challenge_token": "challenge", "code": "000000"}`))
+
+	s.Require().Equal(http.StatusUnauthorized, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_mfa_code", p.Code)
+}
+
+func (s *ControllerTestSuite) TestLoginMFA_MissingCode() {
+	s.router.POST("/login/mfa", s.controller.LoginMFA)
+
+	w := s.performRequest(http.MethodPost, "/login/mfa", []byte(`{"challenge_token": "challenge"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"code": "required"}, fields)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "VerifyMFALogin", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestStartMFAEnrollment() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.POST("/me/mfa", s.controller.StartMFAEnrollment)
+	enrollment := &domain.MFAEnrollment{Secret: "SECRET", ProvisioningURI: "otpauth://totp/x"}
+	s.mockUserUsecase.On("StartMFAEnrollment", "u1").Return(enrollment, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/me/mfa", nil)
+
+	s.Require().Equal(http.StatusCreated, w.Code)
+	s.Assert().JSONEq(`{"secret": "SECRET", "provisioning_uri": "otpauth://totp/x"}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestConfirmMFAEnrollment() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.POST("/me/mfa/confirm", s.controller.ConfirmMFAEnrollment)
+	s.mockUserUsecase.On("ConfirmMFAEnrollment", "u1", "123456").Return([]string{"aaaaa-bbbbb"}, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/me/mfa/confirm", []byte(`{"code": "123456"}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"recovery_codes": ["aaaaa-bbbbb"]}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestDisableMFA_NotEnabled() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.DELETE("/me/mfa", s.controller.DisableMFA)
+	s.mockUserUsecase.On("DisableMFA", "u1", "123456").Return(errs.ErrMFANotEnabled).Once()
+
+	w := s.performRequest(http.MethodDelete, "/me/mfa", []byte(`{"code": "123456"}`))
+
+	s.Require().Equal(http.StatusConflict, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("mfa_not_enabled", p.Code)
+}
+
+func (s *ControllerTestSuite) TestUpdateSecuritySettings() {
+	s.router.PUT("/settings/security", s.controller.UpdateSecuritySettings)
+	s.mockUserUsecase.On("UpdateSecuritySettings", "ws1", &domain.SecuritySettings{RequireAdminMFA: false}).Return(nil).Once()
+
+	w := s.performRequest(http.MethodPut, "/settings/security", []byte(`{"require_admin_mfa": false}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"require_admin_mfa": false}`, w.Body.String())
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestUpdateSecuritySettings_MissingField() {
+	s.router.PUT("/settings/security", s.controller.UpdateSecuritySettings)
+
+	w := s.performRequest(http.MethodPut, "/settings/security", []byte(`{}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"require_admin_mfa": "required"}, fields)
+}
+
+func (s *ControllerTestSuite) TestChangePassword() {
+	user := &domain.User{ID: "u1", WorkspaceID: "ws1"}
+	s.asUser(user)
+	s.router.POST("/me/password", s.controller.ChangePassword)
+	s.mockUserUsecase.On("ChangePassword", user, "oldpassword1", "newpassword2").Return("a.new.jwt", nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/me/password", []byte(`{"current_password": "oldpassword1", "new_password": "newpassword2"}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"token": "a.new.jwt"}`, w.Body.String())
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestChangePassword_MissingCurrentPassword() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.POST("/me/password", s.controller.ChangePassword)
+
+	w := s.performRequest(http.MethodPost, "/me/password", []byte(`{"new_password": "newpassword2"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"current_password": "required"}, fields)
+}
+
+func (s *ControllerTestSuite) TestRequestPasswordReset() {
+	s.router.POST("/password/forgot", s.controller.RequestPasswordReset)
+	s.mockUserUsecase.On("RequestPasswordReset", "testuser").Return(nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/password/forgot", []byte(`{"username": "testuser"}`))
+
+	s.Assert().Equal(http.StatusAccepted, w.Code)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestResetPassword() {
+	s.router.POST("/password/reset", s.controller.ResetPassword)
+	s.mockUserUsecase.On("ResetPassword", "reset-token", "newpassword2").Return(nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/password/reset", []byte(`{"token": "reset-token", "new_password": "newpassword2"}`))
+
+	s.Assert().Equal(http.StatusNoContent, w.Code)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestResetPassword_InvalidToken() {
+	s.router.POST("/password/reset", s.controller.ResetPassword)
+	s.mockUserUsecase.On("ResetPassword", "used-token", "newpassword2").Return(errs.ErrInvalidResetToken).Once()
+
+	w := s.performRequest(http.MethodPost, "/password/reset", []byte(`{"token": "used-token", "new_password": "newpassword2"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_reset_token", p.Code)
+}
+
+func (s *ControllerTestSuite) TestSSOLogin_Redirects() {
+	s.router.GET("/auth/oidc/login", s.ssoController.Login)
+	start := &domain.SSOStart{AuthURL: "https://idp.example/authorize?state=abc", Request: "sealed"}
+	s.mockSSOUsecase.On("Start", "").Return(start, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/auth/oidc/login", nil)
+
+	s.Require().Equal(http.StatusFound, w.Code)
+	s.Assert().Equal(start.AuthURL, w.Header().Get("Location"))
+	cookie := w.Result().Cookies()[0]
+	s.Assert().Equal("sso_request", cookie.Name)
+	s.Assert().Equal("sealed", cookie.Value)
+	s.Assert().Equal("/auth/oidc", cookie.Path)
+	s.Assert().True(cookie.HttpOnly)
+	s.Assert().Equal(http.SameSiteLaxMode, cookie.SameSite)
+}
+
+func (s *ControllerTestSuite) TestSSOLogin_NotConfigured() {
+	s.router.GET("/auth/oidc/login", s.ssoController.Login)
+	s.mockSSOUsecase.On("Start", "").Return(nil, errs.ErrSSONotConfigured).Once()
+
+	w := s.performRequest(http.MethodGet, "/auth/oidc/login", nil)
+
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("sso_not_configured", p.Code)
+}
+
+func (s *ControllerTestSuite) ssoCallback(query string, cookie bool) *httptest.ResponseRecorder {
+	s.router.GET("/auth/oidc/callback", s.ssoController.Callback)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query, nil)
+	if cookie {
+		req.AddCookie(&http.Cookie{Name: "sso_request", Value: "sealed"})
+	}
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *ControllerTestSuite) TestSSOCallback_Login() {
+	s.mockSSOUsecase.On("Callback", "sealed", "abc", "the-code").Return(&domain.SSOResult{Token: "a.valid.jwt"}, nil).Once()
+
+	w := s.ssoCallback("state=abc&code=the-code", true)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"token": "a.valid.jwt"}`, w.Body.String())
+	cookie := w.Result().Cookies()[0]
+	s.Assert().Equal("sso_request", cookie.Name)
+	s.Assert().Negative(cookie.MaxAge, "the request cookie is cleared")
+}
+
+func (s *ControllerTestSuite) TestSSOCallback_Linked() {
+	result := &domain.SSOResult{Linked: true, Identity: domain.ExternalIdentity{Issuer: "https://idp.example", Subject: "42"}}
+	s.mockSSOUsecase.On("Callback", "sealed", "abc", "the-code").Return(result, nil).Once()
+
+	w := s.ssoCallback("state=abc&code=the-code", true)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"issuer": "https://idp.example", "subject": "42"}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestSSOCallback_MissingCookie() {
+	w := s.ssoCallback("state=abc&code=the-code", false)
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("invalid_sso_state", p.Code)
+	s.mockSSOUsecase.AssertNotCalled(s.T(), "Callback", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestSSOCallback_ProviderError() {
+	w := s.ssoCallback("state=abc&error=access_denied", true)
+
+	s.Require().Equal(http.StatusUnauthorized, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("sso_failed", p.Code)
+}
+
+func (s *ControllerTestSuite) TestLinkIdentity() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.POST("/me/identities/oidc", s.ssoController.LinkIdentity)
+	start := &domain.SSOStart{AuthURL: "https://idp.example/authorize", Request: "sealed"}
+	s.mockSSOUsecase.On("Start", "u1").Return(start, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/me/identities/oidc", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"authorization_url": "https://idp.example/authorize"}`, w.Body.String())
+	s.Assert().Equal("sealed", w.Result().Cookies()[0].Value)
+}
+
+func (s *ControllerTestSuite) TestCreateAPIToken() {
+	s.asUser(&domain.User{ID: "u1", WorkspaceID: "ws1"})
+	s.router.POST("/me/tokens", s.controller.CreateAPIToken)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.mockUserUsecase.On("CreateAPIToken", "u1", mock.AnythingOfType("*domain.APIToken")).Run(func(args mock.Arguments) {
+		token := args.Get(1).(*domain.APIToken)
+		s.Assert().Equal("ci", token.Name)
+		s.Assert().Equal("ws1", token.WorkspaceID, "tokens act in the workspace they are created in")
+		s.Assert().Equal([]string{"tasks:write"}, token.Scopes)
+		s.Assert().True(token.ExpiresAt.IsZero())
+		token.ID = "t1"
+		token.CreatedAt = created
+	}).Return("tm_secret", nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/me/tokens", []byte(`{"name": "ci", "scopes": ["tasks:write"]}`))
+
+	s.Require().Equal(http.StatusCreated, w.Code)
+	s.Assert().JSONEq(`{"id": "t1", "name": "ci", "scopes": ["tasks:write"], "workspace_id": "ws1", "created_at": "2025-03-01T12:00:00Z", "token": "tm_secret"}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestCreateAPIToken_Invalid() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.POST("/me/tokens", s.controller.CreateAPIToken)
+
+	w := s.performRequest(http.MethodPost, "/me/tokens", []byte(`{"name": "ci", "scopes": ["tasks:delete"], "expires_at": "2001-01-01T00:00:00Z"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"scopes[0]": "oneof", "expires_at": "future"}, fields)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "CreateAPIToken", mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestListAPITokens() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.GET("/me/tokens", s.controller.ListAPITokens)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.mockUserUsecase.On("ListAPITokens", "u1").Return([]*domain.APIToken{{
+		ID: "t1", Name: "ci", Scopes: []string{"tasks:read"}, WorkspaceID: "ws1", TokenHash: "secret-hash",
+		CreatedAt: created, ExpiresAt: created.AddDate(0, 1, 0), LastUsedAt: created.Add(time.Hour),
+	}}, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/me/tokens", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`[{"id": "t1", "name": "ci", "scopes": ["tasks:read"], "workspace_id": "ws1", "created_at": "2025-03-01T12:00:00Z",
+		"expires_at": "2025-04-01T12:00:00Z", "last_used_at": "2025-03-01T13:00:00Z"}]`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestRevokeAPIToken_NotFound() {
+	s.asUser(&domain.User{ID: "u1"})
+	s.router.DELETE("/me/tokens/:id", s.controller.RevokeAPIToken)
+	s.mockUserUsecase.On("RevokeAPIToken", "u1", "t9").Return(errs.ErrAPITokenNotFound).Once()
+
+	w := s.performRequest(http.MethodDelete, "/me/tokens/t9", nil)
+
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("api_token_not_found", p.Code)
+}
+
+// role handler tests
+
+func (s *ControllerTestSuite) TestCreateRole() {
+	admin := &domain.User{ID: "a1", Role: domain.RoleAdmin}
+	s.asUser(admin)
+	s.router.POST("/roles", s.roleController.CreateRole)
+	s.mockRoleUsecase.On("CreateRole", admin, mock.MatchedBy(func(r *domain.Role) bool {
+		return r.Name == "editor" && len(r.Permissions) == 2
+	})).Return(nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/roles", []byte(`{"name": "editor", "description": "Edits tasks", "permissions": ["task.read", "task.update"]}`))
+
+	s.Require().Equal(http.StatusCreated, w.Code)
+	s.Assert().Contains(w.Body.String(), `"name":"editor"`)
+	s.mockRoleUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestCreateRole_Invalid() {
+	s.asUser(&domain.User{ID: "a1", Role: domain.RoleAdmin})
+	s.router.POST("/roles", s.roleController.CreateRole)
+
+	w := s.performRequest(http.MethodPost, "/roles", []byte(`{"name": "Task Editors", "permissions": ["task.read", "task.fly"]}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal("rolename", fields["name"])
+	s.Assert().Equal("oneof", fields["permissions[1]"])
+	s.mockRoleUsecase.AssertNotCalled(s.T(), "CreateRole", mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestDeleteRole_InUse() {
+	s.router.DELETE("/roles/:name", s.roleController.DeleteRole)
+	s.mockRoleUsecase.On("DeleteRole", mock.Anything, "editor").Return(errs.ErrRoleInUse).Once()
+
+	w := s.performRequest(http.MethodDelete, "/roles/editor", nil)
+
+	s.Require().Equal(http.StatusConflict, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("role_in_use", p.Code)
+}
+
+func (s *ControllerTestSuite) TestAssignRole() {
+	admin := &domain.User{ID: "a1", Role: domain.RoleAdmin}
+	s.asUser(admin)
+	s.router.PUT("/users/:id/role", s.roleController.AssignRole)
+	s.mockRoleUsecase.On("AssignRole", admin, "u1", "editor").Return(nil).Once()
+
+	w := s.performRequest(http.MethodPut, "/users/u1/role", []byte(`{"role": "editor"}`))
+
+	s.Require().Equal(http.StatusNoContent, w.Code)
+	s.mockRoleUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestGetMyPermissions() {
+	user := &domain.User{ID: "u1", Role: domain.RoleUser}
+	s.asUser(user)
+	s.router.GET("/me/permissions", s.roleController.GetMyPermissions)
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/me/permissions", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"role": "user", "permissions": ["task.read"]}`, w.Body.String())
+}
+
+// policy handler tests
+
+func (s *ControllerTestSuite) TestExplainAccess() {
+	bob := &domain.User{ID: "u2", Memberships: []domain.Membership{{WorkspaceID: "ws1", Role: domain.RoleUser}}}
+	s.router.POST("/policies/explain", s.controller.ExplainAccess)
+	s.mockUserUsecase.On("GetUserByID", "u2").Return(bob, nil).Once()
+	s.mockTaskUsecase.On("ExplainAccess", bob, domain.PermTaskUpdate, "t1", domain.Task{Status: "Completed"}).Return(&domain.AccessDecision{
+		Reason:   `the conditions of policy "assignees" do not hold: resource.assignee [] is not in subject.id [u2]`,
+		Policies: []domain.PolicyResult{{ID: "assignees", Effect: domain.EffectAllow, Applies: true, Failed: "resource.assignee [] is not in subject.id [u2]"}},
+	}, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/policies/explain", []byte(`{"user_id": "u2", "action": "task.update", "task_id": "t1", "task": {"status": "Completed"}}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"allowed": false, "reason": "the conditions of policy \"assignees\" do not hold: resource.assignee [] is not in subject.id [u2]",
+		"policies": [{"id": "assignees", "effect": "allow", "applies": true, "matched": false, "failed": "resource.assignee [] is not in subject.id [u2]"}]}`, w.Body.String())
+	s.mockTaskUsecase.AssertExpectations(s.T())
+	s.Assert().Equal("ws1", bob.WorkspaceID, "other users are asked about as members of the current workspace")
+}
+
+func (s *ControllerTestSuite) TestExplainAccess_UserOfAnotherWorkspace() {
+	s.router.POST("/policies/explain", s.controller.ExplainAccess)
+	s.mockUserUsecase.On("GetUserByID", "u2").Return(&domain.User{ID: "u2", Memberships: []domain.Membership{{WorkspaceID: "ws2", Role: domain.RoleAdmin}}}, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/policies/explain", []byte(`{"user_id": "u2", "action": "task.read", "task_id": "t1"}`))
+
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	s.mockTaskUsecase.AssertNotCalled(s.T(), "ExplainAccess", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestExplainAccess_Invalid() {
+	s.router.POST("/policies/explain", s.controller.ExplainAccess)
+
+	w := s.performRequest(http.MethodPost, "/policies/explain", []byte(`{"action": "task.fly"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal("oneof", fields["action"])
+	s.mockTaskUsecase.AssertNotCalled(s.T(), "ExplainAccess", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// user management handler tests
+
+func (s *ControllerTestSuite) TestListUsers() {
+	s.router.GET("/users", s.userController.ListUsers)
+	s.mockUserAdmin.On("ListUsers", mock.Anything, domain.UserQuery{Search: "ali", Page: 2, PerPage: 10}).Return(&domain.UserPage{
+		Users:   []*domain.User{{ID: "u1", Username: "alice", Role: domain.RoleUser, Disabled: true}},
+		Total:   11,
+		Page:    2,
+		PerPage: 10,
+	}, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/users?q=ali&page=2&per_page=10", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"users": [{"id": "u1", "username": "alice", "role": "user", "disabled": true, "mfa_enabled": false}],
+		"total": 11, "page": 2, "per_page": 10}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestCreateInvitation() {
+	s.router.POST("/invitations", s.userController.CreateInvitation)
+	s.mockUserAdmin.On("CreateInvitation", mock.Anything, mock.MatchedBy(func(i *domain.Invitation) bool {
+		return i.Role == "editor" && i.ExpiresAt.IsZero()
+	})).Run(func(args mock.Arguments) {
+		invitation := args.Get(1).(*domain.Invitation)
+		invitation.ID = "i1"
+		invitation.CreatedBy = "u1"
+	}).Return("secret", nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/invitations", []byte(`{"role": "editor"}`))
+
+	s.Require().Equal(http.StatusCreated, w.Code)
+	var body map[string]any
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &body))
+	s.Assert().Equal("i1", body["id"])
+	s.Assert().Equal("secret", body["token"])
+}
+
+func (s *ControllerTestSuite) TestListInvitations_HidesTokens() {
+	s.router.GET("/invitations", s.userController.ListInvitations)
+	s.mockUserAdmin.On("ListInvitations", mock.Anything).Return([]*domain.Invitation{{ID: "i1", Role: "user", TokenHash: "hash"}}, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/invitations", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().NotContains(w.Body.String(), "token")
+	s.Assert().NotContains(w.Body.String(), "hash")
+}
+
+func (s *ControllerTestSuite) TestRevokeInvitation_NotFound() {
+	s.router.DELETE("/invitations/:id", s.userController.RevokeInvitation)
+	s.mockUserAdmin.On("RevokeInvitation", mock.Anything, "i9").Return(errs.ErrInvitationNotFound).Once()
+
+	w := s.performRequest(http.MethodDelete, "/invitations/i9", nil)
+
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *ControllerTestSuite) TestUpdateUser() {
+	s.router.PATCH("/users/:id", s.userController.UpdateUser)
+	s.mockUserAdmin.On("UpdateUser", mock.Anything, "u1", mock.MatchedBy(func(u domain.UserUpdate) bool {
+		return u.Username == nil && u.Disabled != nil && *u.Disabled
+	})).Return(&domain.User{ID: "u1", Username: "alice", Role: domain.RoleUser, Disabled: true}, nil).Once()
+
+	w := s.performRequest(http.MethodPatch, "/users/u1", []byte(`{"disabled": true}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Contains(w.Body.String(), `"disabled":true`)
+	s.mockUserAdmin.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestUpdateUser_InvalidUsername() {
+	s.router.PATCH("/users/:id", s.userController.UpdateUser)
+
+	w := s.performRequest(http.MethodPatch, "/users/u1", []byte(`{"username": "a b"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Contains(fields, "username")
+	s.mockUserAdmin.AssertNotCalled(s.T(), "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestDeleteUser_LastAdmin() {
+	s.router.DELETE("/users/:id", s.userController.DeleteUser)
+	s.mockUserAdmin.On("DeleteUser", mock.Anything, "a1").Return(errs.ErrLastAdmin).Once()
+
+	w := s.performRequest(http.MethodDelete, "/users/a1", nil)
+
+	s.Require().Equal(http.StatusConflict, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("last_admin", p.Code)
+}
+
+func (s *ControllerTestSuite) TestDemote() {
+	s.router.POST("/demote/:id", s.userController.Demote)
+	s.mockUserAdmin.On("Demote", mock.Anything, "a2").Return(nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/demote/a2", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.mockUserAdmin.AssertExpectations(s.T())
+}
+
+// profile handler tests
+
+func (s *ControllerTestSuite) TestGetProfile() {
+	s.asUser(&domain.User{ID: "u1", Username: "alice", Role: domain.RoleUser, PasswordHash: "secret-hash",
+		Profile: domain.Profile{DisplayName: "Alice", Email: "alice@example.com", EmailVerified: true, TimeZone: "Europe/Berlin", Locale: "de-DE"}})
+	s.router.GET("/me", s.controller.GetProfile)
+
+	w := s.performRequest(http.MethodGet, "/me", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"id": "u1", "username": "alice", "role": "user", "display_name": "Alice", "email": "alice@example.com",
+		"email_verified": true, "time_zone": "Europe/Berlin", "locale": "de-DE", "mfa_enabled": false}`, w.Body.String())
+	s.Assert().NotContains(w.Body.String(), "secret-hash")
+}
+
+func (s *ControllerTestSuite) TestUpdateProfile() {
+	s.asUser(&domain.User{ID: "u1", Username: "alice", Role: domain.RoleUser})
+	s.router.PATCH("/me", s.controller.UpdateProfile)
+	s.mockUserUsecase.On("UpdateProfile", "u1", mock.MatchedBy(func(u domain.ProfileUpdate) bool {
+		return u.Locale != nil && *u.Locale == "fr-CA" && u.DisplayName == nil && u.Email == nil && u.TimeZone == nil
+	})).Return(&domain.User{ID: "u1", Username: "alice", Profile: domain.Profile{Locale: "fr-CA"}}, nil).Once()
+
+	w := s.performRequest(http.MethodPatch, "/me", []byte(`{"locale": "fr-CA"}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Contains(w.Body.String(), `"locale":"fr-CA"`)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *ControllerTestSuite) TestUpdateProfile_Invalid() {
+	s.router.PATCH("/me", s.controller.UpdateProfile)
+
+	w := s.performRequest(http.MethodPatch, "/me", []byte(`{"email": "not-an-email", "time_zone": "Mars/Olympus", "locale": "??"}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"email": "email", "time_zone": "timezone", "locale": "bcp47_language_tag"}, fields)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "UpdateProfile", mock.Anything, mock.Anything)
+}
+
+// workspace handler tests
+
+func (s *ControllerTestSuite) TestListWorkspaces() {
+	user := &domain.User{ID: "u1", WorkspaceID: "ws2", Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleAdmin, Disabled: true},
+		{WorkspaceID: "ws2", Role: domain.RoleUser},
+	}}
+	s.asUser(user)
+	s.router.GET("/workspaces", s.wsController.ListWorkspaces)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.mockWorkspaces.On("ListWorkspaces", user).Return([]*domain.Workspace{
+		{ID: "ws2", Name: "Acme", CreatedAt: created},
+		{ID: "ws1", Name: "Home", CreatedAt: created},
+	}, nil).Once()
+
+	w := s.performRequest(http.MethodGet, "/workspaces", nil)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`[
+		{"id": "ws2", "name": "Acme", "role": "user", "disabled": false, "active": true, "created_at": "2025-03-01T12:00:00Z"},
+		{"id": "ws1", "name": "Home", "role": "admin", "disabled": true, "active": false, "created_at": "2025-03-01T12:00:00Z"}
+	]`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestCreateWorkspace() {
+	s.router.POST("/workspaces", s.wsController.CreateWorkspace)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.mockWorkspaces.On("CreateWorkspace", mock.Anything, "Acme").Return(&domain.Workspace{ID: "ws2", Name: "Acme", CreatedAt: created}, nil).Once()
+
+	w := s.performRequest(http.MethodPost, "/workspaces", []byte(`{"name": "Acme"}`))
+
+	s.Require().Equal(http.StatusCreated, w.Code)
+	s.Assert().JSONEq(`{"id": "ws2", "name": "Acme", "role": "admin", "disabled": false, "active": false, "created_at": "2025-03-01T12:00:00Z"}`, w.Body.String())
+}
+
+func (s *ControllerTestSuite) TestJoinWorkspace() {
+	s.router.POST("/workspaces/join", s.wsController.JoinWorkspace)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.mockWorkspaces.On("JoinWorkspace", mock.Anything, "invite").Run(func(args mock.Arguments) {
+		user := args.Get(0).(*domain.User)
+		user.Memberships = append(user.Memberships, domain.Membership{WorkspaceID: "ws2", Role: "editor"})
+	}).Return(&domain.Workspace{ID: "ws2", Name: "Acme", CreatedAt: created}, nil).Once()
+	s.mockWorkspaces.On("JoinWorkspace", mock.Anything, "used").Return(nil, errs.ErrInvalidInvitation).Once()
+
+	w := s.performRequest(http.MethodPost, "/workspaces/join", []byte(`{"invitation": "invite"}`))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"id": "ws2", "name": "Acme", "role": "editor", "disabled": false, "active": false, "created_at": "2025-03-01T12:00:00Z"}`, w.Body.String())
+	s.Assert().Equal(http.StatusBadRequest, s.performRequest(http.MethodPost, "/workspaces/join", []byte(`{"invitation": "used"}`)).Code)
+}
+
+func (s *ControllerTestSuite) TestCreateWorkspace_Invalid() {
+	s.router.POST("/workspaces", s.wsController.CreateWorkspace)
+
+	w := s.performRequest(http.MethodPost, "/workspaces", []byte(`{"name": ""}`))
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	_, fields := s.decodeProblem(w)
+	s.Assert().Equal(map[string]string{"name": "required"}, fields)
+	s.mockWorkspaces.AssertNotCalled(s.T(), "CreateWorkspace", mock.Anything, mock.Anything)
+}
+
+func (s *ControllerTestSuite) TestSwitchWorkspace() {
+	s.router.POST("/workspaces/:id/switch", s.wsController.SwitchWorkspace)
+	s.mockWorkspaces.On("SwitchWorkspace", mock.Anything, "ws2").Return("a.new.jwt", nil).Once()
+	s.mockWorkspaces.On("SwitchWorkspace", mock.Anything, "ws9").Return("", errs.ErrWorkspaceNotFound).Once()
+
+	w := s.performRequest(http.MethodPost, "/workspaces/ws2/switch", nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().JSONEq(`{"token": "a.new.jwt"}`, w.Body.String())
+
+	w = s.performRequest(http.MethodPost, "/workspaces/ws9/switch", nil)
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	p, _ := s.decodeProblem(w)
+	s.Assert().Equal("workspace_not_found", p.Code)
+}
@@ -1,30 +1,11 @@
 package controllers
 
 import (
-	"errors"
-	"log"
-	"net/http"
-	"task-manager/errs"
-
 	"github.com/gin-gonic/gin"
 )
 
+// handleError leaves err for infrastructure.ErrorMiddleware, which renders
+// every error of the API as application/problem+json.
 func handleError(c *gin.Context, err error) {
-	switch {
-	case errors.Is(err, errs.ErrTaskNotFound):
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-	case errors.Is(err, errs.ErrUserNotFound):
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-	case errors.Is(err, errs.ErrInvalidUserId):
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-	case errors.Is(err, errs.ErrInvalidTaskId):
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-	case errors.Is(err, errs.ErrUsernameExists):
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-	case errors.Is(err, errs.ErrIncorrectPassword):
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-	default:
-		log.Printf("An unexpected error occurred: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
-	}
+	_ = c.Error(err)
 }
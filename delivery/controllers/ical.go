@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalTodo  = "VTODO"
+	icalEvent = "VEVENT"
+
+	icalDateTime    = "20060102T150405"
+	icalDateTimeUTC = "20060102T150405Z"
+	icalDate        = "20060102"
+
+	// RFC 5545 limits content lines to 75 octets, excluding the line break.
+	icalLineLimit = 75
+)
+
+// icalWriter writes iCalendar (RFC 5545) content lines. The first write
+// error is kept and later writes are skipped.
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (iw *icalWriter) line(name, value string) {
+	if iw.err != nil {
+		return
+	}
+	_, iw.err = io.WriteString(iw.w, foldLine(name+":"+value))
+}
+
+func (iw *icalWriter) text(name, value string) {
+	iw.line(name, escapeText(value))
+}
+
+// writeCalendar renders tasks as VTODO or VEVENT components. Times are
+// always written in UTC so that no VTIMEZONE definitions are needed.
+func writeCalendar(w io.Writer, name, component string, tasks []*domain.Task, now time.Time) error {
+	iw := &icalWriter{w: w}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//task-manager//Tasks//EN")
+	iw.line("CALSCALE", "GREGORIAN")
+	iw.line("METHOD", "PUBLISH")
+	iw.text("X-WR-CALNAME", name)
+
+	for _, task := range tasks {
+		if task.DueDate.IsZero() {
+			continue
+		}
+		iw.line("BEGIN", component)
+		iw.line("UID", task.ID+"@task-manager")
+		iw.line("DTSTAMP", now.UTC().Format(icalDateTimeUTC))
+		iw.text("SUMMARY", task.Title)
+		if task.Description != "" {
+			iw.text("DESCRIPTION", task.Description)
+		}
+
+		due := task.DueDate.UTC().Format(icalDateTimeUTC)
+		if component == icalTodo {
+			iw.line("DUE", due)
+			iw.line("STATUS", todoStatus(task.Status))
+			if task.Status == domain.StatusCompleted {
+				iw.line("PERCENT-COMPLETE", "100")
+			}
+		} else {
+			// without DTEND the event ends when it starts, and TRANSPARENT
+			// keeps it from showing up as busy time
+			iw.line("DTSTART", due)
+			iw.line("STATUS", eventStatus(task.Status))
+			iw.line("TRANSP", "TRANSPARENT")
+		}
+
+		if len(task.Labels) > 0 {
+			labels := make([]string, 0, len(task.Labels))
+			for _, label := range task.Labels {
+				labels = append(labels, escapeText(label))
+			}
+			iw.line("CATEGORIES", strings.Join(labels, ","))
+		}
+		// VEVENT has no status for work in progress, so the task status is
+		// also kept as is for clients (and imports) that want it
+		iw.text("X-TASK-STATUS", task.Status)
+		iw.line("END", component)
+	}
+
+	iw.line("END", "VCALENDAR")
+	return iw.err
+}
+
+func todoStatus(status string) string {
+	switch status {
+	case domain.StatusInProgress:
+		return "IN-PROCESS"
+	case domain.StatusCompleted:
+		return "COMPLETED"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+func eventStatus(status string) string {
+	if status == domain.StatusPending || status == "" {
+		return "TENTATIVE"
+	}
+	return "CONFIRMED"
+}
+
+// foldLine splits a content line into 75-octet pieces joined by CRLF and a
+// space, without cutting through a multi-byte character.
+func foldLine(line string) string {
+	var b strings.Builder
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space of a continuation line counts towards the limit
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' || s[i] == 'N' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// splitText splits a TEXT list such as CATEGORIES on unescaped commas.
+func splitText(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeText(s[start:]))
+}
+
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+type icalComponent struct {
+	kind  string
+	line  int
+	props map[string][]icalProperty
+}
+
+func (comp *icalComponent) get(name string) (icalProperty, bool) {
+	props := comp.props[name]
+	if len(props) == 0 {
+		return icalProperty{}, false
+	}
+	return props[0], true
+}
+
+// readICSRows turns the VTODO and VEVENT components of an iCalendar file
+// into import rows. Floating times (without a time zone) are read in loc,
+// or else in the calendar's X-WR-TIMEZONE, or else in UTC.
+func readICSRows(body io.Reader, loc *time.Location) ([]domain.ImportRow, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidImport, err)
+	}
+
+	calendar := &icalComponent{props: make(map[string][]icalProperty)}
+	var components []*icalComponent
+	var current *icalComponent
+	var stack []string
+	sawCalendar := false
+
+	for _, line := range unfoldLines(string(data)) {
+		prop, err := parseContentLine(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", errs.ErrInvalidImport, line.number, err)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			kind := strings.ToUpper(prop.value)
+			if len(stack) == 0 && kind != "VCALENDAR" {
+				return nil, fmt.Errorf("%w: line %d: expected BEGIN:VCALENDAR", errs.ErrInvalidImport, line.number)
+			}
+			sawCalendar = true
+			stack = append(stack, kind)
+			if (kind == icalTodo || kind == icalEvent) && len(stack) == 2 {
+				current = &icalComponent{kind: kind, line: line.number, props: make(map[string][]icalProperty)}
+			}
+		case "END":
+			kind := strings.ToUpper(prop.value)
+			if len(stack) == 0 || stack[len(stack)-1] != kind {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", errs.ErrInvalidImport, line.number, prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			if current != nil && len(stack) == 1 {
+				components = append(components, current)
+				current = nil
+			}
+		default:
+			switch {
+			case len(stack) == 1:
+				calendar.props[prop.name] = append(calendar.props[prop.name], prop)
+			case len(stack) == 2 && current != nil:
+				// properties of nested components such as VALARM are ignored
+				current.props[prop.name] = append(current.props[prop.name], prop)
+			}
+		}
+	}
+	if !sawCalendar {
+		return nil, fmt.Errorf("%w: not an iCalendar file", errs.ErrInvalidImport)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", errs.ErrInvalidImport, stack[len(stack)-1])
+	}
+
+	if loc == nil {
+		loc = time.UTC
+		if tz, ok := calendar.get("X-WR-TIMEZONE"); ok {
+			if calendarLoc, err := loadICSLocation(tz.value); err == nil {
+				loc = calendarLoc
+			}
+		}
+	}
+
+	rows := make([]domain.ImportRow, 0, len(components))
+	for _, comp := range components {
+		rows = append(rows, icsRow(comp, loc))
+	}
+	return rows, nil
+}
+
+func icsRow(comp *icalComponent, loc *time.Location) domain.ImportRow {
+	row := domain.ImportRow{Line: comp.line, Values: make(map[string]string)}
+
+	if summary, ok := comp.get("SUMMARY"); ok {
+		row.Values["title"] = unescapeText(summary.value)
+	}
+	if description, ok := comp.get("DESCRIPTION"); ok {
+		row.Values["description"] = unescapeText(description.value)
+	}
+
+	var labels []string
+	for _, categories := range comp.props["CATEGORIES"] {
+		labels = append(labels, splitText(categories.value)...)
+	}
+	if len(labels) > 0 {
+		row.Values["labels"] = strings.Join(labels, ";")
+	}
+
+	due, ok := comp.get("DUE")
+	if !ok || comp.kind == icalEvent {
+		due, ok = comp.get("DTSTART")
+	}
+	if ok {
+		value, err := icsDate(due, loc)
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s: %v", due.name, err))
+		}
+		row.Values["due_date"] = value
+	}
+
+	if status, ok := comp.get("X-TASK-STATUS"); ok {
+		row.Values["status"] = unescapeText(status.value)
+	} else if comp.kind == icalTodo {
+		status, _ := comp.get("STATUS")
+		percent, _ := comp.get("PERCENT-COMPLETE")
+		switch {
+		case strings.EqualFold(status.value, "COMPLETED") || percent.value == "100":
+			row.Values["status"] = domain.StatusCompleted
+		case strings.EqualFold(status.value, "IN-PROCESS"):
+			row.Values["status"] = domain.StatusInProgress
+		case strings.EqualFold(status.value, "NEEDS-ACTION"):
+			row.Values["status"] = domain.StatusPending
+		}
+	}
+	return row
+}
+
+// icsDate converts a DATE or DATE-TIME value into the formats the importer
+// accepts: YYYY-MM-DD for whole days and RFC 3339 in UTC otherwise.
+func icsDate(prop icalProperty, loc *time.Location) (string, error) {
+	value := strings.TrimSpace(prop.value)
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len(icalDate) {
+		day, err := time.Parse(icalDate, value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a date", value)
+		}
+		return day.Format(time.DateOnly), nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalDateTimeUTC, value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a date-time", value)
+		}
+		return t.Format(time.RFC3339), nil
+	}
+
+	if tzid := prop.params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = loadICSLocation(tzid); err != nil {
+			return "", fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	t, err := time.ParseInLocation(icalDateTime, value, loc)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a date-time", value)
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+// loadICSLocation resolves a TZID. Besides plain IANA names it accepts the
+// prefixed forms some clients write, such as
+// "/freeassociation.sourceforge.net/Tzfile/Europe/Berlin".
+func loadICSLocation(tzid string) (*time.Location, error) {
+	parts := strings.Split(strings.Trim(tzid, "/"), "/")
+	for i := range parts {
+		if loc, err := time.LoadLocation(strings.Join(parts[i:], "/")); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown time zone %q", tzid)
+}
+
+type icalLine struct {
+	number int
+	text   string
+}
+
+// unfoldLines joins folded continuation lines (those starting with a space
+// or tab) to the line before them, keeping the number of the first line.
+func unfoldLines(data string) []icalLine {
+	data = strings.TrimPrefix(data, "\uFEFF")
+	var lines []icalLine
+	for i, raw := range strings.Split(data, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		if len(lines) > 0 && (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")) {
+			lines[len(lines)-1].text += raw[1:]
+			continue
+		}
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		lines = append(lines, icalLine{number: i + 1, text: raw})
+	}
+	return lines
+}
+
+// parseContentLine parses NAME;PARAM=value;PARAM="quoted":value.
+func parseContentLine(line string) (icalProperty, error) {
+	prop := icalProperty{params: make(map[string]string)}
+
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return prop, fmt.Errorf("expected NAME:value")
+	}
+	prop.name = strings.ToUpper(line[:end])
+	rest := line[end:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return prop, fmt.Errorf("invalid parameter in %s", prop.name)
+		}
+		param := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				return prop, fmt.Errorf("unterminated quoted parameter in %s", prop.name)
+			}
+			value, rest = rest[1:closing+1], rest[closing+2:]
+		} else {
+			stop := strings.IndexAny(rest, ";:")
+			if stop < 0 {
+				return prop, fmt.Errorf("missing value in %s", prop.name)
+			}
+			value, rest = rest[:stop], rest[stop:]
+		}
+		prop.params[param] = value
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return prop, fmt.Errorf("missing value in %s", prop.name)
+	}
+	prop.value = rest[1:]
+	return prop, nil
+}
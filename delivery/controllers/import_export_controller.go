@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxImportSize = 10 << 20
+
+var exportColumns = []string{"id", "title", "description", "due_date", "status", "labels", "overdue"}
+
+// taskWriter writes tasks in one export format. begin is only called once
+// the export is known to succeed up to the first task, so that errors before
+// that can still be reported with a proper status code.
+type taskWriter interface {
+	begin() error
+	write(task *ginTask) error
+	end() error
+}
+
+// ExportTasks handles GET api/tasks/export?format=csv|json|ndjson requests.
+func (ac *AppController) ExportTasks(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+
+	var writer taskWriter
+	switch format {
+	case "csv":
+		writer = &csvTaskWriter{w: csv.NewWriter(c.Writer)}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	case "json":
+		writer = &jsonTaskWriter{w: c.Writer}
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	case "ndjson":
+		writer = &ndjsonTaskWriter{w: c.Writer}
+		c.Header("Content-Type", "application/x-ndjson")
+	default:
+		handleError(c, queryParamError("format", "csv json ndjson"))
+		return
+	}
+
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tasks.%s"`, format))
+		c.Status(http.StatusOK)
+		return writer.begin()
+	}
+
+	rows := 0
+	err := ac.taskUsecase.ExportTasks(currentUser(c), c.Query("q"), func(task *domain.Task) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := writer.write(fromDomainTask(task)); err != nil {
+			return err
+		}
+		if rows++; rows%100 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		if err = start(); err == nil {
+			err = writer.end()
+		}
+	}
+
+	if err != nil {
+		if !started {
+			handleError(c, err)
+			return
+		}
+		// the status line is already sent, all we can do is cut the
+		// response short so the client sees an incomplete file
+		log.Printf("ERROR: task export failed after %d rows: %v", rows, err)
+		c.Abort()
+		return
+	}
+	c.Writer.Flush()
+}
+
+type csvTaskWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvTaskWriter) begin() error {
+	return cw.w.Write(exportColumns)
+}
+
+func (cw *csvTaskWriter) write(task *ginTask) error {
+	dueDate := ""
+	if !task.DueDate.IsZero() {
+		dueDate = task.DueDate.Format(time.RFC3339)
+	}
+	return cw.w.Write([]string{
+		task.ID,
+		task.Title,
+		task.Description,
+		dueDate,
+		task.Status,
+		strings.Join(task.Labels, ";"),
+		strconv.FormatBool(task.Overdue),
+	})
+}
+
+func (cw *csvTaskWriter) end() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonTaskWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (jw *jsonTaskWriter) begin() error {
+	_, err := io.WriteString(jw.w, "[")
+	return err
+}
+
+func (jw *jsonTaskWriter) write(task *ginTask) error {
+	if jw.count > 0 {
+		if _, err := io.WriteString(jw.w, ","); err != nil {
+			return err
+		}
+	}
+	jw.count++
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(jw.w, "\n%s", data)
+	return err
+}
+
+func (jw *jsonTaskWriter) end() error {
+	_, err := io.WriteString(jw.w, "\n]\n")
+	return err
+}
+
+type ndjsonTaskWriter struct {
+	w io.Writer
+}
+
+func (nw *ndjsonTaskWriter) begin() error { return nil }
+
+func (nw *ndjsonTaskWriter) write(task *ginTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(nw.w, "%s\n", data)
+	return err
+}
+
+func (nw *ndjsonTaskWriter) end() error { return nil }
+
+type ginImportRow struct {
+	Line   int      `json:"line"`
+	Status string   `json:"status"`
+	TaskID string   `json:"task_id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ginImportReport struct {
+	DryRun     bool            `json:"dry_run"`
+	Total      int             `json:"total"`
+	Created    int             `json:"created"`
+	Valid      int             `json:"valid"`
+	Duplicates int             `json:"duplicates"`
+	Failed     int             `json:"failed"`
+	Rows       []*ginImportRow `json:"rows"`
+}
+
+// ImportTasks handles POST api/tasks/import requests.
+func (ac *AppController) ImportTasks(c *gin.Context) {
+	body, filename, ok := importBody(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	mapping, err := parseColumnMapping(c.Query("mapping"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	format := importFormat(c.Query("format"), filename, c.ContentType())
+	rows, err := readImportRows(body, format)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	opts := domain.ImportOptions{Mapping: mapping, DryRun: c.Query("dry_run") == "true"}
+	report, err := ac.taskUsecase.ImportTasks(currentUser(c), rows, opts)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainImportReport(report, opts.DryRun))
+}
+
+// importBody returns the uploaded file, which is either the raw request body
+// or the "file" field of a multipart form, and its name if it has one. It
+// writes the error response itself when there is no file to read.
+func importBody(c *gin.Context) (io.ReadCloser, string, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return c.Request.Body, "", true
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		handleError(c, fmt.Errorf("%w: %v", errs.ErrInvalidImport, err))
+		return nil, "", false
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		handleError(c, fmt.Errorf("%w: %v", errs.ErrInvalidImport, err))
+		return nil, "", false
+	}
+	return file, fileHeader.Filename, true
+}
+
+func fromDomainImportReport(report *domain.ImportReport, dryRun bool) *ginImportReport {
+	gReport := &ginImportReport{
+		DryRun:     dryRun,
+		Total:      report.Total,
+		Created:    report.Created,
+		Valid:      report.Valid,
+		Duplicates: report.Duplicates,
+		Failed:     report.Failed,
+		Rows:       make([]*ginImportRow, 0, len(report.Rows)),
+	}
+	for _, row := range report.Rows {
+		gReport.Rows = append(gReport.Rows, &ginImportRow{Line: row.Line, Status: row.Status, TaskID: row.TaskID, Errors: row.Errors})
+	}
+	return gReport
+}
+
+// importFormat picks the format from the query, then the file extension,
+// then the content type, defaulting to CSV.
+func importFormat(query, filename, contentType string) string {
+	if query != "" {
+		return query
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".csv":
+		return "csv"
+	}
+	switch contentType {
+	case "application/json":
+		return "json"
+	case "application/x-ndjson":
+		return "ndjson"
+	}
+	return "csv"
+}
+
+// parseColumnMapping parses "Name:title,Deadline:due_date".
+func parseColumnMapping(raw string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if raw == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		column, field, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("%w: mapping %q must look like column:field", errs.ErrInvalidImport, pair)
+		}
+		mapping[strings.TrimSpace(column)] = strings.TrimSpace(field)
+	}
+	return mapping, nil
+}
+
+func readImportRows(body io.Reader, format string) ([]domain.ImportRow, error) {
+	switch format {
+	case "csv":
+		return readCSVRows(body)
+	case "json":
+		return readJSONRows(body)
+	case "ndjson":
+		return readNDJSONRows(body)
+	}
+	return nil, fmt.Errorf("%w: format must be one of csv, json or ndjson", errs.ErrInvalidImport)
+}
+
+func readCSVRows(body io.Reader) ([]domain.ImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidImport, err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+
+	var rows []domain.ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrInvalidImport, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				values[column] = record[i]
+			}
+		}
+		rows = append(rows, domain.ImportRow{Line: line, Values: values})
+	}
+}
+
+func readJSONRows(body io.Reader) ([]domain.ImportRow, error) {
+	var records []map[string]any
+	if err := json.NewDecoder(body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("%w: expected a JSON array of objects: %v", errs.ErrInvalidImport, err)
+	}
+
+	rows := make([]domain.ImportRow, 0, len(records))
+	for i, record := range records {
+		rows = append(rows, domain.ImportRow{Line: i + 1, Values: stringValues(record)})
+	}
+	return rows, nil
+}
+
+func readNDJSONRows(body io.Reader) ([]domain.ImportRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportSize)
+
+	var rows []domain.ImportRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%w: line %d is not a JSON object: %v", errs.ErrInvalidImport, line, err)
+		}
+		rows = append(rows, domain.ImportRow{Line: line, Values: stringValues(record)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidImport, err)
+	}
+	return rows, nil
+}
+
+// stringValues flattens a decoded JSON object into strings, joining arrays
+// (such as labels) with semicolons like the CSV export does.
+func stringValues(record map[string]any) map[string]string {
+	values := make(map[string]string, len(record))
+	for key, value := range record {
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case string:
+			values[key] = v
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(parts, ";")
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values
+}
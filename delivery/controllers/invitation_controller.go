@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ginNewInvitation struct {
+	Role string `json:"role" binding:"max=50"`
+	// ExpiresAt is omitted for the default of a week.
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty,future"`
+}
+
+// ginInvitation describes an invitation. Token is only set in the response
+// that creates it.
+type ginInvitation struct {
+	ID        string    `json:"id"`
+	Role      string    `json:"role"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token,omitempty"`
+}
+
+func fromDomainInvitation(invitation *domain.Invitation) *ginInvitation {
+	return &ginInvitation{
+		ID:        invitation.ID,
+		Role:      invitation.Role,
+		CreatedBy: invitation.CreatedBy,
+		CreatedAt: invitation.CreatedAt,
+		ExpiresAt: invitation.ExpiresAt,
+	}
+}
+
+// CreateInvitation handles POST api/invitations requests.
+func (uc *UserController) CreateInvitation(c *gin.Context) {
+	var req ginNewInvitation
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	invitation := &domain.Invitation{Role: req.Role}
+	if req.ExpiresAt != nil {
+		invitation.ExpiresAt = *req.ExpiresAt
+	}
+	token, err := uc.userAdminUsecase.CreateInvitation(currentUser(c), invitation)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := fromDomainInvitation(invitation)
+	out.Token = token
+	c.JSON(http.StatusCreated, out)
+}
+
+// ListInvitations handles GET api/invitations requests.
+func (uc *UserController) ListInvitations(c *gin.Context) {
+	invitations, err := uc.userAdminUsecase.ListInvitations(currentUser(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := make([]*ginInvitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		out = append(out, fromDomainInvitation(invitation))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeInvitation handles DELETE api/invitations/{id} requests.
+func (uc *UserController) RevokeInvitation(c *gin.Context) {
+	if err := uc.userAdminUsecase.RevokeInvitation(currentUser(c), c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
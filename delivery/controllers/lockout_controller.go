@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockUser handles DELETE api/users/:id/lockout requests. It lifts the
+// lockout of a member of the workspace and clears the account's failed
+// logins.
+func (ac *AppController) UnlockUser(c *gin.Context) {
+	if err := ac.userUsecase.UnlockUser(currentUser(c).WorkspaceID, c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
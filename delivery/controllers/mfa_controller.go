@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginLoginResult holds either the token or, for users with MFA, the
+// challenge to send to /login/mfa together with a code.
+type ginLoginResult struct {
+	Token          string `json:"token,omitempty"`
+	MFARequired    bool   `json:"mfa_required,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+func fromDomainLoginResult(result *domain.LoginResult) *ginLoginResult {
+	return &ginLoginResult{
+		Token:          result.Token,
+		MFARequired:    result.ChallengeToken != "",
+		ChallengeToken: result.ChallengeToken,
+	}
+}
+
+type ginMFALogin struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required,max=32"`
+}
+
+// ginMFACode is a TOTP code or a recovery code.
+type ginMFACode struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type ginMFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type ginRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ginSecuritySettings struct {
+	RequireAdminMFA *bool `json:"require_admin_mfa" binding:"required"`
+}
+
+func fromDomainSecuritySettings(settings *domain.SecuritySettings) *ginSecuritySettings {
+	return &ginSecuritySettings{RequireAdminMFA: &settings.RequireAdminMFA}
+}
+
+func toDomainSecuritySettings(settings *ginSecuritySettings) *domain.SecuritySettings {
+	return &domain.SecuritySettings{RequireAdminMFA: *settings.RequireAdminMFA}
+}
+
+// LoginMFA handles POST /login/mfa requests, the second step of a login
+// for users with MFA.
+func (ac *AppController) LoginMFA(c *gin.Context) {
+	var req ginMFALogin
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	token, err := ac.userUsecase.VerifyMFALogin(req.ChallengeToken, req.Code, c.ClientIP())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &ginLoginResult{Token: token})
+}
+
+// StartMFAEnrollment handles POST api/me/mfa requests. MFA is only turned
+// on once a code from the authenticator is confirmed.
+func (ac *AppController) StartMFAEnrollment(c *gin.Context) {
+	enrollment, err := ac.userUsecase.StartMFAEnrollment(currentUser(c).ID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, &ginMFAEnrollment{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+// ConfirmMFAEnrollment handles POST api/me/mfa/confirm requests.
+func (ac *AppController) ConfirmMFAEnrollment(c *gin.Context) {
+	var req ginMFACode
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	codes, err := ac.userUsecase.ConfirmMFAEnrollment(currentUser(c).ID, req.Code)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &ginRecoveryCodes{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes handles POST api/me/mfa/recovery-codes requests.
+func (ac *AppController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req ginMFACode
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	codes, err := ac.userUsecase.RegenerateRecoveryCodes(currentUser(c).ID, req.Code)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &ginRecoveryCodes{RecoveryCodes: codes})
+}
+
+// DisableMFA handles DELETE api/me/mfa requests.
+func (ac *AppController) DisableMFA(c *gin.Context) {
+	var req ginMFACode
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := ac.userUsecase.DisableMFA(currentUser(c).ID, req.Code); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetSecuritySettings handles GET api/settings/security requests.
+func (ac *AppController) GetSecuritySettings(c *gin.Context) {
+	settings, err := ac.userUsecase.GetSecuritySettings(currentUser(c).WorkspaceID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainSecuritySettings(settings))
+}
+
+// UpdateSecuritySettings handles PUT api/settings/security requests.
+func (ac *AppController) UpdateSecuritySettings(c *gin.Context) {
+	var req ginSecuritySettings
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	settings := toDomainSecuritySettings(&req)
+	if err := ac.userUsecase.UpdateSecuritySettings(currentUser(c).WorkspaceID, settings); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainSecuritySettings(settings))
+}
@@ -0,0 +1,994 @@
+package controllers
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"task-manager/domain"
+	"task-manager/infrastructure"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi_docs.html
+var docsPage []byte
+
+// schema is a literal JSON Schema. Values inside it that are Go structs,
+// slices or pointers (such as ginTask{}) are replaced by their reflected
+// schema when the document is built.
+type schema map[string]any
+
+type apiParam struct {
+	name        string
+	typ         string
+	description string
+}
+
+type apiResponse struct {
+	status      int
+	description string
+	content     map[string]any // content type to schema
+}
+
+// apiOperation documents one route. Paths use gin syntax so that they can be
+// compared with the router's routes; path parameters are derived from them.
+type apiOperation struct {
+	id      string
+	method  string
+	path    string
+	tag     string
+	summary string
+	access  string // empty for public routes, otherwise domain.RoleUser
+	// permissions are what the user's role needs, if anything
+	permissions []string
+	scope       string // what API tokens need, empty if they are not accepted
+	query       []apiParam
+	body        map[string]any
+	idempotent  bool // whether retries can send an Idempotency-Key
+	responses   []apiResponse
+	errors      []int
+}
+
+func jsonContent(v any) map[string]any {
+	return map[string]any{"application/json": v}
+}
+
+func respond(status int, description string, v any) apiResponse {
+	if v == nil {
+		return apiResponse{status: status, description: description}
+	}
+	return apiResponse{status: status, description: description, content: jsonContent(v)}
+}
+
+var (
+	messageSchema = schema{"type": "object", "properties": map[string]any{"message": schema{"type": "string"}}}
+	fileSchema    = schema{"type": "string", "contentMediaType": "application/octet-stream"}
+	uploadContent = map[string]any{
+		"text/csv":             fileSchema,
+		"application/json":     fileSchema,
+		"application/x-ndjson": fileSchema,
+		"multipart/form-data": schema{
+			"type":       "object",
+			"properties": map[string]any{"file": fileSchema},
+			"required":   []string{"file"},
+		},
+	}
+	filterParam = apiParam{"q", "string", "Filter expression, e.g. `status:Pending AND label:api`."}
+	dryRunParam = apiParam{"dry_run", "boolean", "Validate the file without creating any tasks."}
+)
+
+// apiOperations describes every route registered by router.SetupRouter.
+var apiOperations = []apiOperation{
+	{
+		id: "register", method: http.MethodPost, path: "/register", tag: "Auth",
+		summary:   "Register a new user, if the registration mode allows it. With an invitation, the user joins the workspace it is for; otherwise they get a workspace of their own.",
+		body:      jsonContent(ginUser{}),
+		responses: []apiResponse{respond(http.StatusCreated, "User registered", messageSchema)},
+		errors:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusTooManyRequests},
+	},
+	{
+		id: "requestInvitation", method: http.MethodPost, path: "/register/invitation", tag: "Auth",
+		summary:   "Email an invitation to register to an address at one of the allowed domains. Only in the domain-allowlist registration mode.",
+		body:      jsonContent(ginInvitationRequest{}),
+		responses: []apiResponse{respond(http.StatusAccepted, "Invitation sent", messageSchema)},
+		errors:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusTooManyRequests},
+	},
+	{
+		id: "login", method: http.MethodPost, path: "/login", tag: "Auth",
+		summary:   "Log in with the username or a verified email address and receive a JWT for the Authorization header.",
+		body:      jsonContent(ginCredentials{}),
+		responses: []apiResponse{respond(http.StatusOK, "Logged in, or a challenge when `mfa_required` is set", ginLoginResult{})},
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusLocked, http.StatusTooManyRequests},
+	},
+	{
+		id: "loginMFA", method: http.MethodPost, path: "/login/mfa", tag: "Auth",
+		summary:   "Finish a login with the challenge from /login and a TOTP or recovery code.",
+		body:      jsonContent(ginMFALogin{}),
+		responses: []apiResponse{respond(http.StatusOK, "Logged in", ginLoginResult{})},
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusLocked, http.StatusTooManyRequests},
+	},
+	{
+		id: "requestPasswordReset", method: http.MethodPost, path: "/password/forgot", tag: "Auth",
+		summary:   "Send a password reset token to the user. The response does not reveal whether the user exists.",
+		body:      jsonContent(ginPasswordResetRequest{}),
+		responses: []apiResponse{respond(http.StatusAccepted, "Reset requested", messageSchema)},
+		errors:    []int{http.StatusBadRequest, http.StatusTooManyRequests},
+	},
+	{
+		id: "resetPassword", method: http.MethodPost, path: "/password/reset", tag: "Auth",
+		summary:   "Choose a new password with a reset token. Signs out every session.",
+		body:      jsonContent(ginPasswordReset{}),
+		responses: []apiResponse{respond(http.StatusNoContent, "Password reset", nil)},
+		errors:    []int{http.StatusBadRequest, http.StatusTooManyRequests},
+	},
+	{
+		id: "changePassword", method: http.MethodPost, path: "/api/me/password", tag: "Auth",
+		summary:   "Change the password. Signs out every other session and returns a new token.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginPasswordChange{}),
+		responses: []apiResponse{respond(http.StatusOK, "Password changed", ginLoginResult{})},
+		errors:    []int{http.StatusBadRequest},
+	},
+	{
+		id: "ssoLogin", method: http.MethodGet, path: "/auth/oidc/login", tag: "Auth",
+		summary:   "Log in with the OpenID Connect provider. Open this in a browser; it redirects to the provider and back to /auth/oidc/callback.",
+		responses: []apiResponse{respond(http.StatusFound, "Redirect to the identity provider", nil)},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		id: "ssoCallback", method: http.MethodGet, path: "/auth/oidc/callback", tag: "Auth",
+		summary: "Where the identity provider redirects back to. Users seen for the first time are created.",
+		query: []apiParam{
+			{"code", "string", "Authorization code from the provider."},
+			{"state", "string", "Must match the login that was started in this browser."},
+		},
+		responses: []apiResponse{respond(http.StatusOK, "Logged in, or the identity that was linked", schema{
+			"oneOf": []any{ginLoginResult{}, ginIdentity{}},
+		})},
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "linkIdentity", method: http.MethodPost, path: "/api/me/identities/oidc", tag: "Auth",
+		summary:   "Link an identity at the OpenID Connect provider to the current user. Open `authorization_url` in the same browser.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "Where to log in at the provider", ginSSOStart{})},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		id: "getProfile", method: http.MethodGet, path: "/api/me", tag: "Auth",
+		summary:   "Get the current user's profile.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "Profile", ginProfile{})},
+	},
+	{
+		id: "updateProfile", method: http.MethodPatch, path: "/api/me", tag: "Auth",
+		summary:   "Change the current user's display name, email, time zone or locale. Omitted fields are left alone and empty ones cleared.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginProfileUpdate{}),
+		responses: []apiResponse{respond(http.StatusOK, "Profile updated", ginProfile{})},
+		errors:    []int{http.StatusBadRequest},
+	},
+	{
+		id: "getMyPermissions", method: http.MethodGet, path: "/api/me/permissions", tag: "Auth",
+		summary:   "Get the current user's role and permissions, e.g. to hide what they cannot do.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "Role and permissions", ginPermissions{})},
+	},
+	{
+		id: "listAPITokens", method: http.MethodGet, path: "/api/me/tokens", tag: "Auth",
+		summary:   "List the current user's API tokens, without the tokens themselves.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "API tokens", []*ginAPIToken{})},
+	},
+	{
+		id: "createAPIToken", method: http.MethodPost, path: "/api/me/tokens", tag: "Auth",
+		summary:   "Create an API token for scripts. The token is only shown in this response.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginNewAPIToken{}),
+		responses: []apiResponse{respond(http.StatusCreated, "API token created", ginAPIToken{})},
+		errors:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict},
+	},
+	{
+		id: "revokeAPIToken", method: http.MethodDelete, path: "/api/me/tokens/:id", tag: "Auth",
+		summary:   "Revoke one of the current user's API tokens.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusNoContent, "API token revoked", nil)},
+		errors:    []int{http.StatusNotFound},
+	},
+	{
+		id: "listWorkspaces", method: http.MethodGet, path: "/api/workspaces", tag: "Workspaces",
+		summary:   "List the workspaces the current user is a member of.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "Workspaces", []*ginWorkspace{})},
+	},
+	{
+		id: "createWorkspace", method: http.MethodPost, path: "/api/workspaces", tag: "Workspaces",
+		summary:    "Create a workspace with the current user as its admin. Switch to it to work there.",
+		access:     domain.RoleUser,
+		body:       jsonContent(ginWorkspaceCreate{}),
+		idempotent: true,
+		responses:  []apiResponse{respond(http.StatusCreated, "Workspace created", ginWorkspace{})},
+		errors:     []int{http.StatusBadRequest, http.StatusConflict},
+	},
+	{
+		id: "joinWorkspace", method: http.MethodPost, path: "/api/workspaces/join", tag: "Workspaces",
+		summary:   "Accept an invitation to a workspace. Switch to it to work there.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginWorkspaceJoin{}),
+		responses: []apiResponse{respond(http.StatusOK, "Workspace joined", ginWorkspace{})},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "switchWorkspace", method: http.MethodPost, path: "/api/workspaces/:id/switch", tag: "Workspaces",
+		summary:   "Get a token that acts in another of the current user's workspaces.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "Token for the workspace", ginLoginResult{})},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		id: "getJWKS", method: http.MethodGet, path: "/.well-known/jwks.json", tag: "Auth",
+		summary: "The public keys tokens are signed with, as a JSON Web Key Set. Keys are published a day before they sign and kept until the tokens they signed expire.",
+		responses: []apiResponse{respond(http.StatusOK, "JSON Web Key Set", schema{
+			"type": "object",
+			"properties": map[string]any{
+				"keys": schema{"type": "array", "items": schema{"type": "object"}},
+			},
+		})},
+	},
+	{
+		id: "startMFAEnrollment", method: http.MethodPost, path: "/api/me/mfa", tag: "Auth",
+		summary:   "Start enrolling an authenticator app. Show `provisioning_uri` as a QR code.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusCreated, "TOTP secret", ginMFAEnrollment{})},
+		errors:    []int{http.StatusConflict},
+	},
+	{
+		id: "confirmMFAEnrollment", method: http.MethodPost, path: "/api/me/mfa/confirm", tag: "Auth",
+		summary:   "Turn MFA on with a code from the authenticator. The recovery codes are only shown once.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginMFACode{}),
+		responses: []apiResponse{respond(http.StatusOK, "MFA enabled", ginRecoveryCodes{})},
+		errors:    []int{http.StatusBadRequest, http.StatusConflict},
+	},
+	{
+		id: "regenerateRecoveryCodes", method: http.MethodPost, path: "/api/me/mfa/recovery-codes", tag: "Auth",
+		summary:   "Replace all recovery codes.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginMFACode{}),
+		responses: []apiResponse{respond(http.StatusOK, "New recovery codes", ginRecoveryCodes{})},
+		errors:    []int{http.StatusBadRequest, http.StatusConflict},
+	},
+	{
+		id: "disableMFA", method: http.MethodDelete, path: "/api/me/mfa", tag: "Auth",
+		summary:   "Turn MFA off with a TOTP or recovery code.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginMFACode{}),
+		responses: []apiResponse{respond(http.StatusNoContent, "MFA disabled", nil)},
+		errors:    []int{http.StatusBadRequest, http.StatusConflict},
+	},
+	{
+		id: "getSecuritySettings", method: http.MethodGet, path: "/api/settings/security", tag: "Auth",
+		summary:     "Get the security settings.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermSettingsManage},
+		responses:   []apiResponse{respond(http.StatusOK, "Security settings", ginSecuritySettings{})},
+	},
+	{
+		id: "updateSecuritySettings", method: http.MethodPut, path: "/api/settings/security", tag: "Auth",
+		summary:     "Change the security settings, e.g. require MFA for admins.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermSettingsManage},
+		body:        jsonContent(ginSecuritySettings{}),
+		responses:   []apiResponse{respond(http.StatusOK, "Security settings", ginSecuritySettings{})},
+		errors:      []int{http.StatusBadRequest},
+	},
+	{
+		id: "promoteUser", method: http.MethodPost, path: "/api/promote/:id", tag: "Auth",
+		summary:     "Promote a user to admin.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: domain.Permissions,
+		responses:   []apiResponse{respond(http.StatusOK, "User promoted", messageSchema)},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "demoteUser", method: http.MethodPost, path: "/api/demote/:id", tag: "Auth",
+		summary:     "Give a user the user role. You need every permission the user has, and the last admin cannot be demoted.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserPromote},
+		responses:   []apiResponse{respond(http.StatusOK, "User demoted", messageSchema)},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "listUsers", method: http.MethodGet, path: "/api/users", tag: "Users",
+		summary:     "List the members of the current workspace by username, a page at a time.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		query: []apiParam{
+			{"q", "string", "Only users whose username contains this, ignoring case."},
+			{"role", "string", "Only users with this role."},
+			{"page", "integer", "Page number, from 1."},
+			{"per_page", "integer", "Users per page (default 20, at most 100)."},
+		},
+		responses: []apiResponse{respond(http.StatusOK, "A page of users", ginUserPage{})},
+	},
+	{
+		id: "getUser", method: http.MethodGet, path: "/api/users/:id", tag: "Users",
+		summary:     "Get a member of the current workspace.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		responses:   []apiResponse{respond(http.StatusOK, "User", ginUserInfo{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "updateUser", method: http.MethodPatch, path: "/api/users/:id", tag: "Users",
+		summary:     "Rename, disable or enable a member of the current workspace. Disabled members cannot use the workspace. Users who belong to other workspaces too cannot be renamed. You need every permission the user has, and the last admin cannot be disabled.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		body:        jsonContent(ginUserUpdate{}),
+		responses:   []apiResponse{respond(http.StatusOK, "User updated", ginUserInfo{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "deleteUser", method: http.MethodDelete, path: "/api/users/:id", tag: "Users",
+		summary:     "Remove a member from the current workspace, deleting users who belong to no other workspace. You need every permission the user has, and the last admin cannot be removed.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		responses:   []apiResponse{respond(http.StatusNoContent, "User deleted", nil)},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "listInvitations", method: http.MethodGet, path: "/api/invitations", tag: "Users",
+		summary:     "List the unused, unexpired invitations to the current workspace.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		responses:   []apiResponse{respond(http.StatusOK, "Invitations", []*ginInvitation{})},
+	},
+	{
+		id: "createInvitation", method: http.MethodPost, path: "/api/invitations", tag: "Users",
+		summary:     "Invite someone to register as a member of the current workspace, with the user role unless another is given. You need every permission of the role. The token is only shown in this response.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		body:        jsonContent(ginNewInvitation{}),
+		responses:   []apiResponse{respond(http.StatusCreated, "Invitation created", ginInvitation{})},
+		errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		id: "revokeInvitation", method: http.MethodDelete, path: "/api/invitations/:id", tag: "Users",
+		summary:     "Revoke an invitation to the current workspace.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserManage},
+		responses:   []apiResponse{respond(http.StatusNoContent, "Invitation revoked", nil)},
+		errors:      []int{http.StatusNotFound},
+	},
+	{
+		id: "unlockUser", method: http.MethodDelete, path: "/api/users/:id/lockout", tag: "Auth",
+		summary:     "Lift the lockout of a member's account and clear its failed logins.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeAdmin,
+		permissions: []string{domain.PermUserUnlock},
+		responses:   []apiResponse{respond(http.StatusNoContent, "Account unlocked", nil)},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "assignRole", method: http.MethodPut, path: "/api/users/:id/role", tag: "Auth",
+		summary:     "Give a user a role. You need every permission of both the user's current role and the new one.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermUserPromote},
+		scope:       domain.ScopeAdmin,
+		body:        jsonContent(ginRoleAssignment{}),
+		responses:   []apiResponse{respond(http.StatusNoContent, "Role assigned", nil)},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "listRoles", method: http.MethodGet, path: "/api/roles", tag: "Auth",
+		summary:     "List the roles and their permissions.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermRoleManage},
+		scope:       domain.ScopeAdmin,
+		responses:   []apiResponse{respond(http.StatusOK, "Roles", []*ginRole{})},
+	},
+	{
+		id: "createRole", method: http.MethodPost, path: "/api/roles", tag: "Auth",
+		summary:     "Create a role. It can only have permissions you have.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermRoleManage},
+		scope:       domain.ScopeAdmin,
+		body:        jsonContent(ginNewRole{}),
+		idempotent:  true,
+		responses:   []apiResponse{respond(http.StatusCreated, "Role created", ginRole{})},
+		errors:      []int{http.StatusBadRequest, http.StatusConflict},
+	},
+	{
+		id: "updateRole", method: http.MethodPut, path: "/api/roles/:name", tag: "Auth",
+		summary:     "Change the description and permissions of a role. You can only add or remove permissions you have. The admin role cannot be changed.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermRoleManage},
+		scope:       domain.ScopeAdmin,
+		body:        jsonContent(ginRoleChange{}),
+		responses:   []apiResponse{respond(http.StatusOK, "Role updated", ginRole{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "deleteRole", method: http.MethodDelete, path: "/api/roles/:name", tag: "Auth",
+		summary:     "Delete a role nobody has. Built-in roles cannot be deleted.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermRoleManage},
+		scope:       domain.ScopeAdmin,
+		responses:   []apiResponse{respond(http.StatusNoContent, "Role deleted", nil)},
+		errors:      []int{http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		id: "explainAccess", method: http.MethodPost, path: "/api/policies/explain", tag: "Auth",
+		summary:     "Tell whether the access policies let a user read, create, update or delete a task, and why.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermRoleManage},
+		scope:       domain.ScopeAdmin,
+		body:        jsonContent(ginAccessQuery{}),
+		responses:   []apiResponse{respond(http.StatusOK, "Access decision", ginAccessDecision{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "listTasks", method: http.MethodGet, path: "/api/tasks", tag: "Tasks",
+		summary:     "List tasks, optionally filtered.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksRead,
+		permissions: []string{domain.PermTaskRead},
+		query:       []apiParam{filterParam},
+		responses:   []apiResponse{respond(http.StatusOK, "Tasks", []*ginTask{})},
+		errors:      []int{http.StatusBadRequest},
+	},
+	{
+		id: "createTask", method: http.MethodPost, path: "/api/tasks", tag: "Tasks",
+		summary:     "Create a task.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksWrite,
+		permissions: []string{domain.PermTaskCreate},
+		body:        jsonContent(ginNewTask{}),
+		idempotent:  true,
+		responses:   []apiResponse{respond(http.StatusCreated, "Task created", ginTask{})},
+		errors:      []int{http.StatusBadRequest},
+	},
+	{
+		id: "getTask", method: http.MethodGet, path: "/api/tasks/:id", tag: "Tasks",
+		summary:     "Get a task.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksRead,
+		permissions: []string{domain.PermTaskRead},
+		responses:   []apiResponse{respond(http.StatusOK, "Task", ginTask{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "updateTask", method: http.MethodPut, path: "/api/tasks/:id", tag: "Tasks",
+		summary:     "Update a task.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksWrite,
+		permissions: []string{domain.PermTaskUpdate},
+		body:        jsonContent(ginTask{}),
+		responses:   []apiResponse{respond(http.StatusOK, "Task updated", ginTask{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "deleteTask", method: http.MethodDelete, path: "/api/tasks/:id", tag: "Tasks",
+		summary:     "Delete a task.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksWrite,
+		permissions: []string{domain.PermTaskDelete},
+		responses:   []apiResponse{respond(http.StatusNoContent, "Task deleted", nil)},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "bulkTasks", method: http.MethodPost, path: "/api/tasks/bulk", tag: "Tasks",
+		summary:     "Create, update and delete many tasks, or patch every task matching a filter.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksWrite,
+		permissions: []string{domain.PermTaskCreate, domain.PermTaskUpdate, domain.PermTaskDelete},
+		body:        jsonContent(ginBulkRequest{}),
+		idempotent:  true,
+		responses: []apiResponse{
+			respond(http.StatusOK, "Per-operation results, or the matched and modified counts for a filter patch", schema{
+				"oneOf": []any{bulkResponseSchema, schema{
+					"type": "object",
+					"properties": map[string]any{
+						"matched":  schema{"type": "integer"},
+						"modified": schema{"type": "integer"},
+					},
+				}},
+			}),
+			respond(http.StatusUnprocessableEntity, "All-or-nothing request aborted; nothing was applied", bulkResponseSchema),
+		},
+		errors: []int{http.StatusBadRequest, http.StatusNotImplemented},
+	},
+	{
+		id: "searchTasks", method: http.MethodGet, path: "/api/search", tag: "Search",
+		summary:     "Full-text search over task titles and descriptions.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksRead,
+		permissions: []string{domain.PermTaskRead},
+		query: []apiParam{
+			{"q", "string", "Search terms, \"quoted phrases\" and prefix* terms."},
+			{"limit", "integer", "Maximum number of results (default 20, at most 100)."},
+		},
+		responses: []apiResponse{respond(http.StatusOK, "Ranked results", []*ginSearchResult{})},
+		errors:    []int{http.StatusBadRequest},
+	},
+	{
+		id: "exportTasks", method: http.MethodGet, path: "/api/tasks/export", tag: "Import and export",
+		summary:     "Stream tasks as CSV, JSON or NDJSON.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksRead,
+		permissions: []string{domain.PermTaskRead},
+		query:       []apiParam{{"format", "string", "`csv`, `json` (default) or `ndjson`."}, filterParam},
+		responses: []apiResponse{{
+			status:      http.StatusOK,
+			description: "Task export",
+			content: map[string]any{
+				"text/csv":             fileSchema,
+				"application/json":     []*ginTask{},
+				"application/x-ndjson": fileSchema,
+			},
+		}},
+		errors: []int{http.StatusBadRequest},
+	},
+	{
+		id: "importTasks", method: http.MethodPost, path: "/api/tasks/import", tag: "Import and export",
+		summary:     "Import tasks from CSV, JSON or NDJSON.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksWrite,
+		permissions: []string{domain.PermTaskImport},
+		query: []apiParam{
+			{"format", "string", "`csv`, `json` or `ndjson`; guessed from the file otherwise."},
+			{"mapping", "string", "Column mapping, e.g. `Name:title,Deadline:due_date`."},
+			dryRunParam,
+		},
+		body:       uploadContent,
+		idempotent: true,
+		responses:  []apiResponse{respond(http.StatusOK, "Per-row import report", ginImportReport{})},
+		errors:     []int{http.StatusBadRequest},
+	},
+	{
+		id: "importCalendar", method: http.MethodPost, path: "/api/tasks/import/ics", tag: "Calendar",
+		summary:     "Import the VTODO and VEVENT components of an iCalendar file.",
+		access:      domain.RoleUser,
+		scope:       domain.ScopeTasksWrite,
+		permissions: []string{domain.PermTaskImport},
+		query: []apiParam{
+			{"tz", "string", "IANA time zone for times without one."},
+			dryRunParam,
+		},
+		body: map[string]any{
+			"text/calendar":       fileSchema,
+			"multipart/form-data": uploadContent["multipart/form-data"],
+		},
+		idempotent: true,
+		responses:  []apiResponse{respond(http.StatusOK, "Per-component import report", ginImportReport{})},
+		errors:     []int{http.StatusBadRequest},
+	},
+	{
+		id: "createCalendarToken", method: http.MethodPost, path: "/api/me/calendar", tag: "Calendar",
+		summary:     "Create (or replace) the current user's calendar feed URL.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermTaskRead},
+		responses: []apiResponse{respond(http.StatusCreated, "Feed URL; the token is only shown once", schema{
+			"type": "object",
+			"properties": map[string]any{
+				"token": schema{"type": "string"},
+				"url":   schema{"type": "string", "format": "uri"},
+			},
+		})},
+	},
+	{
+		id: "revokeCalendarToken", method: http.MethodDelete, path: "/api/me/calendar", tag: "Calendar",
+		summary:   "Revoke the current user's calendar feed URL.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusNoContent, "Feed revoked", nil)},
+	},
+	{
+		id: "calendarFeed", method: http.MethodGet, path: "/ical/:feed", tag: "Calendar",
+		summary: "iCalendar feed of tasks with a due date. The path is the feed token followed by `.ics`; the feed's user needs the `task.read` permission.",
+		query: []apiParam{
+			{"as", "string", "`todo` (default) for VTODO or `event` for VEVENT components."},
+			filterParam,
+		},
+		responses: []apiResponse{{
+			status:      http.StatusOK,
+			description: "iCalendar feed",
+			content:     map[string]any{"text/calendar": schema{"type": "string"}},
+		}},
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		id: "listViews", method: http.MethodGet, path: "/api/views", tag: "Views",
+		summary:   "List views owned by or shared with the current user.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "Views", []*ginView{})},
+	},
+	{
+		id: "createView", method: http.MethodPost, path: "/api/views", tag: "Views",
+		idempotent: true,
+		summary:    "Save a filter query as a view.",
+		access:     domain.RoleUser,
+		body:       jsonContent(ginView{}),
+		responses:  []apiResponse{respond(http.StatusCreated, "View created", ginView{})},
+		errors:     []int{http.StatusBadRequest},
+	},
+	{
+		id: "getView", method: http.MethodGet, path: "/api/views/:id", tag: "Views",
+		summary:   "Get a view.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusOK, "View", ginView{})},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "updateView", method: http.MethodPut, path: "/api/views/:id", tag: "Views",
+		summary:   "Update a view. Owner only.",
+		access:    domain.RoleUser,
+		body:      jsonContent(ginView{}),
+		responses: []apiResponse{respond(http.StatusOK, "View updated", ginView{})},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "deleteView", method: http.MethodDelete, path: "/api/views/:id", tag: "Views",
+		summary:   "Delete a view. Owner only.",
+		access:    domain.RoleUser,
+		responses: []apiResponse{respond(http.StatusNoContent, "View deleted", nil)},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "getViewTasks", method: http.MethodGet, path: "/api/views/:id/tasks", tag: "Views",
+		summary:     "Run a view's query.",
+		access:      domain.RoleUser,
+		permissions: []string{domain.PermTaskRead},
+		responses:   []apiResponse{respond(http.StatusOK, "Matching tasks", []*ginTask{})},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		id: "getOpenAPI", method: http.MethodGet, path: "/openapi.json", tag: "Documentation",
+		summary:   "This OpenAPI document.",
+		responses: []apiResponse{respond(http.StatusOK, "OpenAPI 3.1 document", schema{"type": "object"})},
+	},
+	{
+		id: "getDocs", method: http.MethodGet, path: "/docs", tag: "Documentation",
+		summary: "Interactive API documentation.",
+		responses: []apiResponse{{
+			status:      http.StatusOK,
+			description: "HTML page",
+			content:     map[string]any{"text/html": schema{"type": "string"}},
+		}},
+	},
+}
+
+var bulkResponseSchema = schema{
+	"type": "object",
+	"properties": map[string]any{
+		"results":   []*ginBulkResult{},
+		"succeeded": schema{"type": "integer"},
+		"failed":    schema{"type": "integer"},
+		"error":     schema{"type": "string"},
+	},
+}
+
+// OpenAPISpec returns the OpenAPI 3.1 document for the API.
+var OpenAPISpec = sync.OnceValue(func() map[string]any {
+	return buildOpenAPISpec(apiOperations)
+})
+
+// ServeOpenAPI handles GET /openapi.json requests.
+func ServeOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPISpec())
+}
+
+// ServeDocs handles GET /docs requests with a page that renders /openapi.json.
+func ServeDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+var (
+	pathParam = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+	// oneOfValue matches the values of a oneof rule, which are quoted when
+	// they contain spaces
+	oneOfValue = regexp.MustCompile(`'[^']*'|\S+`)
+)
+
+func buildOpenAPISpec(operations []apiOperation) map[string]any {
+	b := &specBuilder{schemas: make(map[string]any)}
+	b.reflect(reflect.TypeOf(infrastructure.Problem{}))
+
+	paths := make(map[string]map[string]any)
+	for _, op := range operations {
+		path := pathParam.ReplaceAllString(op.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(op.method)] = b.operation(op)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Task Manager API",
+			"version":     "1.0.0",
+			"description": "Task management with role-based access. Send the token from /login, or an API token from /api/me/tokens, as `Authorization: Bearer <token>`.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT or API token"},
+			},
+		},
+	}
+}
+
+type specBuilder struct {
+	schemas map[string]any
+}
+
+func (b *specBuilder) operation(op apiOperation) map[string]any {
+	var params []any
+	for _, match := range pathParam.FindAllStringSubmatch(op.path, -1) {
+		params = append(params, map[string]any{
+			"name": match[1], "in": "path", "required": true, "schema": schema{"type": "string"},
+		})
+	}
+	for _, param := range op.query {
+		params = append(params, map[string]any{
+			"name": param.name, "in": "query", "description": param.description, "schema": schema{"type": param.typ},
+		})
+	}
+
+	responses := make(map[string]any)
+	for _, response := range op.responses {
+		responses[statusKey(response.status)] = b.response(response)
+	}
+	errorCodes := slices.Clone(op.errors)
+	if op.idempotent {
+		params = append(params, map[string]any{
+			"name": "Idempotency-Key", "in": "header", "schema": schema{"type": "string", "maxLength": 255},
+			"description": "Makes retries replay the first response rather than repeat the request.",
+		})
+		errorCodes = append(errorCodes, http.StatusConflict, http.StatusUnprocessableEntity)
+	}
+	description := ""
+	if op.access != "" {
+		// every authenticated route is rate limited
+		errorCodes = append(errorCodes, http.StatusUnauthorized, http.StatusTooManyRequests)
+		description = "Requires an authenticated user."
+		if len(op.permissions) > 0 {
+			description = "Requires the " + permissionList(op.permissions) + "."
+			errorCodes = append(errorCodes, http.StatusForbidden)
+		}
+		if op.scope != "" {
+			description += fmt.Sprintf(" API tokens need the `%s` scope.", op.scope)
+			errorCodes = append(errorCodes, http.StatusForbidden)
+		} else {
+			description += " API tokens are not accepted."
+		}
+	}
+	errorCodes = append(errorCodes, http.StatusInternalServerError)
+	for _, code := range errorCodes {
+		responses[statusKey(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content": map[string]any{
+				"application/problem+json": schema{"$ref": "#/components/schemas/Problem"},
+			},
+		}
+	}
+
+	operation := map[string]any{
+		"operationId": op.id,
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+		"responses":   responses,
+	}
+	if description != "" {
+		operation["description"] = description
+		operation["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	if op.body != nil {
+		operation["requestBody"] = map[string]any{"required": true, "content": b.content(op.body)}
+	}
+	return operation
+}
+
+// permissionList reads "`task.read` permission" or "`task.create` and
+// `task.delete` permissions".
+func permissionList(permissions []string) string {
+	quoted := make([]string, len(permissions))
+	for i, p := range permissions {
+		quoted[i] = "`" + p + "`"
+	}
+	if len(quoted) == 1 {
+		return quoted[0] + " permission"
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1] + " permissions"
+}
+
+func (b *specBuilder) response(response apiResponse) map[string]any {
+	out := map[string]any{"description": response.description}
+	if response.content != nil {
+		out["content"] = b.content(response.content)
+	}
+	return out
+}
+
+func (b *specBuilder) content(content map[string]any) map[string]any {
+	out := make(map[string]any, len(content))
+	for contentType, v := range content {
+		out[contentType] = map[string]any{"schema": b.resolve(v)}
+	}
+	return out
+}
+
+// resolve copies a literal schema, replacing Go values with their reflected
+// schemas.
+func (b *specBuilder) resolve(v any) any {
+	switch v := v.(type) {
+	case schema:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = b.resolve(value)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = b.resolve(value)
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, value := range v {
+			out = append(out, b.resolve(value))
+		}
+		return out
+	case string, bool, int, float64, []string:
+		return v
+	}
+	return b.reflect(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// reflect derives a schema from a Go type. Named structs (ginTask becomes
+// Task) are added to the components once and referenced from then on.
+func (b *specBuilder) reflect(t reflect.Type) map[string]any {
+	if t == timeType {
+		return schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.reflect(t.Elem())
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "gin")
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := b.schemas[name]; !ok {
+			b.schemas[name] = nil // guards against recursive types
+			b.schemas[name] = b.structSchema(t)
+		}
+		return schema{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": b.reflect(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": b.reflect(t.Elem())}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	}
+	return schema{}
+}
+
+func (b *specBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	b.addFields(t, properties, &required)
+
+	out := schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+// addFields adds the JSON fields of t, flattening embedded structs the way
+// encoding/json does: fields of the outer struct win.
+func (b *specBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.reflect(field.Type)
+		rules := strings.Split(field.Tag.Get("binding"), ",")
+		for _, rule := range rules {
+			if rule == "dive" {
+				break
+			}
+			if rule == "required" && !slices.Contains(*required, name) {
+				*required = append(*required, name)
+			}
+			addConstraint(property, rule)
+		}
+		properties[name] = property
+	}
+
+	for _, inner := range embedded {
+		innerProperties := make(map[string]any)
+		b.addFields(inner, innerProperties, required)
+		for name, property := range innerProperties {
+			if _, ok := properties[name]; !ok {
+				properties[name] = property
+			}
+		}
+	}
+}
+
+// addConstraint translates a validation rule from a binding tag into JSON
+// Schema keywords, where there is an equivalent.
+func addConstraint(property map[string]any, rule string) {
+	key, param, _ := strings.Cut(rule, "=")
+	n, err := strconv.Atoi(param)
+	isArray := property["type"] == "array"
+
+	switch {
+	case key == "max" && err == nil && isArray:
+		property["maxItems"] = n
+	case key == "max" && err == nil:
+		property["maxLength"] = n
+	case key == "min" && err == nil && isArray:
+		property["minItems"] = n
+	case key == "min" && err == nil:
+		property["minLength"] = n
+	case key == "oneof":
+		var values []string
+		for _, value := range oneOfValue.FindAllString(param, -1) {
+			values = append(values, strings.Trim(value, "'"))
+		}
+		property["enum"] = values
+	case key == "username":
+		property["pattern"] = usernamePattern.String()
+	case key == "email":
+		property["format"] = "email"
+	}
+}
+
+func statusKey(status int) string {
+	return strconv.Itoa(status)
+}
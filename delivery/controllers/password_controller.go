@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ginPasswordChange struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ginPasswordResetRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type ginPasswordReset struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword handles POST api/me/password requests. Every other session
+// of the user is signed out, so the response carries a fresh token.
+func (ac *AppController) ChangePassword(c *gin.Context) {
+	var req ginPasswordChange
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	token, err := ac.userUsecase.ChangePassword(currentUser(c), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &ginLoginResult{Token: token})
+}
+
+// RequestPasswordReset handles POST /password/forgot requests. It answers
+// the same whether or not the username exists.
+func (ac *AppController) RequestPasswordReset(c *gin.Context) {
+	var req ginPasswordResetRequest
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := ac.userUsecase.RequestPasswordReset(req.Username); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset token has been sent"})
+}
+
+// ResetPassword handles POST /password/reset requests.
+func (ac *AppController) ResetPassword(c *gin.Context) {
+	var req ginPasswordReset
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := ac.userUsecase.ResetPassword(req.Token, req.NewPassword); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginAccessQuery asks whether a user may do action with a task. Task holds
+// the fields a create or update would set.
+type ginAccessQuery struct {
+	// UserID defaults to the current user. Other users must be members of
+	// the current workspace.
+	UserID string  `json:"user_id"`
+	Action string  `json:"action" binding:"required,oneof=task.read task.create task.update task.delete"`
+	TaskID string  `json:"task_id" binding:"required_unless=Action task.create"`
+	Task   ginTask `json:"task"`
+}
+
+type ginPolicyResult struct {
+	ID      string `json:"id"`
+	Effect  string `json:"effect"`
+	Applies bool   `json:"applies"`
+	Matched bool   `json:"matched"`
+	Failed  string `json:"failed,omitempty"`
+}
+
+type ginAccessDecision struct {
+	Allowed  bool              `json:"allowed"`
+	Reason   string            `json:"reason"`
+	Policies []ginPolicyResult `json:"policies"`
+}
+
+// ExplainAccess handles POST api/policies/explain requests.
+func (ac *AppController) ExplainAccess(c *gin.Context) {
+	var req ginAccessQuery
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	if req.UserID != "" && req.UserID != user.ID {
+		var err error
+		workspaceID := user.WorkspaceID
+		if user, err = ac.userUsecase.GetUserByID(req.UserID); err != nil {
+			handleError(c, err)
+			return
+		}
+		if !user.Activate(workspaceID) {
+			handleError(c, errs.ErrUserNotFound)
+			return
+		}
+	}
+	decision, err := ac.taskUsecase.ExplainAccess(user, req.Action, req.TaskID, *toDomainTask(&req.Task))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	out := ginAccessDecision{Allowed: decision.Allowed, Reason: decision.Reason, Policies: make([]ginPolicyResult, 0, len(decision.Policies))}
+	for _, result := range decision.Policies {
+		out.Policies = append(out.Policies, ginPolicyResult(result))
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginProfile is the current user as they see themselves.
+type ginProfile struct {
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	Role          string `json:"role"`
+	DisplayName   string `json:"display_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	TimeZone      string `json:"time_zone"`
+	Locale        string `json:"locale"`
+	MFAEnabled    bool   `json:"mfa_enabled"`
+}
+
+// ginProfileUpdate holds the fields to change; omitted ones are left alone
+// and empty ones cleared.
+type ginProfileUpdate struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Email       *string `json:"email" binding:"omitempty,max=254,email"`
+	TimeZone    *string `json:"time_zone" binding:"omitempty,timezone"`
+	Locale      *string `json:"locale" binding:"omitempty,max=35,bcp47_language_tag"`
+}
+
+func fromDomainProfile(user *domain.User) *ginProfile {
+	return &ginProfile{
+		ID:            user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		DisplayName:   user.Profile.DisplayName,
+		Email:         user.Profile.Email,
+		EmailVerified: user.Profile.EmailVerified,
+		TimeZone:      user.Profile.TimeZone,
+		Locale:        user.Profile.Locale,
+		MFAEnabled:    user.MFA.Enabled,
+	}
+}
+
+// GetProfile handles GET api/me requests.
+func (ac *AppController) GetProfile(c *gin.Context) {
+	c.JSON(http.StatusOK, fromDomainProfile(currentUser(c)))
+}
+
+// UpdateProfile handles PATCH api/me requests.
+func (ac *AppController) UpdateProfile(c *gin.Context) {
+	var req ginProfileUpdate
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	update := domain.ProfileUpdate{DisplayName: req.DisplayName, Email: req.Email, TimeZone: req.TimeZone, Locale: req.Locale}
+	user, err := ac.userUsecase.UpdateProfile(currentUser(c).ID, update)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainProfile(user))
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleController handles roles and what they let users do.
+type RoleController struct {
+	roleUsecase usecases.RoleUsecase
+}
+
+func NewRoleController(ru usecases.RoleUsecase) *RoleController {
+	return &RoleController{roleUsecase: ru}
+}
+
+// ginRoleChange is what can be changed about a role.
+type ginRoleChange struct {
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions" binding:"required,dive,oneof=task.read task.create task.update task.delete task.import user.promote user.unlock user.manage settings.manage role.manage"`
+}
+
+type ginNewRole struct {
+	ginRoleChange
+	Name string `json:"name" binding:"required,max=50,rolename"`
+}
+
+type ginRole struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	BuiltIn     bool      `json:"built_in"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ginRoleAssignment struct {
+	Role string `json:"role" binding:"required,max=50"`
+}
+
+type ginPermissions struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+func fromDomainRole(role *domain.Role) *ginRole {
+	return &ginRole{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		BuiltIn:     role.BuiltIn,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+// ListRoles handles GET api/roles requests.
+func (rc *RoleController) ListRoles(c *gin.Context) {
+	roles, err := rc.roleUsecase.ListRoles(currentUser(c).WorkspaceID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := make([]*ginRole, 0, len(roles))
+	for _, role := range roles {
+		out = append(out, fromDomainRole(role))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CreateRole handles POST api/roles requests.
+func (rc *RoleController) CreateRole(c *gin.Context) {
+	var req ginNewRole
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	role := &domain.Role{Name: req.Name, Description: req.Description, Permissions: req.Permissions}
+	if err := rc.roleUsecase.CreateRole(currentUser(c), role); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, fromDomainRole(role))
+}
+
+// UpdateRole handles PUT api/roles/{name} requests.
+func (rc *RoleController) UpdateRole(c *gin.Context) {
+	var req ginRoleChange
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	role := &domain.Role{Name: c.Param("name"), Description: req.Description, Permissions: req.Permissions}
+	if err := rc.roleUsecase.UpdateRole(currentUser(c), role); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainRole(role))
+}
+
+// DeleteRole handles DELETE api/roles/{name} requests.
+func (rc *RoleController) DeleteRole(c *gin.Context) {
+	if err := rc.roleUsecase.DeleteRole(currentUser(c), c.Param("name")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AssignRole handles PUT api/users/{id}/role requests.
+func (rc *RoleController) AssignRole(c *gin.Context) {
+	var req ginRoleAssignment
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := rc.roleUsecase.AssignRole(currentUser(c), c.Param("id"), req.Role); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetMyPermissions handles GET api/me/permissions requests, so that UIs can
+// hide what the user cannot do.
+func (rc *RoleController) GetMyPermissions(c *gin.Context) {
+	user := currentUser(c)
+	permissions, err := rc.roleUsecase.Permissions(user)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ginPermissions{Role: user.Role, Permissions: permissions})
+}
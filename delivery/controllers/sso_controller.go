@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"task-manager/errs"
+	"task-manager/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ssoCookie holds the sealed SSO request between the redirect to the
+	// identity provider and the callback.
+	ssoCookie     = "sso_request"
+	ssoCookiePath = "/auth/oidc"
+	ssoCookieAge  = 10 * 60
+)
+
+// SSOController handles single sign-on with an OpenID Connect provider.
+type SSOController struct {
+	ssoUsecase usecases.SSOUsecase
+}
+
+func NewSSOController(su usecases.SSOUsecase) *SSOController {
+	return &SSOController{ssoUsecase: su}
+}
+
+type ginSSOStart struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type ginIdentity struct {
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+}
+
+// Login handles GET /auth/oidc/login requests by sending the browser to the
+// identity provider.
+func (sc *SSOController) Login(c *gin.Context) {
+	start, err := sc.ssoUsecase.Start("")
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	setSSOCookie(c, start.Request, ssoCookieAge)
+	c.Redirect(http.StatusFound, start.AuthURL)
+}
+
+// LinkIdentity handles POST api/me/identities/oidc requests. The client
+// opens the returned URL in the same browser to link the identity it logs
+// in with to the current user.
+func (sc *SSOController) LinkIdentity(c *gin.Context) {
+	start, err := sc.ssoUsecase.Start(currentUser(c).ID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	setSSOCookie(c, start.Request, ssoCookieAge)
+	c.JSON(http.StatusOK, &ginSSOStart{AuthorizationURL: start.AuthURL})
+}
+
+// Callback handles GET /auth/oidc/callback requests, where the identity
+// provider sends the browser back to.
+func (sc *SSOController) Callback(c *gin.Context) {
+	sealed, _ := c.Cookie(ssoCookie)
+	setSSOCookie(c, "", -1)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		handleError(c, fmt.Errorf("%w: %s %s", errs.ErrSSOFailed, providerErr, c.Query("error_description")))
+		return
+	}
+	if sealed == "" {
+		handleError(c, fmt.Errorf("%w: the request cookie is missing", errs.ErrInvalidSSOState))
+		return
+	}
+
+	result, err := sc.ssoUsecase.Callback(sealed, c.Query("state"), c.Query("code"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if result.Linked {
+		c.JSON(http.StatusOK, &ginIdentity{Issuer: result.Identity.Issuer, Subject: result.Identity.Subject})
+		return
+	}
+	c.JSON(http.StatusOK, &ginLoginResult{Token: result.Token})
+}
+
+// setSSOCookie keeps the cookie away from scripts and other paths. Lax
+// still sends it on the top-level redirect back from the provider.
+func setSSOCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoCookie, value, maxAge, ssoCookiePath, "", secure, true)
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"task-manager/domain"
+	"task-manager/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserController lets admins manage the members of their workspace.
+type UserController struct {
+	userAdminUsecase usecases.UserAdminUsecase
+}
+
+func NewUserController(uau usecases.UserAdminUsecase) *UserController {
+	return &UserController{userAdminUsecase: uau}
+}
+
+// ginUserInfo is a user as admins see them.
+type ginUserInfo struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	Disabled   bool   `json:"disabled"`
+	MFAEnabled bool   `json:"mfa_enabled"`
+}
+
+type ginUserPage struct {
+	Users   []*ginUserInfo `json:"users"`
+	Total   int64          `json:"total"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+}
+
+// ginUserUpdate holds the fields to change; omitted ones are left alone.
+type ginUserUpdate struct {
+	Username *string `json:"username" binding:"omitempty,min=3,max=32,username"`
+	Disabled *bool   `json:"disabled"`
+}
+
+func fromDomainUserInfo(user *domain.User) *ginUserInfo {
+	return &ginUserInfo{
+		ID:         user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		Disabled:   user.Disabled,
+		MFAEnabled: user.MFA.Enabled,
+	}
+}
+
+// ListUsers handles GET api/users requests.
+func (uc *UserController) ListUsers(c *gin.Context) {
+	query := domain.UserQuery{Search: c.Query("q"), Role: c.Query("role")}
+	query.Page, _ = strconv.Atoi(c.Query("page"))
+	query.PerPage, _ = strconv.Atoi(c.Query("per_page"))
+
+	page, err := uc.userAdminUsecase.ListUsers(currentUser(c), query)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := ginUserPage{Users: make([]*ginUserInfo, 0, len(page.Users)), Total: page.Total, Page: page.Page, PerPage: page.PerPage}
+	for _, user := range page.Users {
+		out.Users = append(out.Users, fromDomainUserInfo(user))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetUser handles GET api/users/{id} requests.
+func (uc *UserController) GetUser(c *gin.Context) {
+	user, err := uc.userAdminUsecase.GetUser(currentUser(c), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainUserInfo(user))
+}
+
+// UpdateUser handles PATCH api/users/{id} requests.
+func (uc *UserController) UpdateUser(c *gin.Context) {
+	var req ginUserUpdate
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	update := domain.UserUpdate{Username: req.Username, Disabled: req.Disabled}
+	user, err := uc.userAdminUsecase.UpdateUser(currentUser(c), c.Param("id"), update)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainUserInfo(user))
+}
+
+// DeleteUser handles DELETE api/users/{id} requests.
+func (uc *UserController) DeleteUser(c *gin.Context) {
+	if err := uc.userAdminUsecase.DeleteUser(currentUser(c), c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Demote handles POST api/demote/{id} requests.
+func (uc *UserController) Demote(c *gin.Context) {
+	if err := uc.userAdminUsecase.Demote(currentUser(c), c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User demoted successfully"})
+}
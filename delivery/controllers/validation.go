@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const dueDateHorizon = 20 // years
+
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	roleNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	earliestDueDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// The DTOs are validated declaratively through gin's `binding` struct tags.
+// The custom rules used in those tags are registered here.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// report fields by their JSON names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("rolename", func(fl validator.FieldLevel) bool {
+		return roleNamePattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("duedate", func(fl validator.FieldLevel) bool {
+		due, ok := fl.Field().Interface().(time.Time)
+		if !ok || due.IsZero() {
+			return true
+		}
+		return !due.Before(earliestDueDate) && due.Before(time.Now().AddDate(dueDateHorizon, 0, 0))
+	})
+	_ = v.RegisterValidation("future", func(fl validator.FieldLevel) bool {
+		t, ok := fl.Field().Interface().(time.Time)
+		return ok && t.After(time.Now())
+	})
+	v.RegisterStructValidation(func(sl validator.StructLevel) {
+		op := sl.Current().Interface().(ginBulkOperation)
+		if op.Op == domain.BulkCreate && op.Task.Title == "" {
+			sl.ReportError(op.Task.Title, "task.title", "Title", "required", "")
+		}
+	}, ginBulkOperation{})
+}
+
+// bindJSON decodes and validates the request body into obj. Any problem is
+// returned as an *errs.ValidationError listing every offending field.
+func bindJSON(c *gin.Context, obj any) error {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]errs.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, errs.FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Code:    fe.Tag(),
+				Message: fieldMessage(fe),
+			})
+		}
+		return &errs.ValidationError{Fields: fields}
+	case errors.As(err, &typeErr):
+		return &errs.ValidationError{Fields: []errs.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be " + jsonType(typeErr.Type),
+		}}}
+	case errors.As(err, &timeErr):
+		return &errs.ValidationError{Fields: []errs.FieldError{{
+			Code:    "type",
+			Message: fmt.Sprintf("%q is not an RFC 3339 date-time", timeErr.Value),
+		}}}
+	default:
+		return &errs.ValidationError{Fields: []errs.FieldError{{
+			Code:    "malformed",
+			Message: "request body is not valid JSON: " + err.Error(),
+		}}}
+	}
+}
+
+// fieldPath turns "ginNewTask.ginTask.labels[2]" into "labels[2]".
+func fieldPath(namespace string) string {
+	parts := strings.Split(namespace, ".")
+	kept := parts[:0]
+	for _, part := range parts {
+		if !strings.HasPrefix(part, "gin") {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ".")
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	sized := "characters"
+	if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+		sized = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return fmt.Sprintf("must have at most %s %s", fe.Param(), sized)
+	case "min":
+		return fmt.Sprintf("must have at least %s %s", fe.Param(), sized)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), "'", `"`)
+	case "username":
+		return "may only contain letters, digits, '.', '_' and '-'"
+	case "rolename":
+		return "may only contain lowercase letters, digits, '_' and '-'"
+	case "email":
+		return "must be an email address"
+	case "timezone":
+		return "must be an IANA time zone such as Europe/Berlin"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag such as de-DE"
+	case "future":
+		return "must be in the future"
+	case "duedate":
+		return fmt.Sprintf("must be after %s and within %d years from now", earliestDueDate.Format(time.DateOnly), dueDateHorizon)
+	}
+	return "is invalid (" + fe.Tag() + ")"
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// queryParamError reports a query parameter that is not one of allowed.
+func queryParamError(param, allowed string) error {
+	return &errs.ValidationError{Fields: []errs.FieldError{{
+		Field:   param,
+		Code:    "oneof",
+		Message: "must be one of " + allowed,
+	}}}
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ViewController handles the HTTP requests for saved views.
+type ViewController struct {
+	viewUsecase usecases.ViewUsecase
+}
+
+type ginView struct {
+	ID         string    `json:"id,omitempty"`
+	Name       string    `json:"name" binding:"max=100"`
+	Query      string    `json:"query" binding:"max=1000"`
+	OwnerID    string    `json:"owner_id,omitempty"`
+	SharedWith []string  `json:"shared_with" binding:"max=100"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func fromDomainView(view *domain.View) *ginView {
+	sharedWith := view.SharedWith
+	if sharedWith == nil {
+		sharedWith = []string{}
+	}
+	return &ginView{
+		ID:         view.ID,
+		Name:       view.Name,
+		Query:      view.Query,
+		OwnerID:    view.OwnerID,
+		SharedWith: sharedWith,
+		CreatedAt:  view.CreatedAt,
+	}
+}
+func toDomainView(gView *ginView) *domain.View {
+	return &domain.View{
+		Name:       gView.Name,
+		Query:      gView.Query,
+		SharedWith: gView.SharedWith,
+	}
+}
+
+func NewViewController(vu usecases.ViewUsecase) *ViewController {
+	return &ViewController{viewUsecase: vu}
+}
+
+// currentUser returns the authenticated user set by the auth middleware.
+func currentUser(c *gin.Context) *domain.User {
+	user, _ := c.MustGet("user").(*domain.User)
+	return user
+}
+
+// CreateView handles POST api/views requests.
+func (vc *ViewController) CreateView(c *gin.Context) {
+	var view ginView
+	if err := bindJSON(c, &view); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	created, err := vc.viewUsecase.CreateView(currentUser(c), toDomainView(&view))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, fromDomainView(created))
+}
+
+// GetViews handles GET api/views requests.
+func (vc *ViewController) GetViews(c *gin.Context) {
+	views, err := vc.viewUsecase.GetViews(currentUser(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	ginViews := make([]*ginView, 0, len(views))
+	for _, view := range views {
+		ginViews = append(ginViews, fromDomainView(view))
+	}
+	c.JSON(http.StatusOK, ginViews)
+}
+
+// GetView handles GET api/views/:id requests.
+func (vc *ViewController) GetView(c *gin.Context) {
+	view, err := vc.viewUsecase.GetView(currentUser(c), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainView(view))
+}
+
+// UpdateView handles PUT api/views/:id requests.
+func (vc *ViewController) UpdateView(c *gin.Context) {
+	var view ginView
+	if err := bindJSON(c, &view); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	updated, err := vc.viewUsecase.UpdateView(currentUser(c), c.Param("id"), *toDomainView(&view))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainView(updated))
+}
+
+// DeleteView handles DELETE api/views/:id requests.
+func (vc *ViewController) DeleteView(c *gin.Context) {
+	if err := vc.viewUsecase.DeleteView(currentUser(c), c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetViewTasks handles GET api/views/:id/tasks requests.
+func (vc *ViewController) GetViewTasks(c *gin.Context) {
+	tasks, err := vc.viewUsecase.GetViewTasks(currentUser(c), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	ginTasks := make([]*ginTask, 0, len(tasks))
+	for _, task := range tasks {
+		ginTasks = append(ginTasks, fromDomainTask(task))
+	}
+	c.JSON(http.StatusOK, ginTasks)
+}
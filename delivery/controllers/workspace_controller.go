@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"net/http"
+	"task-manager/domain"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceController lets users create workspaces and move between them.
+type WorkspaceController struct {
+	workspaceUsecase usecases.WorkspaceUsecase
+}
+
+func NewWorkspaceController(wu usecases.WorkspaceUsecase) *WorkspaceController {
+	return &WorkspaceController{workspaceUsecase: wu}
+}
+
+type ginWorkspaceCreate struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
+
+type ginWorkspaceJoin struct {
+	Invitation string `json:"invitation" binding:"required"`
+}
+
+// ginWorkspace is a workspace as one of its members sees it. Active is set
+// on the workspace the request acts in.
+type ginWorkspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func fromDomainWorkspace(workspace *domain.Workspace, user *domain.User) *ginWorkspace {
+	out := &ginWorkspace{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Active:    workspace.ID == user.WorkspaceID,
+		CreatedAt: workspace.CreatedAt,
+	}
+	if membership := user.Membership(workspace.ID); membership != nil {
+		out.Role = membership.Role
+		out.Disabled = membership.Disabled
+	}
+	return out
+}
+
+// ListWorkspaces handles GET api/workspaces requests.
+func (wc *WorkspaceController) ListWorkspaces(c *gin.Context) {
+	user := currentUser(c)
+	workspaces, err := wc.workspaceUsecase.ListWorkspaces(user)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	out := make([]*ginWorkspace, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		out = append(out, fromDomainWorkspace(workspace, user))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CreateWorkspace handles POST api/workspaces requests. The current token
+// stays in its workspace; switching to the new one takes a new token.
+func (wc *WorkspaceController) CreateWorkspace(c *gin.Context) {
+	var req ginWorkspaceCreate
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	workspace, err := wc.workspaceUsecase.CreateWorkspace(currentUser(c), req.Name)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, &ginWorkspace{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Role:      domain.RoleAdmin,
+		CreatedAt: workspace.CreatedAt,
+	})
+}
+
+// SwitchWorkspace handles POST api/workspaces/{id}/switch requests. It
+// returns a token that acts in the workspace.
+func (wc *WorkspaceController) SwitchWorkspace(c *gin.Context) {
+	token, err := wc.workspaceUsecase.SwitchWorkspace(currentUser(c), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &ginLoginResult{Token: token})
+}
+
+// JoinWorkspace handles POST api/workspaces/join requests, with which users
+// accept an invitation to a workspace. Like a new workspace, it takes a
+// switch to work there.
+func (wc *WorkspaceController) JoinWorkspace(c *gin.Context) {
+	var req ginWorkspaceJoin
+	if err := bindJSON(c, &req); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	workspace, err := wc.workspaceUsecase.JoinWorkspace(user, req.Invitation)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fromDomainWorkspace(workspace, user))
+}
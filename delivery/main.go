@@ -1,10 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"task-manager/delivery/controllers"
 	"task-manager/delivery/router"
+	"task-manager/domain"
 	"task-manager/infrastructure"
 	"task-manager/repositories"
 	"task-manager/usecases"
@@ -19,27 +31,201 @@ const (
 	DATABASE_NAME = "task_db"
 )
 
+// policyReloadInterval is how often the policy file is checked for changes.
+const policyReloadInterval = 5 * time.Second
+
+// shutdownTimeout is how long a stopping server waits for the requests it is
+// serving.
+const shutdownTimeout = 15 * time.Second
+
+var reminderConfig = usecases.ReminderConfig{
+	Offsets:       []time.Duration{24 * time.Hour, time.Hour},
+	EscalateAfter: 48 * time.Hour,
+	Interval:      time.Minute,
+	LeaseTTL:      3 * time.Minute,
+}
+
 func main() {
 	client, err := connectToDB()
 	if err != nil {
 		log.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
+	if supported, err := repositories.SupportsTransactions(client); err != nil {
+		log.Printf("WARN: could not tell whether MongoDB supports transactions: %v", err)
+	} else if !supported {
+		log.Println(`WARN: MongoDB is a standalone server, so bulk requests with "atomic": true answer 501 Not Implemented; run it as a replica set to support them`)
+	}
 	tasksCollection := client.Database(DATABASE_NAME).Collection("tasks")
 	usersCollection := client.Database(DATABASE_NAME).Collection("users")
+	leasesCollection := client.Database(DATABASE_NAME).Collection("leases")
+	viewsCollection := client.Database(DATABASE_NAME).Collection("views")
+	loginAttemptsCollection := client.Database(DATABASE_NAME).Collection("login_attempts")
+	settingsCollection := client.Database(DATABASE_NAME).Collection("settings")
+	passwordResetsCollection := client.Database(DATABASE_NAME).Collection("password_resets")
+	signingKeysCollection := client.Database(DATABASE_NAME).Collection("signing_keys")
+	apiTokensCollection := client.Database(DATABASE_NAME).Collection("api_tokens")
+	rolesCollection := client.Database(DATABASE_NAME).Collection("roles")
+	workspacesCollection := client.Database(DATABASE_NAME).Collection("workspaces")
+	invitationsCollection := client.Database(DATABASE_NAME).Collection("invitations")
+	rateLimitsCollection := client.Database(DATABASE_NAME).Collection("rate_limits")
+	idempotencyKeysCollection := client.Database(DATABASE_NAME).Collection("idempotency_keys")
+	// the migration rekeys the roles, so it runs before their index is built
+	migration := repositories.WorkspaceMigration{
+		Workspaces: workspacesCollection,
+		Users:      usersCollection,
+		Tasks:      tasksCollection,
+		Roles:      rolesCollection,
+		Settings:   settingsCollection,
+		APITokens:  apiTokensCollection,
+		Views:      viewsCollection,
+	}
+	if workspace, err := migration.Run(); err != nil {
+		log.Fatalf("Failed to move existing data into a workspace: %v", err)
+	} else if workspace != nil {
+		log.Printf("INFO: moved existing users and tasks into workspace %s (%s)", workspace.ID, workspace.Name)
+	}
+	// the unique index is on the canonical usernames the migration stores
+	if updated, err := (repositories.UsernameMigration{Users: usersCollection}).Run(); err != nil {
+		log.Fatalf("Failed to store canonical usernames: %v", err)
+	} else if updated > 0 {
+		log.Printf("INFO: stored the canonical usernames of %d users", updated)
+	}
+	if err := repositories.EnsureTaskIndexes(tasksCollection); err != nil {
+		log.Fatalf("Failed to create task indexes: %v", err)
+	}
+	if err := repositories.EnsureUserIndexes(usersCollection); err != nil {
+		log.Fatalf("Failed to create user indexes: %v", err)
+	}
+	if err := repositories.EnsureLoginAttemptIndexes(loginAttemptsCollection); err != nil {
+		log.Fatalf("Failed to create login attempt indexes: %v", err)
+	}
+	if err := repositories.EnsurePasswordResetIndexes(passwordResetsCollection); err != nil {
+		log.Fatalf("Failed to create password reset indexes: %v", err)
+	}
+	if err := repositories.EnsureSigningKeyIndexes(signingKeysCollection); err != nil {
+		log.Fatalf("Failed to create signing key indexes: %v", err)
+	}
+	if err := repositories.EnsureAPITokenIndexes(apiTokensCollection); err != nil {
+		log.Fatalf("Failed to create API token indexes: %v", err)
+	}
+	if err := repositories.EnsureRoleIndexes(rolesCollection); err != nil {
+		log.Fatalf("Failed to create role indexes: %v", err)
+	}
+	if err := repositories.EnsureInvitationIndexes(invitationsCollection); err != nil {
+		log.Fatalf("Failed to create invitation indexes: %v", err)
+	}
+	notifier := notifier()
 	newMongoTaskRepository := repositories.NewMongoTaskRepository(tasksCollection)
 	newMongoUserRepository := repositories.NewMongoUserRepository(usersCollection)
-	newTaskUseCase := usecases.NewTaskUsecase(newMongoTaskRepository)
+	newMongoWorkspaceRepository := repositories.NewMongoWorkspaceRepository(workspacesCollection)
+	newMongoInvitationRepository := repositories.NewMongoInvitationRepository(invitationsCollection)
+	keyring, err := infrastructure.NewKeyring(repositories.NewMongoSigningKeyRepository(signingKeysCollection), keyringConfig())
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	jwtService := infrastructure.NewJWTServiceV5(keyring, jwtIssuer())
+	// SIGINT and SIGTERM stop the server and the background jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var policies usecases.PolicySource
+	if file := loadPolicyFile(); file != nil {
+		policies = file
+		go file.Run(ctx, policyReloadInterval)
+	}
+	newTaskUseCase := usecases.NewTaskUsecase(newMongoTaskRepository, policies)
+	authCfg := authConfig()
 	newUserUsecase := usecases.NewUserUsecase(
 		newMongoUserRepository,
+		newMongoWorkspaceRepository,
+		repositories.NewMongoLoginAttemptRepository(loginAttemptsCollection),
+		repositories.NewMongoSettingsRepository(settingsCollection),
+		repositories.NewMongoPasswordResetRepository(passwordResetsCollection),
+		repositories.NewMongoAPITokenRepository(apiTokensCollection),
+		newMongoInvitationRepository,
 		infrastructure.NewBcryptService(),
-		infrastructure.NewJWTServiceV5(),
+		jwtService,
+		infrastructure.NewTOTPService("Task Manager"),
+		notifier,
+		loadBreachedPasswords(),
+		authCfg,
+	)
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		runBootstrap(newUserUsecase, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "unlock-ip" {
+		runUnlockIP(newUserUsecase, os.Args[2:])
+		return
+	}
+	newMongoRoleRepository := repositories.NewMongoRoleRepository(rolesCollection)
+	newMongoLeaseRepository := repositories.NewMongoLeaseRepository(leasesCollection)
+	newRoleUsecase := usecases.NewRoleUsecase(newMongoRoleRepository, newMongoUserRepository, newMongoLeaseRepository)
+
+	reminderScheduler := usecases.NewReminderScheduler(
+		newMongoTaskRepository,
+		newMongoUserRepository,
+		newMongoLeaseRepository,
+		notifier,
+		reminderConfig,
+		replicaID(),
+	)
+	// the scheduler releases its lease when it stops, so shutdown waits for it
+	var scheduler sync.WaitGroup
+	scheduler.Add(1)
+	go func() {
+		defer scheduler.Done()
+		reminderScheduler.Run(ctx)
+	}()
+	go keyring.Run(ctx)
+
+	newViewUsecase := usecases.NewViewUsecase(
+		repositories.NewMongoViewRepository(viewsCollection),
+		newMongoTaskRepository,
+		policies,
 	)
 
 	newAppController := controllers.NewAppController(newTaskUseCase, newUserUsecase)
-	r := router.SetupRouter(newAppController, newUserUsecase)
+	newViewController := controllers.NewViewController(newViewUsecase)
+	newSSOController := controllers.NewSSOController(usecases.NewSSOUsecase(
+		identityProvider(),
+		newMongoUserRepository,
+		newMongoWorkspaceRepository,
+		jwtService,
+		jwtService,
+		ssoConfig(authCfg.Registration.Mode),
+	))
+	newRoleController := controllers.NewRoleController(newRoleUsecase)
+	newUserController := controllers.NewUserController(usecases.NewUserAdminUsecase(newMongoUserRepository, newMongoRoleRepository, newMongoInvitationRepository, newMongoLeaseRepository))
+	newWorkspaceController := controllers.NewWorkspaceController(usecases.NewWorkspaceUsecase(newMongoWorkspaceRepository, newMongoUserRepository, newMongoInvitationRepository, jwtService))
+	r := router.SetupRouter(newAppController, newViewController, newSSOController, newRoleController, newUserController, newWorkspaceController, newUserUsecase, newRoleUsecase, jwtService, rateLimiter(rateLimitsCollection), idempotency(idempotencyKeysCollection))
+
+	if proxies := trustedProxies(); proxies != nil {
+		if err := r.SetTrustedProxies(proxies); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
+
+	server := &http.Server{Addr: ":5000", Handler: r}
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
 
-	if err := r.Run(":5000"); err != nil {
+	select {
+	case err := <-served:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("INFO: shutting down")
+
+	// requests in flight get shutdownTimeout to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("WARN: requests still in flight were cut off: %v", err)
+	}
+	scheduler.Wait()
+	if err := client.Disconnect(shutdownCtx); err != nil {
+		log.Printf("WARN: failed to disconnect from MongoDB: %v", err)
 	}
 }
 
@@ -60,3 +246,278 @@ func connectToDB() (*mongo.Client, error) {
 
 	return client, nil
 }
+
+// runBootstrap creates the first admin of a new installation:
+//
+//	task-manager bootstrap -username admin -workspace Acme
+//
+// The password is read from BOOTSTRAP_PASSWORD, or else from standard input.
+func runBootstrap(uu usecases.UserUsecase, args []string) {
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	username := flags.String("username", "admin", "username of the first admin")
+	workspace := flags.String("workspace", "Default", "name of the first workspace")
+	flags.Parse(args)
+
+	password := os.Getenv("BOOTSTRAP_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatalf("Failed to read the password: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if err := uu.Bootstrap(&domain.User{Username: *username, Password: password}, *workspace); err != nil {
+		log.Fatalf("Failed to create the first admin: %v", err)
+	}
+	log.Printf("INFO: created the first admin '%s' in workspace '%s'", *username, *workspace)
+}
+
+// runUnlockIP lifts the lockout of client addresses:
+//
+//	task-manager unlock-ip 203.0.113.7
+//
+// Addresses are shared by every workspace, so no workspace admin can unlock
+// them through the API.
+func runUnlockIP(uu usecases.UserUsecase, args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: unlock-ip <address>...")
+	}
+	for _, ip := range args {
+		if err := uu.UnlockIP(ip); err != nil {
+			log.Fatalf("Failed to unlock %s: %v", ip, err)
+		}
+	}
+}
+
+// authConfig reads REGISTRATION_MODE, one of usecases.RegistrationModes,
+// and REGISTRATION_DOMAINS, the comma-separated email domains of the
+// domain-allowlist mode. That mode emails invitations, so it needs
+// NOTIFY_WEBHOOK_URL, see notifier.
+func authConfig() usecases.AuthConfig {
+	config := usecases.DefaultAuthConfig
+	if mode := os.Getenv("REGISTRATION_MODE"); mode != "" {
+		if !slices.Contains(usecases.RegistrationModes, mode) {
+			log.Fatalf("Invalid REGISTRATION_MODE %q, use one of %s", mode, strings.Join(usecases.RegistrationModes, ", "))
+		}
+		config.Registration.Mode = mode
+	}
+	for _, domainName := range strings.Split(os.Getenv("REGISTRATION_DOMAINS"), ",") {
+		if domainName = strings.TrimSpace(domainName); domainName != "" {
+			config.Registration.AllowedDomains = append(config.Registration.AllowedDomains, domainName)
+		}
+	}
+	switch {
+	case config.Registration.Mode == usecases.RegistrationDomainAllowlist && len(config.Registration.AllowedDomains) == 0:
+		log.Fatal("REGISTRATION_DOMAINS is required with REGISTRATION_MODE=domain-allowlist")
+	case config.Registration.Mode == usecases.RegistrationDomainAllowlist && os.Getenv("NOTIFY_WEBHOOK_URL") == "":
+		log.Fatal("NOTIFY_WEBHOOK_URL is required with REGISTRATION_MODE=domain-allowlist, or the invitations cannot be delivered")
+	case config.Registration.Mode == usecases.RegistrationOpen:
+		log.Println("WARN: REGISTRATION_MODE is open, anyone can register")
+	}
+	return config
+}
+
+// rateLimiter reads RATE_LIMIT_AUTH and RATE_LIMIT_API, the limits of the
+// route groups such as "10/1m" or "off", and RATE_LIMIT_STORE, memory or
+// mongo. Replicas only share their buckets in mongo.
+func rateLimiter(collection *mongo.Collection) *infrastructure.RateLimiter {
+	limits := maps.Clone(infrastructure.DefaultRateLimits)
+	for group, variable := range map[string]string{
+		infrastructure.RateLimitAuth: "RATE_LIMIT_AUTH",
+		infrastructure.RateLimitAPI:  "RATE_LIMIT_API",
+	} {
+		if value := os.Getenv(variable); value != "" {
+			limit, err := infrastructure.ParseRateLimit(value)
+			if err != nil {
+				log.Fatalf("Invalid %s: %v", variable, err)
+			}
+			limits[group] = limit
+		}
+	}
+
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		return infrastructure.NewRateLimiter(infrastructure.NewMemoryRateLimitStore(), limits)
+	case "mongo":
+		if err := repositories.EnsureRateLimitIndexes(collection); err != nil {
+			log.Fatalf("Failed to create rate limit indexes: %v", err)
+		}
+		return infrastructure.NewRateLimiter(repositories.NewMongoRateLimitStore(collection), limits)
+	default:
+		log.Fatalf("Invalid RATE_LIMIT_STORE %q, use memory or mongo", store)
+		return nil
+	}
+}
+
+// trustedProxies reads TRUSTED_PROXIES, the comma-separated addresses or
+// CIDR ranges of the proxies in front of the app. Client addresses are only
+// taken from X-Forwarded-For if the request came through one of them; by
+// default no proxy is trusted.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// idempotency reads IDEMPOTENCY_TTL, how long the responses to idempotency
+// keys are replayed, and IDEMPOTENCY_STORE, memory or mongo. Replicas only
+// share the keys in mongo, so behind a load balancer retries are only
+// recognized there.
+func idempotency(collection *mongo.Collection) *infrastructure.Idempotency {
+	ttl := infrastructure.DefaultIdempotencyTTL
+	if value := os.Getenv("IDEMPOTENCY_TTL"); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_TTL %q, use a positive duration such as 24h", value)
+		}
+	}
+
+	switch store := os.Getenv("IDEMPOTENCY_STORE"); store {
+	case "", "memory":
+		return infrastructure.NewIdempotency(infrastructure.NewMemoryIdempotencyStore(), ttl)
+	case "mongo":
+		if err := repositories.EnsureIdempotencyIndexes(collection); err != nil {
+			log.Fatalf("Failed to create idempotency key indexes: %v", err)
+		}
+		return infrastructure.NewIdempotency(repositories.NewMongoIdempotencyStore(collection), ttl)
+	default:
+		log.Fatalf("Invalid IDEMPOTENCY_STORE %q, use memory or mongo", store)
+		return nil
+	}
+}
+
+// loadBreachedPasswords loads the list named by BREACHED_PASSWORDS_PATH.
+// Without it, passwords are only checked against the password policy.
+func loadBreachedPasswords() usecases.BreachedPasswordChecker {
+	path := os.Getenv("BREACHED_PASSWORDS_PATH")
+	if path == "" {
+		log.Println("WARN: BREACHED_PASSWORDS_PATH is not set, breached passwords will not be rejected")
+		return nil
+	}
+	list, err := infrastructure.LoadBreachedPasswordList(path)
+	if err != nil {
+		log.Fatalf("Failed to load breached passwords: %v", err)
+	}
+	log.Printf("INFO: loaded %d breached password hashes", list.Len())
+	return list
+}
+
+// loadPolicyFile loads the access policies at ACCESS_POLICIES_PATH, which
+// are reloaded when the file changes. Without it, roles alone decide.
+func loadPolicyFile() *infrastructure.PolicyFile {
+	path := os.Getenv("ACCESS_POLICIES_PATH")
+	if path == "" {
+		return nil
+	}
+	file, err := infrastructure.LoadPolicyFile(path)
+	if err != nil {
+		log.Fatalf("Failed to load access policies: %v", err)
+	}
+	log.Printf("INFO: loaded %d access policies from %s", len(file.Policies()), path)
+	return file
+}
+
+// keyringConfig reads JWT_SIGNING_ALG (EdDSA or RS256) and
+// JWT_KEY_ROTATION, how long each signing key is used for.
+func keyringConfig() infrastructure.KeyringConfig {
+	config := infrastructure.DefaultKeyringConfig
+	if alg := os.Getenv("JWT_SIGNING_ALG"); alg != "" {
+		config.Algorithm = alg
+	}
+	if rotation := os.Getenv("JWT_KEY_ROTATION"); rotation != "" {
+		every, err := time.ParseDuration(rotation)
+		if err != nil {
+			log.Fatalf("Invalid JWT_KEY_ROTATION: %v", err)
+		}
+		config.RotateEvery = every
+	}
+	return config
+}
+
+// jwtIssuer is the iss claim of our tokens, JWT_ISSUER or "task-manager".
+func jwtIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return infrastructure.DefaultJWTIssuer
+}
+
+// notifier delivers notifications to NOTIFY_WEBHOOK_URL, authenticated with
+// NOTIFY_WEBHOOK_TOKEN if set. Without a webhook they are only logged, which
+// cannot deliver password resets or invitations.
+func notifier() usecases.Notifier {
+	url := os.Getenv("NOTIFY_WEBHOOK_URL")
+	if url == "" {
+		log.Println("WARN: NOTIFY_WEBHOOK_URL is not set, so notifications are only logged and password resets and invitations cannot be delivered")
+		return infrastructure.NewLogNotifier()
+	}
+	if !strings.HasPrefix(url, "https://") {
+		log.Println("WARN: NOTIFY_WEBHOOK_URL is not HTTPS, reset and invitation tokens are sent in the clear")
+	}
+	log.Println("INFO: notifications are delivered by webhook")
+	return infrastructure.NewWebhookNotifier(url, os.Getenv("NOTIFY_WEBHOOK_TOKEN"))
+}
+
+// identityProvider configures single sign-on from the OIDC_* variables. It
+// is off unless OIDC_ISSUER is set.
+func identityProvider() usecases.IdentityProvider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	config := infrastructure.OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	}
+	if config.ClientID == "" || config.RedirectURL == "" {
+		log.Fatal("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+	log.Printf("INFO: single sign-on with %s is enabled", issuer)
+	return infrastructure.NewOIDCProvider(config, nil)
+}
+
+// ssoConfig maps provider claims to users, see usecases.SSOConfig.
+// OIDC_PROVISIONING, on or off, tells whether single sign-on creates
+// accounts; by default it only does in the open registration mode.
+func ssoConfig(registrationMode string) usecases.SSOConfig {
+	config := usecases.DefaultSSOConfig
+	if claim := os.Getenv("OIDC_USERNAME_CLAIM"); claim != "" {
+		config.UsernameClaim = claim
+	}
+	config.RoleClaim = os.Getenv("OIDC_ROLE_CLAIM")
+	if values := os.Getenv("OIDC_ADMIN_VALUES"); values != "" {
+		config.AdminValues = strings.Split(values, ",")
+	}
+	config.Workspace = os.Getenv("OIDC_WORKSPACE")
+	if config.RoleClaim != "" && config.Workspace == "" {
+		log.Println("WARN: OIDC_ROLE_CLAIM is ignored without OIDC_WORKSPACE, the workspace it makes admins of")
+	}
+	switch provisioning := os.Getenv("OIDC_PROVISIONING"); provisioning {
+	case "":
+		config.Provision = registrationMode == usecases.RegistrationOpen
+	case "on", "off":
+		config.Provision = provisioning == "on"
+	default:
+		log.Fatalf("Invalid OIDC_PROVISIONING %q, use on or off", provisioning)
+	}
+	return config
+}
+
+// replicaID identifies this process when competing for background job leases.
+func replicaID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
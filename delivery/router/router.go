@@ -9,32 +9,116 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(ac *controllers.AppController, uu usecases.UserUsecase) *gin.Engine {
+func SetupRouter(ac *controllers.AppController, vc *controllers.ViewController, sc *controllers.SSOController, rc *controllers.RoleController, uc *controllers.UserController, wc *controllers.WorkspaceController, uu usecases.UserUsecase, ru usecases.RoleUsecase, js *infrastructure.JWTServiceV5, rl *infrastructure.RateLimiter, idem *infrastructure.Idempotency) *gin.Engine {
 	r := gin.Default()
+	// X-Forwarded-For is only believed from the proxies that main trusts,
+	// so that clients cannot pick the address they are rate limited by;
+	// trusting none cannot fail
+	_ = r.SetTrustedProxies(nil)
+	r.Use(infrastructure.ErrorMiddleware())
+	r.NoRoute(infrastructure.NoRoute)
 
-	// public routes
-	r.POST("/register", ac.Register)
-	r.POST("/login", ac.Login)
+	// public routes; the ones taking credentials are limited per address
+	authLimit := rl.Limit(infrastructure.RateLimitAuth, infrastructure.RateLimitByIP)
+	r.POST("/register", authLimit, ac.Register)
+	r.POST("/register/invitation", authLimit, ac.RequestInvitation)
+	r.POST("/login", authLimit, ac.Login)
+	r.POST("/login/mfa", authLimit, ac.LoginMFA)
+	r.POST("/password/forgot", authLimit, ac.RequestPasswordReset)
+	r.POST("/password/reset", authLimit, ac.ResetPassword)
+	r.GET("/auth/oidc/login", sc.Login)
+	r.GET("/auth/oidc/callback", sc.Callback)
+	r.GET("/.well-known/jwks.json", infrastructure.JWKSHandler(js))
+	r.GET("/openapi.json", controllers.ServeOpenAPI)
+	r.GET("/docs", controllers.ServeDocs)
 
-	// private routes
+	// private routes; each one needs the permissions listed with it
+	can := func(permissions ...string) gin.HandlerFunc {
+		return infrastructure.RequirePermission(uu, ru, permissions...)
+	}
+	// calendar clients authenticate by the token in the feed URL
+	r.GET("/ical/:feed", infrastructure.CalendarFeedAuth(uu), can(domain.PermTaskRead), ac.CalendarFeed)
+	// every user and API token has one bucket for all of the API
+	apiLimit := rl.Limit(infrastructure.RateLimitAPI, infrastructure.RateLimitByAPIToken)
+	// POST requests that create something can be retried with an
+	// Idempotency-Key; the ones answered with credentials cannot, since the
+	// responses are stored
+	idempotent := idem.Middleware()
 	api := r.Group("/api")
 	{
-		// Admin-only routes
+		// API tokens need the scope of each group.
+		taskWriteRoutes := api.Group("")
+		taskWriteRoutes.Use(infrastructure.AuthMiddleware(uu, js, domain.ScopeTasksWrite), apiLimit)
+		{
+			taskWriteRoutes.POST("/tasks", can(domain.PermTaskCreate), idempotent, ac.CreateTask)
+			taskWriteRoutes.POST("/tasks/bulk", can(domain.PermTaskCreate, domain.PermTaskUpdate, domain.PermTaskDelete), idempotent, ac.BulkTasks)
+			taskWriteRoutes.POST("/tasks/import", can(domain.PermTaskImport), idempotent, ac.ImportTasks)
+			taskWriteRoutes.POST("/tasks/import/ics", can(domain.PermTaskImport), idempotent, ac.ImportCalendar)
+			taskWriteRoutes.PUT("/tasks/:id", can(domain.PermTaskUpdate), ac.UpdateTask)
+			taskWriteRoutes.DELETE("/tasks/:id", can(domain.PermTaskDelete), ac.DeleteTask)
+		}
 		adminRoutes := api.Group("")
-		adminRoutes.Use(infrastructure.AuthMiddleware(uu, domain.RoleAdmin))
+		adminRoutes.Use(infrastructure.AuthMiddleware(uu, js, domain.ScopeAdmin), apiLimit)
 		{
-			adminRoutes.POST("/tasks", ac.CreateTask)
-			adminRoutes.PUT("/tasks/:id", ac.UpdateTask)
-			adminRoutes.DELETE("/tasks/:id", ac.DeleteTask)
-			adminRoutes.POST("/promote/:id", ac.Promote)
+			// admins have every permission
+			adminRoutes.POST("/promote/:id", can(domain.Permissions...), ac.Promote)
+			adminRoutes.POST("/demote/:id", can(domain.PermUserPromote), uc.Demote)
+			adminRoutes.GET("/users", can(domain.PermUserManage), uc.ListUsers)
+			adminRoutes.GET("/users/:id", can(domain.PermUserManage), uc.GetUser)
+			adminRoutes.PATCH("/users/:id", can(domain.PermUserManage), uc.UpdateUser)
+			adminRoutes.DELETE("/users/:id", can(domain.PermUserManage), uc.DeleteUser)
+			adminRoutes.GET("/invitations", can(domain.PermUserManage), uc.ListInvitations)
+			adminRoutes.POST("/invitations", can(domain.PermUserManage), uc.CreateInvitation)
+			adminRoutes.DELETE("/invitations/:id", can(domain.PermUserManage), uc.RevokeInvitation)
+			adminRoutes.PUT("/users/:id/role", can(domain.PermUserPromote), rc.AssignRole)
+			adminRoutes.DELETE("/users/:id/lockout", can(domain.PermUserUnlock), ac.UnlockUser)
+			adminRoutes.GET("/settings/security", can(domain.PermSettingsManage), ac.GetSecuritySettings)
+			adminRoutes.PUT("/settings/security", can(domain.PermSettingsManage), ac.UpdateSecuritySettings)
+			adminRoutes.GET("/roles", can(domain.PermRoleManage), rc.ListRoles)
+			adminRoutes.POST("/roles", can(domain.PermRoleManage), idempotent, rc.CreateRole)
+			adminRoutes.PUT("/roles/:name", can(domain.PermRoleManage), rc.UpdateRole)
+			adminRoutes.DELETE("/roles/:name", can(domain.PermRoleManage), rc.DeleteRole)
+			adminRoutes.POST("/policies/explain", can(domain.PermRoleManage), ac.ExplainAccess)
 		}
 
-		// Routes for all authenticated users (Admin and User)
+		taskReadRoutes := api.Group("")
+		taskReadRoutes.Use(infrastructure.AuthMiddleware(uu, js, domain.ScopeTasksRead), apiLimit, can(domain.PermTaskRead))
+		{
+			taskReadRoutes.GET("/tasks", ac.GetTasks)
+			taskReadRoutes.GET("/tasks/:id", ac.GetTaskByID)
+			taskReadRoutes.GET("/tasks/export", ac.ExportTasks)
+			taskReadRoutes.GET("/search", ac.SearchTasks)
+		}
+		// API tokens cannot manage the account or other credentials
 		userRoutes := api.Group("")
-		userRoutes.Use(infrastructure.AuthMiddleware(uu, domain.RoleUser))
+		userRoutes.Use(infrastructure.AuthMiddleware(uu, js, ""), apiLimit)
 		{
-			userRoutes.GET("/tasks", ac.GetTasks)
-			userRoutes.GET("/tasks/:id", ac.GetTaskByID)
+			userRoutes.GET("/me", ac.GetProfile)
+			userRoutes.PATCH("/me", ac.UpdateProfile)
+			userRoutes.GET("/me/permissions", rc.GetMyPermissions)
+			userRoutes.POST("/me/calendar", can(domain.PermTaskRead), ac.CreateCalendarToken)
+			userRoutes.DELETE("/me/calendar", ac.RevokeCalendarToken)
+			userRoutes.POST("/me/mfa", ac.StartMFAEnrollment)
+			userRoutes.POST("/me/mfa/confirm", ac.ConfirmMFAEnrollment)
+			userRoutes.POST("/me/mfa/recovery-codes", ac.RegenerateRecoveryCodes)
+			userRoutes.DELETE("/me/mfa", ac.DisableMFA)
+			userRoutes.POST("/me/password", ac.ChangePassword)
+			userRoutes.POST("/me/identities/oidc", sc.LinkIdentity)
+			userRoutes.GET("/me/tokens", ac.ListAPITokens)
+			userRoutes.POST("/me/tokens", ac.CreateAPIToken)
+			userRoutes.DELETE("/me/tokens/:id", ac.RevokeAPIToken)
+
+			userRoutes.GET("/workspaces", wc.ListWorkspaces)
+			userRoutes.POST("/workspaces", idempotent, wc.CreateWorkspace)
+			userRoutes.POST("/workspaces/join", wc.JoinWorkspace)
+			userRoutes.POST("/workspaces/:id/switch", wc.SwitchWorkspace)
+
+			userRoutes.GET("/views", vc.GetViews)
+			userRoutes.POST("/views", idempotent, vc.CreateView)
+			userRoutes.GET("/views/:id", vc.GetView)
+			userRoutes.PUT("/views/:id", vc.UpdateView)
+			userRoutes.DELETE("/views/:id", vc.DeleteView)
+			userRoutes.GET("/views/:id/tasks", can(domain.PermTaskRead), vc.GetViewTasks)
 		}
 	}
 
@@ -0,0 +1,242 @@
+package router_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"task-manager/delivery/controllers"
+	"task-manager/delivery/router"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	repoMocks "task-manager/repositories/mocks"
+	"task-manager/usecases"
+	"task-manager/usecases/mocks"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type RouterTestSuite struct {
+	suite.Suite
+	router *gin.Engine
+}
+
+func (s *RouterTestSuite) SetupTest() {
+	s.setup(nil)
+}
+
+// setup builds the router with the rate limits of rl.
+func (s *RouterTestSuite) setup(rl *infrastructure.RateLimiter) {
+	gin.SetMode(gin.TestMode)
+
+	uu := new(mocks.UserUsecase)
+	ac := controllers.NewAppController(new(mocks.TaskUsecase), uu)
+	vc := controllers.NewViewController(nil)
+	sc := controllers.NewSSOController(nil)
+	ru := new(mocks.RoleUsecase)
+	rc := controllers.NewRoleController(ru)
+	uc := controllers.NewUserController(new(mocks.UserAdminUsecase))
+	wc := controllers.NewWorkspaceController(new(mocks.WorkspaceUsecase))
+	keys, err := infrastructure.NewKeyring(nil, infrastructure.DefaultKeyringConfig)
+	s.Require().NoError(err)
+	s.router = router.SetupRouter(ac, vc, sc, rc, uc, wc, uu, ru, infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer), rl, nil)
+}
+
+func TestRouter(t *testing.T) {
+	suite.Run(t, new(RouterTestSuite))
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+
+func (s *RouterTestSuite) specPaths() map[string]map[string]any {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &spec))
+	s.Require().Equal("3.1.0", spec.OpenAPI)
+	return spec.Paths
+}
+
+func (s *RouterTestSuite) TestEveryRouteIsDocumented() {
+	paths := s.specPaths()
+
+	for _, route := range s.router.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		_, ok := paths[path][strings.ToLower(route.Method)]
+		s.Assert().Truef(ok, "%s %s is missing from the OpenAPI document", route.Method, route.Path)
+	}
+}
+
+func (s *RouterTestSuite) TestEveryDocumentedOperationIsRouted() {
+	routed := make(map[string]bool)
+	for _, route := range s.router.Routes() {
+		routed[route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+
+	for path, operations := range s.specPaths() {
+		for method := range operations {
+			key := strings.ToUpper(method) + " " + path
+			s.Assert().Truef(routed[key], "%s is documented but not routed", key)
+		}
+	}
+}
+
+func (s *RouterTestSuite) TestDocsPage() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/docs", nil)
+	s.router.ServeHTTP(w, req)
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Contains(w.Body.String(), `fetch("openapi.json")`)
+}
+
+func (s *RouterTestSuite) TestPublicRoutesAreLimitedPerAddress() {
+	s.setup(infrastructure.NewRateLimiter(infrastructure.NewMemoryRateLimitStore(), map[string]domain.RateLimit{
+		infrastructure.RateLimitAuth: {Requests: 2, Period: time.Minute},
+	}))
+	post := func(path, addr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = addr
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/login", "203.0.113.7:1234")
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+	s.Assert().Equal("2", w.Header().Get("RateLimit-Limit"))
+	s.Assert().Equal("1", w.Header().Get("RateLimit-Remaining"))
+	s.Assert().Equal(http.StatusBadRequest, post("/register", "203.0.113.7:1234").Code)
+
+	// the routes share the bucket of the address
+	w = post("/password/forgot", "203.0.113.7:1234")
+	s.Require().Equal(http.StatusTooManyRequests, w.Code)
+	s.Assert().Contains(w.Body.String(), `"code":"rate_limited"`)
+	s.Assert().Equal("30", w.Header().Get("Retry-After"))
+	s.Assert().Equal("0", w.Header().Get("RateLimit-Remaining"))
+
+	s.Assert().Equal(http.StatusBadRequest, post("/login", "198.51.100.1:1234").Code)
+}
+
+func (s *RouterTestSuite) TestForwardedForIsNotTrusted() {
+	s.setup(infrastructure.NewRateLimiter(infrastructure.NewMemoryRateLimitStore(), map[string]domain.RateLimit{
+		infrastructure.RateLimitAuth: {Requests: 1, Period: time.Minute},
+	}))
+	post := func(forwardedFor string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/login", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "203.0.113.7:1234"
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Require().Equal(http.StatusBadRequest, post("198.51.100.1").Code)
+
+	// a client cannot get a new bucket by claiming another address
+	s.Assert().Equal(http.StatusTooManyRequests, post("198.51.100.2").Code)
+}
+
+// memoryUsers stands in for the user repository: the check made by Create
+// is atomic, the one made by CheckUsername is not. That the unique index
+// really works so is shown by TestCreate_ConcurrentUsernames in the
+// repositories package, against MongoDB.
+type memoryUsers struct {
+	usecases.UserRepository
+	mu        sync.Mutex
+	usernames map[string]bool
+}
+
+func (m *memoryUsers) CheckUsername(username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usernames[username], nil
+}
+
+func (m *memoryUsers) Create(user *domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.usernames[user.Username] {
+		return errs.ErrUsernameExists
+	}
+	m.usernames[user.Username] = true
+	user.ID = fmt.Sprintf("u%d", len(m.usernames))
+	return nil
+}
+
+// TestRegister_ConcurrentDuplicatesConflict checks that a username taken
+// while a registration is under way answers 409, not 500, and that the
+// other registrations go through.
+func TestRegister_ConcurrentDuplicatesConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := &memoryUsers{usernames: map[string]bool{}}
+	workspaces := new(repoMocks.WorkspaceRepository)
+	workspaces.On("Create", mock.AnythingOfType("*domain.Workspace")).Return(nil)
+	workspaces.On("Delete", mock.Anything).Return(nil)
+	uu := usecases.NewUserUsecase(users, workspaces, nil, nil, nil, nil, nil,
+		infrastructure.NewBcryptService(), nil, nil, nil, nil, usecases.DefaultAuthConfig)
+	keys, err := infrastructure.NewKeyring(nil, infrastructure.DefaultKeyringConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouter(controllers.NewAppController(nil, uu), nil, nil, nil, nil, nil, uu, nil,
+		infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer), nil, nil)
+
+	// half of the requests race for one username, the others take their own
+	const requests = 20
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			username := "alice"
+			if i%2 == 1 {
+				username = fmt.Sprintf("bob%d", i)
+			}
+			body := fmt.Sprintf(`{"username": %q, "password": "correct-horse-7"}`, username)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}()
+	}
+	wg.Wait()
+
+	created, conflicts := 0, 0
+	for i, code := range codes {
+		switch {
+		case i%2 == 1 && code != http.StatusCreated:
+			t.Errorf("request %d for its own username: got %d, want 201", i, code)
+		case i%2 == 0 && code == http.StatusCreated:
+			created++
+		case i%2 == 0 && code == http.StatusConflict:
+			conflicts++
+		case i%2 == 0:
+			t.Errorf("request %d for alice: got %d, want 201 or 409", i, code)
+		}
+	}
+	if created != 1 || conflicts != requests/2-1 {
+		t.Errorf("alice was registered %d times with %d conflicts, want once with %d", created, conflicts, requests/2-1)
+	}
+	if len(users.usernames) != requests/2+1 {
+		t.Errorf("%d users were created, want %d", len(users.usernames), requests/2+1)
+	}
+}
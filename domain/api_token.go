@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Scopes limit what an API token can do on top of its owner's role.
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+	ScopeAdmin      = "admin"
+)
+
+// APIToken is a long-lived credential for scripts, sent instead of a JWT.
+// Only the SHA-256 of the token is kept. It acts in the workspace it was
+// created in.
+type APIToken struct {
+	ID          string
+	UserID      string
+	WorkspaceID string
+	Name        string
+	Scopes      []string
+	TokenHash   string
+	CreatedAt   time.Time
+	// ExpiresAt is zero for tokens that do not expire.
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+}
+
+// Grants tells whether the token may be used where scope is required. The
+// admin scope grants every scope and tasks:write grants tasks:read.
+func (t *APIToken) Grants(scope string) bool {
+	if slices.Contains(t.Scopes, ScopeAdmin) || slices.Contains(t.Scopes, scope) {
+		return true
+	}
+	return scope == ScopeTasksRead && slices.Contains(t.Scopes, ScopeTasksWrite)
+}
+
+// Expired tells whether the token can no longer be used at now.
+func (t *APIToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
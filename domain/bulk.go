@@ -0,0 +1,32 @@
+package domain
+
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+const (
+	BulkStatusOK      = "ok"
+	BulkStatusFailed  = "failed"
+	BulkStatusSkipped = "skipped"
+)
+
+// BulkOperation is one item of a bulk request. ID is used by update and
+// delete, Task by create and update.
+type BulkOperation struct {
+	Op   string
+	ID   string
+	Task Task
+}
+
+// BulkResult reports what happened to the operation at Index. Skipped
+// operations were valid but not applied because the request was rolled back.
+type BulkResult struct {
+	Index  int
+	Op     string
+	ID     string
+	Status string
+	Err    error
+	Task   *Task
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	FilterAnd     = "and"
+	FilterOr      = "or"
+	FilterNot     = "not"
+	FilterCompare = "compare"
+)
+
+// Comparison operators. OpContains means "has a label" for label and
+// "contains the text" for title and description.
+const (
+	OpEq       = "="
+	OpNe       = "!="
+	OpLt       = "<"
+	OpLte      = "<="
+	OpGt       = ">"
+	OpGte      = ">="
+	OpContains = ":"
+)
+
+// Filterable task fields.
+const (
+	FieldStatus      = "status"
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldDue         = "due"
+	FieldLabel       = "label"
+	FieldOverdue     = "overdue"
+)
+
+// Filter is a parsed task filter expression. And/Or/Not nodes only use
+// Children; compare nodes only use Field, Op and Value. Value holds a
+// string, a time.Time (for due) or a bool (for overdue).
+type Filter struct {
+	Kind     string
+	Children []*Filter
+	Field    string
+	Op       string
+	Value    any
+}
+
+// View is a named, saved filter query. It belongs to the workspace it was
+// created in, and only runs on the tasks there.
+type View struct {
+	ID          string
+	WorkspaceID string
+	Name        string
+	Query       string
+	OwnerID     string
+	SharedWith  []string
+	CreatedAt   time.Time
+}
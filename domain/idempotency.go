@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord remembers a request made with an idempotency key, so
+// that retries of it get its response instead of repeating it. Key is
+// scoped to the user who sent the request, and Fingerprint tells whether a
+// retry is the same request.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	// Response is nil while the request is still being served.
+	Response  *IdempotentResponse
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// IdempotentResponse is the response replayed to retries.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
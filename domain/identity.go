@@ -0,0 +1,40 @@
+package domain
+
+// ExternalIdentity links a user to their account at an OpenID Connect
+// provider. Subject is only unique per Issuer.
+type ExternalIdentity struct {
+	Issuer  string
+	Subject string
+}
+
+// IdentityClaims are the verified claims of an ID token.
+type IdentityClaims struct {
+	Issuer  string
+	Subject string
+	Claims  map[string]any
+}
+
+// SSORequest is kept by the browser between sending the user to the
+// identity provider and the callback. LinkUserID is set when an existing
+// user is linking an identity rather than logging in.
+type SSORequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	LinkUserID   string
+}
+
+// SSOStart is where to send the user to log in, and the sealed SSORequest
+// to keep until the callback.
+type SSOStart struct {
+	AuthURL string
+	Request string
+}
+
+// SSOResult is the outcome of a callback: a token for a login, or the
+// identity that was linked.
+type SSOResult struct {
+	Token    string
+	Linked   bool
+	Identity ExternalIdentity
+}
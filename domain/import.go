@@ -0,0 +1,41 @@
+package domain
+
+const (
+	ImportRowCreated   = "created"
+	ImportRowValid     = "valid"
+	ImportRowDuplicate = "duplicate"
+	ImportRowFailed    = "failed"
+)
+
+// ImportRow is one record of an uploaded file, keyed by the file's own
+// column names. Line is the position in the file used in error reports.
+// Errors holds problems the file reader already found in the row.
+type ImportRow struct {
+	Line   int
+	Values map[string]string
+	Errors []string
+}
+
+type ImportOptions struct {
+	// Mapping maps file columns to task fields (title, description,
+	// due_date, status, labels). Columns that already use a field name do
+	// not need to be mapped.
+	Mapping map[string]string
+	DryRun  bool
+}
+
+type ImportRowResult struct {
+	Line   int
+	Status string
+	TaskID string
+	Errors []string
+}
+
+type ImportReport struct {
+	Total      int
+	Created    int
+	Valid      int
+	Duplicates int
+	Failed     int
+	Rows       []*ImportRowResult
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// Invitation lets someone register as a member of a workspace, with a role
+// chosen by the admin who invited them. Only the SHA-256 of the token in
+// the invitation link is kept, and the token works once.
+//
+// Invitations sent to an email address the registrant asked for have no
+// workspace; whoever registers with them gets their own, and the address.
+type Invitation struct {
+	ID          string
+	WorkspaceID string
+	Role        string
+	Email       string
+	// CreatedBy is the ID of the admin who issued the invitation.
+	CreatedBy string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// LoginAttempts counts the recent failed logins for one key, which is
+// either an account or a client IP address.
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
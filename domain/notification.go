@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	NotificationDueReminder = "due_reminder"
+	NotificationOverdue     = "overdue"
+	NotificationEscalation  = "escalation"
+
+	NotificationPasswordReset = "password_reset"
+	NotificationInvitation    = "invitation"
+)
+
+// Notification is an event emitted by the app. An empty Recipients list means
+// the notification is not addressed to anyone in particular.
+type Notification struct {
+	Kind       string
+	Recipients []string
+	TaskID     string
+	Subject    string
+	Body       string
+	CreatedAt  time.Time
+}
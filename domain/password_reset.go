@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// PasswordReset is an outstanding request to reset a forgotten password.
+// Only the SHA-256 of the token sent to the user is kept.
+type PasswordReset struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+}
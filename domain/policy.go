@@ -0,0 +1,61 @@
+package domain
+
+// Policy effects.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Condition operators. Attributes are lists of strings; a single value is
+// a list of one.
+const (
+	// OpIn holds when the attribute has one of the values.
+	OpIn = "in"
+	// OpNotIn holds when the attribute has none of the values.
+	OpNotIn = "not_in"
+	// OpSubset holds when every value of the attribute is one of the
+	// values, e.g. when only some fields change.
+	OpSubset = "subset"
+)
+
+// Policy is an attribute-based rule about what users may do with tasks,
+// beyond what their role allows. A policy applies to a request for one of
+// its Actions when every Target condition holds. A deny policy that
+// applies denies the request if its Conditions hold. If any allow policy
+// applies, the Conditions of one of them must hold. Requests no policy
+// applies to are left to the roles.
+type Policy struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	Effect      string            `json:"effect"`
+	Actions     []string          `json:"actions"`
+	Target      []PolicyCondition `json:"target"`
+	Conditions  []PolicyCondition `json:"conditions"`
+}
+
+// PolicyCondition compares an attribute, such as "subject.role" or
+// "resource.labels", with Values, or with the attribute named by Ref.
+type PolicyCondition struct {
+	Attribute string   `json:"attribute"`
+	Operator  string   `json:"operator"`
+	Values    []string `json:"values,omitempty"`
+	Ref       string   `json:"ref,omitempty"`
+}
+
+// AccessDecision tells whether a user may do something with a task, and
+// why.
+type AccessDecision struct {
+	Allowed bool
+	Reason  string
+	// Policies lists how each policy for the action was evaluated.
+	Policies []PolicyResult
+}
+
+type PolicyResult struct {
+	ID      string
+	Effect  string
+	Applies bool
+	Matched bool
+	// Failed describes the first condition that did not hold.
+	Failed string
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit is a token bucket: it holds up to Requests tokens, is refilled
+// with Requests tokens every Period, and every request takes one. Clients
+// can therefore spend a full bucket at once and then continue at the rate
+// it refills.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled tells whether the limit applies at all.
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// PerSecond is how many tokens the bucket gets back each second.
+func (l RateLimit) PerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// TokenBucket is the state of a bucket at UpdatedAt.
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// RateLimitStatus is the outcome of taking a token.
+type RateLimitStatus struct {
+	Allowed bool
+	// Remaining is how many whole tokens are left.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, if there was none.
+	RetryAfter time.Duration
+}
+
+// Take refills bucket up to now and takes a token from it if there is one.
+// A bucket that was never used is full.
+func (l RateLimit) Take(bucket *TokenBucket, now time.Time) RateLimitStatus {
+	if bucket.UpdatedAt.IsZero() {
+		bucket.Tokens = float64(l.Requests)
+	} else if elapsed := now.Sub(bucket.UpdatedAt); elapsed > 0 {
+		bucket.Tokens = math.Min(float64(l.Requests), bucket.Tokens+elapsed.Seconds()*l.PerSecond())
+	}
+	bucket.UpdatedAt = now
+
+	allowed := bucket.Tokens >= 1
+	if allowed {
+		bucket.Tokens--
+	}
+	return l.Status(allowed, bucket.Tokens)
+}
+
+// Status describes a bucket left with tokens after a request was allowed
+// or not.
+func (l RateLimit) Status(allowed bool, tokens float64) RateLimitStatus {
+	status := RateLimitStatus{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     l.refill(float64(l.Requests) - tokens),
+	}
+	if !allowed {
+		status.RetryAfter = l.refill(1 - tokens)
+	}
+	return status
+}
+
+// refill returns how long it takes to get tokens back.
+func (l RateLimit) refill(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.PerSecond() * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Permissions name what a user may do. Roles grant them.
+const (
+	PermTaskRead       = "task.read"
+	PermTaskCreate     = "task.create"
+	PermTaskUpdate     = "task.update"
+	PermTaskDelete     = "task.delete"
+	PermTaskImport     = "task.import"
+	PermUserPromote    = "user.promote"
+	PermUserUnlock     = "user.unlock"
+	PermUserManage     = "user.manage"
+	PermSettingsManage = "settings.manage"
+	PermRoleManage     = "role.manage"
+)
+
+// Permissions lists every permission. The admin role always has all of them.
+var Permissions = []string{
+	PermTaskRead,
+	PermTaskCreate,
+	PermTaskUpdate,
+	PermTaskDelete,
+	PermTaskImport,
+	PermUserPromote,
+	PermUserUnlock,
+	PermUserManage,
+	PermSettingsManage,
+	PermRoleManage,
+}
+
+// IsAdminPermission tells whether permission is about managing the
+// workspace rather than its tasks. Roles with any of these are admin roles,
+// whose members may be required to enable MFA.
+func IsAdminPermission(permission string) bool {
+	switch permission {
+	case PermUserPromote, PermUserUnlock, PermUserManage, PermSettingsManage, PermRoleManage:
+		return true
+	}
+	return false
+}
+
+// Role is a named set of permissions. Each workspace has its own roles,
+// and each member has one of them, by name in Membership.Role. The built-in
+// admin and user roles cannot be deleted, and the admin role cannot be
+// changed.
+type Role struct {
+	WorkspaceID string
+	Name        string
+	Description string
+	Permissions []string
+	BuiltIn     bool
+	UpdatedAt   time.Time
+}
+
+func (r *Role) Has(permission string) bool {
+	return slices.Contains(r.Permissions, permission)
+}
+
+// BuiltInRoles returns the roles every workspace starts with. Users can
+// read tasks, and admins can do everything.
+func BuiltInRoles() []*Role {
+	return []*Role{
+		{Name: RoleAdmin, Description: "Full access", Permissions: slices.Clone(Permissions), BuiltIn: true},
+		{Name: RoleUser, Description: "Reads tasks", Permissions: []string{PermTaskRead}, BuiltIn: true},
+	}
+}
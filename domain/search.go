@@ -0,0 +1,23 @@
+package domain
+
+// SearchQuery is a parsed free-text query. Terms match whole words (with
+// stemming where the store supports it), Phrases must appear verbatim and
+// Prefixes match any word starting with them.
+type SearchQuery struct {
+	Terms    []string
+	Phrases  []string
+	Prefixes []string
+	Limit    int
+}
+
+func (q SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && len(q.Prefixes) == 0
+}
+
+// SearchResult is a task matched by a search together with its relevance
+// score and highlighted snippets keyed by field name.
+type SearchResult struct {
+	Task       *Task
+	Score      float64
+	Highlights map[string]string
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// SigningKey is a key the app signs its tokens with. It is published from
+// when it is created, signs tokens from ActivatesAt until RetiresAt, and is
+// kept to verify them until ExpiresAt.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	// PrivateKey is the PKCS #8, ASN.1 DER form of the key.
+	PrivateKey  []byte
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiresAt   time.Time
+	ExpiresAt   time.Time
+}
@@ -12,8 +12,17 @@ const (
 
 type Task struct {
 	ID          string
+	WorkspaceID string
 	Title       string
 	Description string
 	DueDate     time.Time
 	Status      string
+	Labels      []string
+	// Assignee is the ID of the user the task is assigned to, if any.
+	Assignee string
+
+	// Set by the reminder scheduler, never by clients.
+	Overdue       bool
+	RemindersSent []time.Duration
+	EscalatedAt   time.Time
 }
@@ -10,5 +10,122 @@ type User struct {
 	Username     string
 	Password     string
 	PasswordHash string
-	Role         string
+	// WorkspaceID is the workspace the user is acting in, the one Role and
+	// Disabled are taken from. See Activate.
+	WorkspaceID string
+	Role        string
+	Memberships []Membership
+	MFA         MFA
+	// TokenVersion is embedded in every token issued to the user and goes
+	// up when the password changes, which signs out all existing sessions.
+	TokenVersion int
+	// Identities are the accounts at identity providers the user can log
+	// in with.
+	Identities []ExternalIdentity
+	// Disabled users cannot use the workspace, and their tokens for it
+	// stop working.
+	Disabled bool
+	Profile  Profile
+}
+
+// Membership returns the user's membership of a workspace, or nil.
+func (u *User) Membership(workspaceID string) *Membership {
+	for i := range u.Memberships {
+		if u.Memberships[i].WorkspaceID == workspaceID {
+			return &u.Memberships[i]
+		}
+	}
+	return nil
+}
+
+// Activate makes workspaceID the user's workspace, taking Role and Disabled
+// from the membership. It reports false if the user is not a member.
+func (u *User) Activate(workspaceID string) bool {
+	membership := u.Membership(workspaceID)
+	if membership == nil {
+		return false
+	}
+	u.WorkspaceID = workspaceID
+	u.Role = membership.Role
+	u.Disabled = membership.Disabled
+	return true
+}
+
+// Profile is what users say about themselves. TimeZone is an IANA name
+// such as "Europe/Berlin" and Locale a BCP 47 tag such as "de-DE".
+type Profile struct {
+	DisplayName string
+	Email       string
+	// EmailVerified tells whether the user proved they own Email, which
+	// lets them log in with it.
+	EmailVerified bool
+	TimeZone      string
+	Locale        string
+}
+
+// ProfileUpdate holds the profile fields to change. Nil fields are left
+// alone and empty ones are cleared.
+type ProfileUpdate struct {
+	DisplayName *string
+	Email       *string
+	TimeZone    *string
+	Locale      *string
+}
+
+// UserQuery selects a page of the members of a workspace. Pages count
+// from 1.
+type UserQuery struct {
+	WorkspaceID string
+	// Search matches usernames containing it, ignoring case.
+	Search  string
+	Role    string
+	Page    int
+	PerPage int
+}
+
+// UserPage is a page of users, and how many match the query in total.
+type UserPage struct {
+	Users   []*User
+	Total   int64
+	Page    int
+	PerPage int
+}
+
+// UserUpdate holds what an admin changes about a user. Nil fields are
+// left alone.
+type UserUpdate struct {
+	Username *string
+	Disabled *bool
+}
+
+// MFA is a user's TOTP second factor.
+type MFA struct {
+	Enabled bool
+	// Secret is the base32 TOTP secret once enrollment is confirmed, and
+	// PendingSecret the one being enrolled until then.
+	Secret        string
+	PendingSecret string
+	// LastStep is the most recent TOTP time step used to log in, so a code
+	// cannot be replayed.
+	LastStep           int64
+	RecoveryCodeHashes []string
+}
+
+// MFAEnrollment is what an authenticator app needs to start generating
+// codes. ProvisioningURI is an otpauth:// URI meant to be shown as a QR code.
+type MFAEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// LoginResult is the outcome of a correct password. Users with MFA get a
+// ChallengeToken to exchange for the Token together with a code.
+type LoginResult struct {
+	Token          string
+	ChallengeToken string
+}
+
+// SecuritySettings are the authentication rules admins can change at runtime.
+type SecuritySettings struct {
+	RequireAdminMFA bool
 }
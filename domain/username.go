@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Canonical returns the form usernames and verified email addresses are
+// compared in: compatibility characters are replaced by the ones they stand
+// for and case is folded, so "Alice", "alice" and "ａｌｉｃｅ" are the same
+// name. Normalizing again after folding keeps the result stable.
+func Canonical(name string) string {
+	// casers keep state, so they cannot be shared between goroutines
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(name)))
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// Workspace is a team's own space. Its tasks, roles and settings are kept
+// apart from those of every other workspace.
+type Workspace struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// Membership is a user's place in a workspace. The role and being disabled
+// only apply there.
+type Membership struct {
+	WorkspaceID string
+	Role        string
+	Disabled    bool
+	// CalendarTokenHash is the SHA-256 of the token in the user's iCal feed
+	// URL for the workspace, empty when no feed has been created.
+	CalendarTokenHash string
+	JoinedAt          time.Time
+}
@@ -2,15 +2,30 @@ package errs
 
 import (
 	"errors"
+	"net/http"
+	"strings"
+	"time"
 )
 
+// TypeBase prefixes the problem type URI of every error. The rest of the
+// URI is the error's code with dashes, e.g. /problems/task-not-found.
+const TypeBase = "/problems/"
+
+// AppError is an error that knows how it is reported to clients: Code is a
+// stable machine-readable identifier and Status the HTTP status code.
+// Errors are compared by Code, so a copy made with Wrap still matches its
+// sentinel with errors.Is.
 type AppError struct {
-	Code int
-	Msg  string
-	Err  error
+	Code   string
+	Status int
+	Msg    string
+	Err    error
 }
 
 func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
 	return e.Msg
 }
 
@@ -18,16 +33,154 @@ func (e *AppError) Unwrap() error {
 	return e.Err
 }
 
-func New(code int, msg string, err error) *AppError {
-	return &AppError{code, msg, err}
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// Type returns the RFC 7807 problem type URI of the error.
+func (e *AppError) Type() string {
+	return TypeBase + strings.ReplaceAll(e.Code, "_", "-")
+}
+
+// New defines an error kind.
+func New(code string, status int, msg string) *AppError {
+	return &AppError{Code: code, Status: status, Msg: msg}
+}
+
+// Wrap returns a copy of kind caused by err.
+func Wrap(kind *AppError, err error) *AppError {
+	return &AppError{Code: kind.Code, Status: kind.Status, Msg: kind.Msg, Err: err}
 }
 
 var (
-	ErrUserNotFound      = errors.New("user is not found")
-	ErrTaskNotFound      = errors.New("task in not found")
-	ErrUnexpected        = errors.New("unexpected error")
-	ErrInvalidUserId     = errors.New("invalid user id")
-	ErrInvalidTaskId     = errors.New("invalid task id")
-	ErrIncorrectPassword = errors.New("incorrect password")
-	ErrUsernameExists    = errors.New("username is already exists")
+	ErrUserNotFound      = New("user_not_found", http.StatusNotFound, "user is not found")
+	ErrTaskNotFound      = New("task_not_found", http.StatusNotFound, "task is not found")
+	ErrUnexpected        = New("internal_error", http.StatusInternalServerError, "unexpected error")
+	ErrInvalidUserId     = New("invalid_user_id", http.StatusBadRequest, "invalid user id")
+	ErrInvalidTaskId     = New("invalid_task_id", http.StatusBadRequest, "invalid task id")
+	ErrIncorrectPassword = New("incorrect_password", http.StatusUnauthorized, "incorrect password")
+	ErrUsernameExists    = New("username_exists", http.StatusConflict, "username already exists")
+	ErrEmptySearchQuery  = New("empty_search_query", http.StatusBadRequest, "search query is empty")
+	ErrInvalidQuery      = New("invalid_query", http.StatusBadRequest, "invalid query")
+	ErrViewNotFound      = New("view_not_found", http.StatusNotFound, "view is not found")
+	ErrInvalidViewId     = New("invalid_view_id", http.StatusBadRequest, "invalid view id")
+	ErrForbidden         = New("forbidden", http.StatusForbidden, "operation is not allowed")
+	ErrAccessDenied      = New("access_denied", http.StatusForbidden, "access denied by policy")
+
+	ErrInvalidBulkRequest      = New("invalid_bulk_request", http.StatusBadRequest, "invalid bulk request")
+	ErrBulkAborted             = New("bulk_aborted", http.StatusUnprocessableEntity, "bulk request aborted, no changes were applied")
+	ErrTransactionsUnsupported = New("transactions_unsupported", http.StatusNotImplemented, "all-or-nothing mode requires a database that supports transactions")
+
+	ErrInvalidImport = New("invalid_import", http.StatusBadRequest, "invalid import")
+
+	ErrInvalidCalendarToken = New("calendar_not_found", http.StatusNotFound, "calendar feed is not found")
+
+	ErrUnauthenticated  = New("unauthenticated", http.StatusUnauthorized, "missing or malformed token")
+	ErrInvalidToken     = New("invalid_token", http.StatusUnauthorized, "invalid token")
+	ErrTokenExpired     = New("token_expired", http.StatusUnauthorized, "token has expired")
+	ErrInsufficientRole = New("insufficient_role", http.StatusForbidden, "insufficient permissions")
+	ErrRouteNotFound    = New("route_not_found", http.StatusNotFound, "no such endpoint")
+	ErrValidation       = New("validation_failed", http.StatusBadRequest, "validation failed")
+
+	ErrAccountLocked        = New("account_locked", http.StatusLocked, "account is temporarily locked after too many failed logins")
+	ErrTooManyLoginFailures = New("too_many_login_failures", http.StatusTooManyRequests, "too many failed logins from this address")
+	ErrRateLimited          = New("rate_limited", http.StatusTooManyRequests, "too many requests")
+
+	ErrInvalidMFACode        = New("invalid_mfa_code", http.StatusUnauthorized, "invalid two-factor code")
+	ErrInvalidMFAChallenge   = New("invalid_mfa_challenge", http.StatusUnauthorized, "invalid or expired two-factor challenge")
+	ErrMFAAlreadyEnabled     = New("mfa_already_enabled", http.StatusConflict, "two-factor authentication is already enabled")
+	ErrMFANotEnabled         = New("mfa_not_enabled", http.StatusConflict, "two-factor authentication is not enabled")
+	ErrMFAEnrollmentRequired = New("mfa_enrollment_required", http.StatusForbidden, "admins must enable two-factor authentication")
+
+	ErrInvalidResetToken = New("invalid_reset_token", http.StatusBadRequest, "invalid or expired password reset token")
+
+	ErrSSONotConfigured = New("sso_not_configured", http.StatusNotFound, "single sign-on is not configured")
+	ErrInvalidSSOState  = New("invalid_sso_state", http.StatusBadRequest, "invalid or expired single sign-on request, start again")
+	ErrSSOFailed        = New("sso_failed", http.StatusUnauthorized, "single sign-on failed")
+	ErrIdentityConflict = New("identity_conflict", http.StatusConflict, "identity cannot be linked to this account")
+
+	ErrAPITokenNotFound  = New("api_token_not_found", http.StatusNotFound, "API token is not found")
+	ErrInsufficientScope = New("insufficient_scope", http.StatusForbidden, "API token lacks the scope for this operation")
+	ErrTooManyAPITokens  = New("too_many_api_tokens", http.StatusConflict, "too many API tokens, revoke some first")
+
+	ErrRoleNotFound = New("role_not_found", http.StatusNotFound, "role is not found")
+	ErrRoleExists   = New("role_exists", http.StatusConflict, "role already exists")
+	ErrRoleInUse    = New("role_in_use", http.StatusConflict, "role is assigned to users")
+	ErrBuiltInRole  = New("built_in_role", http.StatusConflict, "built-in roles cannot be changed or deleted")
+
+	ErrAccountDisabled       = New("account_disabled", http.StatusForbidden, "account is disabled")
+	ErrLastAdmin             = New("last_admin", http.StatusConflict, "the last admin cannot be demoted, disabled or deleted")
+	ErrAdminChangeInProgress = New("admin_change_in_progress", http.StatusConflict, "another admin is being changed, try again")
+
+	ErrWorkspaceNotFound  = New("workspace_not_found", http.StatusNotFound, "workspace is not found")
+	ErrInvalidWorkspaceId = New("invalid_workspace_id", http.StatusBadRequest, "invalid workspace id")
+	ErrNoWorkspace        = New("no_workspace", http.StatusForbidden, "you are not a member of any workspace")
+	ErrAlreadyMember      = New("already_member", http.StatusConflict, "user is already a member of the workspace")
+
+	ErrRegistrationClosed    = New("registration_closed", http.StatusForbidden, "registration is closed")
+	ErrInvitationRequired    = New("invitation_required", http.StatusForbidden, "registration needs an invitation")
+	ErrEmailDomainNotAllowed = New("email_domain_not_allowed", http.StatusForbidden, "registration is not open to this email domain")
+	ErrInvalidInvitation     = New("invalid_invitation", http.StatusBadRequest, "invalid, used or expired invitation")
+	ErrInvitationNotFound    = New("invitation_not_found", http.StatusNotFound, "invitation is not found")
+	ErrAlreadyBootstrapped   = New("already_bootstrapped", http.StatusConflict, "the app already has users")
+	ErrEmailExists           = New("email_exists", http.StatusConflict, "email address is verified by another account")
+
+	ErrIdempotencyKeyInUse  = New("idempotency_key_in_use", http.StatusConflict, "a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused = New("idempotency_key_reused", http.StatusUnprocessableEntity, "idempotency key was already used for a different request")
 )
+
+// FieldError describes one invalid field of a request. Code is the rule
+// that failed, such as "required" or "max".
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError lists every invalid field of a request rather than just
+// the first one. It is reported as ErrValidation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		if f.Field == "" {
+			msgs = append(msgs, f.Message)
+		} else {
+			msgs = append(msgs, f.Field+" "+f.Message)
+		}
+	}
+	return ErrValidation.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// RetryError tells the client how long Err will keep applying, for example
+// until a lockout ends. It is reported as Err with a Retry-After header.
+type RetryError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Kind returns the AppError that decides how err is reported, or
+// ErrUnexpected for errors that are not (and do not wrap) an AppError.
+func Kind(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrUnexpected
+}
@@ -2,67 +2,156 @@ package infrastructure
 
 import (
 	"errors"
-	"net/http"
+	"fmt"
+	"slices"
+	"strings"
 	"task-manager/domain"
+	"task-manager/errs"
 	"task-manager/usecases"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware creates a gin.HandlerFunc for JWT authentication and authorization.
-func AuthMiddleware(userUsecase usecases.UserUsecase, requiredRole string) gin.HandlerFunc {
+// AuthMiddleware creates a gin.HandlerFunc for JWT authentication. It puts
+// the user in the context; RequirePermission then authorizes each route.
+// API tokens are accepted too on routes with a scope, if they have it;
+// routes without one, such as managing API tokens, need a JWT.
+func AuthMiddleware(userUsecase usecases.UserUsecase, jwtService *JWTServiceV5, scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
 		if authHeader == "" || len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or malformed token"})
+			abortWithError(c, errs.ErrUnauthenticated)
 			return
 		}
 
 		tokenString := authHeader[7:]
 
-		claims := CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
-			return []byte(JWTSecret), nil
-		})
-
+		var user *domain.User
+		var err error
+		if strings.HasPrefix(tokenString, usecases.APITokenPrefix) {
+			user, err = authenticateAPIToken(c, userUsecase, tokenString, scope)
+		} else {
+			user, err = authenticateJWT(userUsecase, jwtService, tokenString)
+		}
 		if err != nil {
-			if errors.Is(err, jwt.ErrTokenExpired) {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has expired"})
-			} else {
-				// log.Panic(err.Error())
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			}
+			abortWithError(c, err)
 			return
 		}
-
-		if !token.Valid {
-			// log.Panic("just token being invalid after passing parsing")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		if user.Disabled {
+			abortWithError(c, errs.ErrAccountDisabled)
 			return
 		}
 
-		// look out this error
-		user, err := userUsecase.GetUserByID(claims.UserID)
+		// Set user in context for downstream handlers
+		c.Set("user", user)
+		c.Next()
+	}
+}
 
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+// CalendarFeedAuth authenticates GET ical/{token}.ics requests by the token
+// in the URL, since calendar clients cannot send a bearer token. Like
+// AuthMiddleware, it puts the user in the context for RequirePermission.
+func CalendarFeedAuth(userUsecase usecases.UserUsecase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutSuffix(c.Param("feed"), ".ics")
+		if !ok {
+			abortWithError(c, errs.ErrInvalidCalendarToken)
 			return
 		}
-		// Admins can access user routes, but not the other way around
-		if requiredRole == domain.RoleUser && user.Role != domain.RoleUser && user.Role != domain.RoleAdmin {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+
+		user, err := userUsecase.GetUserByCalendarToken(token)
+		if err != nil {
+			abortWithError(c, err)
 			return
 		}
-		// Only admins can access admin routes
-		if requiredRole == domain.RoleAdmin && user.Role != domain.RoleAdmin {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions, admin access required"})
+
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// RequirePermission lets users through whose role has every one of permissions,
+// and leaves them in the context as "permissions". It follows
+// AuthMiddleware. Users with an admin permission also need MFA for anything
+// beyond reading tasks if their workspace requires it; enrolling needs no
+// permission.
+func RequirePermission(userUsecase usecases.UserUsecase, roleUsecase usecases.RoleUsecase, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*domain.User)
+		granted, err := roleUsecase.Permissions(user)
+		if err != nil {
+			abortWithError(c, err)
 			return
 		}
+		for _, permission := range permissions {
+			if !slices.Contains(granted, permission) {
+				abortWithError(c, fmt.Errorf("%w: %s is required", errs.ErrInsufficientRole, permission))
+				return
+			}
+		}
 
-		// Set user in context for downstream handlers
-		c.Set("user", user)
+		admin := slices.ContainsFunc(granted, domain.IsAdminPermission)
+		if admin && slices.ContainsFunc(permissions, func(p string) bool { return p != domain.PermTaskRead }) {
+			required, err := userUsecase.MFAEnrollmentRequired(user)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			if required {
+				abortWithError(c, errs.ErrMFAEnrollmentRequired)
+				return
+			}
+		}
+
+		c.Set("permissions", granted)
 		c.Next()
 	}
 }
+
+func authenticateJWT(userUsecase usecases.UserUsecase, jwtService *JWTServiceV5, tokenString string) (*domain.User, error) {
+	claims, err := jwtService.ParseJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := userUsecase.GetUserByID(claims.UserID)
+	if err != nil {
+		// a valid token for a user that no longer exists is just as
+		// unusable as a forged one
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrInvalidUserId) {
+			err = errs.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if claims.TokenVersion != user.TokenVersion {
+		return nil, fmt.Errorf("%w: password was changed", errs.ErrInvalidToken)
+	}
+	// tokens from before workspaces have no workspace and need a new login
+	if !user.Activate(claims.WorkspaceID) {
+		return nil, fmt.Errorf("%w: not a member of the workspace", errs.ErrInvalidToken)
+	}
+	return user, nil
+}
+
+// authenticateAPIToken leaves the token in the context as "api_token".
+func authenticateAPIToken(c *gin.Context, userUsecase usecases.UserUsecase, tokenString, scope string) (*domain.User, error) {
+	user, token, err := userUsecase.AuthenticateAPIToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if scope == "" {
+		return nil, fmt.Errorf("%w: API tokens cannot be used here, log in instead", errs.ErrInsufficientScope)
+	}
+	if !token.Grants(scope) {
+		return nil, fmt.Errorf("%w: %s is required", errs.ErrInsufficientScope, scope)
+	}
+	c.Set("api_token", token)
+	return user, nil
+}
+
+// abortWithError stops the chain and leaves err for ErrorMiddleware to render.
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
@@ -1,6 +1,7 @@
 package infrastructure_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"task-manager/domain"
@@ -16,95 +17,354 @@ import (
 type AuthMiddlewareTestSuite struct {
 	suite.Suite
 	mockUserUsecase *mocks.UserUsecase
-	jwtService      infrastructure.JWTServiceV5
+	mockRoleUsecase *mocks.RoleUsecase
+	jwtService      *infrastructure.JWTServiceV5
 	router          *gin.Engine
 }
 
 func (s *AuthMiddlewareTestSuite) SetupTest() {
 	gin.SetMode(gin.TestMode)
 	s.mockUserUsecase = new(mocks.UserUsecase)
-	s.jwtService = *infrastructure.NewJWTServiceV5()
+	s.mockRoleUsecase = new(mocks.RoleUsecase)
+	keys, err := infrastructure.NewKeyring(nil, infrastructure.DefaultKeyringConfig)
+	s.Require().NoError(err)
+	s.jwtService = infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
 	s.router = gin.Default()
+	s.router.Use(infrastructure.ErrorMiddleware())
 }
 
 func TestAuthMiddleware(t *testing.T) {
 	suite.Run(t, new(AuthMiddlewareTestSuite))
 }
 
-func (s *AuthMiddlewareTestSuite) performRequestWithAuth(header string, requiredRole string) *httptest.ResponseRecorder {
+// performRequest requests a route that API tokens need scope for and users
+// need permissions for.
+func (s *AuthMiddlewareTestSuite) performRequest(header, scope string, permissions ...string) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
 	if header != "" {
 		req.Header.Set("Authorization", header)
 	}
 
-	s.router.GET("/protected", infrastructure.AuthMiddleware(s.mockUserUsecase, requiredRole), func(c *gin.Context) {
+	handlers := []gin.HandlerFunc{infrastructure.AuthMiddleware(s.mockUserUsecase, s.jwtService, scope)}
+	if len(permissions) > 0 {
+		handlers = append(handlers, infrastructure.RequirePermission(s.mockUserUsecase, s.mockRoleUsecase, permissions...))
+	}
+	handlers = append(handlers, func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
+	s.router.GET("/protected", handlers...)
 
 	s.router.ServeHTTP(w, req)
 	return w
 }
 
+// member returns a user of the workspace ws1, active there.
+func member(id, username, role string) *domain.User {
+	user := &domain.User{ID: id, Username: username, Memberships: []domain.Membership{{WorkspaceID: "ws1", Role: role}}}
+	user.Activate("ws1")
+	return user
+}
+
+func (s *AuthMiddlewareTestSuite) problemCode(w *httptest.ResponseRecorder) string {
+	s.Require().Equal("application/problem+json", w.Header().Get("Content-Type"))
+	var p infrastructure.Problem
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &p))
+	return p.Code
+}
+
 func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_NoHeader() {
-	w := s.performRequestWithAuth("", domain.RoleUser)
+	w := s.performRequest("", "")
 	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("unauthenticated", s.problemCode(w))
 }
 
 func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_MalformedHeader() {
-	w := s.performRequestWithAuth("InvalidToken", domain.RoleUser)
+	w := s.performRequest("InvalidToken", "")
 	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("unauthenticated", s.problemCode(w))
 }
 
 func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_InvalidToken() {
-	w := s.performRequestWithAuth("Bearer invalid-token-string", domain.RoleUser)
+	w := s.performRequest("Bearer invalid-token-string", "")
 	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("invalid_token", s.problemCode(w))
 }
 
 func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_UserNotFound() {
-	user := &domain.User{ID: "123", Username: "test", Role: domain.RoleUser}
+	user := member("123", "test", domain.RoleUser)
 	token, _ := s.jwtService.GenerateJWT(user)
 
 	s.mockUserUsecase.On("GetUserByID", user.ID).Return(nil, errs.ErrUserNotFound).Once()
 
-	w := s.performRequestWithAuth("Bearer "+token, domain.RoleUser)
+	w := s.performRequest("Bearer "+token, "")
 
 	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("invalid_token", s.problemCode(w))
 	s.mockUserUsecase.AssertExpectations(s.T())
 }
 
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_DisabledUser() {
+	user := member("123", "test", domain.RoleUser)
+	token, _ := s.jwtService.GenerateJWT(user)
+	user.Memberships[0].Disabled = true
+
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "")
+
+	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("account_disabled", s.problemCode(w))
+}
+
 func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_InsufficientPermissions() {
-	user := &domain.User{ID: "123", Username: "test", Role: domain.RoleUser}
+	user := member("123", "test", domain.RoleUser)
 	token, _ := s.jwtService.GenerateJWT(user)
 
 	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil).Once()
 
-	w := s.performRequestWithAuth("Bearer "+token, domain.RoleAdmin)
+	w := s.performRequest("Bearer "+token, "", domain.PermTaskRead, domain.PermTaskCreate)
 
 	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("insufficient_role", s.problemCode(w))
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_ReadingNeedsNoMFA() {
+	user := member("123", "test", domain.RoleUser)
+	token, _ := s.jwtService.GenerateJWT(user)
+
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "", domain.PermTaskRead)
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "MFAEnrollmentRequired", user)
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_Success() {
+	user := member("123", "test", domain.RoleUser)
+	token, _ := s.jwtService.GenerateJWT(user)
+
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "")
+
+	s.Assert().Equal(http.StatusOK, w.Code)
 	s.mockUserUsecase.AssertExpectations(s.T())
+	s.mockRoleUsecase.AssertNotCalled(s.T(), "Permissions", user)
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_CustomRole() {
+	user := member("123", "test", "editor")
+	token, _ := s.jwtService.GenerateJWT(user)
+
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead, domain.PermTaskUpdate}, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "", domain.PermTaskUpdate)
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+	s.mockUserUsecase.AssertExpectations(s.T())
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_OnlyAdminsNeedRequiredMFA() {
+	user := member("123", "test", "editor")
+	token, _ := s.jwtService.GenerateJWT(user)
+
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead, domain.PermTaskCreate}, nil).Once()
+	// the workspace requires MFA, which the user has not enabled
+	s.mockUserUsecase.On("MFAEnrollmentRequired", user).Return(true, nil).Maybe()
+
+	w := s.performRequest("Bearer "+token, "", domain.PermTaskCreate)
+
+	s.Assert().Equal(http.StatusOK, w.Code, "users without admin permissions can still create tasks")
+	s.mockUserUsecase.AssertNotCalled(s.T(), "MFAEnrollmentRequired", user)
 }
 
-func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_AdminAccessingUserRoute_Success() {
-	admin := &domain.User{ID: "456", Username: "admin", Role: domain.RoleAdmin}
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_AdminRoute_Success() {
+	admin := member("456", "admin", domain.RoleAdmin)
 	token, _ := s.jwtService.GenerateJWT(admin)
 
 	s.mockUserUsecase.On("GetUserByID", admin.ID).Return(admin, nil).Once()
+	s.mockRoleUsecase.On("Permissions", admin).Return(domain.Permissions, nil).Once()
+	s.mockUserUsecase.On("MFAEnrollmentRequired", admin).Return(false, nil).Once()
 
-	w := s.performRequestWithAuth("Bearer "+token, domain.RoleUser)
+	w := s.performRequest("Bearer "+token, "", domain.PermSettingsManage)
 
 	s.Assert().Equal(http.StatusOK, w.Code)
 	s.mockUserUsecase.AssertExpectations(s.T())
 }
 
-func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_Success() {
-	user := &domain.User{ID: "123", Username: "test", Role: domain.RoleUser}
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_AdminWithoutRequiredMFA() {
+	admin := member("456", "admin", domain.RoleAdmin)
+	token, _ := s.jwtService.GenerateJWT(admin)
+
+	s.mockUserUsecase.On("GetUserByID", admin.ID).Return(admin, nil).Once()
+	s.mockRoleUsecase.On("Permissions", admin).Return(domain.Permissions, nil).Once()
+	s.mockUserUsecase.On("MFAEnrollmentRequired", admin).Return(true, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "", domain.PermSettingsManage)
+
+	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("mfa_enrollment_required", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_MFAChallengeIsNotAToken() {
+	user := member("123", "test", domain.RoleUser)
+	challenge, err := s.jwtService.GenerateMFAChallenge(user)
+	s.Require().NoError(err)
+
+	w := s.performRequest("Bearer "+challenge, "")
+
+	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("invalid_token", s.problemCode(w))
+	s.mockUserUsecase.AssertNotCalled(s.T(), "GetUserByID", user.ID)
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_TokenFromBeforePasswordChange() {
+	user := member("123", "test", domain.RoleUser)
+	token, _ := s.jwtService.GenerateJWT(user)
+
+	changed := *user
+	changed.TokenVersion = 1
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(&changed, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "")
+
+	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("invalid_token", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_TokenForAnotherWorkspace() {
+	user := member("123", "test", domain.RoleUser)
+	token, _ := s.jwtService.GenerateJWT(user)
+
+	// the user has left ws1 since
+	left := &domain.User{ID: "123", Username: "test", Memberships: []domain.Membership{{WorkspaceID: "ws2", Role: domain.RoleAdmin}}}
+	s.mockUserUsecase.On("GetUserByID", user.ID).Return(left, nil).Once()
+
+	w := s.performRequest("Bearer "+token, "")
+
+	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("invalid_token", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_ActivatesTheTokensWorkspace() {
+	user := &domain.User{ID: "123", Username: "test", Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleAdmin},
+		{WorkspaceID: "ws2", Role: domain.RoleUser},
+	}}
+	user.Activate("ws2")
 	token, _ := s.jwtService.GenerateJWT(user)
+	user.Activate("ws1")
 
 	s.mockUserUsecase.On("GetUserByID", user.ID).Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil).Once()
 
-	w := s.performRequestWithAuth("Bearer "+token, domain.RoleUser)
+	w := s.performRequest("Bearer "+token, "", domain.PermTaskRead)
 
 	s.Assert().Equal(http.StatusOK, w.Code)
-	s.mockUserUsecase.AssertExpectations(s.T())
+	s.Assert().Equal("ws2", user.WorkspaceID)
+	s.Assert().Equal(domain.RoleUser, user.Role)
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_APIToken() {
+	user := member("123", "test", domain.RoleUser)
+	token := &domain.APIToken{ID: "t1", UserID: user.ID, Scopes: []string{domain.ScopeTasksWrite}}
+	s.mockUserUsecase.On("AuthenticateAPIToken", "tm_secret").Return(user, token, nil)
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil)
+
+	// tasks:write grants tasks:read
+	w := s.performRequest("Bearer tm_secret", domain.ScopeTasksRead, domain.PermTaskRead)
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "GetUserByID", user.ID)
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_APITokenWithoutScope() {
+	user := member("123", "test", domain.RoleUser)
+	token := &domain.APIToken{ID: "t1", UserID: user.ID, Scopes: []string{domain.ScopeTasksRead}}
+	s.mockUserUsecase.On("AuthenticateAPIToken", "tm_secret").Return(user, token, nil)
+
+	w := s.performRequest("Bearer tm_secret", domain.ScopeTasksWrite, domain.PermTaskCreate)
+
+	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("insufficient_scope", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_APITokenOnSessionOnlyRoute() {
+	user := member("123", "test", domain.RoleUser)
+	token := &domain.APIToken{ID: "t1", UserID: user.ID, Scopes: []string{domain.ScopeAdmin}}
+	s.mockUserUsecase.On("AuthenticateAPIToken", "tm_secret").Return(user, token, nil)
+
+	w := s.performRequest("Bearer tm_secret", "")
+
+	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("insufficient_scope", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_APITokenScopeDoesNotAddPermissions() {
+	user := member("123", "test", domain.RoleUser)
+	token := &domain.APIToken{ID: "t1", UserID: user.ID, Scopes: []string{domain.ScopeAdmin}}
+	s.mockUserUsecase.On("AuthenticateAPIToken", "tm_secret").Return(user, token, nil)
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil)
+
+	w := s.performRequest("Bearer tm_secret", domain.ScopeAdmin, domain.PermSettingsManage)
+
+	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("insufficient_role", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestAuthMiddleware_InvalidAPIToken() {
+	s.mockUserUsecase.On("AuthenticateAPIToken", "tm_revoked").Return(nil, nil, errs.ErrInvalidToken)
+
+	w := s.performRequest("Bearer tm_revoked", domain.ScopeTasksRead)
+
+	s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	s.Assert().Equal("invalid_token", s.problemCode(w))
+}
+
+// requestFeed requests a calendar feed, which needs task.read.
+func (s *AuthMiddlewareTestSuite) requestFeed(path string) *httptest.ResponseRecorder {
+	s.router.GET("/ical/:feed",
+		infrastructure.CalendarFeedAuth(s.mockUserUsecase),
+		infrastructure.RequirePermission(s.mockUserUsecase, s.mockRoleUsecase, domain.PermTaskRead),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *AuthMiddlewareTestSuite) TestCalendarFeedAuth() {
+	user := member("123", "test", domain.RoleUser)
+	s.mockUserUsecase.On("GetUserByCalendarToken", "secret").Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{domain.PermTaskRead}, nil).Once()
+
+	w := s.requestFeed("/ical/secret.ics")
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+}
+
+func (s *AuthMiddlewareTestSuite) TestCalendarFeedAuth_WithoutTaskRead() {
+	user := member("123", "test", "auditor")
+	s.mockUserUsecase.On("GetUserByCalendarToken", "secret").Return(user, nil).Once()
+	s.mockRoleUsecase.On("Permissions", user).Return([]string{}, nil).Once()
+
+	w := s.requestFeed("/ical/secret.ics")
+
+	s.Assert().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("insufficient_role", s.problemCode(w))
+}
+
+func (s *AuthMiddlewareTestSuite) TestCalendarFeedAuth_WithoutExtension() {
+	w := s.requestFeed("/ical/secret")
+
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+	s.mockUserUsecase.AssertNotCalled(s.T(), "GetUserByCalendarToken", "secret")
 }
@@ -0,0 +1,127 @@
+package infrastructure
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// prefixLength is how many leading hex digits of a SHA-1 name its range.
+const prefixLength = 5
+
+// BreachedPasswordList is an offline copy of a k-anonymity breached-password
+// list such as Pwned Passwords. Passwords are looked up by SHA-1: the first
+// five hex digits select a range and the remaining 35 are searched in it, so
+// the list is stored the same way the range API serves it.
+type BreachedPasswordList struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedPasswordList reads path, which is either a directory of range
+// files named by their prefix (e.g. "21BD1" or "21BD1.txt") holding
+// "SUFFIX:COUNT" lines, or a single file of full "HASH:COUNT" lines.
+func LoadBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &BreachedPasswordList{ranges: make(map[string][]string)}
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := list.read(f, ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		list.sort()
+		return list, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		prefix := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || !isHex(prefix, prefixLength) {
+			continue
+		}
+		f, err := os.Open(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		err = list.read(f, strings.ToUpper(prefix))
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+	list.sort()
+	return list, nil
+}
+
+// read adds the hashes in r. With a prefix, lines hold only the rest of the
+// hash. Entries with a count of zero are padding and are skipped.
+func (l *BreachedPasswordList) read(r io.Reader, prefix string) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, count, _ := strings.Cut(text, ":")
+		hash = prefix + strings.ToUpper(hash)
+		if !isHex(hash, sha1.Size*2) {
+			return fmt.Errorf("line %d: %q is not a SHA-1 hash", line, text)
+		}
+		if strings.TrimSpace(count) == "0" {
+			continue
+		}
+		l.ranges[hash[:prefixLength]] = append(l.ranges[hash[:prefixLength]], hash[prefixLength:])
+	}
+	return scanner.Err()
+}
+
+func (l *BreachedPasswordList) sort() {
+	for prefix, suffixes := range l.ranges {
+		slices.Sort(suffixes)
+		l.ranges[prefix] = slices.Compact(suffixes)
+	}
+}
+
+// Len returns the number of hashes in the list.
+func (l *BreachedPasswordList) Len() int {
+	n := 0
+	for _, suffixes := range l.ranges {
+		n += len(suffixes)
+	}
+	return n
+}
+
+func (l *BreachedPasswordList) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := slices.BinarySearch(l.ranges[hash[:prefixLength]], hash[prefixLength:])
+	return found, nil
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
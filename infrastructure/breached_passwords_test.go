@@ -0,0 +1,71 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"task-manager/infrastructure"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SHA-1 of "password1"
+const breachedHash = "E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D"
+
+type BreachedPasswordListTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *BreachedPasswordListTestSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+func TestBreachedPasswordList(t *testing.T) {
+	suite.Run(t, new(BreachedPasswordListTestSuite))
+}
+
+func (s *BreachedPasswordListTestSuite) write(name, content string) string {
+	path := filepath.Join(s.dir, name)
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func (s *BreachedPasswordListTestSuite) TestRangeDirectory() {
+	s.write("E38AD.txt", "0000000000000000000000000000000000A:0\r\n"+breachedHash[5:]+":3303003\r\n")
+	s.write("README.md", "not a range")
+
+	list, err := infrastructure.LoadBreachedPasswordList(s.dir)
+	s.Require().NoError(err)
+	s.Assert().Equal(1, list.Len(), "zero-count padding is skipped")
+
+	breached, err := list.IsBreached("password1")
+	s.Require().NoError(err)
+	s.Assert().True(breached)
+
+	breached, err = list.IsBreached("correct horse battery staple 42")
+	s.Require().NoError(err)
+	s.Assert().False(breached)
+}
+
+func (s *BreachedPasswordListTestSuite) TestHashFile() {
+	path := s.write("hashes.txt", "20C02F5C23E5FD1BDF7CE0FA09553AF818C782CF:12\n"+breachedHash+":3303003\n")
+
+	list, err := infrastructure.LoadBreachedPasswordList(path)
+	s.Require().NoError(err)
+	s.Assert().Equal(2, list.Len())
+
+	for _, password := range []string{"password1", "letmein99"} {
+		breached, err := list.IsBreached(password)
+		s.Require().NoError(err)
+		s.Assert().True(breached, password)
+	}
+}
+
+func (s *BreachedPasswordListTestSuite) TestMalformedLine() {
+	path := s.write("hashes.txt", breachedHash+":1\nnot-a-hash:2\n")
+
+	_, err := infrastructure.LoadBreachedPasswordList(path)
+	s.Require().Error(err)
+	s.Assert().Contains(err.Error(), "line 2")
+}
@@ -0,0 +1,107 @@
+package infrastructure
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"task-manager/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Code     string         `json:"code"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Errors   []ProblemField `json:"errors,omitempty"`
+}
+
+// ProblemField is one invalid field of a validation problem.
+type ProblemField struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewProblem describes err for a client. Errors that are not an
+// errs.AppError are reported as errs.ErrUnexpected, and server errors never
+// reveal more than their title.
+func NewProblem(err error, instance string) *Problem {
+	kind := errs.Kind(err)
+	p := &Problem{
+		Type:     kind.Type(),
+		Title:    kind.Msg,
+		Status:   kind.Status,
+		Code:     kind.Code,
+		Detail:   err.Error(),
+		Instance: instance,
+	}
+	if kind.Status >= http.StatusInternalServerError {
+		p.Detail = ""
+	}
+
+	var validationErr *errs.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, f := range validationErr.Fields {
+			p.Errors = append(p.Errors, ProblemField{Field: f.Field, Code: f.Code, Message: f.Message})
+		}
+	}
+	return p
+}
+
+// ErrorMiddleware renders the last error a handler attached with c.Error,
+// or a panic, as application/problem+json. Handlers that already wrote a
+// response keep it; the error is only logged.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			log.Printf("ERROR: panic serving %s %s: %v\n%s", c.Request.Method, c.Request.URL.Path, rec, debug.Stack())
+			c.Abort()
+			writeProblem(c, errs.ErrUnexpected)
+		}()
+
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+		err := c.Errors.Last().Err
+		if errs.Kind(err).Status >= http.StatusInternalServerError {
+			log.Printf("ERROR: %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+		if c.Writer.Written() {
+			return
+		}
+		writeProblem(c, err)
+	}
+}
+
+// NoRoute reports requests for unknown endpoints through ErrorMiddleware.
+func NoRoute(c *gin.Context) {
+	_ = c.Error(errs.ErrRouteNotFound)
+}
+
+func writeProblem(c *gin.Context, err error) {
+	p := NewProblem(err, c.Request.URL.Path)
+	var retryErr *errs.RetryError
+	if errors.As(err, &retryErr) {
+		seconds := int(math.Ceil(retryErr.After.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(p.Status, p)
+}
@@ -0,0 +1,150 @@
+package infrastructure_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorMiddlewareTestSuite struct {
+	suite.Suite
+	router *gin.Engine
+}
+
+func (s *ErrorMiddlewareTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	s.router = gin.New()
+	s.router.Use(infrastructure.ErrorMiddleware())
+	s.router.NoRoute(infrastructure.NoRoute)
+}
+
+func TestErrorMiddleware(t *testing.T) {
+	suite.Run(t, new(ErrorMiddlewareTestSuite))
+}
+
+func (s *ErrorMiddlewareTestSuite) perform(handler gin.HandlerFunc) (*httptest.ResponseRecorder, infrastructure.Problem) {
+	s.router.GET("/test", handler)
+	return s.get("/test")
+}
+
+func (s *ErrorMiddlewareTestSuite) get(path string) (*httptest.ResponseRecorder, infrastructure.Problem) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	s.router.ServeHTTP(w, req)
+
+	var p infrastructure.Problem
+	if w.Header().Get("Content-Type") == "application/problem+json" {
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &p))
+	}
+	return w, p
+}
+
+func (s *ErrorMiddlewareTestSuite) TestAppError() {
+	w, p := s.perform(func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("%w: id 42", errs.ErrTaskNotFound))
+	})
+
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	s.Assert().Equal("application/problem+json", w.Header().Get("Content-Type"))
+	s.Assert().Equal(infrastructure.Problem{
+		Type:     "/problems/task-not-found",
+		Title:    "task is not found",
+		Status:   http.StatusNotFound,
+		Code:     "task_not_found",
+		Detail:   "task is not found: id 42",
+		Instance: "/test",
+	}, p)
+}
+
+func (s *ErrorMiddlewareTestSuite) TestWrappedAppError() {
+	w, p := s.perform(func(c *gin.Context) {
+		_ = c.Error(errs.Wrap(errs.ErrInsufficientRole, errors.New("admin access required")))
+	})
+
+	s.Require().Equal(http.StatusForbidden, w.Code)
+	s.Assert().Equal("insufficient_role", p.Code)
+	s.Assert().Equal("insufficient permissions: admin access required", p.Detail)
+}
+
+func (s *ErrorMiddlewareTestSuite) TestUnknownErrorIsInternal() {
+	w, p := s.perform(func(c *gin.Context) {
+		_ = c.Error(errors.New("dial tcp 10.0.0.5:27017: connection refused"))
+	})
+
+	s.Require().Equal(http.StatusInternalServerError, w.Code)
+	s.Assert().Equal("internal_error", p.Code)
+	s.Assert().Empty(p.Detail)
+	s.Assert().NotContains(w.Body.String(), "10.0.0.5")
+}
+
+func (s *ErrorMiddlewareTestSuite) TestValidationError() {
+	w, p := s.perform(func(c *gin.Context) {
+		_ = c.Error(&errs.ValidationError{Fields: []errs.FieldError{
+			{Field: "title", Code: "required", Message: "is required"},
+			{Field: "labels[0]", Code: "max", Message: "must have at most 50 characters"},
+		}})
+	})
+
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+	s.Assert().Equal("validation_failed", p.Code)
+	s.Assert().Equal([]infrastructure.ProblemField{
+		{Field: "title", Code: "required", Message: "is required"},
+		{Field: "labels[0]", Code: "max", Message: "must have at most 50 characters"},
+	}, p.Errors)
+}
+
+func (s *ErrorMiddlewareTestSuite) TestPanic() {
+	w, p := s.perform(func(c *gin.Context) {
+		panic("nil map")
+	})
+
+	s.Require().Equal(http.StatusInternalServerError, w.Code)
+	s.Assert().Equal("internal_error", p.Code)
+	s.Assert().NotContains(w.Body.String(), "nil map")
+}
+
+func (s *ErrorMiddlewareTestSuite) TestWrittenResponseIsKept() {
+	w, _ := s.perform(func(c *gin.Context) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"results": []string{}})
+		_ = c.Error(errs.ErrBulkAborted)
+	})
+
+	s.Require().Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Assert().JSONEq(`{"results": []}`, w.Body.String())
+}
+
+func (s *ErrorMiddlewareTestSuite) TestNoRoute() {
+	w, p := s.get("/nowhere")
+
+	s.Require().Equal(http.StatusNotFound, w.Code)
+	s.Assert().Equal("route_not_found", p.Code)
+	s.Assert().Equal("/nowhere", p.Instance)
+}
+
+func (s *ErrorMiddlewareTestSuite) TestNoError() {
+	w, _ := s.perform(func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	s.Assert().Equal(http.StatusNoContent, w.Code)
+	s.Assert().Empty(w.Body.String())
+}
+
+func (s *ErrorMiddlewareTestSuite) TestRetryAfter() {
+	w, p := s.perform(func(c *gin.Context) {
+		_ = c.Error(&errs.RetryError{Err: errs.ErrAccountLocked, After: 90*time.Second + time.Millisecond})
+	})
+
+	s.Require().Equal(http.StatusLocked, w.Code)
+	s.Assert().Equal("account_locked", p.Code)
+	s.Assert().Equal("91", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,263 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader names the key clients send with a POST request
+	// they may retry.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// DefaultIdempotencyTTL is how long the response to a key is replayed.
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// maxIdempotencyKeyLength fits UUIDs and anything else sensible.
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize bounds the bodies read to fingerprint them. It
+	// leaves room for the largest import.
+	maxIdempotentBodySize = 16 << 20
+	// idempotencyClaimTimeout is how long a request keeps its key without
+	// completing, after which a replica that died serving it no longer
+	// blocks retries.
+	idempotencyClaimTimeout = 5 * time.Minute
+)
+
+// Idempotency replays the first response to a POST request with an
+// Idempotency-Key to the retries of that request, so that they do not
+// repeat it.
+type Idempotency struct {
+	store usecases.IdempotencyStore
+	ttl   time.Duration
+}
+
+// NewIdempotency creates the middleware keeping the responses in store for
+// ttl.
+func NewIdempotency(store usecases.IdempotencyStore, ttl time.Duration) *Idempotency {
+	return &Idempotency{store: store, ttl: ttl}
+}
+
+// Middleware follows AuthMiddleware, since keys are scoped to their user.
+// Requests without a key, and every request of a nil Idempotency, are
+// served as usual.
+//
+// The first request with a key is served and its response stored, unless
+// it was a server error, which retries may not get again. Retries then get
+// that response with Idempotent-Replayed: true; a retry sent while the first
+// request is still being served gets a 409, and a different request with the
+// same key a 422.
+func (i *Idempotency) Middleware() gin.HandlerFunc {
+	if i == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortWithError(c, &errs.ValidationError{Fields: []errs.FieldError{{
+				Field:   IdempotencyKeyHeader,
+				Code:    "max",
+				Message: fmt.Sprintf("must be at most %d characters long", maxIdempotencyKeyLength),
+			}}})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			abortWithError(c, fmt.Errorf("%w: cannot read the request body: %v", errs.ErrValidation, err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := c.MustGet("user").(*domain.User)
+		// stores may keep times to the millisecond only, and the claim must
+		// match what they keep
+		now := time.Now().Truncate(time.Millisecond)
+		record := &domain.IdempotencyRecord{
+			Key:         user.ID + ":" + key,
+			Fingerprint: requestFingerprint(c, user, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(idempotencyClaimTimeout),
+		}
+		// without the store, a retry could not be told from a new request
+		earlier, err := i.store.Begin(record, now)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if earlier != nil {
+			replay(c, record, earlier)
+			return
+		}
+
+		i.serve(c, record)
+	}
+}
+
+// serve runs the handlers for the request that claimed record, and stores
+// their response or releases the claim.
+func (i *Idempotency) serve(c *gin.Context, record *domain.IdempotencyRecord) {
+	completed := false
+	defer func() {
+		// nothing was stored, so retries may serve the request again
+		if !completed {
+			if err := i.store.Release(record); err != nil {
+				log.Printf("WARN: idempotency key of %s not released: %v", c.Request.URL.Path, err)
+			}
+		}
+	}()
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// errors are rendered here rather than by ErrorMiddleware, so that they
+	// are stored too
+	if !c.Writer.Written() && len(c.Errors) > 0 {
+		writeProblem(c, c.Errors.Last().Err)
+	}
+	if c.Writer.Status() >= http.StatusInternalServerError {
+		return
+	}
+	response := domain.IdempotentResponse{
+		Status:      c.Writer.Status(),
+		ContentType: c.Writer.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	}
+	if err := i.store.Complete(record, response, time.Now().Add(i.ttl)); err != nil {
+		log.Printf("WARN: response of %s not stored for its idempotency key: %v", c.Request.URL.Path, err)
+		return
+	}
+	completed = true
+}
+
+// replay answers a request whose key was claimed by an earlier one.
+func replay(c *gin.Context, record, earlier *domain.IdempotencyRecord) {
+	switch {
+	case earlier.Fingerprint != record.Fingerprint:
+		abortWithError(c, errs.ErrIdempotencyKeyReused)
+	case earlier.Response == nil:
+		abortWithError(c, &errs.RetryError{Err: errs.ErrIdempotencyKeyInUse, After: time.Second})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(earlier.Response.Status, earlier.Response.ContentType, earlier.Response.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint tells requests apart by what they do: the endpoint, the
+// workspace they act in and the body.
+func requestFingerprint(c *gin.Context, user *domain.User, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{c.Request.Method, c.Request.URL.RequestURI(), user.WorkspaceID, c.ContentType()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the body written through it.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// memoryIdempotencyStore keeps the keys in memory, so every replica has its
+// own.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*domain.IdempotencyRecord
+	swept   time.Time
+}
+
+// idempotencySweepInterval is how often expired keys are forgotten.
+const idempotencySweepInterval = time.Minute
+
+// NewMemoryIdempotencyStore creates a store for a single replica.
+func NewMemoryIdempotencyStore() usecases.IdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*domain.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Begin(record *domain.IdempotencyRecord, now time.Time) (*domain.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	if earlier := s.records[record.Key]; earlier != nil && now.Before(earlier.ExpiresAt) {
+		copied := *earlier
+		return &copied, nil
+	}
+	copied := *record
+	copied.Response = nil
+	s.records[record.Key] = &copied
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(claim *domain.IdempotencyRecord, response domain.IdempotentResponse, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record := s.claimed(claim); record != nil {
+		record.Response = &response
+		record.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(claim *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.claimed(claim) != nil {
+		delete(s.records, claim.Key)
+	}
+	return nil
+}
+
+// claimed returns the record of claim if it still holds its key without a
+// response.
+func (s *memoryIdempotencyStore) claimed(claim *domain.IdempotencyRecord) *domain.IdempotencyRecord {
+	record := s.records[claim.Key]
+	if record == nil || record.Response != nil || record.Fingerprint != claim.Fingerprint || !record.CreatedAt.Equal(claim.CreatedAt) {
+		return nil
+	}
+	return record
+}
+
+// sweep forgets the expired keys.
+func (s *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < idempotencySweepInterval {
+		return
+	}
+	s.swept = now
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package infrastructure_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type IdempotencyTestSuite struct {
+	suite.Suite
+	idempotency *infrastructure.Idempotency
+	// handler serves POST /tasks after the middleware
+	handler gin.HandlerFunc
+	calls   atomic.Int32
+}
+
+func TestIdempotency(t *testing.T) {
+	suite.Run(t, new(IdempotencyTestSuite))
+}
+
+func (s *IdempotencyTestSuite) SetupTest() {
+	s.idempotency = infrastructure.NewIdempotency(infrastructure.NewMemoryIdempotencyStore(), time.Hour)
+	s.calls.Store(0)
+	s.handler = func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"id": s.calls.Load()})
+	}
+}
+
+// post sends body to POST /tasks as the user with the key given, if any.
+func (s *IdempotencyTestSuite) post(userID, key, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(infrastructure.ErrorMiddleware())
+	r.POST("/tasks", func(c *gin.Context) {
+		c.Set("user", &domain.User{ID: userID, WorkspaceID: "ws1"})
+	}, s.idempotency.Middleware(), func(c *gin.Context) {
+		s.calls.Add(1)
+		s.handler(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(infrastructure.IdempotencyKeyHeader, key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func (s *IdempotencyTestSuite) TestRetryReplaysTheFirstResponse() {
+	first := s.post("u1", "k1", `{"title":"a"}`)
+	s.Require().Equal(http.StatusCreated, first.Code)
+
+	retry := s.post("u1", "k1", `{"title":"a"}`)
+
+	s.Assert().Equal(int32(1), s.calls.Load(), "the retry was not served again")
+	s.Assert().Equal(http.StatusCreated, retry.Code)
+	s.Assert().Equal(first.Body.String(), retry.Body.String())
+	s.Assert().Equal(first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	s.Assert().Equal("true", retry.Header().Get("Idempotent-Replayed"))
+	s.Assert().Empty(first.Header().Get("Idempotent-Replayed"))
+}
+
+func (s *IdempotencyTestSuite) TestDifferentRequestWithTheKey() {
+	s.Require().Equal(http.StatusCreated, s.post("u1", "k1", `{"title":"a"}`).Code)
+
+	w := s.post("u1", "k1", `{"title":"b"}`)
+
+	s.Assert().Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Assert().Contains(w.Body.String(), "idempotency_key_reused")
+	s.Assert().Equal(int32(1), s.calls.Load())
+}
+
+func (s *IdempotencyTestSuite) TestKeysAreScopedToTheirUser() {
+	s.post("u1", "k1", `{"title":"a"}`)
+	w := s.post("u2", "k1", `{"title":"a"}`)
+
+	s.Assert().Equal(http.StatusCreated, w.Code)
+	s.Assert().Empty(w.Header().Get("Idempotent-Replayed"))
+	s.Assert().Equal(int32(2), s.calls.Load())
+}
+
+func (s *IdempotencyTestSuite) TestRetryWhileTheFirstRequestIsServed() {
+	started, release := make(chan struct{}), make(chan struct{})
+	s.handler = func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusCreated)
+	}
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- s.post("u1", "k1", `{"title":"a"}`) }()
+	<-started
+
+	w := s.post("u1", "k1", `{"title":"a"}`)
+	close(release)
+
+	s.Assert().Equal(http.StatusConflict, w.Code)
+	s.Assert().Contains(w.Body.String(), "idempotency_key_in_use")
+	s.Assert().Equal("1", w.Header().Get("Retry-After"))
+	s.Assert().Equal(http.StatusCreated, (<-done).Code)
+	s.Assert().Equal(int32(1), s.calls.Load())
+}
+
+func (s *IdempotencyTestSuite) TestClientErrorsAreReplayed() {
+	s.handler = func(c *gin.Context) { _ = c.Error(errs.ErrValidation) }
+
+	first := s.post("u1", "k1", `{}`)
+	retry := s.post("u1", "k1", `{}`)
+
+	s.Assert().Equal(http.StatusBadRequest, first.Code)
+	s.Assert().Equal("application/problem+json", first.Header().Get("Content-Type"))
+	s.Assert().Equal(http.StatusBadRequest, retry.Code)
+	s.Assert().Equal(first.Body.String(), retry.Body.String())
+	s.Assert().Equal(int32(1), s.calls.Load())
+}
+
+func (s *IdempotencyTestSuite) TestServerErrorsCanBeRetried() {
+	s.handler = func(c *gin.Context) { _ = c.Error(errs.ErrUnexpected) }
+	s.Require().Equal(http.StatusInternalServerError, s.post("u1", "k1", `{}`).Code)
+
+	s.handler = func(c *gin.Context) { panic("boom") }
+	s.Require().Equal(http.StatusInternalServerError, s.post("u1", "k1", `{}`).Code)
+
+	s.handler = func(c *gin.Context) { c.Status(http.StatusCreated) }
+	s.Assert().Equal(http.StatusCreated, s.post("u1", "k1", `{}`).Code)
+	s.Assert().Equal(int32(3), s.calls.Load())
+}
+
+func (s *IdempotencyTestSuite) TestWithoutKey() {
+	s.post("u1", "", `{"title":"a"}`)
+	s.post("u1", "", `{"title":"a"}`)
+	s.Assert().Equal(int32(2), s.calls.Load())
+
+	s.idempotency = nil
+	s.post("u1", "k1", `{"title":"a"}`)
+	s.post("u1", "k1", `{"title":"a"}`)
+	s.Assert().Equal(int32(4), s.calls.Load(), "a nil Idempotency stores nothing")
+}
+
+func (s *IdempotencyTestSuite) TestKeyTooLong() {
+	w := s.post("u1", strings.Repeat("k", 256), `{}`)
+
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+	s.Assert().Contains(w.Body.String(), infrastructure.IdempotencyKeyHeader)
+	s.Assert().Zero(s.calls.Load())
+}
+
+func (s *IdempotencyTestSuite) TestMemoryStore_ExpiredKeysCanBeClaimedAgain() {
+	store := infrastructure.NewMemoryIdempotencyStore()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	record := &domain.IdempotencyRecord{Key: "u1:k1", Fingerprint: "f1", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	earlier, err := store.Begin(record, now)
+	s.Require().NoError(err)
+	s.Require().Nil(earlier)
+	s.Require().NoError(store.Complete(record, domain.IdempotentResponse{Status: http.StatusCreated}, now.Add(time.Hour)))
+
+	earlier, _ = store.Begin(record, now.Add(30*time.Minute))
+	s.Require().NotNil(earlier, "completing the request kept the key for longer")
+	s.Assert().Equal(http.StatusCreated, earlier.Response.Status)
+	s.Assert().NoError(store.Release(record))
+	earlier, _ = store.Begin(record, now.Add(30*time.Minute))
+	s.Assert().NotNil(earlier, "completed keys are not released")
+
+	earlier, _ = store.Begin(record, now.Add(2*time.Hour))
+	s.Assert().Nil(earlier)
+}
+
+func (s *IdempotencyTestSuite) TestMemoryStore_ExpiredClaimCannotCompleteOrRelease() {
+	store := infrastructure.NewMemoryIdempotencyStore()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	stale := &domain.IdempotencyRecord{Key: "u1:k1", Fingerprint: "f1", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+	_, err := store.Begin(stale, now)
+	s.Require().NoError(err)
+
+	// the first request outlived its claim, and a retry took the key over
+	later := now.Add(2 * time.Minute)
+	retry := &domain.IdempotencyRecord{Key: "u1:k1", Fingerprint: "f1", CreatedAt: later, ExpiresAt: later.Add(time.Minute)}
+	earlier, err := store.Begin(retry, later)
+	s.Require().NoError(err)
+	s.Require().Nil(earlier)
+
+	s.Require().NoError(store.Complete(stale, domain.IdempotentResponse{Status: http.StatusCreated}, later.Add(time.Hour)))
+	s.Require().NoError(store.Release(stale))
+
+	earlier, _ = store.Begin(retry, later.Add(time.Second))
+	s.Require().NotNil(earlier, "the retry still holds the key")
+	s.Assert().Nil(earlier.Response, "the stale request stored no response")
+	s.Assert().True(earlier.CreatedAt.Equal(later))
+
+	s.Require().NoError(store.Complete(retry, domain.IdempotentResponse{Status: http.StatusAccepted}, later.Add(time.Hour)))
+	earlier, _ = store.Begin(retry, later.Add(time.Second))
+	s.Assert().Equal(http.StatusAccepted, earlier.Response.Status)
+}
@@ -1,48 +1,201 @@
 package infrastructure
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"task-manager/domain"
 	"task-manager/errs"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-type JWTServiceV5 struct{}
+// JWTServiceV5 issues and verifies the app's tokens, signing them with the
+// current key of its keyring.
+type JWTServiceV5 struct {
+	keys   *Keyring
+	issuer string
+}
 
-func NewJWTServiceV5() *JWTServiceV5 {
-	return &JWTServiceV5{}
+// NewJWTServiceV5 creates the service. issuer is the iss claim of every
+// token, which other services check along with the keys at
+// /.well-known/jwks.json.
+func NewJWTServiceV5(keys *Keyring, issuer string) *JWTServiceV5 {
+	return &JWTServiceV5{keys: keys, issuer: issuer}
 }
 
-const JWTSecret = "task_manager_secret"
+const DefaultJWTIssuer = "task-manager"
+
+const (
+	accessTokenAudience = "api"
+	accessTokenTTL      = 24 * time.Hour
+)
+
+// Tokens for other purposes have an audience of their own, so that they can
+// never pass for an access token.
+const (
+	mfaChallengeAudience = "mfa-challenge"
+	mfaChallengeTTL      = 5 * time.Minute
+)
+
+const (
+	ssoRequestAudience = "sso-request"
+	ssoRequestTTL      = 10 * time.Minute
+)
+
+type ssoRequestClaims struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   string `json:"link_user_id,omitempty"`
+	jwt.RegisteredClaims
+}
 
 type CustomClaims struct {
 	UserID   string // `json:"user_id"`
 	Username string // `json:"username"`
 	Role     string // `json:"role"`
+	// TokenVersion must match the user's, or the token was issued before
+	// the last password change.
+	TokenVersion int `json:"tv,omitempty"`
+	// WorkspaceID is the workspace the token acts in.
+	WorkspaceID string `json:"wid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 func (js *JWTServiceV5) GenerateJWT(user *domain.User) (string, error) {
+	now := time.Now()
 	claims := CustomClaims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     user.Role,
+
+		TokenVersion: user.TokenVersion,
+		WorkspaceID:  user.WorkspaceID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    js.issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	signedToken, err := token.SignedString([]byte(JWTSecret))
+	signedToken, err := js.sign(now, &claims)
 	if err != nil {
 		log.Printf("ERROR: Failed to generate JWT for user '%s': %v", user.Username, err)
-		return "", errs.New(http.StatusInternalServerError, "unexpected error", err)
+		return "", err
 	}
 	return signedToken, nil
 }
+
+// ParseJWT verifies an access token and returns its claims.
+func (js *JWTServiceV5) ParseJWT(tokenString string) (*CustomClaims, error) {
+	var claims CustomClaims
+	if err := js.parse(tokenString, &claims, accessTokenAudience); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errs.ErrTokenExpired
+		}
+		return nil, errs.ErrInvalidToken
+	}
+	if claims.UserID == "" {
+		return nil, errs.ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// GenerateMFAChallenge issues the short-lived token that proves the password
+// step of a login succeeded for user.
+func (js *JWTServiceV5) GenerateMFAChallenge(user *domain.User) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    js.issuer,
+		Subject:   user.ID,
+		Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+
+	return js.sign(now, claims)
+}
+
+// ParseMFAChallenge returns the ID of the user a challenge was issued to.
+func (js *JWTServiceV5) ParseMFAChallenge(challenge string) (string, error) {
+	var claims jwt.RegisteredClaims
+	err := js.parse(challenge, &claims, mfaChallengeAudience)
+	if err != nil || claims.Subject == "" {
+		return "", errs.ErrInvalidMFAChallenge
+	}
+	return claims.Subject, nil
+}
+
+// SealSSORequest signs req so that it can be left with the browser until the
+// identity provider redirects back.
+func (js *JWTServiceV5) SealSSORequest(req *domain.SSORequest) (string, error) {
+	now := time.Now()
+	claims := ssoRequestClaims{
+		State:        req.State,
+		Nonce:        req.Nonce,
+		CodeVerifier: req.CodeVerifier,
+		LinkUserID:   req.LinkUserID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    js.issuer,
+			Audience:  jwt.ClaimStrings{ssoRequestAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ssoRequestTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return js.sign(now, claims)
+}
+
+func (js *JWTServiceV5) OpenSSORequest(sealed string) (*domain.SSORequest, error) {
+	var claims ssoRequestClaims
+	err := js.parse(sealed, &claims, ssoRequestAudience)
+	if err != nil || claims.State == "" {
+		return nil, errs.ErrInvalidSSOState
+	}
+	return &domain.SSORequest{
+		State:        claims.State,
+		Nonce:        claims.Nonce,
+		CodeVerifier: claims.CodeVerifier,
+		LinkUserID:   claims.LinkUserID,
+	}, nil
+}
+
+func (js *JWTServiceV5) sign(now time.Time, claims jwt.Claims) (string, error) {
+	key, err := js.keys.signingKey(now)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		return "", errs.Wrap(errs.ErrUnexpected, err)
+	}
+	return signed, nil
+}
+
+func (js *JWTServiceV5) parse(tokenString string, claims jwt.Claims, audience string) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, js.keys.verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(js.issuer),
+		jwt.WithAudience(audience),
+	)
+	return err
+}
+
+// JWKSHandler handles GET /.well-known/jwks.json requests with the public
+// keys of js. Caches must not keep them for longer than keys are published
+// before they sign.
+func JWKSHandler(js *JWTServiceV5) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.JSON(http.StatusOK, gin.H{"keys": js.keys.JWKS()})
+	}
+}
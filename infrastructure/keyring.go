@@ -0,0 +1,332 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyringConfig tells which signing keys a Keyring creates and when.
+type KeyringConfig struct {
+	// Algorithm of new keys, "EdDSA" (Ed25519) or "RS256". Existing keys
+	// keep theirs, so it can be changed at any time.
+	Algorithm string
+	// RotateEvery is how long a key signs tokens before the next one
+	// takes over.
+	RotateEvery time.Duration
+	// PublishAhead is how long the next key is published before it signs,
+	// so that services caching the JWKS know it by then.
+	PublishAhead time.Duration
+	// CheckInterval is how often Run reloads the keys and rotates them.
+	CheckInterval time.Duration
+}
+
+var DefaultKeyringConfig = KeyringConfig{
+	Algorithm:     jwt.SigningMethodEdDSA.Alg(),
+	RotateEvery:   30 * 24 * time.Hour,
+	PublishAhead:  24 * time.Hour,
+	CheckInterval: time.Minute,
+}
+
+const (
+	rsaKeyBits = 2048
+	// keyRetention is how long a retired key still verifies tokens, which
+	// is as long as the last token it signed lives.
+	keyRetention = accessTokenTTL + time.Minute
+	// keyReloadInterval limits how often a token with an unknown key ID
+	// makes the keys be loaded again, e.g. one signed with a key another
+	// replica just created.
+	keyReloadInterval = 10 * time.Second
+)
+
+type loadedKey struct {
+	domain.SigningKey
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// Keyring holds the keys tokens are signed and verified with. Keys are kept
+// in a SigningKeyRepository so that every replica uses the same ones.
+type Keyring struct {
+	repo   usecases.SigningKeyRepository
+	config KeyringConfig
+
+	mu     sync.RWMutex
+	keys   []loadedKey // by ActivatesAt, then ID
+	loaded time.Time
+}
+
+// NewKeyring loads the keys from repo and creates the first one if there is
+// none. Without repo the keys are only kept in memory, which does for a
+// single replica that may sign everyone out when it restarts.
+func NewKeyring(repo usecases.SigningKeyRepository, config KeyringConfig) (*Keyring, error) {
+	if _, err := signingMethod(config.Algorithm); err != nil {
+		return nil, err
+	}
+	if config.PublishAhead >= config.RotateEvery {
+		return nil, fmt.Errorf("keys must be published for less time than they are used for, got %s and %s", config.PublishAhead, config.RotateEvery)
+	}
+	if repo == nil {
+		repo = &memorySigningKeys{}
+	}
+
+	k := &Keyring{repo: repo, config: config}
+	if err := k.Rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Run rotates the keys until ctx is done.
+func (k *Keyring) Run(ctx context.Context) {
+	ticker := time.NewTicker(k.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := k.Rotate(now); err != nil {
+				log.Printf("ERROR: Failed to rotate signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// Rotate loads the keys and creates the next one once the current key is
+// due to retire within PublishAhead. Replicas that rotate at the same time
+// each create one; all of them are published, and the same one signs
+// everywhere since keys are ordered by ID after ActivatesAt.
+func (k *Keyring) Rotate(now time.Time) error {
+	if err := k.reload(now); err != nil {
+		return err
+	}
+
+	activatesAt := now
+	k.mu.RLock()
+	if n := len(k.keys); n > 0 {
+		latest := k.keys[n-1]
+		if now.Before(latest.RetiresAt.Add(-k.config.PublishAhead)) {
+			k.mu.RUnlock()
+			return nil
+		}
+		if latest.RetiresAt.After(now) {
+			activatesAt = latest.RetiresAt
+		}
+	}
+	k.mu.RUnlock()
+
+	key, err := newSigningKey(k.config.Algorithm, now, activatesAt, k.config.RotateEvery)
+	if err != nil {
+		return err
+	}
+	if err := k.repo.Create(key); err != nil {
+		return err
+	}
+	log.Printf("INFO: Created %s signing key %s, signing from %s", key.Algorithm, key.ID, key.ActivatesAt.Format(time.RFC3339))
+	return k.reload(now)
+}
+
+func (k *Keyring) reload(now time.Time) error {
+	stored, err := k.repo.List(now)
+	if err != nil {
+		return err
+	}
+	keys := make([]loadedKey, 0, len(stored))
+	for _, key := range stored {
+		loaded, err := loadKey(key)
+		if err != nil {
+			log.Printf("ERROR: Skipping signing key %s: %v", key.ID, err)
+			continue
+		}
+		keys = append(keys, loaded)
+	}
+	slices.SortFunc(keys, func(a, b loadedKey) int {
+		if c := a.ActivatesAt.Compare(b.ActivatesAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+	k.loaded = time.Now()
+	return nil
+}
+
+// signingKey returns the key that signs tokens at now.
+func (k *Keyring) signingKey(now time.Time) (loadedKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		key := k.keys[i]
+		if key.ActivatesAt.After(now) {
+			continue
+		}
+		// a retired key would sign tokens that outlive it
+		if !key.RetiresAt.After(now) {
+			break
+		}
+		return key, nil
+	}
+	return loadedKey{}, errs.Wrap(errs.ErrUnexpected, fmt.Errorf("no signing key is active, check that keys are being rotated"))
+}
+
+// verificationKey is the jwt.Keyfunc of tokens signed by the keyring.
+func (k *Keyring) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok, stale := k.lookup(kid)
+	if !ok && stale {
+		if err := k.reload(time.Now()); err != nil {
+			return nil, err
+		}
+		key, ok, _ = k.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("signing key %q is not for %s", kid, token.Method.Alg())
+	}
+	return key.private.Public(), nil
+}
+
+func (k *Keyring) lookup(kid string) (key loadedKey, ok, stale bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key, true, false
+		}
+	}
+	return loadedKey{}, false, time.Since(k.loaded) >= keyReloadInterval
+}
+
+// JWKS returns the public keys, including the one that signs next.
+func (k *Keyring) JWKS() []jsonWebKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	jwks := make([]jsonWebKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		jwk := jsonWebKey{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch public := key.private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case jwt.SigningMethodEdDSA.Alg():
+		return jwt.SigningMethodEdDSA, nil
+	case jwt.SigningMethodRS256.Alg():
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q, use EdDSA or RS256", alg)
+}
+
+func newSigningKey(alg string, now, activatesAt time.Time, lifetime time.Duration) (*domain.SigningKey, error) {
+	var private any
+	var err error
+	switch alg {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		_, err = signingMethod(alg)
+	}
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrUnexpected, err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrUnexpected, err)
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errs.Wrap(errs.ErrUnexpected, err)
+	}
+
+	retiresAt := activatesAt.Add(lifetime)
+	return &domain.SigningKey{
+		ID:          base64.RawURLEncoding.EncodeToString(id),
+		Algorithm:   alg,
+		PrivateKey:  der,
+		CreatedAt:   now,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(keyRetention),
+	}, nil
+}
+
+func loadKey(key domain.SigningKey) (loadedKey, error) {
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return loadedKey{}, err
+	}
+	private, err := x509.ParsePKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return loadedKey{}, err
+	}
+	switch private.(type) {
+	case ed25519.PrivateKey:
+		if method != jwt.SigningMethodEdDSA {
+			return loadedKey{}, fmt.Errorf("an Ed25519 key cannot sign %s", key.Algorithm)
+		}
+	case *rsa.PrivateKey:
+		if method != jwt.SigningMethodRS256 {
+			return loadedKey{}, fmt.Errorf("an RSA key cannot sign %s", key.Algorithm)
+		}
+	default:
+		return loadedKey{}, fmt.Errorf("unsupported key type %T", private)
+	}
+	return loadedKey{SigningKey: key, method: method, private: private.(crypto.Signer)}, nil
+}
+
+// memorySigningKeys keeps the keys of a Keyring without a repository.
+type memorySigningKeys struct {
+	mu   sync.Mutex
+	keys []domain.SigningKey
+}
+
+func (m *memorySigningKeys) Create(key *domain.SigningKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = append(m.keys, *key)
+	return nil
+}
+
+func (m *memorySigningKeys) List(now time.Time) ([]domain.SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = slices.DeleteFunc(m.keys, func(key domain.SigningKey) bool {
+		return !key.ExpiresAt.After(now)
+	})
+	return slices.Clone(m.keys), nil
+}
@@ -0,0 +1,238 @@
+package infrastructure_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"task-manager/repositories/mocks"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type KeyringTestSuite struct {
+	suite.Suite
+	mockKeyRepo *mocks.SigningKeyRepository
+	config      infrastructure.KeyringConfig
+}
+
+// signingKeyStore is a SigningKeyRepository that keeps what is created.
+type signingKeyStore struct {
+	keys []domain.SigningKey
+}
+
+func (r *signingKeyStore) Create(key *domain.SigningKey) error {
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+func (r *signingKeyStore) List(time.Time) ([]domain.SigningKey, error) {
+	return r.keys, nil
+}
+
+func (s *KeyringTestSuite) SetupTest() {
+	s.mockKeyRepo = new(mocks.SigningKeyRepository)
+	s.config = infrastructure.DefaultKeyringConfig
+}
+
+func TestKeyring(t *testing.T) {
+	suite.Run(t, new(KeyringTestSuite))
+}
+
+// storedKey makes a key that started signing activeFor ago.
+func (s *KeyringTestSuite) storedKey(id string, private any, activeFor time.Duration) (domain.SigningKey, any) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	s.Require().NoError(err)
+	alg := "EdDSA"
+	if _, ok := private.(*rsa.PrivateKey); ok {
+		alg = "RS256"
+	}
+	activatesAt := time.Now().Add(-activeFor)
+	return domain.SigningKey{
+		ID:          id,
+		Algorithm:   alg,
+		PrivateKey:  der,
+		CreatedAt:   activatesAt,
+		ActivatesAt: activatesAt,
+		RetiresAt:   activatesAt.Add(s.config.RotateEvery),
+		ExpiresAt:   activatesAt.Add(s.config.RotateEvery + 25*time.Hour),
+	}, private
+}
+
+func (s *KeyringTestSuite) ed25519Key() ed25519.PrivateKey {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+	return private
+}
+
+func (s *KeyringTestSuite) jwtService() *infrastructure.JWTServiceV5 {
+	keys, err := infrastructure.NewKeyring(s.mockKeyRepo, s.config)
+	s.Require().NoError(err)
+	return infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
+}
+
+func (s *KeyringTestSuite) kid(token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	s.Require().NoError(err)
+	return parsed.Header["kid"].(string)
+}
+
+func (s *KeyringTestSuite) TestNewKeyring_CreatesFirstKey() {
+	store := &signingKeyStore{}
+	keys, err := infrastructure.NewKeyring(store, s.config)
+	s.Require().NoError(err)
+	js := infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
+	s.Require().Len(store.keys, 1)
+	created := store.keys[0]
+
+	s.Assert().Equal("EdDSA", created.Algorithm)
+	s.Assert().WithinDuration(time.Now(), created.ActivatesAt, time.Second)
+	s.Assert().Equal(created.ActivatesAt.Add(s.config.RotateEvery), created.RetiresAt)
+	s.Assert().True(created.ExpiresAt.After(created.RetiresAt.Add(24 * time.Hour)))
+
+	token, err := js.GenerateJWT(&domain.User{ID: "1", Username: "alice", Role: domain.RoleUser})
+	s.Require().NoError(err)
+	s.Assert().Equal(created.ID, s.kid(token))
+	claims, err := js.ParseJWT(token)
+	s.Require().NoError(err)
+	s.Assert().Equal("1", claims.UserID)
+	s.Assert().Equal("1", claims.Subject)
+	s.Assert().Equal(infrastructure.DefaultJWTIssuer, claims.Issuer)
+}
+
+func (s *KeyringTestSuite) TestRotate_PublishesNextKeyAhead() {
+	// the current key retires in 12 hours, within a day of publishing
+	current, _ := s.storedKey("current", s.ed25519Key(), s.config.RotateEvery-12*time.Hour)
+	store := &signingKeyStore{keys: []domain.SigningKey{current}}
+	keys, err := infrastructure.NewKeyring(store, s.config)
+	s.Require().NoError(err)
+	js := infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
+	s.Require().Len(store.keys, 2)
+	next := store.keys[1]
+
+	s.Assert().Equal(current.RetiresAt, next.ActivatesAt)
+	token, err := js.GenerateJWT(&domain.User{ID: "1", Username: "alice", Role: domain.RoleUser})
+	s.Require().NoError(err)
+	s.Assert().Equal("current", s.kid(token), "the next key must not sign before it activates")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	infrastructure.JWKSHandler(js)(c)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Contains(w.Header().Get("Cache-Control"), "max-age=")
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &jwks))
+	s.Require().Len(jwks.Keys, 2)
+	s.Assert().Equal("current", jwks.Keys[0]["kid"])
+	s.Assert().Equal(next.ID, jwks.Keys[1]["kid"])
+	s.Assert().Equal("OKP", jwks.Keys[0]["kty"])
+	s.Assert().Equal("Ed25519", jwks.Keys[0]["crv"])
+	s.Assert().Equal("EdDSA", jwks.Keys[0]["alg"])
+	s.Assert().NotContains(jwks.Keys[0], "d", "private keys must not be published")
+}
+
+func (s *KeyringTestSuite) TestRotate_NotDue() {
+	current, _ := s.storedKey("current", s.ed25519Key(), time.Hour)
+	s.mockKeyRepo.On("List", mock.Anything).Return([]domain.SigningKey{current}, nil)
+
+	keys, err := infrastructure.NewKeyring(s.mockKeyRepo, s.config)
+	s.Require().NoError(err)
+	s.Require().NoError(keys.Rotate(time.Now().Add(time.Hour)))
+
+	s.mockKeyRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *KeyringTestSuite) TestParseJWT_OlderKeysStillVerify() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	// an RS256 key from before the algorithm was changed, and the current one
+	older, olderPrivate := s.storedKey("older", rsaKey, 2*time.Hour)
+	newer, _ := s.storedKey("newer", s.ed25519Key(), time.Hour)
+	s.mockKeyRepo.On("List", mock.Anything).Return([]domain.SigningKey{newer, older}, nil)
+	js := s.jwtService()
+
+	token, err := js.GenerateJWT(&domain.User{ID: "1", Username: "alice", Role: domain.RoleUser})
+	s.Require().NoError(err)
+	s.Assert().Equal("newer", s.kid(token))
+
+	now := time.Now()
+	old := jwt.NewWithClaims(jwt.SigningMethodRS256, infrastructure.CustomClaims{
+		UserID: "2",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    infrastructure.DefaultJWTIssuer,
+			Audience:  jwt.ClaimStrings{"api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	old.Header["kid"] = "older"
+	signed, err := old.SignedString(olderPrivate)
+	s.Require().NoError(err)
+
+	claims, err := js.ParseJWT(signed)
+	s.Require().NoError(err)
+	s.Assert().Equal("2", claims.UserID)
+}
+
+func (s *KeyringTestSuite) TestParseJWT_Rejects() {
+	current, private := s.storedKey("current", s.ed25519Key(), time.Hour)
+	s.mockKeyRepo.On("List", mock.Anything).Return([]domain.SigningKey{current}, nil)
+	js := s.jwtService()
+	now := time.Now()
+	valid := infrastructure.CustomClaims{
+		UserID: "1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    infrastructure.DefaultJWTIssuer,
+			Audience:  jwt.ClaimStrings{"api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	sign := func(method jwt.SigningMethod, kid string, claims infrastructure.CustomClaims, key any) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		s.Require().NoError(err)
+		return signed
+	}
+
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour))
+	otherIssuer := valid
+	otherIssuer.Issuer = "someone-else"
+	otherAudience := valid
+	otherAudience.Audience = jwt.ClaimStrings{"mfa-challenge"}
+
+	cases := map[string]struct {
+		token string
+		err   error
+	}{
+		"expired":        {sign(jwt.SigningMethodEdDSA, "current", expired, private), errs.ErrTokenExpired},
+		"other issuer":   {sign(jwt.SigningMethodEdDSA, "current", otherIssuer, private), errs.ErrInvalidToken},
+		"other audience": {sign(jwt.SigningMethodEdDSA, "current", otherAudience, private), errs.ErrInvalidToken},
+		"unknown key":    {sign(jwt.SigningMethodEdDSA, "unknown", valid, s.ed25519Key()), errs.ErrInvalidToken},
+		"wrong key":      {sign(jwt.SigningMethodEdDSA, "current", valid, s.ed25519Key()), errs.ErrInvalidToken},
+		"shared secret":  {sign(jwt.SigningMethodHS256, "current", valid, []byte("task_manager_secret")), errs.ErrInvalidToken},
+	}
+	for name, c := range cases {
+		_, err := js.ParseJWT(c.token)
+		s.Assert().ErrorIsf(err, c.err, name)
+	}
+}
+
+func (s *KeyringTestSuite) TestNewKeyring_UnsupportedAlgorithm() {
+	s.config.Algorithm = "HS256"
+	_, err := infrastructure.NewKeyring(s.mockKeyRepo, s.config)
+	s.Assert().Error(err)
+}
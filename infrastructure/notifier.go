@@ -0,0 +1,106 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+)
+
+// secretKinds are the notifications whose body carries a token that only
+// the recipient may see, so they are useless unless delivered.
+var secretKinds = []string{domain.NotificationPasswordReset, domain.NotificationInvitation}
+
+type logNotifier struct{}
+
+// NewLogNotifier returns a Notifier that writes notifications to the server
+// log. It is the default until a real delivery channel is configured. It
+// cannot deliver password resets or invitations, whose tokens it keeps out
+// of the log, and reports each of them as an error there.
+func NewLogNotifier() usecases.Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Notify(notification domain.Notification) error {
+	recipients := "everyone"
+	if len(notification.Recipients) > 0 {
+		recipients = strings.Join(notification.Recipients, ",")
+	}
+	if slices.Contains(secretKinds, notification.Kind) {
+		log.Printf("ERROR: NOTIFY [%s] to=%s was not delivered: configure NOTIFY_WEBHOOK_URL to send it", notification.Kind, recipients)
+		return nil
+	}
+	log.Printf("NOTIFY [%s] to=%s task=%s: %s", notification.Kind, recipients, notification.TaskID, notification.Subject)
+	return nil
+}
+
+// webhookTimeout bounds each delivery, since notifications are sent while
+// requests and scheduler ticks wait.
+const webhookTimeout = 10 * time.Second
+
+type webhookNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// webhookNotification is the JSON body POSTed for each notification.
+type webhookNotification struct {
+	Kind       string    `json:"kind"`
+	Recipients []string  `json:"recipients"`
+	TaskID     string    `json:"task_id,omitempty"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewWebhookNotifier returns a Notifier that POSTs every notification as
+// JSON to url, such as a mail relay. The body may hold tokens, so url should
+// use HTTPS; token, if set, is sent as a bearer token for the receiver to
+// check.
+func NewWebhookNotifier(url, token string) usecases.Notifier {
+	return &webhookNotifier{url: url, token: token, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (n *webhookNotifier) Notify(notification domain.Notification) error {
+	payload, err := json.Marshal(webhookNotification{
+		Kind:       notification.Kind,
+		Recipients: notification.Recipients,
+		TaskID:     notification.TaskID,
+		Subject:    notification.Subject,
+		Body:       notification.Body,
+		CreatedAt:  notification.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: notification not delivered: %v", errs.ErrUnexpected, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: notification not delivered: the webhook answered %s", errs.ErrUnexpected, resp.Status)
+	}
+	return nil
+}
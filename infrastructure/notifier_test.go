@@ -0,0 +1,75 @@
+package infrastructure_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhookNotifierTestSuite struct {
+	suite.Suite
+	status   int
+	received []map[string]any
+	auth     string
+	server   *httptest.Server
+}
+
+func (s *WebhookNotifierTestSuite) SetupTest() {
+	s.status = http.StatusNoContent
+	s.received = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(r.Body).Decode(&body))
+		s.received = append(s.received, body)
+		s.auth = r.Header.Get("Authorization")
+		w.WriteHeader(s.status)
+	}))
+}
+
+func (s *WebhookNotifierTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	suite.Run(t, new(WebhookNotifierTestSuite))
+}
+
+func (s *WebhookNotifierTestSuite) TestDeliversTheBody() {
+	notifier := infrastructure.NewWebhookNotifier(s.server.URL, "hook-secret")
+
+	err := notifier.Notify(domain.Notification{
+		Kind:       domain.NotificationPasswordReset,
+		Recipients: []string{"test@example.com"},
+		Subject:    "Reset your password",
+		Body:       "Use this token: secret-token\n",
+		CreatedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	s.Require().NoError(err)
+	s.Require().Len(s.received, 1)
+	s.Assert().Equal("Bearer hook-secret", s.auth)
+	s.Assert().Equal(map[string]any{
+		"kind":       "password_reset",
+		"recipients": []any{"test@example.com"},
+		"subject":    "Reset your password",
+		"body":       "Use this token: secret-token\n",
+		"created_at": "2026-01-02T03:04:05Z",
+	}, s.received[0])
+}
+
+func (s *WebhookNotifierTestSuite) TestFailedDeliveryIsAnError() {
+	s.status = http.StatusBadGateway
+	notifier := infrastructure.NewWebhookNotifier(s.server.URL, "")
+
+	err := notifier.Notify(domain.Notification{Kind: domain.NotificationInvitation, Recipients: []string{"new@example.com"}})
+
+	s.Assert().ErrorIs(err, errs.ErrUnexpected)
+	s.Assert().Empty(s.auth)
+}
@@ -0,0 +1,327 @@
+package infrastructure
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCConfig registers the app as a client of an OpenID Connect provider.
+// ClientSecret may be empty for public clients, which rely on PKCE alone.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to, i.e. the
+	// /auth/oidc/callback route.
+	RedirectURL string
+	Scopes      []string
+}
+
+const (
+	oidcTimeout = 10 * time.Second
+	// jwksRefreshInterval limits how often an unknown key ID makes the
+	// provider's keys be fetched again.
+	jwksRefreshInterval = time.Minute
+)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+// NewOIDCProvider returns a provider that discovers its endpoints from the
+// issuer's /.well-known/openid-configuration on first use.
+func NewOIDCProvider(config OIDCConfig, client *http.Client) usecases.IdentityProvider {
+	if client == nil {
+		client = &http.Client{Timeout: oidcTimeout}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &oidcProvider{config: config, client: client}
+}
+
+func (p *oidcProvider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+func (p *oidcProvider) Exchange(code, codeVerifier, nonce string) (*domain.IdentityClaims, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.config.ClientID)
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrUnexpected, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrUnexpected, fmt.Errorf("token request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, errs.Wrap(errs.ErrUnexpected, fmt.Errorf("token response: %w", err))
+	}
+	if body.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", errs.ErrSSOFailed, body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint answered %s without an ID token", errs.ErrSSOFailed, resp.Status)
+	}
+	return p.verifyIDToken(body.IDToken, nonce)
+}
+
+func (p *oidcProvider) verifyIDToken(idToken, nonce string) (*domain.IdentityClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, p.key,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ID token: %v", errs.ErrSSOFailed, err)
+	}
+	// the nonce binds the token to the login this browser started
+	if got, _ := claims["nonce"].(string); got != nonce || nonce == "" {
+		return nil, fmt.Errorf("%w: ID token nonce does not match", errs.ErrSSOFailed)
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", errs.ErrSSOFailed)
+	}
+	return &domain.IdentityClaims{Issuer: p.config.Issuer, Subject: subject, Claims: claims}, nil
+}
+
+// key finds the provider key that signed a token, fetching the keys again
+// if it is not known, e.g. after the provider rotated them.
+func (p *oidcProvider) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(kid, token.Method); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := p.fetchKeys(); err != nil {
+		return nil, err
+	}
+	if key, ok := p.lookupKey(kid, token.Method); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey accepts a token without a key ID if the provider has just one
+// key of the right type.
+func (p *oidcProvider) lookupKey(kid string, method jwt.SigningMethod) (any, bool) {
+	if kid != "" {
+		key, ok := p.keys[kid]
+		return key, ok
+	}
+	var found any
+	for _, key := range p.keys {
+		_, isRSA := key.(*rsa.PublicKey)
+		if isRSA != strings.HasPrefix(method.Alg(), "RS") {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = key
+	}
+	return found, found != nil
+}
+
+func (p *oidcProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.config.Issuer {
+		return nil, errs.Wrap(errs.ErrUnexpected, fmt.Errorf("OIDC discovery: issuer %q does not match %q", discovery.Issuer, p.config.Issuer))
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errs.Wrap(errs.ErrUnexpected, fmt.Errorf("OIDC discovery: endpoints missing"))
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// fetchKeys loads the provider's RSA, EC and Ed25519 signing keys. p.mu is held.
+func (p *oidcProvider) fetchKeys() error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(p.discovery.JWKSURI, &set); err != nil {
+		return err
+	}
+	p.keysFetched = time.Now()
+
+	keys := make(map[string]any)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (p *oidcProvider) getJSON(url string, v any) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return errs.Wrap(errs.ErrUnexpected, fmt.Errorf("OIDC: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errs.Wrap(errs.ErrUnexpected, fmt.Errorf("OIDC: GET %s: %s", url, resp.Status))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return errs.Wrap(errs.ErrUnexpected, fmt.Errorf("OIDC: GET %s: %w", url, err))
+	}
+	return nil
+}
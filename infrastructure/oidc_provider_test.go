@@ -0,0 +1,125 @@
+package infrastructure_test
+
+import (
+	"net/url"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"task-manager/infrastructure/oidctest"
+	"task-manager/usecases"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// the verifier and S256 challenge from RFC 7636, appendix B
+const (
+	pkceVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	pkceChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+type OIDCProviderTestSuite struct {
+	suite.Suite
+	idp      *oidctest.Server
+	provider usecases.IdentityProvider
+}
+
+func (s *OIDCProviderTestSuite) SetupTest() {
+	s.idp = oidctest.NewServer("task-manager", "client-secret")
+	s.provider = s.newProvider(s.idp.Issuer(), "client-secret")
+}
+
+func (s *OIDCProviderTestSuite) TearDownTest() {
+	s.idp.Close()
+}
+
+func TestOIDCProvider(t *testing.T) {
+	suite.Run(t, new(OIDCProviderTestSuite))
+}
+
+func (s *OIDCProviderTestSuite) newProvider(issuer, secret string) usecases.IdentityProvider {
+	return infrastructure.NewOIDCProvider(infrastructure.OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     "task-manager",
+		ClientSecret: secret,
+		RedirectURL:  "http://app.example/auth/oidc/callback",
+	}, nil)
+}
+
+// authorize starts a login and returns the code the provider sends back.
+func (s *OIDCProviderTestSuite) authorize(nonce string) string {
+	authURL, err := s.provider.AuthCodeURL("the-state", nonce, pkceChallenge)
+	s.Require().NoError(err)
+	code, state, err := s.idp.Authorize(authURL)
+	s.Require().NoError(err)
+	s.Require().Equal("the-state", state)
+	return code
+}
+
+func (s *OIDCProviderTestSuite) TestAuthCodeURL() {
+	authURL, err := s.provider.AuthCodeURL("the-state", "the-nonce", pkceChallenge)
+	s.Require().NoError(err)
+
+	parsed, err := url.Parse(authURL)
+	s.Require().NoError(err)
+	s.Assert().Equal(s.idp.Issuer()+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	query := parsed.Query()
+	s.Assert().Equal("code", query.Get("response_type"))
+	s.Assert().Equal("task-manager", query.Get("client_id"))
+	s.Assert().Equal("openid profile email", query.Get("scope"))
+	s.Assert().Equal("the-nonce", query.Get("nonce"))
+	s.Assert().Equal(pkceChallenge, query.Get("code_challenge"))
+	s.Assert().Equal("S256", query.Get("code_challenge_method"))
+}
+
+func (s *OIDCProviderTestSuite) TestExchange() {
+	s.idp.SetUser(map[string]any{"sub": "42", "preferred_username": "alice", "groups": []string{"staff"}})
+	code := s.authorize("the-nonce")
+
+	claims, err := s.provider.Exchange(code, pkceVerifier, "the-nonce")
+
+	s.Require().NoError(err)
+	s.Assert().Equal(s.idp.Issuer(), claims.Issuer)
+	s.Assert().Equal("42", claims.Subject)
+	s.Assert().Equal("alice", claims.Claims["preferred_username"])
+	s.Assert().Equal([]any{"staff"}, claims.Claims["groups"])
+}
+
+func (s *OIDCProviderTestSuite) TestExchange_WrongVerifier() {
+	code := s.authorize("the-nonce")
+
+	_, err := s.provider.Exchange(code, "not-the-verifier-that-was-hashed-for-this-code", "the-nonce")
+
+	s.Assert().ErrorIs(err, errs.ErrSSOFailed)
+}
+
+func (s *OIDCProviderTestSuite) TestExchange_CodeIsSingleUse() {
+	code := s.authorize("the-nonce")
+	_, err := s.provider.Exchange(code, pkceVerifier, "the-nonce")
+	s.Require().NoError(err)
+
+	_, err = s.provider.Exchange(code, pkceVerifier, "the-nonce")
+
+	s.Assert().ErrorIs(err, errs.ErrSSOFailed)
+}
+
+func (s *OIDCProviderTestSuite) TestExchange_WrongNonce() {
+	code := s.authorize("the-nonce")
+
+	_, err := s.provider.Exchange(code, pkceVerifier, "another-nonce")
+
+	s.Assert().ErrorIs(err, errs.ErrSSOFailed)
+}
+
+func (s *OIDCProviderTestSuite) TestExchange_WrongClientSecret() {
+	code := s.authorize("the-nonce")
+
+	_, err := s.newProvider(s.idp.Issuer(), "wrong").Exchange(code, pkceVerifier, "the-nonce")
+
+	s.Assert().ErrorIs(err, errs.ErrSSOFailed)
+}
+
+func (s *OIDCProviderTestSuite) TestDiscovery_IssuerMismatch() {
+	_, err := s.newProvider(s.idp.Issuer()+"/", "client-secret").AuthCodeURL("state", "nonce", pkceChallenge)
+
+	s.Assert().ErrorIs(err, errs.ErrUnexpected)
+}
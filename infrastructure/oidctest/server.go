@@ -0,0 +1,244 @@
+// Package oidctest runs a minimal OpenID Connect provider for tests and local
+// development. It implements the authorization code flow with PKCE and signs
+// in whichever user was set with SetUser, without asking for credentials.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "oidctest"
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]any
+}
+
+// Server is the stand-in provider. It accepts any redirect URI.
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	user   map[string]any
+	grants map[string]grant
+}
+
+// NewServer starts a provider for one client. An empty clientSecret makes
+// it a public client.
+func NewServer(clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		user:         map[string]any{"sub": "user-1", "preferred_username": "alice"},
+		grants:       make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Issuer is the issuer identifier to configure the client with.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// SetUser sets the claims of whoever logs in next. They must include "sub".
+func (s *Server) SetUser(claims map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = claims
+}
+
+// Authorize plays the browser: it follows authURL and returns the code and
+// state the provider redirects back with.
+func (s *Server) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("authorize: %s", resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	query := location.Query()
+	if query.Get("error") != "" {
+		return "", "", fmt.Errorf("authorize: %s", query.Get("error"))
+	}
+	return query.Get("code"), query.Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]any{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := redirectURI.Query()
+	back.Set("state", query.Get("state"))
+
+	switch {
+	case query.Get("client_id") != s.ClientID:
+		back.Set("error", "unauthorized_client")
+	case query.Get("response_type") != "code":
+		back.Set("error", "unsupported_response_type")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		back.Set("error", "invalid_request")
+	default:
+		code := randomString()
+		s.mu.Lock()
+		s.grants[code] = grant{
+			clientID:      s.ClientID,
+			redirectURI:   redirectURI.String(),
+			nonce:         query.Get("nonce"),
+			codeChallenge: query.Get("code_challenge"),
+			claims:        s.user,
+		}
+		s.mu.Unlock()
+		back.Set("code", code)
+	}
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	// codes are single use, even when the exchange fails
+	s.mu.Lock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.codeChallenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+	claims["iss"] = s.URL
+	claims["aud"] = g.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,108 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"task-manager/domain"
+	"task-manager/usecases"
+	"time"
+)
+
+// PolicyFile holds the access policies of a JSON file of the form
+// {"policies": [...]}, and reloads them when the file changes.
+type PolicyFile struct {
+	path string
+
+	mu       sync.RWMutex
+	policies []domain.Policy
+	// read tells whether the file was read at modTime and size, even if
+	// it turned out to be broken.
+	read    bool
+	modTime time.Time
+	size    int64
+}
+
+// LoadPolicyFile reads and validates the policies at path.
+func LoadPolicyFile(path string) (*PolicyFile, error) {
+	f := &PolicyFile{path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *PolicyFile) Policies() []domain.Policy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.policies
+}
+
+// Run reloads the policies every interval if the file has changed, until
+// ctx is done. A broken edit is logged and the policies from before it
+// stay in force.
+func (f *PolicyFile) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := f.Reload()
+			if err != nil {
+				log.Printf("ERROR: Keeping the previous access policies: %v", err)
+			} else if reloaded {
+				log.Printf("INFO: Reloaded %d access policies from %s", len(f.Policies()), f.path)
+			}
+		}
+	}
+}
+
+// Reload reads the file if it changed since it was last read, reporting
+// whether it did. A broken file is only reported once.
+func (f *PolicyFile) Reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+	f.mu.RLock()
+	unchanged := f.read && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	policies, err := readPolicies(f.path)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.read, f.modTime, f.size = true, info.ModTime(), info.Size()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", f.path, err)
+	}
+	f.policies = policies
+	return true, nil
+}
+
+func readPolicies(path string) ([]domain.Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Policies []domain.Policy `json:"policies"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if err := usecases.ValidatePolicies(doc.Policies); err != nil {
+		return nil, err
+	}
+	return doc.Policies, nil
+}
@@ -0,0 +1,70 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"task-manager/infrastructure"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const denyPolicy = `{"policies": [{"id": "hide-done", "effect": "deny", "actions": ["task.read"],
+	"conditions": [{"attribute": "resource.status", "operator": "in", "values": ["Completed"]}]}]}`
+
+type PolicyFileTestSuite struct {
+	suite.Suite
+	path string
+}
+
+func (s *PolicyFileTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "policies.json")
+}
+
+func TestPolicyFile(t *testing.T) {
+	suite.Run(t, new(PolicyFileTestSuite))
+}
+
+func (s *PolicyFileTestSuite) write(content string) {
+	s.Require().NoError(os.WriteFile(s.path, []byte(content), 0o600))
+}
+
+func (s *PolicyFileTestSuite) TestReload() {
+	s.write(`{"policies": []}`)
+	file, err := infrastructure.LoadPolicyFile(s.path)
+	s.Require().NoError(err)
+	s.Assert().Empty(file.Policies())
+
+	reloaded, err := file.Reload()
+	s.Require().NoError(err)
+	s.Assert().False(reloaded, "unchanged files are not read again")
+
+	s.write(denyPolicy)
+	reloaded, err = file.Reload()
+	s.Require().NoError(err)
+	s.Assert().True(reloaded)
+	s.Require().Len(file.Policies(), 1)
+	s.Assert().Equal("hide-done", file.Policies()[0].ID)
+}
+
+func (s *PolicyFileTestSuite) TestBrokenEditKeepsPolicies() {
+	s.write(denyPolicy)
+	file, err := infrastructure.LoadPolicyFile(s.path)
+	s.Require().NoError(err)
+
+	s.write(`{"policies": [{"id": "x", "effect": "allow", "actions": ["task.fly"]}]}`)
+	_, err = file.Reload()
+	s.Assert().ErrorContains(err, `unknown action "task.fly"`)
+	s.Assert().Len(file.Policies(), 1)
+
+	reloaded, err := file.Reload()
+	s.Assert().NoError(err, "a broken file is reported once")
+	s.Assert().False(reloaded)
+}
+
+func (s *PolicyFileTestSuite) TestUnknownField() {
+	s.write(`{"policies": [{"id": "x", "effect": "allow", "actions": ["task.read"], "subject": "bob"}]}`)
+
+	_, err := infrastructure.LoadPolicyFile(s.path)
+	s.Assert().ErrorContains(err, "unknown field")
+}
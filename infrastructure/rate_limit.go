@@ -0,0 +1,176 @@
+package infrastructure
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The route groups that have rate limits.
+const (
+	// RateLimitAuth covers the public routes that take credentials.
+	RateLimitAuth = "auth"
+	// RateLimitAPI covers the routes of logged-in users.
+	RateLimitAPI = "api"
+)
+
+// DefaultRateLimits allow 10 attempts to log in, register or reset a
+// password a minute, and 300 API requests.
+var DefaultRateLimits = map[string]domain.RateLimit{
+	RateLimitAuth: {Requests: 10, Period: time.Minute},
+	RateLimitAPI:  {Requests: 300, Period: time.Minute},
+}
+
+// ParseRateLimit reads a limit such as "10/1m", 10 requests a minute. "off"
+// turns the limit off.
+func ParseRateLimit(s string) (domain.RateLimit, error) {
+	if s == "off" {
+		return domain.RateLimit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return domain.RateLimit{}, fmt.Errorf("rate limit %q is not requests/period, such as 10/1m", s)
+	}
+	var limit domain.RateLimit
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+		return domain.RateLimit{}, fmt.Errorf("rate limit %q does not start with a positive number of requests", s)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return domain.RateLimit{}, fmt.Errorf("rate limit %q does not end with a positive duration", s)
+	}
+	return limit, nil
+}
+
+// RateLimitKey picks the bucket a request takes its token from.
+type RateLimitKey func(c *gin.Context) string
+
+// RateLimitByIP gives every client address a bucket.
+func RateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitByUser gives every user a bucket, and requests without one a
+// bucket per address. It follows AuthMiddleware.
+func RateLimitByUser(c *gin.Context) string {
+	if user, ok := c.Get("user"); ok {
+		return "user:" + user.(*domain.User).ID
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitByAPIToken gives every API token a bucket apart from its user's,
+// and otherwise works like RateLimitByUser.
+func RateLimitByAPIToken(c *gin.Context) string {
+	if token, ok := c.Get("api_token"); ok {
+		return "token:" + token.(*domain.APIToken).ID
+	}
+	return RateLimitByUser(c)
+}
+
+// RateLimiter limits route groups, each with a limit of its own.
+type RateLimiter struct {
+	store  usecases.RateLimitStore
+	limits map[string]domain.RateLimit
+}
+
+// NewRateLimiter creates a limiter keeping its buckets in store. Groups
+// without an enabled limit are not limited.
+func NewRateLimiter(store usecases.RateLimitStore, limits map[string]domain.RateLimit) *RateLimiter {
+	return &RateLimiter{store: store, limits: limits}
+}
+
+// Limit creates the middleware of a route group, whose requests take their
+// tokens from the buckets key picks. Every response says how many are left
+// in RateLimit-* headers, and requests finding none get a 429 with
+// Retry-After. A nil limiter limits nothing.
+func (l *RateLimiter) Limit(group string, key RateLimitKey) gin.HandlerFunc {
+	var limit domain.RateLimit
+	if l != nil {
+		limit = l.limits[group]
+	}
+	if !limit.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(math.Ceil(limit.Period.Seconds())))
+	return func(c *gin.Context) {
+		status, err := l.store.Take(group+":"+key(c), limit, time.Now())
+		if err != nil {
+			// an unavailable store should not take the API down with it
+			log.Printf("WARN: rate limit of %s not checked: %v", c.Request.URL.Path, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(math.Ceil(status.Reset.Seconds()))))
+		if !status.Allowed {
+			abortWithError(c, &errs.RetryError{Err: errs.ErrRateLimited, After: status.RetryAfter})
+			return
+		}
+		c.Next()
+	}
+}
+
+// memoryRateLimitStore keeps buckets in memory, so every replica has its
+// own.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	swept   time.Time
+}
+
+type memoryBucket struct {
+	domain.TokenBucket
+	// full is when the bucket will have filled up again
+	full time.Time
+}
+
+// rateLimitSweepInterval is how often full buckets are forgotten.
+const rateLimitSweepInterval = time.Minute
+
+// NewMemoryRateLimitStore creates a store for a single replica.
+func NewMemoryRateLimitStore() usecases.RateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *memoryRateLimitStore) Take(key string, limit domain.RateLimit, now time.Time) (domain.RateLimitStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	bucket := s.buckets[key]
+	if bucket == nil {
+		bucket = &memoryBucket{}
+		s.buckets[key] = bucket
+	}
+	status := limit.Take(&bucket.TokenBucket, now)
+	bucket.full = now.Add(status.Reset)
+	return status, nil
+}
+
+// sweep forgets the buckets that filled up again, which are no different
+// from new ones.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < rateLimitSweepInterval {
+		return
+	}
+	s.swept = now
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
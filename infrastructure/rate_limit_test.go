@@ -0,0 +1,145 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"task-manager/domain"
+	"task-manager/infrastructure"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+func TestRateLimit(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
+
+// brokenStore fails like a store whose database is down.
+type brokenStore struct{}
+
+func (brokenStore) Take(string, domain.RateLimit, time.Time) (domain.RateLimitStatus, error) {
+	return domain.RateLimitStatus{}, errors.New("connection refused")
+}
+
+// serve routes GET /limited through the middleware of rl, after setting the
+// context values given.
+func (s *RateLimitTestSuite) serve(rl *infrastructure.RateLimiter, key infrastructure.RateLimitKey, values map[string]any) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(infrastructure.ErrorMiddleware())
+	r.GET("/limited", func(c *gin.Context) {
+		for k, v := range values {
+			c.Set(k, v)
+		}
+	}, rl.Limit(infrastructure.RateLimitAPI, key), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func get(r *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func (s *RateLimitTestSuite) TestParseRateLimit() {
+	limit, err := infrastructure.ParseRateLimit("10/1m")
+	s.Require().NoError(err)
+	s.Assert().Equal(domain.RateLimit{Requests: 10, Period: time.Minute}, limit)
+
+	limit, err = infrastructure.ParseRateLimit("off")
+	s.Require().NoError(err)
+	s.Assert().False(limit.Enabled())
+
+	for _, invalid := range []string{"10", "ten/1m", "0/1m", "10/minute", "10/0s"} {
+		_, err := infrastructure.ParseRateLimit(invalid)
+		s.Assert().Error(err, invalid)
+	}
+}
+
+func (s *RateLimitTestSuite) TestMemoryStore_RefillsAtTheRate() {
+	store := infrastructure.NewMemoryRateLimitStore()
+	limit := domain.RateLimit{Requests: 3, Period: 3 * time.Second}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		status, err := store.Take("k", limit, start)
+		s.Require().NoError(err)
+		s.Assert().True(status.Allowed)
+		s.Assert().Equal(2-i, status.Remaining)
+	}
+	status, _ := store.Take("k", limit, start.Add(500*time.Millisecond))
+	s.Assert().False(status.Allowed, "the bucket is empty")
+	s.Assert().Equal(500*time.Millisecond, status.RetryAfter)
+	s.Assert().Equal(2500*time.Millisecond, status.Reset)
+
+	status, _ = store.Take("k", limit, start.Add(time.Second))
+	s.Assert().True(status.Allowed, "a token came back after a second")
+	status, _ = store.Take("other", limit, start.Add(time.Second))
+	s.Assert().Equal(2, status.Remaining, "every key has its own bucket")
+
+	status, _ = store.Take("k", limit, start.Add(time.Hour))
+	s.Assert().Equal(2, status.Remaining, "buckets do not fill beyond their size")
+}
+
+func (s *RateLimitTestSuite) TestLimit() {
+	rl := infrastructure.NewRateLimiter(infrastructure.NewMemoryRateLimitStore(), map[string]domain.RateLimit{
+		infrastructure.RateLimitAPI: {Requests: 1, Period: time.Minute},
+	})
+	r := s.serve(rl, infrastructure.RateLimitByIP, nil)
+
+	w := get(r)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Assert().Equal("1;w=60", w.Header().Get("RateLimit-Policy"))
+	s.Assert().Equal("1", w.Header().Get("RateLimit-Limit"))
+	s.Assert().Equal("0", w.Header().Get("RateLimit-Remaining"))
+	s.Assert().Equal("60", w.Header().Get("RateLimit-Reset"))
+
+	w = get(r)
+	s.Require().Equal(http.StatusTooManyRequests, w.Code)
+	s.Assert().Equal("application/problem+json", w.Header().Get("Content-Type"))
+	s.Assert().Equal("60", w.Header().Get("Retry-After"))
+}
+
+func (s *RateLimitTestSuite) TestLimit_ByAPIToken() {
+	rl := infrastructure.NewRateLimiter(infrastructure.NewMemoryRateLimitStore(), map[string]domain.RateLimit{
+		infrastructure.RateLimitAPI: {Requests: 1, Period: time.Minute},
+	})
+	user := &domain.User{ID: "u1"}
+
+	s.Require().Equal(http.StatusOK, get(s.serve(rl, infrastructure.RateLimitByAPIToken, map[string]any{"user": user})).Code)
+	// the token of the same user, from the same address, has a bucket of its own
+	withToken := s.serve(rl, infrastructure.RateLimitByAPIToken, map[string]any{"user": user, "api_token": &domain.APIToken{ID: "t1"}})
+	s.Require().Equal(http.StatusOK, get(withToken).Code)
+	s.Assert().Equal(http.StatusTooManyRequests, get(withToken).Code)
+	s.Assert().Equal(http.StatusTooManyRequests, get(s.serve(rl, infrastructure.RateLimitByUser, map[string]any{"user": user})).Code)
+}
+
+func (s *RateLimitTestSuite) TestLimit_Unlimited() {
+	var none *infrastructure.RateLimiter
+	w := get(s.serve(none, infrastructure.RateLimitByIP, nil))
+	s.Assert().Equal(http.StatusOK, w.Code)
+	s.Assert().Empty(w.Header().Get("RateLimit-Limit"))
+
+	off := infrastructure.NewRateLimiter(brokenStore{}, map[string]domain.RateLimit{infrastructure.RateLimitAPI: {}})
+	s.Assert().Equal(http.StatusOK, get(s.serve(off, infrastructure.RateLimitByIP, nil)).Code)
+}
+
+func (s *RateLimitTestSuite) TestLimit_LetsRequestsThroughWhenTheStoreFails() {
+	rl := infrastructure.NewRateLimiter(brokenStore{}, infrastructure.DefaultRateLimits)
+
+	w := get(s.serve(rl, infrastructure.RateLimitByIP, nil))
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+	s.Assert().Empty(w.Header().Get("RateLimit-Remaining"))
+}
@@ -0,0 +1,87 @@
+package infrastructure_test
+
+import (
+	"net/url"
+	"task-manager/infrastructure"
+	"task-manager/usecases"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// the ASCII secret "12345678901234567890" of the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+type TOTPServiceTestSuite struct {
+	suite.Suite
+	otp usecases.OTPService
+}
+
+func (s *TOTPServiceTestSuite) SetupTest() {
+	s.otp = infrastructure.NewTOTPService("Task Manager")
+}
+
+func TestTOTPService(t *testing.T) {
+	suite.Run(t, new(TOTPServiceTestSuite))
+}
+
+func (s *TOTPServiceTestSuite) TestValidate_RFCVectors() {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, v := range vectors {
+		step, ok := s.otp.Validate(rfcSecret, v.code, time.Unix(v.unix, 0))
+		s.Assert().True(ok, "code at %d", v.unix)
+		s.Assert().Equal(v.unix/30, step)
+	}
+}
+
+func (s *TOTPServiceTestSuite) TestValidate_Skew() {
+	// 287082 belongs to step 1
+	_, ok := s.otp.Validate(rfcSecret, "287082", time.Unix(89, 0))
+	s.Assert().True(ok, "one step late is accepted")
+	_, ok = s.otp.Validate(rfcSecret, "287082", time.Unix(95, 0))
+	s.Assert().False(ok, "two steps late is not")
+}
+
+func (s *TOTPServiceTestSuite) TestValidate_Rejects() {
+	at := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870822", "287083", "abcdef"} {
+		_, ok := s.otp.Validate(rfcSecret, code, at)
+		s.Assert().False(ok, code)
+	}
+	_, ok := s.otp.Validate("not base32!", "287082", at)
+	s.Assert().False(ok)
+}
+
+func (s *TOTPServiceTestSuite) TestGenerateSecret() {
+	secret, err := s.otp.GenerateSecret()
+	s.Require().NoError(err)
+	s.Assert().Len(secret, 32, "160 bits in unpadded base32")
+
+	other, err := s.otp.GenerateSecret()
+	s.Require().NoError(err)
+	s.Assert().NotEqual(secret, other)
+}
+
+func (s *TOTPServiceTestSuite) TestProvisioningURI() {
+	uri, err := url.Parse(s.otp.ProvisioningURI(rfcSecret, "jane doe"))
+	s.Require().NoError(err)
+
+	s.Assert().Equal("otpauth", uri.Scheme)
+	s.Assert().Equal("totp", uri.Host)
+	s.Assert().Equal("/Task Manager:jane doe", uri.Path)
+	query := uri.Query()
+	s.Assert().Equal(rfcSecret, query.Get("secret"))
+	s.Assert().Equal("Task Manager", query.Get("issuer"))
+	s.Assert().Equal("6", query.Get("digits"))
+	s.Assert().Equal("30", query.Get("period"))
+}
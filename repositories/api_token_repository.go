@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// expiredAPITokenRetention is how long expired tokens are still listed, so
+// that their owners can tell why a script stopped working.
+const expiredAPITokenRetention = 7 * 24 * time.Hour
+
+type mongoAPITokenRepository struct {
+	collection *mongo.Collection
+}
+
+type mongoAPIToken struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      string             `bson:"user_id"`
+	WorkspaceID string             `bson:"workspace_id"`
+	Name        string             `bson:"name"`
+	Scopes      []string           `bson:"scopes"`
+	TokenHash   string             `bson:"token_hash"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	ExpiresAt   *time.Time         `bson:"expires_at,omitempty"`
+	LastUsedAt  *time.Time         `bson:"last_used_at,omitempty"`
+}
+
+func NewMongoAPITokenRepository(collection *mongo.Collection) usecases.APITokenRepository {
+	return &mongoAPITokenRepository{collection: collection}
+}
+
+// EnsureAPITokenIndexes creates the unique index tokens are looked up by,
+// the index of each user's tokens and the TTL index that drops expired ones.
+func EnsureAPITokenIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetName("api_tokens_hash").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("api_tokens_user"),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("api_tokens_ttl").SetExpireAfterSeconds(int32(expiredAPITokenRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func buildAPIToken(from mongoAPIToken) *domain.APIToken {
+	token := &domain.APIToken{
+		ID:          from.ID.Hex(),
+		UserID:      from.UserID,
+		WorkspaceID: from.WorkspaceID,
+		Name:        from.Name,
+		Scopes:      from.Scopes,
+		TokenHash:   from.TokenHash,
+		CreatedAt:   from.CreatedAt,
+	}
+	if from.ExpiresAt != nil {
+		token.ExpiresAt = *from.ExpiresAt
+	}
+	if from.LastUsedAt != nil {
+		token.LastUsedAt = *from.LastUsedAt
+	}
+	return token
+}
+
+func (r *mongoAPITokenRepository) Create(token *domain.APIToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	doc := mongoAPIToken{
+		ID:          primitive.NewObjectID(),
+		UserID:      token.UserID,
+		WorkspaceID: token.WorkspaceID,
+		Name:        token.Name,
+		Scopes:      token.Scopes,
+		TokenHash:   token.TokenHash,
+		CreatedAt:   token.CreatedAt,
+	}
+	if !token.ExpiresAt.IsZero() {
+		doc.ExpiresAt = &token.ExpiresAt
+	}
+	if _, err := r.collection.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	token.ID = doc.ID.Hex()
+	return nil
+}
+
+func (r *mongoAPITokenRepository) ListByUser(userID string) ([]*domain.APIToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	var docs []mongoAPIToken
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	tokens := make([]*domain.APIToken, 0, len(docs))
+	for _, doc := range docs {
+		tokens = append(tokens, buildAPIToken(doc))
+	}
+	return tokens, nil
+}
+
+func (r *mongoAPITokenRepository) GetByHash(tokenHash string) (*domain.APIToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc mongoAPIToken
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrAPITokenNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildAPIToken(doc), nil
+}
+
+func (r *mongoAPITokenRepository) Delete(userID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrAPITokenNotFound
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.DeletedCount == 0 {
+		return errs.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+func (r *mongoAPITokenRepository) DeleteByUser(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoAPITokenRepository) UpdateLastUsed(id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrAPITokenNotFound
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"last_used_at": at}})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"fmt"
+	"regexp"
+	"task-manager/domain"
+	"task-manager/errs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var mongoTaskFields = map[string]string{
+	domain.FieldStatus:      "status",
+	domain.FieldTitle:       "title",
+	domain.FieldDescription: "description",
+	domain.FieldDue:         "due_date",
+	domain.FieldLabel:       "labels",
+	domain.FieldOverdue:     "overdue",
+}
+
+var mongoOperators = map[string]string{
+	domain.OpNe:  "$ne",
+	domain.OpLt:  "$lt",
+	domain.OpLte: "$lte",
+	domain.OpGt:  "$gt",
+	domain.OpGte: "$gte",
+}
+
+// buildMongoFilter compiles a parsed filter into a query document for the
+// tasks collection. A nil filter matches every task.
+func buildMongoFilter(filter *domain.Filter) (bson.M, error) {
+	if filter == nil {
+		return bson.M{}, nil
+	}
+
+	switch filter.Kind {
+	case domain.FilterAnd, domain.FilterOr, domain.FilterNot:
+		children := bson.A{}
+		for _, child := range filter.Children {
+			compiled, err := buildMongoFilter(child)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, compiled)
+		}
+		operator := map[string]string{domain.FilterAnd: "$and", domain.FilterOr: "$or", domain.FilterNot: "$nor"}[filter.Kind]
+		return bson.M{operator: children}, nil
+
+	case domain.FilterCompare:
+		field, ok := mongoTaskFields[filter.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", errs.ErrInvalidQuery, filter.Field)
+		}
+		switch filter.Op {
+		case domain.OpEq:
+			return bson.M{field: filter.Value}, nil
+		case domain.OpContains:
+			pattern := regexp.QuoteMeta(fmt.Sprint(filter.Value))
+			return bson.M{field: primitive.Regex{Pattern: pattern, Options: "i"}}, nil
+		}
+		if operator, ok := mongoOperators[filter.Op]; ok {
+			return bson.M{field: bson.M{operator: filter.Value}}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown operator %q", errs.ErrInvalidQuery, filter.Op)
+	}
+	return nil, fmt.Errorf("%w: unknown filter kind %q", errs.ErrInvalidQuery, filter.Kind)
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"task-manager/usecases"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FilterCompilerTestSuite struct {
+	suite.Suite
+}
+
+func TestFilterCompiler(t *testing.T) {
+	suite.Run(t, new(FilterCompilerTestSuite))
+}
+
+const compilerTestQuery = `status:"In Progress" AND due<2026-11-01 AND (label:backend OR NOT title:"50%")`
+
+func (s *FilterCompilerTestSuite) TestBuildMongoFilter() {
+	filter, err := usecases.ParseFilterQuery(compilerTestQuery)
+	s.Require().NoError(err)
+
+	query, err := buildMongoFilter(filter)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(bson.M{"$and": bson.A{
+		bson.M{"status": "In Progress"},
+		bson.M{"due_date": bson.M{"$lt": time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)}},
+		bson.M{"$or": bson.A{
+			bson.M{"labels": "backend"},
+			bson.M{"$nor": bson.A{bson.M{"title": primitive.Regex{Pattern: "50%", Options: "i"}}}},
+		}},
+	}}, query)
+}
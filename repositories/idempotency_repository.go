@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoIdempotencyStore struct {
+	collection *mongo.Collection
+}
+
+// mongoIdempotencyRecord is keyed by the scoped idempotency key, so that
+// only one request can claim it. The TTL index removes it once it expires.
+type mongoIdempotencyRecord struct {
+	Key         string                   `bson:"_id"`
+	Fingerprint string                   `bson:"fingerprint"`
+	Response    *mongoIdempotentResponse `bson:"response,omitempty"`
+	CreatedAt   time.Time                `bson:"created_at"`
+	ExpiresAt   time.Time                `bson:"expires_at"`
+}
+
+type mongoIdempotentResponse struct {
+	Status      int    `bson:"status"`
+	ContentType string `bson:"content_type"`
+	Body        []byte `bson:"body"`
+}
+
+// NewMongoIdempotencyStore creates a store that replicas can share.
+func NewMongoIdempotencyStore(collection *mongo.Collection) usecases.IdempotencyStore {
+	return &mongoIdempotencyStore{collection: collection}
+}
+
+// EnsureIdempotencyIndexes creates the TTL index that drops expired keys.
+func EnsureIdempotencyIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("idempotency_keys_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// Begin claims the key like a lease: the filter only matches a record that
+// has expired, which the TTL index may not have removed yet, so the upsert
+// of a key in use inserts a second document with the same _id and fails
+// with a duplicate key error. The record holding the key is then returned.
+func (r *mongoIdempotencyStore) Begin(record *domain.IdempotencyRecord, now time.Time) (*domain.IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": record.Key, "expires_at": bson.M{"$lte": now}}
+	update := bson.M{
+		"$set": bson.M{
+			"fingerprint": record.Fingerprint,
+			"created_at":  record.CreatedAt,
+			"expires_at":  record.ExpiresAt,
+		},
+		"$unset": bson.M{"response": ""},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	var existing mongoIdempotencyRecord
+	err = r.collection.FindOne(ctx, bson.M{"_id": record.Key}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// released or expired in the meantime; the client can try again
+		return nil, &errs.RetryError{Err: errs.ErrIdempotencyKeyInUse, After: time.Second}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildIdempotencyRecord(existing), nil
+}
+
+func (r *mongoIdempotencyStore) Complete(claim *domain.IdempotencyRecord, response domain.IdempotentResponse, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"response": mongoIdempotentResponse{
+			Status:      response.Status,
+			ContentType: response.ContentType,
+			Body:        response.Body,
+		},
+		"expires_at": expiresAt,
+	}}
+	_, err := r.collection.UpdateOne(ctx, claimFilter(claim), update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoIdempotencyStore) Release(claim *domain.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, claimFilter(claim))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// claimFilter matches the record of claim while it holds its key without a
+// response, and not the claim of a request that took the key over after it
+// expired.
+func claimFilter(claim *domain.IdempotencyRecord) bson.M {
+	return bson.M{
+		"_id":         claim.Key,
+		"fingerprint": claim.Fingerprint,
+		"created_at":  claim.CreatedAt,
+		"response":    bson.M{"$exists": false},
+	}
+}
+
+func buildIdempotencyRecord(from mongoIdempotencyRecord) *domain.IdempotencyRecord {
+	record := &domain.IdempotencyRecord{
+		Key:         from.Key,
+		Fingerprint: from.Fingerprint,
+		CreatedAt:   from.CreatedAt,
+		ExpiresAt:   from.ExpiresAt,
+	}
+	if from.Response != nil {
+		record.Response = &domain.IdempotentResponse{
+			Status:      from.Response.Status,
+			ContentType: from.Response.ContentType,
+			Body:        from.Response.Body,
+		}
+	}
+	return record
+}
@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoInvitationRepository struct {
+	collection *mongo.Collection
+}
+
+type mongoInvitation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	WorkspaceID string             `bson:"workspace_id,omitempty"`
+	Role        string             `bson:"role,omitempty"`
+	Email       string             `bson:"email,omitempty"`
+	CreatedBy   string             `bson:"created_by,omitempty"`
+	TokenHash   string             `bson:"token_hash"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	ExpiresAt   time.Time          `bson:"expires_at"`
+}
+
+func NewMongoInvitationRepository(collection *mongo.Collection) usecases.InvitationRepository {
+	return &mongoInvitationRepository{collection: collection}
+}
+
+// EnsureInvitationIndexes creates the unique index invitations are looked
+// up by, the index of each workspace's invitations and the TTL index that
+// drops expired ones.
+func EnsureInvitationIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetName("invitations_hash").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "workspace_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("invitations_workspace"),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("invitations_ttl").SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func buildInvitation(from mongoInvitation) *domain.Invitation {
+	return &domain.Invitation{
+		ID:          from.ID.Hex(),
+		WorkspaceID: from.WorkspaceID,
+		Role:        from.Role,
+		Email:       from.Email,
+		CreatedBy:   from.CreatedBy,
+		TokenHash:   from.TokenHash,
+		CreatedAt:   from.CreatedAt,
+		ExpiresAt:   from.ExpiresAt,
+	}
+}
+
+func (r *mongoInvitationRepository) Create(invitation *domain.Invitation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	if invitation.ID != "" {
+		existing, err := primitive.ObjectIDFromHex(invitation.ID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid invitation id %q", errs.ErrUnexpected, invitation.ID)
+		}
+		id = existing
+	}
+	_, err := r.collection.InsertOne(ctx, mongoInvitation{
+		ID:          id,
+		WorkspaceID: invitation.WorkspaceID,
+		Role:        invitation.Role,
+		Email:       invitation.Email,
+		CreatedBy:   invitation.CreatedBy,
+		TokenHash:   invitation.TokenHash,
+		CreatedAt:   invitation.CreatedAt,
+		ExpiresAt:   invitation.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	invitation.ID = id.Hex()
+	return nil
+}
+
+func (r *mongoInvitationRepository) ListByWorkspace(workspaceID string, now time.Time) ([]*domain.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"workspace_id": workspaceID, "expires_at": bson.M{"$gt": now}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	var docs []mongoInvitation
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	invitations := make([]*domain.Invitation, 0, len(docs))
+	for _, doc := range docs {
+		invitations = append(invitations, buildInvitation(doc))
+	}
+	return invitations, nil
+}
+
+// Consume deletes the invitation as it reads it, so two registrations with
+// the same token cannot both succeed. The TTL monitor only runs once a
+// minute, hence the explicit expiry check.
+func (r *mongoInvitationRepository) Consume(tokenHash string, now time.Time) (*domain.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc mongoInvitation
+	filter := bson.M{"token_hash": tokenHash, "expires_at": bson.M{"$gt": now}}
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrInvalidInvitation
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildInvitation(doc), nil
+}
+
+func (r *mongoInvitationRepository) Delete(workspaceID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvitationNotFound
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "workspace_id": workspaceID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.DeletedCount == 0 {
+		return errs.ErrInvitationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoLeaseRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoLease is a single lease document; the lease name is the _id so that
+// only one document, and therefore one holder, can exist per lease.
+type mongoLease struct {
+	Name      string    `bson:"_id"`
+	Holder    string    `bson:"holder"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func NewMongoLeaseRepository(collection *mongo.Collection) usecases.LeaseRepository {
+	return &mongoLeaseRepository{collection: collection}
+}
+
+// Acquire takes or renews the lease. The filter only matches when the lease
+// is already ours or has expired; otherwise the upsert tries to insert a
+// second document with the same _id and fails with a duplicate key error,
+// which means somebody else is holding it.
+func (r *mongoLeaseRepository) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"holder":     holder,
+			"expires_at": now.Add(ttl),
+		},
+	}
+
+	var lease mongoLease
+	err := r.collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&lease)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return lease.Holder == holder, nil
+}
+
+func (r *mongoLeaseRepository) Release(name, holder string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoLoginAttempts is keyed by the lockout key. ExpiresAt is the later of
+// the end of the counting window and the end of the lockout, after which a
+// TTL index removes the document.
+type mongoLoginAttempts struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until,omitempty"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+func NewMongoLoginAttemptRepository(collection *mongo.Collection) usecases.LoginAttemptRepository {
+	return &mongoLoginAttemptRepository{collection: collection}
+}
+
+// EnsureLoginAttemptIndexes creates the TTL index that drops counters once
+// they no longer matter.
+func EnsureLoginAttemptIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("login_attempts_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoLoginAttemptRepository) buildLoginAttempts(from mongoLoginAttempts) *domain.LoginAttempts {
+	return &domain.LoginAttempts{
+		Key:         from.Key,
+		Failures:    from.Failures,
+		LastFailure: from.LastFailure,
+		LockedUntil: from.LockedUntil,
+	}
+}
+
+func (r *mongoLoginAttemptRepository) Get(key string) (*domain.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var attempts mongoLoginAttempts
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return &domain.LoginAttempts{Key: key}, nil
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return r.buildLoginAttempts(attempts), nil
+}
+
+// RecordFailure increments the counter in a single update pipeline so that
+// concurrent failures are all counted. The counter restarts at one when the
+// previous failure fell out of the window.
+func (r *mongoLoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (*domain.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "failures", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$gte", Value: bson.A{"$last_failure", at.Add(-window)}}},
+			bson.D{{Key: "$add", Value: bson.A{"$failures", 1}}},
+			1,
+		}}}},
+		{Key: "last_failure", Value: at},
+		{Key: "expires_at", Value: bson.D{{Key: "$max", Value: bson.A{"$locked_until", at.Add(window)}}}},
+	}}}}
+
+	var attempts mongoLoginAttempts
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return r.buildLoginAttempts(attempts), nil
+}
+
+func (r *mongoLoginAttemptRepository) Lock(key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{"locked_until": until},
+		"$max": bson.M{"expires_at": until},
+	}
+	_, err := r.collection.UpdateByID(ctx, key, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoLoginAttemptRepository) Reset(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"task-manager/domain"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type APITokenRepository struct {
+	mock.Mock
+}
+
+func (m *APITokenRepository) Create(token *domain.APIToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *APITokenRepository) ListByUser(userID string) ([]*domain.APIToken, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.APIToken), args.Error(1)
+}
+
+func (m *APITokenRepository) GetByHash(tokenHash string) (*domain.APIToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIToken), args.Error(1)
+}
+
+func (m *APITokenRepository) Delete(userID, id string) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func (m *APITokenRepository) DeleteByUser(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *APITokenRepository) UpdateLastUsed(id string, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"task-manager/domain"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type InvitationRepository struct {
+	mock.Mock
+}
+
+func (m *InvitationRepository) Create(invitation *domain.Invitation) error {
+	args := m.Called(invitation)
+	return args.Error(0)
+}
+
+func (m *InvitationRepository) ListByWorkspace(workspaceID string, now time.Time) ([]*domain.Invitation, error) {
+	args := m.Called(workspaceID, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invitation), args.Error(1)
+}
+
+func (m *InvitationRepository) Consume(tokenHash string, now time.Time) (*domain.Invitation, error) {
+	args := m.Called(tokenHash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invitation), args.Error(1)
+}
+
+func (m *InvitationRepository) Delete(workspaceID, id string) error {
+	args := m.Called(workspaceID, id)
+	return args.Error(0)
+}
@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type LeaseRepository struct {
+	mock.Mock
+}
+
+func (m *LeaseRepository) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	args := m.Called(name, holder, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *LeaseRepository) Release(name, holder string) error {
+	args := m.Called(name, holder)
+	return args.Error(0)
+}
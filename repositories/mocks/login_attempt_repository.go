@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"task-manager/domain"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type LoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *LoginAttemptRepository) Get(key string) (*domain.LoginAttempts, error) {
+	args := m.Called(key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LoginAttempts), args.Error(1)
+}
+
+func (m *LoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (*domain.LoginAttempts, error) {
+	args := m.Called(key, at, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LoginAttempts), args.Error(1)
+}
+
+func (m *LoginAttemptRepository) Lock(key string, until time.Time) error {
+	args := m.Called(key, until)
+	return args.Error(0)
+}
+
+func (m *LoginAttemptRepository) Reset(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"task-manager/domain"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *PasswordResetRepository) Create(reset *domain.PasswordReset) error {
+	args := m.Called(reset)
+	return args.Error(0)
+}
+
+func (m *PasswordResetRepository) Get(tokenHash string, now time.Time) (*domain.PasswordReset, error) {
+	args := m.Called(tokenHash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordReset), args.Error(1)
+}
+
+func (m *PasswordResetRepository) Consume(tokenHash string, now time.Time) (*domain.PasswordReset, error) {
+	args := m.Called(tokenHash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordReset), args.Error(1)
+}
+
+func (m *PasswordResetRepository) DeleteByUser(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
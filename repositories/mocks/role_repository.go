@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RoleRepository struct {
+	mock.Mock
+}
+
+func (m *RoleRepository) List(workspaceID string) ([]*domain.Role, error) {
+	args := m.Called(workspaceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Role), args.Error(1)
+}
+
+func (m *RoleRepository) GetByName(workspaceID, name string) (*domain.Role, error) {
+	args := m.Called(workspaceID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Role), args.Error(1)
+}
+
+func (m *RoleRepository) Create(role *domain.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *RoleRepository) Save(role *domain.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *RoleRepository) Delete(workspaceID, name string) error {
+	args := m.Called(workspaceID, name)
+	return args.Error(0)
+}
@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type SettingsRepository struct {
+	mock.Mock
+}
+
+func (m *SettingsRepository) GetSecuritySettings(workspaceID string) (*domain.SecuritySettings, error) {
+	args := m.Called(workspaceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SecuritySettings), args.Error(1)
+}
+
+func (m *SettingsRepository) SaveSecuritySettings(workspaceID string, settings *domain.SecuritySettings) error {
+	args := m.Called(workspaceID, settings)
+	return args.Error(0)
+}
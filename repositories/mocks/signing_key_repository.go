@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"task-manager/domain"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type SigningKeyRepository struct {
+	mock.Mock
+}
+
+func (m *SigningKeyRepository) Create(key *domain.SigningKey) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *SigningKeyRepository) List(now time.Time) ([]domain.SigningKey, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SigningKey), args.Error(1)
+}
@@ -2,13 +2,24 @@ package mocks
 
 import (
 	"task-manager/domain"
+	"task-manager/usecases"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
 
-// TaskRepository is a mock type for the TaskRepository interface
+// TaskRepository is a mock type for the TaskRepository interface. It is
+// its own InWorkspace repository, and remembers the last workspace asked
+// for in Workspace.
 type TaskRepository struct {
 	mock.Mock
+	Workspace string
+}
+
+// InWorkspace records workspaceID and returns the mock itself
+func (m *TaskRepository) InWorkspace(workspaceID string) usecases.TaskRepository {
+	m.Workspace = workspaceID
+	return m
 }
 
 // Create provides a mock function with given fields: task
@@ -52,3 +63,86 @@ func (m *TaskRepository) Delete(id string) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+// GetDueBefore provides a mock function with given fields: t
+func (m *TaskRepository) GetDueBefore(t time.Time) ([]*domain.Task, error) {
+	args := m.Called(t)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Task), args.Error(1)
+}
+
+// MarkReminderSent provides a mock function with given fields: id, offset
+func (m *TaskRepository) MarkReminderSent(id string, offset time.Duration) error {
+	args := m.Called(id, offset)
+	return args.Error(0)
+}
+
+// MarkOverdue provides a mock function with given fields: id
+func (m *TaskRepository) MarkOverdue(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+// MarkEscalated provides a mock function with given fields: id, at
+func (m *TaskRepository) MarkEscalated(id string, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+// Search provides a mock function with given fields: query
+func (m *TaskRepository) Search(query domain.SearchQuery) ([]*domain.Task, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Task), args.Error(1)
+}
+
+// Find provides a mock function with given fields: filter
+func (m *TaskRepository) Find(filter *domain.Filter) ([]*domain.Task, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Task), args.Error(1)
+}
+
+// BulkWrite provides a mock function with given fields: ops, atomic
+func (m *TaskRepository) BulkWrite(ops []domain.BulkOperation, atomic bool) ([]*domain.BulkResult, error) {
+	args := m.Called(ops, atomic)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BulkResult), args.Error(1)
+}
+
+// UpdateMany provides a mock function with given fields: filter, patch
+func (m *TaskRepository) UpdateMany(filter *domain.Filter, patch domain.Task) (int64, int64, error) {
+	args := m.Called(filter, patch)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+// FindByTitles provides a mock function with given fields: titles
+func (m *TaskRepository) FindByTitles(titles []string) ([]*domain.Task, error) {
+	args := m.Called(titles)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Task), args.Error(1)
+}
+
+// Stream provides a mock function with given fields: filter, fn. The tasks
+// given to Return are passed to fn one by one.
+func (m *TaskRepository) Stream(filter *domain.Filter, fn func(*domain.Task) error) error {
+	args := m.Called(filter, fn)
+	if tasks, ok := args.Get(0).([]*domain.Task); ok {
+		for _, task := range tasks {
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
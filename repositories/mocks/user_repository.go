@@ -23,25 +23,125 @@ func (m *UserRepository) GetByUsername(username string) (*domain.User, error) {
 	return args.Get(0).(*domain.User), args.Error(1)
 }
 
-func (m *UserRepository) GetByID(id string) (*domain.User, error) {
-	args := m.Called(id)
+func (m *UserRepository) GetByVerifiedEmail(email string) (*domain.User, error) {
+	args := m.Called(email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.User), args.Error(1)
 }
 
-func (m *UserRepository) UpdateUserStatus(id string) error {
+func (m *UserRepository) GetByID(id string) (*domain.User, error) {
 	args := m.Called(id)
-	return args.Error(0)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
 }
 
-func (m *UserRepository) Count() (int64, error) {
-	args := m.Called()
-	return args.Get(0).(int64), args.Error(1)
+func (m *UserRepository) SetRole(workspaceID, id, role string) error {
+	args := m.Called(workspaceID, id, role)
+	return args.Error(0)
 }
 
 func (m *UserRepository) CheckUsername(username string) (exist bool, err error) {
 	args := m.Called(username)
 	return args.Get(0).(bool), args.Error(1)
 }
+
+func (m *UserRepository) CreateFirst(user *domain.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *UserRepository) GetByRole(workspaceID, role string) ([]*domain.User, error) {
+	args := m.Called(workspaceID, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.User), args.Error(1)
+}
+
+func (m *UserRepository) SetCalendarToken(workspaceID, id, tokenHash string) error {
+	args := m.Called(workspaceID, id, tokenHash)
+	return args.Error(0)
+}
+
+func (m *UserRepository) GetByCalendarToken(tokenHash string) (*domain.User, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *UserRepository) SetMFA(id string, mfa domain.MFA) error {
+	args := m.Called(id, mfa)
+	return args.Error(0)
+}
+
+func (m *UserRepository) AdvanceMFAStep(id string, step int64) (bool, error) {
+	args := m.Called(id, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *UserRepository) UseRecoveryCode(id, codeHash string) (bool, error) {
+	args := m.Called(id, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *UserRepository) UpdatePassword(id, passwordHash string) error {
+	args := m.Called(id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *UserRepository) GetByIdentity(issuer, subject string) (*domain.User, error) {
+	args := m.Called(issuer, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *UserRepository) LinkIdentity(id string, identity domain.ExternalIdentity) error {
+	args := m.Called(id, identity)
+	return args.Error(0)
+}
+
+func (m *UserRepository) List(query domain.UserQuery) ([]*domain.User, int64, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*domain.User), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *UserRepository) SetUsername(id, username string) error {
+	args := m.Called(id, username)
+	return args.Error(0)
+}
+
+func (m *UserRepository) SetDisabled(workspaceID, id string, disabled bool) error {
+	args := m.Called(workspaceID, id, disabled)
+	return args.Error(0)
+}
+
+func (m *UserRepository) Delete(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *UserRepository) SetProfile(id string, profile domain.Profile) error {
+	args := m.Called(id, profile)
+	return args.Error(0)
+}
+
+func (m *UserRepository) AddMembership(id string, membership domain.Membership) error {
+	args := m.Called(id, membership)
+	return args.Error(0)
+}
+
+func (m *UserRepository) RemoveMembership(workspaceID, id string) error {
+	args := m.Called(workspaceID, id)
+	return args.Error(0)
+}
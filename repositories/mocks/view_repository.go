@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ViewRepository struct {
+	mock.Mock
+}
+
+func (m *ViewRepository) Create(view *domain.View) (*domain.View, error) {
+	args := m.Called(view)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.View), args.Error(1)
+}
+
+func (m *ViewRepository) GetByID(id string) (*domain.View, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.View), args.Error(1)
+}
+
+func (m *ViewRepository) GetVisibleTo(workspaceID, userID string) ([]*domain.View, error) {
+	args := m.Called(workspaceID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.View), args.Error(1)
+}
+
+func (m *ViewRepository) Update(id string, updatedView domain.View) (*domain.View, error) {
+	args := m.Called(id, updatedView)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.View), args.Error(1)
+}
+
+func (m *ViewRepository) Delete(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type WorkspaceRepository struct {
+	mock.Mock
+}
+
+func (m *WorkspaceRepository) Create(workspace *domain.Workspace) error {
+	args := m.Called(workspace)
+	return args.Error(0)
+}
+
+func (m *WorkspaceRepository) GetByID(id string) (*domain.Workspace, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Workspace), args.Error(1)
+}
+
+func (m *WorkspaceRepository) GetByIDs(ids []string) ([]*domain.Workspace, error) {
+	args := m.Called(ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Workspace), args.Error(1)
+}
+
+func (m *WorkspaceRepository) Delete(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoPasswordResetRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoPasswordReset is keyed by the token hash, which is what a reset is
+// looked up by.
+type mongoPasswordReset struct {
+	TokenHash string    `bson:"_id"`
+	UserID    string    `bson:"user_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func NewMongoPasswordResetRepository(collection *mongo.Collection) usecases.PasswordResetRepository {
+	return &mongoPasswordResetRepository{collection: collection}
+}
+
+// EnsurePasswordResetIndexes creates the TTL index that drops expired resets
+// and the index used to void a user's resets.
+func EnsurePasswordResetIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("password_resets_ttl").SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("password_resets_user"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoPasswordResetRepository) Create(reset *domain.PasswordReset) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, mongoPasswordReset{
+		TokenHash: reset.TokenHash,
+		UserID:    reset.UserID,
+		ExpiresAt: reset.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoPasswordResetRepository) Get(tokenHash string, now time.Time) (*domain.PasswordReset, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var reset mongoPasswordReset
+	filter := bson.M{"_id": tokenHash, "expires_at": bson.M{"$gt": now}}
+	err := r.collection.FindOne(ctx, filter).Decode(&reset)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrInvalidResetToken
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return &domain.PasswordReset{
+		TokenHash: reset.TokenHash,
+		UserID:    reset.UserID,
+		ExpiresAt: reset.ExpiresAt,
+	}, nil
+}
+
+// Consume deletes the reset as it reads it, so two requests with the same
+// token cannot both succeed. The TTL monitor only runs once a minute, hence
+// the explicit expiry check.
+func (r *mongoPasswordResetRepository) Consume(tokenHash string, now time.Time) (*domain.PasswordReset, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var reset mongoPasswordReset
+	filter := bson.M{"_id": tokenHash, "expires_at": bson.M{"$gt": now}}
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&reset)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrInvalidResetToken
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return &domain.PasswordReset{
+		TokenHash: reset.TokenHash,
+		UserID:    reset.UserID,
+		ExpiresAt: reset.ExpiresAt,
+	}, nil
+}
+
+func (r *mongoPasswordResetRepository) DeleteByUser(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRateLimitStore struct {
+	collection *mongo.Collection
+}
+
+// mongoBucket is keyed by the bucket key. Allowed tells whether the last
+// request got a token. A bucket is full again one period after it was last
+// used at the latest, and the TTL index then removes it like a new one.
+type mongoBucket struct {
+	Key       string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	Allowed   bool      `bson:"allowed"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewMongoRateLimitStore creates a store that replicas can share.
+func NewMongoRateLimitStore(collection *mongo.Collection) usecases.RateLimitStore {
+	return &mongoRateLimitStore{collection: collection}
+}
+
+// EnsureRateLimitIndexes creates the TTL index that drops full buckets.
+func EnsureRateLimitIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("rate_limits_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// Take refills the bucket and takes a token in a single update pipeline, so
+// that concurrent requests cannot take the same token. It does what
+// domain.RateLimit.Take does; a bucket updated later than now by a replica
+// whose clock is ahead is not refilled.
+func (r *mongoRateLimitStore) Take(key string, limit domain.RateLimit, now time.Time) (domain.RateLimitStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	capacity := float64(limit.Requests)
+	elapsedMillis := bson.D{{Key: "$max", Value: bson.A{0, bson.D{{Key: "$subtract", Value: bson.A{
+		now, bson.D{{Key: "$ifNull", Value: bson.A{"$updated_at", now}}},
+	}}}}}}
+	refilled := bson.D{{Key: "$min", Value: bson.A{capacity, bson.D{{Key: "$add", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$tokens", capacity}}},
+		bson.D{{Key: "$multiply", Value: bson.A{elapsedMillis, limit.PerSecond() / 1000}}},
+	}}}}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: refilled},
+			{Key: "updated_at", Value: now},
+			{Key: "expires_at", Value: now.Add(limit.Period)},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "allowed", Value: bson.D{{Key: "$gte", Value: bson.A{"$tokens", 1}}}},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: bson.D{{Key: "$cond", Value: bson.A{
+				"$allowed", bson.D{{Key: "$subtract", Value: bson.A{"$tokens", 1}}}, "$tokens",
+			}}}},
+		}}},
+	}
+
+	var bucket mongoBucket
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	// two upserts of a new key can both try to insert it; the one that
+	// lost finds the bucket on its second go
+	if mongo.IsDuplicateKeyError(err) {
+		err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	}
+	if err != nil {
+		return domain.RateLimitStatus{}, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return limit.Status(bucket.Allowed, bucket.Tokens), nil
+}
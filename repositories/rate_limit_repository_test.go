@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"sync"
+	"task-manager/domain"
+	"testing"
+	"time"
+)
+
+// TestTake_ConcurrentNewKey needs MongoDB, see testDatabase. Requests that
+// race to create a bucket must all be counted rather than fail, as the
+// middleware lets failed ones through.
+func TestTake_ConcurrentNewKey(t *testing.T) {
+	store := NewMongoRateLimitStore(testDatabase(t).Collection("rate_limits"))
+	limit := domain.RateLimit{Requests: 10, Period: time.Hour}
+	now := time.Now()
+
+	const requests = 20
+	statuses := make([]domain.RateLimitStatus, requests)
+	failures := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], failures[i] = store.Take("ip:203.0.113.7", limit, now)
+		}()
+	}
+	wg.Wait()
+
+	allowed := 0
+	for i := range requests {
+		if failures[i] != nil {
+			t.Errorf("request %d: %v", i, failures[i])
+		}
+		if statuses[i].Allowed {
+			allowed++
+		}
+	}
+	if allowed != limit.Requests {
+		t.Errorf("%d requests were allowed, want %d", allowed, limit.Requests)
+	}
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRoleRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoRole is found by workspace and name, which users refer to it by.
+type mongoRole struct {
+	WorkspaceID primitive.ObjectID `bson:"workspace_id"`
+	Name        string             `bson:"name"`
+	Description string             `bson:"description"`
+	Permissions []string           `bson:"permissions"`
+	BuiltIn     bool               `bson:"built_in"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+func NewMongoRoleRepository(collection *mongo.Collection) usecases.RoleRepository {
+	return &mongoRoleRepository{collection: collection}
+}
+
+// EnsureRoleIndexes creates the index that keeps role names unique within
+// a workspace.
+func EnsureRoleIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "workspace_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetName("roles_workspace_name").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func buildRole(from mongoRole) *domain.Role {
+	return &domain.Role{
+		WorkspaceID: from.WorkspaceID.Hex(),
+		Name:        from.Name,
+		Description: from.Description,
+		Permissions: from.Permissions,
+		BuiltIn:     from.BuiltIn,
+		UpdatedAt:   from.UpdatedAt,
+	}
+}
+
+func toMongoRole(role *domain.Role) (mongoRole, error) {
+	workspaceID, err := primitive.ObjectIDFromHex(role.WorkspaceID)
+	if err != nil {
+		return mongoRole{}, errs.ErrInvalidWorkspaceId
+	}
+	return mongoRole{
+		WorkspaceID: workspaceID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		BuiltIn:     role.BuiltIn,
+		UpdatedAt:   role.UpdatedAt,
+	}, nil
+}
+
+// roleFilter matches the role called name in a workspace, or every role of
+// the workspace if name is empty.
+func roleFilter(workspaceID, name string) (bson.M, error) {
+	wsID, err := primitive.ObjectIDFromHex(workspaceID)
+	if err != nil {
+		return nil, errs.ErrInvalidWorkspaceId
+	}
+	filter := bson.M{"workspace_id": wsID}
+	if name != "" {
+		filter["name"] = name
+	}
+	return filter, nil
+}
+
+func (r *mongoRoleRepository) List(workspaceID string) ([]*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, err := roleFilter(workspaceID, "")
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	roles := make([]*domain.Role, 0)
+	for cursor.Next(ctx) {
+		var mRole mongoRole
+		if err := cursor.Decode(&mRole); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		roles = append(roles, buildRole(mRole))
+	}
+	return roles, nil
+}
+
+func (r *mongoRoleRepository) GetByName(workspaceID, name string) (*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, err := roleFilter(workspaceID, name)
+	if err != nil {
+		return nil, err
+	}
+	var mRole mongoRole
+	err = r.collection.FindOne(ctx, filter).Decode(&mRole)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildRole(mRole), nil
+}
+
+func (r *mongoRoleRepository) Create(role *domain.Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mRole, err := toMongoRole(role)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.InsertOne(ctx, mRole)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errs.ErrRoleExists
+		}
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// Save inserts the role if the workspace has not stored it yet, as is the
+// case for built-in roles until they are first changed.
+func (r *mongoRoleRepository) Save(role *domain.Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mRole, err := toMongoRole(role)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"workspace_id": mRole.WorkspaceID, "name": mRole.Name}
+	_, err = r.collection.ReplaceOne(ctx, filter, mRole, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoRoleRepository) Delete(workspaceID, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, err := roleFilter(workspaceID, name)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.DeletedCount == 0 {
+		return errs.ErrRoleNotFound
+	}
+	return nil
+}
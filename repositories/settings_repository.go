@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const securitySettingsID = "security"
+
+// settingsID is the key of a kind of settings of a workspace.
+func settingsID(kind, workspaceID string) string {
+	return kind + ":" + workspaceID
+}
+
+type mongoSettingsRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoSecuritySettings is the document holding the security settings of
+// a workspace; missing fields keep their zero value.
+type mongoSecuritySettings struct {
+	ID              string `bson:"_id"`
+	RequireAdminMFA bool   `bson:"require_admin_mfa"`
+}
+
+func NewMongoSettingsRepository(collection *mongo.Collection) usecases.SettingsRepository {
+	return &mongoSettingsRepository{collection: collection}
+}
+
+// GetSecuritySettings returns the defaults until settings are first saved.
+func (r *mongoSettingsRepository) GetSecuritySettings(workspaceID string) (*domain.SecuritySettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var settings mongoSecuritySettings
+	err := r.collection.FindOne(ctx, bson.M{"_id": settingsID(securitySettingsID, workspaceID)}).Decode(&settings)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return &domain.SecuritySettings{RequireAdminMFA: settings.RequireAdminMFA}, nil
+}
+
+func (r *mongoSettingsRepository) SaveSecuritySettings(workspaceID string, settings *domain.SecuritySettings) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id := settingsID(securitySettingsID, workspaceID)
+	doc := mongoSecuritySettings{ID: id, RequireAdminMFA: settings.RequireAdminMFA}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": id}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSigningKeyRepository struct {
+	collection *mongo.Collection
+}
+
+type mongoSigningKey struct {
+	ID          string    `bson:"_id"`
+	Algorithm   string    `bson:"algorithm"`
+	PrivateKey  []byte    `bson:"private_key"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ActivatesAt time.Time `bson:"activates_at"`
+	RetiresAt   time.Time `bson:"retires_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// NewMongoSigningKeyRepository stores signing keys as they are. Access to the
+// collection must be restricted accordingly.
+func NewMongoSigningKeyRepository(collection *mongo.Collection) usecases.SigningKeyRepository {
+	return &mongoSigningKeyRepository{collection: collection}
+}
+
+// EnsureSigningKeyIndexes creates the TTL index that drops expired keys.
+func EnsureSigningKeyIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("signing_keys_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+func (r *mongoSigningKeyRepository) Create(key *domain.SigningKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, mongoSigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  key.PrivateKey,
+		CreatedAt:   key.CreatedAt,
+		ActivatesAt: key.ActivatesAt,
+		RetiresAt:   key.RetiresAt,
+		ExpiresAt:   key.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// List filters on expires_at because the TTL monitor only runs once a minute.
+func (r *mongoSigningKeyRepository) List(now time.Time) ([]domain.SigningKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"expires_at": bson.M{"$gt": now}},
+		options.Find().SetSort(bson.D{{Key: "activates_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	var docs []mongoSigningKey
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	keys := make([]domain.SigningKey, 0, len(docs))
+	for _, doc := range docs {
+		keys = append(keys, domain.SigningKey{
+			ID:          doc.ID,
+			Algorithm:   doc.Algorithm,
+			PrivateKey:  doc.PrivateKey,
+			CreatedAt:   doc.CreatedAt,
+			ActivatesAt: doc.ActivatesAt,
+			RetiresAt:   doc.RetiresAt,
+			ExpiresAt:   doc.ExpiresAt,
+		})
+	}
+	return keys, nil
+}
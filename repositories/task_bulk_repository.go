@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// illegalOperationCode is returned by standalone servers when a
+// transaction is started.
+const illegalOperationCode = 20
+
+// SupportsTransactions tells whether the server client is connected to is
+// part of a replica set or a sharded cluster, which all-or-nothing bulk
+// requests need. Standalone servers are neither.
+func SupportsTransactions(client *mongo.Client) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+func (t *mongoTaskRepository) BulkWrite(ops []domain.BulkOperation, atomic bool) ([]*domain.BulkResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if !atomic {
+		results, err := t.bulkWrite(ctx, ops, false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		return results, nil
+	}
+
+	session, err := t.collection.Database().Client().StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer session.EndSession(ctx)
+
+	var results []*domain.BulkResult
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		var writeErr error
+		results, writeErr = t.bulkWrite(sc, ops, true)
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		for _, result := range results {
+			if result.Status == domain.BulkStatusFailed {
+				return nil, errs.ErrBulkAborted
+			}
+		}
+		return nil, nil
+	})
+
+	var cmdErr mongo.CommandError
+	switch {
+	case err == nil:
+		return results, nil
+	case errors.Is(err, errs.ErrBulkAborted):
+		return results, err
+	case errors.As(err, &cmdErr) && cmdErr.Code == illegalOperationCode:
+		return nil, errs.ErrTransactionsUnsupported
+	default:
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+}
+
+// bulkWrite checks that every referenced task exists and then sends all
+// writes in a single BulkWrite call. Operations that cannot be applied are
+// reported as failed instead of being sent; in ordered (atomic) mode nothing
+// is written at all if any operation fails that check.
+func (t *mongoTaskRepository) bulkWrite(ctx context.Context, ops []domain.BulkOperation, ordered bool) ([]*domain.BulkResult, error) {
+	results := make([]*domain.BulkResult, len(ops))
+	objIDs := make([]primitive.ObjectID, len(ops))
+
+	var existing []primitive.ObjectID
+	for i, op := range ops {
+		results[i] = &domain.BulkResult{Index: i, Op: op.Op, ID: op.ID, Status: domain.BulkStatusOK}
+		if op.Op == domain.BulkCreate {
+			continue
+		}
+		objID, err := primitive.ObjectIDFromHex(op.ID)
+		if err != nil {
+			results[i].Status, results[i].Err = domain.BulkStatusFailed, errs.ErrInvalidTaskId
+			continue
+		}
+		objIDs[i] = objID
+		existing = append(existing, objID)
+	}
+
+	found, err := t.existingIDs(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]mongo.WriteModel, 0, len(ops))
+	positions := make([]int, 0, len(ops))
+	failed := false
+	for i, op := range ops {
+		if results[i].Status == domain.BulkStatusFailed {
+			failed = true
+			continue
+		}
+
+		switch op.Op {
+		case domain.BulkCreate:
+			if t.workspaceID.IsZero() {
+				results[i].Status, results[i].Err = domain.BulkStatusFailed, errs.ErrInvalidWorkspaceId
+				failed = true
+				continue
+			}
+			mTask := t.newMongoTask(&op.Task)
+			results[i].ID = mTask.ID.Hex()
+			results[i].Task = t.buildTask(mTask)
+			models = append(models, mongo.NewInsertOneModel().SetDocument(mTask))
+		case domain.BulkUpdate, domain.BulkDelete:
+			if !found[objIDs[i]] {
+				results[i].Status, results[i].Err = domain.BulkStatusFailed, errs.ErrTaskNotFound
+				failed = true
+				continue
+			}
+			if op.Op == domain.BulkUpdate {
+				models = append(models, mongo.NewUpdateOneModel().SetFilter(t.scope(bson.M{"_id": objIDs[i]})).SetUpdate(t.buildUpdate(op.Task)))
+			} else {
+				models = append(models, mongo.NewDeleteOneModel().SetFilter(t.scope(bson.M{"_id": objIDs[i]})))
+			}
+		}
+		positions = append(positions, i)
+	}
+
+	if len(models) == 0 || (ordered && failed) {
+		return results, nil
+	}
+
+	_, err = t.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ordered))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && !ordered {
+		for _, writeErr := range bulkErr.WriteErrors {
+			result := results[positions[writeErr.Index]]
+			result.Status, result.Err, result.Task = domain.BulkStatusFailed, fmt.Errorf("%w: %v", errs.ErrUnexpected, writeErr.Message), nil
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (t *mongoTaskRepository) existingIDs(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	found := make(map[primitive.ObjectID]bool, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	cursor, err := t.collection.Find(ctx, t.scope(bson.M{"_id": bson.M{"$in": ids}}), options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		found[doc.ID] = true
+	}
+	return found, cursor.Err()
+}
+
+func (t *mongoTaskRepository) UpdateMany(filter *domain.Filter, patch domain.Task) (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	query, err := buildMongoFilter(filter)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := t.collection.UpdateMany(ctx, t.scope(query), t.buildUpdate(patch))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return res.MatchedCount, res.ModifiedCount, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"task-manager/domain"
 	"task-manager/errs"
 	"task-manager/usecases"
@@ -12,46 +14,146 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoTaskRepository struct {
 	collection *mongo.Collection
+	// workspaceID limits the repository to one workspace if scoped is set.
+	workspaceID primitive.ObjectID
+	scoped      bool
 }
 
 func NewMongoTaskRepository(collection *mongo.Collection) usecases.TaskRepository {
 	return &mongoTaskRepository{
-		collection,
+		collection: collection,
 	}
 }
 
+// InWorkspace returns a repository whose every query and update is limited
+// to the workspace, and which creates tasks in it. An invalid id gives a
+// repository that sees nothing and cannot create tasks.
+func (t *mongoTaskRepository) InWorkspace(workspaceID string) usecases.TaskRepository {
+	objID, _ := primitive.ObjectIDFromHex(workspaceID)
+	return &mongoTaskRepository{collection: t.collection, workspaceID: objID, scoped: true}
+}
+
+// scope limits filter to the repository's workspace.
+func (t *mongoTaskRepository) scope(filter bson.M) bson.M {
+	if t.scoped {
+		filter["workspace_id"] = t.workspaceID
+	}
+	return filter
+}
+
+// titleCollation compares titles ignoring case, as imports do to find
+// duplicates.
+var titleCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// EnsureTaskIndexes creates the indexes the task repository relies on. The
+// text index weights title matches above description matches, and is
+// prefixed by the workspace, which every request searches within. A text
+// index from before workspaces is dropped and created again with the
+// prefix.
+func EnsureTaskIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := dropUnprefixedTextIndex(ctx, collection); err != nil {
+		return err
+	}
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "workspace_id", Value: 1}, {Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().
+				SetName("task_text").
+				SetWeights(bson.M{"title": 3, "description": 1}),
+		},
+		{
+			Keys:    bson.D{{Key: "workspace_id", Value: 1}, {Key: "due_date", Value: 1}},
+			Options: options.Index().SetName("task_workspace_due"),
+		},
+		{
+			Keys:    bson.D{{Key: "workspace_id", Value: 1}, {Key: "title", Value: 1}},
+			Options: options.Index().SetName("task_workspace_title").SetCollation(titleCollation),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// dropUnprefixedTextIndex drops task_text unless workspace_id is its first
+// key. A collection has at most one text index, so it cannot be replaced
+// while the old one exists.
+func dropUnprefixedTextIndex(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	var indexes []struct {
+		Name string `bson:"name"`
+		Key  bson.D `bson:"key"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	for _, index := range indexes {
+		if index.Name != "task_text" || (len(index.Key) > 0 && index.Key[0].Key == "workspace_id") {
+			continue
+		}
+		if _, err := collection.Indexes().DropOne(ctx, index.Name); err != nil {
+			return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		log.Printf("INFO: dropped the text index of the tasks to prefix it by the workspace")
+	}
+	return nil
+}
+
 type mongoTask struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	WorkspaceID primitive.ObjectID `bson:"workspace_id"`
 	Title       string             `bson:"title"`
 	Description string             `bson:"description"`
 	DueDate     time.Time          `bson:"due_date"`
 	Status      string             `bson:"status"`
+	Labels      []string           `bson:"labels,omitempty"`
+	Assignee    string             `bson:"assignee,omitempty"`
+
+	Overdue       bool            `bson:"overdue"`
+	RemindersSent []time.Duration `bson:"reminders_sent,omitempty"`
+	EscalatedAt   time.Time       `bson:"escalated_at,omitempty"`
 }
 
 func (t *mongoTaskRepository) buildTask(from mongoTask) (to *domain.Task) {
 	return &domain.Task{
 		ID:          from.ID.Hex(),
+		WorkspaceID: from.WorkspaceID.Hex(),
 		Title:       from.Title,
 		Description: from.Description,
 		DueDate:     from.DueDate,
 		Status:      from.Status,
+		Labels:      from.Labels,
+		Assignee:    from.Assignee,
+
+		Overdue:       from.Overdue,
+		RemindersSent: from.RemindersSent,
+		EscalatedAt:   from.EscalatedAt,
 	}
 }
 
-func (t *mongoTaskRepository) Create(task *domain.Task) (*domain.Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func (t *mongoTaskRepository) newMongoTask(task *domain.Task) mongoTask {
 	mTask := mongoTask{
 		ID:          primitive.NewObjectID(),
+		WorkspaceID: t.workspaceID,
 		Title:       task.Title,
 		Description: task.Description,
 		DueDate:     task.DueDate,
 		Status:      "",
+		Labels:      task.Labels,
+		Assignee:    task.Assignee,
 	}
 
 	if task.Status != domain.StatusCompleted && task.Status != domain.StatusInProgress {
@@ -59,6 +161,47 @@ func (t *mongoTaskRepository) Create(task *domain.Task) (*domain.Task, error) {
 	} else {
 		mTask.Status = task.Status
 	}
+	return mTask
+}
+
+// buildUpdate turns the non-empty fields of updatedTask into a $set update.
+func (t *mongoTaskRepository) buildUpdate(updatedTask domain.Task) bson.M {
+	updateFields := bson.M{}
+	if updatedTask.Description != "" {
+		updateFields["description"] = updatedTask.Description
+	}
+	if !updatedTask.DueDate.IsZero() {
+		updateFields["due_date"] = updatedTask.DueDate
+		// a new due date starts the reminder cycle over
+		updateFields["overdue"] = false
+		updateFields["reminders_sent"] = []time.Duration{}
+		updateFields["escalated_at"] = time.Time{}
+	}
+	if updatedTask.Status != "" {
+		updateFields["status"] = updatedTask.Status
+	}
+	if updatedTask.Title != "" {
+		updateFields["title"] = updatedTask.Title
+	}
+	if updatedTask.Labels != nil {
+		updateFields["labels"] = updatedTask.Labels
+	}
+	if updatedTask.Assignee != "" {
+		updateFields["assignee"] = updatedTask.Assignee
+	}
+	return bson.M{
+		"$set": updateFields,
+	}
+}
+
+func (t *mongoTaskRepository) Create(task *domain.Task) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if t.workspaceID.IsZero() {
+		return nil, errs.ErrInvalidWorkspaceId
+	}
+	mTask := t.newMongoTask(task)
 
 	_, err := t.collection.InsertOne(ctx, mTask)
 	if err != nil {
@@ -76,7 +219,7 @@ func (t *mongoTaskRepository) GetAll() ([]*domain.Task, error) {
 
 	tasks := make([]*domain.Task, 0)
 
-	cursor, err := t.collection.Find(ctx, bson.M{})
+	cursor, err := t.collection.Find(ctx, t.scope(bson.M{}))
 	if err != nil {
 		log.Printf("[mongoTaskRepository] GetAll: Find error %e", err)
 		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
@@ -96,6 +239,86 @@ func (t *mongoTaskRepository) GetAll() ([]*domain.Task, error) {
 	return tasks, nil
 }
 
+func (t *mongoTaskRepository) Find(filter *domain.Filter) ([]*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query, err := buildMongoFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := t.collection.Find(ctx, t.scope(query))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	tasks := make([]*domain.Task, 0)
+	for cursor.Next(ctx) {
+		var task mongoTask
+		if err := cursor.Decode(&task); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		tasks = append(tasks, t.buildTask(task))
+	}
+	return tasks, nil
+}
+
+// FindByTitles matches titles with the collation of the task_workspace_title
+// index, which ignores case, so that the index can serve the query.
+func (t *mongoTaskRepository) FindByTitles(titles []string) ([]*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := t.scope(bson.M{"title": bson.M{"$in": titles}})
+	cursor, err := t.collection.Find(ctx, filter, options.Find().SetCollation(titleCollation))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	tasks := make([]*domain.Task, 0)
+	for cursor.Next(ctx) {
+		var task mongoTask
+		if err := cursor.Decode(&task); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		tasks = append(tasks, t.buildTask(task))
+	}
+	return tasks, nil
+}
+
+func (t *mongoTaskRepository) Stream(filter *domain.Filter, fn func(*domain.Task) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	query, err := buildMongoFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := t.collection.Find(ctx, t.scope(query), options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var task mongoTask
+		if err := cursor.Decode(&task); err != nil {
+			return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		if err := fn(t.buildTask(task)); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
 func (t *mongoTaskRepository) GetByID(id string) (*domain.Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -107,7 +330,7 @@ func (t *mongoTaskRepository) GetByID(id string) (*domain.Task, error) {
 
 	var task mongoTask
 
-	err = t.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&task)
+	err = t.collection.FindOne(ctx, t.scope(bson.M{"_id": objID})).Decode(&task)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, errs.ErrTaskNotFound
@@ -125,24 +348,7 @@ func (t *mongoTaskRepository) Update(id string, updatedTask domain.Task) (*domai
 		return nil, errs.ErrInvalidTaskId
 	}
 
-	updateFields := bson.M{}
-	if updatedTask.Description != "" {
-		updateFields["description"] = updatedTask.Description
-	}
-	if !updatedTask.DueDate.IsZero() {
-		updateFields["due_date"] = updatedTask.DueDate
-	}
-	if updatedTask.Status != "" {
-		updateFields["status"] = updatedTask.Status
-	}
-	if updatedTask.Title != "" {
-		updateFields["title"] = updatedTask.Title
-	}
-	update := bson.M{
-		"$set": updateFields,
-	}
-
-	res, err := t.collection.UpdateByID(ctx, objID, update)
+	res, err := t.collection.UpdateOne(ctx, t.scope(bson.M{"_id": objID}), t.buildUpdate(updatedTask))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
@@ -162,7 +368,7 @@ func (t *mongoTaskRepository) Delete(id string) error {
 		return errs.ErrInvalidTaskId
 	}
 
-	res, err := t.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	res, err := t.collection.DeleteOne(ctx, t.scope(bson.M{"_id": objID}))
 	if err != nil {
 		return err
 	}
@@ -171,3 +377,104 @@ func (t *mongoTaskRepository) Delete(id string) error {
 	}
 	return nil
 }
+
+func (t *mongoTaskRepository) GetDueBefore(before time.Time) ([]*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"due_date": bson.M{"$gt": time.Time{}, "$lte": before},
+		"status":   bson.M{"$ne": domain.StatusCompleted},
+	}
+
+	cursor, err := t.collection.Find(ctx, t.scope(filter))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	tasks := make([]*domain.Task, 0)
+	for cursor.Next(ctx) {
+		var task mongoTask
+		if err := cursor.Decode(&task); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		tasks = append(tasks, t.buildTask(task))
+	}
+	return tasks, nil
+}
+
+func (t *mongoTaskRepository) MarkReminderSent(id string, offset time.Duration) error {
+	return t.updateSchedulerFields(id, bson.M{"$addToSet": bson.M{"reminders_sent": offset}})
+}
+
+func (t *mongoTaskRepository) MarkOverdue(id string) error {
+	return t.updateSchedulerFields(id, bson.M{"$set": bson.M{"overdue": true}})
+}
+
+func (t *mongoTaskRepository) MarkEscalated(id string, at time.Time) error {
+	return t.updateSchedulerFields(id, bson.M{"$set": bson.M{"escalated_at": at}})
+}
+
+func (t *mongoTaskRepository) updateSchedulerFields(id string, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidTaskId
+	}
+
+	res, err := t.collection.UpdateOne(ctx, t.scope(bson.M{"_id": objID}), update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if res.MatchedCount == 0 {
+		return errs.ErrTaskNotFound
+	}
+	return nil
+}
+
+// Search uses the text index for whole terms and phrases and a regular
+// expression per prefix, since $text has no prefix matching.
+func (t *mongoTaskRepository) Search(query domain.SearchQuery) ([]*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conditions := bson.A{}
+	opts := options.Find().SetLimit(int64(query.Limit))
+
+	if len(query.Terms) > 0 || len(query.Phrases) > 0 {
+		search := strings.Join(query.Terms, " ")
+		for _, phrase := range query.Phrases {
+			search += ` "` + phrase + `"`
+		}
+		conditions = append(conditions, bson.M{"$text": bson.M{"$search": search}})
+		opts.SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+		opts.SetSort(bson.M{"score": bson.M{"$meta": "textScore"}})
+	}
+
+	for _, prefix := range query.Prefixes {
+		pattern := primitive.Regex{Pattern: `\b` + regexp.QuoteMeta(prefix), Options: "i"}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"title": pattern},
+			bson.M{"description": pattern},
+		}})
+	}
+
+	cursor, err := t.collection.Find(ctx, t.scope(bson.M{"$and": conditions}), opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	tasks := make([]*domain.Task, 0)
+	for cursor.Next(ctx) {
+		var task mongoTask
+		if err := cursor.Decode(&task); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		tasks = append(tasks, t.buildTask(task))
+	}
+	return tasks, nil
+}
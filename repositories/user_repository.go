@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"task-manager/domain"
 	"task-manager/errs"
 	"task-manager/usecases"
@@ -13,6 +15,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // --- MongoDB Implementation ---
@@ -23,16 +26,228 @@ type mongoUserRepository struct {
 
 // mongoUser is a private struct to handle BSON mapping.
 type mongoUser struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	Username     string             `bson:"username"`
-	PasswordHash string             `bson:"password_hash"`
-	Role         string             `bson:"role"`
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Username string             `bson:"username"`
+	// UsernameKey and EmailKey are the canonical forms of the username and
+	// of the verified email address, which users are looked up by.
+	UsernameKey  string            `bson:"username_key"`
+	EmailKey     string            `bson:"email_key,omitempty"`
+	PasswordHash string            `bson:"password_hash"`
+	Memberships  []mongoMembership `bson:"memberships"`
+	// Bootstrap marks the user created by the bootstrap command. The
+	// unique users_bootstrap index lets only one user have it.
+	Bootstrap bool `bson:"bootstrap,omitempty"`
+
+	MFA          *mongoMFA       `bson:"mfa,omitempty"`
+	TokenVersion int             `bson:"token_version,omitempty"`
+	Identities   []mongoIdentity `bson:"identities,omitempty"`
+	Profile      *mongoProfile   `bson:"profile,omitempty"`
+}
+
+type mongoMembership struct {
+	WorkspaceID       primitive.ObjectID `bson:"workspace_id"`
+	Role              string             `bson:"role"`
+	Disabled          bool               `bson:"disabled,omitempty"`
+	CalendarTokenHash string             `bson:"calendar_token_hash,omitempty"`
+	JoinedAt          time.Time          `bson:"joined_at"`
+}
+
+type mongoProfile struct {
+	DisplayName   string `bson:"display_name,omitempty"`
+	Email         string `bson:"email,omitempty"`
+	EmailVerified bool   `bson:"email_verified,omitempty"`
+	TimeZone      string `bson:"time_zone,omitempty"`
+	Locale        string `bson:"locale,omitempty"`
+}
+
+// emailKey returns the key a user can be found by their email address with,
+// or "" if the address is not verified.
+func emailKey(profile domain.Profile) string {
+	if !profile.EmailVerified || profile.Email == "" {
+		return ""
+	}
+	return domain.Canonical(profile.Email)
+}
+
+type mongoIdentity struct {
+	Issuer  string `bson:"issuer"`
+	Subject string `bson:"subject"`
+}
+
+type mongoMFA struct {
+	Enabled            bool     `bson:"enabled"`
+	Secret             string   `bson:"secret,omitempty"`
+	PendingSecret      string   `bson:"pending_secret,omitempty"`
+	LastStep           int64    `bson:"last_step"`
+	RecoveryCodeHashes []string `bson:"recovery_code_hashes"`
+}
+
+func buildUser(from mongoUser) *domain.User {
+	user := &domain.User{
+		ID:           from.ID.Hex(),
+		Username:     from.Username,
+		PasswordHash: from.PasswordHash,
+		TokenVersion: from.TokenVersion,
+	}
+	for _, membership := range from.Memberships {
+		user.Memberships = append(user.Memberships, domain.Membership{
+			WorkspaceID:       membership.WorkspaceID.Hex(),
+			Role:              membership.Role,
+			Disabled:          membership.Disabled,
+			CalendarTokenHash: membership.CalendarTokenHash,
+			JoinedAt:          membership.JoinedAt,
+		})
+	}
+	for _, identity := range from.Identities {
+		user.Identities = append(user.Identities, domain.ExternalIdentity{Issuer: identity.Issuer, Subject: identity.Subject})
+	}
+	if from.Profile != nil {
+		user.Profile = domain.Profile(*from.Profile)
+	}
+	if from.MFA != nil {
+		user.MFA = domain.MFA{
+			Enabled:            from.MFA.Enabled,
+			Secret:             from.MFA.Secret,
+			PendingSecret:      from.MFA.PendingSecret,
+			LastStep:           from.MFA.LastStep,
+			RecoveryCodeHashes: from.MFA.RecoveryCodeHashes,
+		}
+	}
+	return user
 }
 
 func NewMongoUserRepository(collection *mongo.Collection) usecases.UserRepository {
 	return &mongoUserRepository{collection: collection}
 }
 
+// Names of the unique indexes, which tell duplicate key errors apart.
+const (
+	usersUsernameIndex   = "users_username"
+	usersEmailIndex      = "users_email"
+	usersIdentitiesIndex = "users_identities"
+	usersBootstrapIndex  = "users_bootstrap"
+)
+
+// legacyUsernameIndex is the unique index on the username as it was typed,
+// which earlier setups created by hand and users_username replaces.
+const legacyUsernameIndex = "username_1"
+
+// keyCollation ignores case, like the canonical forms do. It backs up the
+// folding done in Go with the server's own on the unique indexes, and the
+// lookups need it to use them.
+var keyCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// EnsureUserIndexes creates the indexes that keep usernames and verified
+// email addresses unique and an external identity from being linked to two
+// users, the one that lets only one user be bootstrapped, and the one
+// finding the members of a workspace. Users that already share a username
+// are reported, as the index cannot be built until they are renamed. Run
+// UsernameMigration first, so every user has a canonical username.
+func EnsureUserIndexes(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := collection.Indexes().DropOne(ctx, legacyUsernameIndex); err != nil && !isIndexNotFound(err) {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username_key", Value: 1}},
+			Options: options.Index().SetName(usersUsernameIndex).SetUnique(true).SetCollation(keyCollation),
+		},
+		{
+			Keys: bson.D{{Key: "email_key", Value: 1}},
+			Options: options.Index().SetName(usersEmailIndex).SetUnique(true).SetCollation(keyCollation).
+				SetPartialFilterExpression(bson.M{"email_key": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "bootstrap", Value: 1}},
+			Options: options.Index().SetName(usersBootstrapIndex).SetUnique(true).
+				SetPartialFilterExpression(bson.M{"bootstrap": true}),
+		},
+		{
+			Keys: bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetName(usersIdentitiesIndex).SetUnique(true).
+				SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "memberships.workspace_id", Value: 1}, {Key: "memberships.role", Value: 1}},
+			Options: options.Index().SetName("users_memberships"),
+		},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		if taken, findErr := duplicateUsernames(ctx, collection); findErr == nil && len(taken) > 0 {
+			return fmt.Errorf("%w: usernames %s are used by more than one user, rename them: %v",
+				errs.ErrUnexpected, strings.Join(taken, ", "), err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return nil
+}
+
+// isIndexNotFound tells whether err is about dropping an index, or from a
+// collection, that does not exist.
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound")
+}
+
+// duplicateUsernames returns the canonical usernames more than one user has.
+func duplicateUsernames(ctx context.Context, collection *mongo.Collection) ([]string, error) {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$username_key", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Username string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	taken := make([]string, 0, len(groups))
+	for _, group := range groups {
+		taken = append(taken, group.Username)
+	}
+	return taken, nil
+}
+
+var duplicateIndexName = regexp.MustCompile(`index: (\S+)`)
+
+// duplicateKeyIndex returns the name of the unique index err is about, or
+// "" if it is not a duplicate key error.
+func duplicateKeyIndex(err error) string {
+	if !mongo.IsDuplicateKeyError(err) {
+		return ""
+	}
+	if match := duplicateIndexName.FindStringSubmatch(err.Error()); match != nil {
+		return match[1]
+	}
+	return "unknown"
+}
+
+// insertError maps a failed insert of a user to the error of the index
+// it broke.
+func writeError(err error) error {
+	switch duplicateKeyIndex(err) {
+	case "":
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	case usersUsernameIndex:
+		return errs.ErrUsernameExists
+	case usersEmailIndex:
+		return errs.ErrEmailExists
+	case usersBootstrapIndex:
+		return errs.ErrAlreadyBootstrapped
+	default:
+		return errs.ErrIdentityConflict
+	}
+}
+
 // func (r *mongoUserRepository) Generate() string {
 // 	return primitive.NewObjectID().Hex()
 // }
@@ -41,25 +256,76 @@ func (r *mongoUserRepository) Create(user *domain.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	mUser := mongoUser{
-		Username:     user.Username,
-		PasswordHash: user.PasswordHash,
-		Role:         user.Role,
+	mUser, err := toMongoUser(user)
+	if err != nil {
+		return err
 	}
-	res, err := r.collection.InsertOne(ctx, mUser)
+	return r.insert(ctx, mUser, user)
+}
+
+// CreateFirst counts the users before inserting one, which leaves a gap for
+// another bootstrap. The unique users_bootstrap index closes it: only one
+// user can ever be inserted with the bootstrap mark.
+func (r *mongoUserRepository) CreateFirst(user *domain.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if count > 0 {
+		return errs.ErrAlreadyBootstrapped
+	}
+	mUser, err := toMongoUser(user)
 	if err != nil {
 		return err
 	}
+	mUser.Bootstrap = true
+	return r.insert(ctx, mUser, user)
+}
+
+func (r *mongoUserRepository) insert(ctx context.Context, mUser mongoUser, user *domain.User) error {
+	res, err := r.collection.InsertOne(ctx, mUser)
+	if err != nil {
+		return writeError(err)
+	}
 	user.ID = res.InsertedID.(primitive.ObjectID).Hex()
 	return nil
 }
 
+func toMongoUser(user *domain.User) (mongoUser, error) {
+	mUser := mongoUser{
+		Username:     user.Username,
+		UsernameKey:  domain.Canonical(user.Username),
+		EmailKey:     emailKey(user.Profile),
+		PasswordHash: user.PasswordHash,
+		Memberships:  []mongoMembership{},
+	}
+	for _, membership := range user.Memberships {
+		mMembership, err := toMongoMembership(membership)
+		if err != nil {
+			return mongoUser{}, err
+		}
+		mUser.Memberships = append(mUser.Memberships, mMembership)
+	}
+	for _, identity := range user.Identities {
+		mUser.Identities = append(mUser.Identities, mongoIdentity{Issuer: identity.Issuer, Subject: identity.Subject})
+	}
+	if user.Profile != (domain.Profile{}) {
+		profile := mongoProfile(user.Profile)
+		mUser.Profile = &profile
+	}
+	return mUser, nil
+}
+
 func (r *mongoUserRepository) GetByUsername(username string) (*domain.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	var mUser mongoUser
-	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&mUser)
+	filter := bson.M{"username_key": domain.Canonical(username)}
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetCollation(keyCollation)).Decode(&mUser)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			log.Printf("WARN: Login failed for username '%s': user not found", username)
@@ -68,51 +334,185 @@ func (r *mongoUserRepository) GetByUsername(username string) (*domain.User, erro
 		log.Printf("ERROR: Database error during login for username '%s': %v", username, err)
 		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
-	user := domain.User{
-		ID:           mUser.ID.Hex(),
-		Username:     mUser.Username,
-		Password:     "",
-		PasswordHash: mUser.PasswordHash,
-		Role:         mUser.Role,
+	return buildUser(mUser), nil
+}
+
+func (r *mongoUserRepository) GetByVerifiedEmail(email string) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var mUser mongoUser
+	filter := bson.M{"email_key": domain.Canonical(email)}
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetCollation(keyCollation)).Decode(&mUser)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
-	return &user, nil
+	return buildUser(mUser), nil
 }
 
 func (r *mongoUserRepository) GetByID(id string) (*domain.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	var user domain.User
+	var mUser mongoUser
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		return nil, errs.ErrInvalidUserId
 	}
-	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&mUser)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errs.ErrInvalidUserId
+			return nil, errs.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
-	return &user, nil
+	return buildUser(mUser), nil
+}
+
+func toMongoMembership(membership domain.Membership) (mongoMembership, error) {
+	workspaceID, err := primitive.ObjectIDFromHex(membership.WorkspaceID)
+	if err != nil {
+		return mongoMembership{}, errs.ErrInvalidWorkspaceId
+	}
+	return mongoMembership{
+		WorkspaceID:       workspaceID,
+		Role:              membership.Role,
+		Disabled:          membership.Disabled,
+		CalendarTokenHash: membership.CalendarTokenHash,
+		JoinedAt:          membership.JoinedAt,
+	}, nil
 }
 
-func (r *mongoUserRepository) UpdateUserStatus(id string) error {
+// memberFilter matches the user with id if they are a member of the
+// workspace, so that updates can use the positional operator on the
+// membership.
+func memberFilter(workspaceID, id string) (bson.M, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errs.ErrInvalidUserId
+	}
+	wsID, err := primitive.ObjectIDFromHex(workspaceID)
+	if err != nil {
+		return nil, errs.ErrInvalidWorkspaceId
+	}
+	return bson.M{"_id": objID, "memberships.workspace_id": wsID}, nil
+}
+
+// updateMembership applies update to the user's membership of a workspace.
+func (r *mongoUserRepository) updateMembership(workspaceID, id string, update bson.M) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	log.Println("Attempting to update user status in repository ")
-	objId, err := primitive.ObjectIDFromHex(id)
+	filter, err := memberFilter(workspaceID, id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
+	if result.MatchedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
 
-	update := bson.M{
-		"$set": bson.M{
-			"role": domain.RoleAdmin,
-		},
+func (r *mongoUserRepository) SetRole(workspaceID, id, role string) error {
+	return r.updateMembership(workspaceID, id, bson.M{"$set": bson.M{"memberships.$.role": role}})
+}
+
+func (r *mongoUserRepository) CheckUsername(username string) (exist bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"username_key": domain.Canonical(username)}
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetCollation(keyCollation))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return count > 0, nil
+}
+
+func (r *mongoUserRepository) GetByRole(workspaceID, role string) ([]*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	wsID, err := primitive.ObjectIDFromHex(workspaceID)
+	if err != nil {
+		return nil, errs.ErrInvalidWorkspaceId
+	}
+	filter := bson.M{"memberships": bson.M{"$elemMatch": bson.M{"workspace_id": wsID, "role": role}}}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	return decodeMembers(ctx, cursor, workspaceID)
+}
+
+// decodeMembers builds the users a cursor returns, activated in workspaceID.
+func decodeMembers(ctx context.Context, cursor *mongo.Cursor, workspaceID string) ([]*domain.User, error) {
+	users := make([]*domain.User, 0)
+	for cursor.Next(ctx) {
+		var mUser mongoUser
+		if err := cursor.Decode(&mUser); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		user := buildUser(mUser)
+		user.Activate(workspaceID)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepository) SetCalendarToken(workspaceID, id, tokenHash string) error {
+	update := bson.M{"$set": bson.M{"memberships.$.calendar_token_hash": tokenHash}}
+	if tokenHash == "" {
+		update = bson.M{"$unset": bson.M{"memberships.$.calendar_token_hash": ""}}
 	}
-	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objId}, update)
+	return r.updateMembership(workspaceID, id, update)
+}
+
+func (r *mongoUserRepository) GetByCalendarToken(tokenHash string) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var mUser mongoUser
+	err := r.collection.FindOne(ctx, bson.M{"memberships.calendar_token_hash": tokenHash}).Decode(&mUser)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildUser(mUser), nil
+}
+
+// SetMFA replaces the user's MFA settings. The zero value removes them.
+func (r *mongoUserRepository) SetMFA(id string, mfa domain.MFA) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+
+	update := bson.M{"$unset": bson.M{"mfa": ""}}
+	if mfa.Enabled || mfa.PendingSecret != "" {
+		update = bson.M{"$set": bson.M{"mfa": mongoMFA{
+			Enabled:            mfa.Enabled,
+			Secret:             mfa.Secret,
+			PendingSecret:      mfa.PendingSecret,
+			LastStep:           mfa.LastStep,
+			RecoveryCodeHashes: mfa.RecoveryCodeHashes,
+		}}}
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
@@ -122,24 +522,280 @@ func (r *mongoUserRepository) UpdateUserStatus(id string) error {
 	return nil
 }
 
-func (r *mongoUserRepository) Count() (int64, error) {
+// AdvanceMFAStep records step as the last used TOTP step. It reports false
+// when the same or a later step was already used, i.e. the code is replayed.
+func (r *mongoUserRepository) AdvanceMFAStep(id string, step int64) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	count, err := r.collection.CountDocuments(ctx, bson.M{})
+	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		return false, errs.ErrInvalidUserId
+	}
+
+	filter := bson.M{"_id": objID, "mfa.enabled": true, "mfa.last_step": bson.M{"$lt": step}}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"mfa.last_step": step}})
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
-	return count, nil
+	return result.ModifiedCount == 1, nil
 }
 
-func (r *mongoUserRepository) CheckUsername(username string) (exist bool, err error) {
+// UseRecoveryCode removes a recovery code hash, reporting false if it was
+// already used, so that each code works once even under concurrent logins.
+func (r *mongoUserRepository) UseRecoveryCode(id, codeHash string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	count, err := r.collection.CountDocuments(ctx, bson.M{"username": username})
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errs.ErrInvalidUserId
+	}
+
+	filter := bson.M{"_id": objID, "mfa.recovery_code_hashes": codeHash}
+	update := bson.M{"$pull": bson.M{"mfa.recovery_code_hashes": codeHash}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return false, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
 	}
-	return count > 0, nil
+	return result.ModifiedCount == 1, nil
+}
+
+// UpdatePassword replaces the password hash. Bumping token_version in the
+// same update means no token issued for the old password remains valid.
+func (r *mongoUserRepository) UpdatePassword(id, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+
+	update := bson.M{
+		"$set": bson.M{"password_hash": passwordHash},
+		"$inc": bson.M{"token_version": 1},
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.MatchedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) GetByIdentity(issuer, subject string) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}}}
+	var mUser mongoUser
+	err := r.collection.FindOne(ctx, filter).Decode(&mUser)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildUser(mUser), nil
+}
+
+func (r *mongoUserRepository) LinkIdentity(id string, identity domain.ExternalIdentity) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+
+	update := bson.M{"$addToSet": bson.M{"identities": mongoIdentity{Issuer: identity.Issuer, Subject: identity.Subject}}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		// the unique index has the last word when two users link at once
+		if mongo.IsDuplicateKeyError(err) {
+			return errs.ErrIdentityConflict
+		}
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.MatchedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
+
+// List returns a page of the users matching query, sorted by username, and
+// how many match in total.
+func (r *mongoUserRepository) List(query domain.UserQuery) ([]*domain.User, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	wsID, err := primitive.ObjectIDFromHex(query.WorkspaceID)
+	if err != nil {
+		return nil, 0, errs.ErrInvalidWorkspaceId
+	}
+	membership := bson.M{"workspace_id": wsID}
+	if query.Role != "" {
+		membership["role"] = query.Role
+	}
+	filter := bson.M{"memberships": bson.M{"$elemMatch": membership}}
+	if query.Search != "" {
+		filter["username"] = bson.M{"$regex": regexp.QuoteMeta(query.Search), "$options": "i"}
+	}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "username", Value: 1}}).
+		SetSkip(int64((query.Page - 1) * query.PerPage)).
+		SetLimit(int64(query.PerPage))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	users, err := decodeMembers(ctx, cursor, query.WorkspaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *mongoUserRepository) SetUsername(id, username string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+
+	update := bson.M{"$set": bson.M{"username": username, "username_key": domain.Canonical(username)}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return writeError(err)
+	}
+	if result.MatchedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetDisabled disables or enables a member of a workspace. Their tokens
+// for the workspace stop working while they are disabled.
+func (r *mongoUserRepository) SetDisabled(workspaceID, id string, disabled bool) error {
+	update := bson.M{"$unset": bson.M{"memberships.$.disabled": ""}}
+	if disabled {
+		update = bson.M{"$set": bson.M{"memberships.$.disabled": true}}
+	}
+	return r.updateMembership(workspaceID, id, update)
+}
+
+func (r *mongoUserRepository) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.DeletedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetProfile replaces the user's profile. The zero value removes it.
+func (r *mongoUserRepository) SetProfile(id string, profile domain.Profile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	if profile != (domain.Profile{}) {
+		set["profile"] = mongoProfile(profile)
+	} else {
+		unset["profile"] = ""
+	}
+	if key := emailKey(profile); key != "" {
+		set["email_key"] = key
+	} else {
+		unset["email_key"] = ""
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return writeError(err)
+	}
+	if result.MatchedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) AddMembership(id string, membership domain.Membership) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidUserId
+	}
+	mMembership, err := toMongoMembership(membership)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": objID, "memberships.workspace_id": bson.M{"$ne": mMembership.WorkspaceID}}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"memberships": mMembership}})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.MatchedCount == 0 {
+		// either there is no such user or they are a member already
+		if _, err := r.GetByID(id); err != nil {
+			return err
+		}
+		return errs.ErrAlreadyMember
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) RemoveMembership(workspaceID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, err := memberFilter(workspaceID, id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$pull": bson.M{"memberships": bson.M{"workspace_id": filter["memberships.workspace_id"]}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.MatchedCount == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
 }
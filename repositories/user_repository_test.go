@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"task-manager/domain"
+	"task-manager/errs"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UserRepositoryTestSuite struct {
+	suite.Suite
+}
+
+func TestUserRepository(t *testing.T) {
+	suite.Run(t, new(UserRepositoryTestSuite))
+}
+
+// duplicateKey is the error a MongoDB server reports when an insert breaks
+// the unique index named index.
+func duplicateKey(index string) error {
+	return mongo.WriteException{WriteErrors: []mongo.WriteError{{
+		Code:    11000,
+		Message: "E11000 duplicate key error collection: task_db.users index: " + index + " dup key: { username: \"alice\" }",
+	}}}
+}
+
+func (s *UserRepositoryTestSuite) TestWriteError_MapsTheBrokenIndex() {
+	s.Assert().ErrorIs(writeError(duplicateKey(usersUsernameIndex)), errs.ErrUsernameExists)
+	s.Assert().ErrorIs(writeError(duplicateKey(usersEmailIndex)), errs.ErrEmailExists)
+	s.Assert().ErrorIs(writeError(duplicateKey(usersIdentitiesIndex)), errs.ErrIdentityConflict)
+	s.Assert().ErrorIs(writeError(duplicateKey(usersBootstrapIndex)), errs.ErrAlreadyBootstrapped)
+	s.Assert().ErrorIs(writeError(errors.New("connection reset")), errs.ErrUnexpected)
+}
+
+func (s *UserRepositoryTestSuite) TestUsernameCollisions() {
+	users := []mongoUser{
+		{Username: "alice"}, {Username: "bob"}, {Username: "Alice"},
+		{Username: "ｂｏｂ"}, {Username: "carol"}, {Username: "Straße"}, {Username: "STRASSE"},
+	}
+
+	s.Assert().Equal([][]string{
+		{`"Alice"`, `"alice"`},
+		{`"STRASSE"`, `"Straße"`},
+		{`"bob"`, `"ｂｏｂ"`},
+	}, usernameCollisions(users))
+	s.Assert().Empty(usernameCollisions(users[:2]))
+}
+
+// TestCreate_ConcurrentUsernames needs MongoDB, see testDatabase. It
+// registers one username from many goroutines at once, in different cases,
+// and checks that the unique index lets exactly one of them through.
+func TestCreate_ConcurrentUsernames(t *testing.T) {
+	db := testDatabase(t)
+	collection := db.Collection("users")
+	if err := EnsureUserIndexes(collection); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewMongoUserRepository(collection)
+	workspaceID := primitive.NewObjectID().Hex()
+
+	const attempts = 20
+	results := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			username := "alice"
+			if i%2 == 1 {
+				username = "ALICE"
+			}
+			results[i] = repo.Create(&domain.User{Username: username, Memberships: []domain.Membership{{WorkspaceID: workspaceID, Role: domain.RoleUser}}})
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for i, err := range results {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, errs.ErrUsernameExists):
+			t.Errorf("attempt %d: got %v, want nil or %v", i, err, errs.ErrUsernameExists)
+		}
+	}
+	if created != 1 {
+		t.Errorf("alice was created %d times, want once", created)
+	}
+}
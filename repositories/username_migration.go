@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UsernameMigration stores the canonical form of the usernames of users
+// from before usernames were compared in it. Users whose usernames only
+// differ in case or in compatible characters, such as "Alice" and "alice",
+// cannot keep them both, so they are reported instead.
+type UsernameMigration struct {
+	Users *mongo.Collection
+}
+
+// Run checks every username, so that a collision with a user created by an
+// older version during an upgrade is found too. It returns how many users
+// it updated, and nothing is updated while there are collisions.
+func (m UsernameMigration) Run() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	projection := bson.M{"username": 1, "username_key": 1}
+	cursor, err := m.Users.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	var users []mongoUser
+	if err := cursor.All(ctx, &users); err != nil {
+		return 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	if collisions := usernameCollisions(users); len(collisions) > 0 {
+		groups := make([]string, 0, len(collisions))
+		for _, usernames := range collisions {
+			groups = append(groups, strings.Join(usernames, ", "))
+		}
+		return 0, fmt.Errorf("%w: these usernames are the same once case and compatible characters are ignored, rename all but one of each: %s",
+			errs.ErrUnexpected, strings.Join(groups, "; "))
+	}
+
+	var updates []mongo.WriteModel
+	for _, user := range users {
+		if key := domain.Canonical(user.Username); key != user.UsernameKey {
+			updates = append(updates, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": user.ID}).
+				SetUpdate(bson.M{"$set": bson.M{"username_key": key}}))
+		}
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	if _, err := m.Users.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false)); err != nil {
+		return 0, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return len(updates), nil
+}
+
+// usernameCollisions returns the usernames that share a canonical form,
+// quoted, grouped and sorted.
+func usernameCollisions(users []mongoUser) [][]string {
+	byKey := map[string][]string{}
+	for _, user := range users {
+		key := domain.Canonical(user.Username)
+		byKey[key] = append(byKey[key], fmt.Sprintf("%q", user.Username))
+	}
+	var collisions [][]string
+	for _, usernames := range byKey {
+		if len(usernames) > 1 {
+			slices.Sort(usernames)
+			collisions = append(collisions, usernames)
+		}
+	}
+	slices.SortFunc(collisions, func(a, b []string) int {
+		return strings.Compare(a[0], b[0])
+	})
+	return collisions
+}
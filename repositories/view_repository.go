@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoViewRepository struct {
+	collection *mongo.Collection
+}
+
+type mongoView struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	WorkspaceID string             `bson:"workspace_id"`
+	Name        string             `bson:"name"`
+	Query       string             `bson:"query"`
+	OwnerID     string             `bson:"owner_id"`
+	SharedWith  []string           `bson:"shared_with"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+func NewMongoViewRepository(collection *mongo.Collection) usecases.ViewRepository {
+	return &mongoViewRepository{collection: collection}
+}
+
+func buildView(from mongoView) *domain.View {
+	return &domain.View{
+		ID:          from.ID.Hex(),
+		WorkspaceID: from.WorkspaceID,
+		Name:        from.Name,
+		Query:       from.Query,
+		OwnerID:     from.OwnerID,
+		SharedWith:  from.SharedWith,
+		CreatedAt:   from.CreatedAt,
+	}
+}
+
+func (r *mongoViewRepository) Create(view *domain.View) (*domain.View, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mView := mongoView{
+		ID:          primitive.NewObjectID(),
+		WorkspaceID: view.WorkspaceID,
+		Name:        view.Name,
+		Query:       view.Query,
+		OwnerID:     view.OwnerID,
+		SharedWith:  view.SharedWith,
+		CreatedAt:   view.CreatedAt,
+	}
+	if _, err := r.collection.InsertOne(ctx, mView); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildView(mView), nil
+}
+
+func (r *mongoViewRepository) GetByID(id string) (*domain.View, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errs.ErrInvalidViewId
+	}
+
+	var mView mongoView
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&mView)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrViewNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildView(mView), nil
+}
+
+func (r *mongoViewRepository) GetVisibleTo(workspaceID, userID string) ([]*domain.View, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"workspace_id": workspaceID,
+		"$or": bson.A{
+			bson.M{"owner_id": userID},
+			bson.M{"shared_with": userID},
+		},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	views := make([]*domain.View, 0)
+	for cursor.Next(ctx) {
+		var mView mongoView
+		if err := cursor.Decode(&mView); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		views = append(views, buildView(mView))
+	}
+	return views, nil
+}
+
+func (r *mongoViewRepository) Update(id string, updatedView domain.View) (*domain.View, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errs.ErrInvalidViewId
+	}
+
+	updateFields := bson.M{}
+	if updatedView.Name != "" {
+		updateFields["name"] = updatedView.Name
+	}
+	if updatedView.Query != "" {
+		updateFields["query"] = updatedView.Query
+	}
+	if updatedView.SharedWith != nil {
+		updateFields["shared_with"] = updatedView.SharedWith
+	}
+
+	if len(updateFields) == 0 {
+		return r.GetByID(id)
+	}
+
+	res, err := r.collection.UpdateByID(ctx, objID, bson.M{"$set": updateFields})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, errs.ErrViewNotFound
+	}
+	return r.GetByID(id)
+}
+
+func (r *mongoViewRepository) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidViewId
+	}
+
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if res.DeletedCount == 0 {
+		return errs.ErrViewNotFound
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// legacyWorkspaceName is the name of the workspace data from before
+// workspaces existed is moved into.
+const legacyWorkspaceName = "Default"
+
+// WorkspaceMigration moves the users, tasks, roles, API tokens, saved views
+// and settings of an installation from before workspaces into one workspace, where
+// everyone keeps the role they had.
+type WorkspaceMigration struct {
+	Workspaces *mongo.Collection
+	Users      *mongo.Collection
+	Tasks      *mongo.Collection
+	Roles      *mongo.Collection
+	Settings   *mongo.Collection
+	APITokens  *mongo.Collection
+	Views      *mongo.Collection
+}
+
+// Run does nothing once everything belongs to a workspace, so it is run at
+// every start. An interrupted run is finished by the next one. It returns
+// the workspace if anything was moved into it.
+func (m WorkspaceMigration) Run() (*domain.Workspace, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	users, err := m.Users.CountDocuments(ctx, bson.M{"memberships": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	tasks, err := m.Tasks.CountDocuments(ctx, bson.M{"workspace_id": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if users == 0 && tasks == 0 {
+		return nil, nil
+	}
+
+	workspace, err := m.legacyWorkspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wsID, _ := primitive.ObjectIDFromHex(workspace.ID)
+	if err := m.migrate(ctx, wsID); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return workspace, nil
+}
+
+// legacyWorkspace finds the workspace of an earlier run or creates it.
+func (m WorkspaceMigration) legacyWorkspace(ctx context.Context) (*domain.Workspace, error) {
+	var doc mongoWorkspace
+	err := m.Workspaces.FindOne(ctx, bson.M{"legacy": true}).Decode(&doc)
+	if err == nil {
+		return buildWorkspace(doc), nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+
+	id := primitive.NewObjectID()
+	now := time.Now()
+	_, err = m.Workspaces.InsertOne(ctx, bson.M{"_id": id, "name": legacyWorkspaceName, "created_at": now, "legacy": true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return &domain.Workspace{ID: id.Hex(), Name: legacyWorkspaceName, CreatedAt: now}, nil
+}
+
+// migrate moves the users last, since they tell whether a run is needed.
+func (m WorkspaceMigration) migrate(ctx context.Context, wsID primitive.ObjectID) error {
+	orphan := bson.M{"workspace_id": bson.M{"$exists": false}}
+
+	// roles used to be keyed by name alone
+	roles := mongo.Pipeline{{{Key: "$set", Value: bson.M{"workspace_id": wsID, "name": "$_id"}}}}
+	if _, err := m.Roles.UpdateMany(ctx, orphan, roles); err != nil {
+		return err
+	}
+
+	if _, err := m.APITokens.UpdateMany(ctx, orphan, bson.M{"$set": bson.M{"workspace_id": wsID.Hex()}}); err != nil {
+		return err
+	}
+	if _, err := m.Views.UpdateMany(ctx, orphan, bson.M{"$set": bson.M{"workspace_id": wsID.Hex()}}); err != nil {
+		return err
+	}
+
+	var settings bson.M
+	err := m.Settings.FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&settings)
+	switch {
+	case err == nil:
+		settings["_id"] = settingsID(securitySettingsID, wsID.Hex())
+		if _, err := m.Settings.InsertOne(ctx, settings); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		if _, err := m.Settings.DeleteOne(ctx, bson.M{"_id": securitySettingsID}); err != nil {
+			return err
+		}
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return err
+	}
+
+	if _, err := m.Tasks.UpdateMany(ctx, orphan, bson.M{"$set": bson.M{"workspace_id": wsID}}); err != nil {
+		return err
+	}
+
+	// the role, disabled flag and calendar feed of a user become those of
+	// their membership
+	membership := bson.M{
+		"workspace_id":        wsID,
+		"role":                bson.M{"$ifNull": bson.A{"$role", domain.RoleUser}},
+		"disabled":            bson.M{"$ifNull": bson.A{"$disabled", false}},
+		"calendar_token_hash": "$calendar_token_hash",
+		"joined_at":           time.Now(),
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"memberships": bson.A{membership}}}},
+		{{Key: "$unset", Value: bson.A{"role", "disabled", "calendar_token_hash"}}},
+	}
+	_, err = m.Users.UpdateMany(ctx, bson.M{"memberships": bson.M{"$exists": false}}, pipeline)
+	return err
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoWorkspaceRepository struct {
+	collection *mongo.Collection
+}
+
+type mongoWorkspace struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+func NewMongoWorkspaceRepository(collection *mongo.Collection) usecases.WorkspaceRepository {
+	return &mongoWorkspaceRepository{collection: collection}
+}
+
+func buildWorkspace(from mongoWorkspace) *domain.Workspace {
+	return &domain.Workspace{
+		ID:        from.ID.Hex(),
+		Name:      from.Name,
+		CreatedAt: from.CreatedAt,
+	}
+}
+
+func (r *mongoWorkspaceRepository) Create(workspace *domain.Workspace) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	doc := mongoWorkspace{ID: primitive.NewObjectID(), Name: workspace.Name, CreatedAt: workspace.CreatedAt}
+	if _, err := r.collection.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	workspace.ID = doc.ID.Hex()
+	return nil
+}
+
+func (r *mongoWorkspaceRepository) GetByID(id string) (*domain.Workspace, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errs.ErrInvalidWorkspaceId
+	}
+	var doc mongoWorkspace
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errs.ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return buildWorkspace(doc), nil
+}
+
+// GetByIDs skips invalid ids along with missing workspaces.
+func (r *mongoWorkspaceRepository) GetByIDs(ids []string) ([]*domain.Workspace, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	workspaces := make([]*domain.Workspace, 0, len(objIDs))
+	if len(objIDs) == 0 {
+		return workspaces, nil
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc mongoWorkspace
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		workspaces = append(workspaces, buildWorkspace(doc))
+	}
+	return workspaces, nil
+}
+
+func (r *mongoWorkspaceRepository) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errs.ErrInvalidWorkspaceId
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	if result.DeletedCount == 0 {
+		return errs.ErrWorkspaceNotFound
+	}
+	return nil
+}
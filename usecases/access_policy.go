@@ -0,0 +1,267 @@
+package usecases
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+)
+
+// PolicyActions are what access policies can be about. They are named
+// after the permissions that allow them.
+var PolicyActions = []string{domain.PermTaskRead, domain.PermTaskCreate, domain.PermTaskUpdate, domain.PermTaskDelete}
+
+// PolicyAttributes are the attributes policy conditions can refer to.
+// "changes" lists the task fields a create sets or an update changes.
+var PolicyAttributes = []string{
+	"subject.id", "subject.username", "subject.role",
+	"resource.id", "resource.title", "resource.status", "resource.labels", "resource.assignee", "resource.overdue",
+	"changes",
+}
+
+// PolicySource provides the current access policies, which may change
+// while the app runs.
+type PolicySource interface {
+	Policies() []domain.Policy
+}
+
+// ValidatePolicies reports the first policy that refers to an unknown
+// action, attribute, effect or operator, or has no ID.
+func ValidatePolicies(policies []domain.Policy) error {
+	seen := make(map[string]bool)
+	for i, policy := range policies {
+		if policy.ID == "" {
+			return fmt.Errorf("policy %d has no id", i+1)
+		}
+		if seen[policy.ID] {
+			return fmt.Errorf("policy %q is defined twice", policy.ID)
+		}
+		seen[policy.ID] = true
+
+		if policy.Effect != domain.EffectAllow && policy.Effect != domain.EffectDeny {
+			return fmt.Errorf("policy %q: effect must be %q or %q", policy.ID, domain.EffectAllow, domain.EffectDeny)
+		}
+		if len(policy.Actions) == 0 {
+			return fmt.Errorf("policy %q has no actions", policy.ID)
+		}
+		for _, action := range policy.Actions {
+			if !slices.Contains(PolicyActions, action) {
+				return fmt.Errorf("policy %q: unknown action %q", policy.ID, action)
+			}
+		}
+		for _, cond := range slices.Concat(policy.Target, policy.Conditions) {
+			if err := validateCondition(cond); err != nil {
+				return fmt.Errorf("policy %q: %w", policy.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateCondition(cond domain.PolicyCondition) error {
+	if !slices.Contains(PolicyAttributes, cond.Attribute) {
+		return fmt.Errorf("unknown attribute %q", cond.Attribute)
+	}
+	if cond.Ref != "" && !slices.Contains(PolicyAttributes, cond.Ref) {
+		return fmt.Errorf("unknown attribute %q", cond.Ref)
+	}
+	switch cond.Operator {
+	case domain.OpIn, domain.OpNotIn, domain.OpSubset:
+	default:
+		return fmt.Errorf("unknown operator %q", cond.Operator)
+	}
+	return nil
+}
+
+// accessRequest is a user wanting to do action with resource, which is the
+// new task for creates. changes lists the fields that would change.
+type accessRequest struct {
+	subject  *domain.User
+	action   string
+	resource *domain.Task
+	changes  []string
+}
+
+func (req accessRequest) attributes() map[string][]string {
+	attrs := map[string][]string{
+		"subject.id":       {req.subject.ID},
+		"subject.username": {req.subject.Username},
+		"subject.role":     {req.subject.Role},
+		"changes":          req.changes,
+	}
+	if task := req.resource; task != nil {
+		attrs["resource.id"] = []string{task.ID}
+		attrs["resource.title"] = []string{task.Title}
+		attrs["resource.status"] = []string{task.Status}
+		attrs["resource.labels"] = task.Labels
+		attrs["resource.assignee"] = []string{task.Assignee}
+		attrs["resource.overdue"] = []string{strconv.FormatBool(task.Overdue)}
+	}
+	return attrs
+}
+
+// evaluatePolicies decides req by the policies about its action. Denies
+// win over allows.
+func evaluatePolicies(policies []domain.Policy, req accessRequest) *domain.AccessDecision {
+	attrs := req.attributes()
+	decision := &domain.AccessDecision{Policies: make([]domain.PolicyResult, 0)}
+
+	var denied, allowed *domain.Policy
+	var allows, failures []string
+	for i := range policies {
+		policy := &policies[i]
+		if !slices.Contains(policy.Actions, req.action) {
+			continue
+		}
+		result := domain.PolicyResult{ID: policy.ID, Effect: policy.Effect}
+		result.Failed = firstFailed(policy.Target, attrs)
+		result.Applies = result.Failed == ""
+		if result.Applies {
+			result.Failed = firstFailed(policy.Conditions, attrs)
+			result.Matched = result.Failed == ""
+		}
+		decision.Policies = append(decision.Policies, result)
+
+		switch {
+		case !result.Applies:
+		case policy.Effect == domain.EffectDeny && result.Matched && denied == nil:
+			denied = policy
+		case policy.Effect == domain.EffectAllow:
+			allows = append(allows, policy.ID)
+			failures = append(failures, result.Failed)
+			if result.Matched && allowed == nil {
+				allowed = policy
+			}
+		}
+	}
+
+	switch {
+	case denied != nil:
+		decision.Reason = fmt.Sprintf("denied by policy %q", denied.ID) + describe(denied)
+	case allowed != nil:
+		decision.Allowed = true
+		decision.Reason = fmt.Sprintf("allowed by policy %q", allowed.ID) + describe(allowed)
+	case len(allows) == 1:
+		decision.Reason = fmt.Sprintf("the conditions of policy %q do not hold: %s", allows[0], failures[0])
+	case len(allows) > 0:
+		decision.Reason = fmt.Sprintf("the conditions of policies %s do not hold", strings.Join(quoteAll(allows), ", "))
+	default:
+		decision.Allowed = true
+		decision.Reason = "no policy applies, so the role decides"
+	}
+	return decision
+}
+
+// firstFailed describes the first of conds that does not hold, or returns
+// "" if they all do.
+func firstFailed(conds []domain.PolicyCondition, attrs map[string][]string) string {
+	for _, cond := range conds {
+		values, expected := attrs[cond.Attribute], cond.Values
+		if cond.Ref != "" {
+			expected = attrs[cond.Ref]
+		}
+		if !holds(cond.Operator, values, expected) {
+			want := "[" + strings.Join(expected, ", ") + "]"
+			if cond.Ref != "" {
+				want = cond.Ref + " " + want
+			}
+			return fmt.Sprintf("%s [%s] is not %s %s", cond.Attribute, strings.Join(values, ", "), strings.ReplaceAll(cond.Operator, "_", " "), want)
+		}
+	}
+	return ""
+}
+
+func holds(op string, values, expected []string) bool {
+	in := func(v string) bool { return v != "" && slices.Contains(expected, v) }
+	switch op {
+	case domain.OpIn:
+		return slices.ContainsFunc(values, in)
+	case domain.OpNotIn:
+		return !slices.ContainsFunc(values, in)
+	case domain.OpSubset:
+		return !slices.ContainsFunc(values, func(v string) bool { return !in(v) })
+	}
+	return false
+}
+
+func describe(policy *domain.Policy) string {
+	if policy.Description == "" {
+		return ""
+	}
+	return ": " + policy.Description
+}
+
+func quoteAll(ids []string) []string {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = strconv.Quote(id)
+	}
+	return quoted
+}
+
+// taskFields lists the fields task sets, or, given the stored task as
+// existing, the fields it would change.
+func taskFields(task domain.Task, existing *domain.Task) []string {
+	var fields []string
+	add := func(field string, set, same bool) {
+		if set && (existing == nil || !same) {
+			fields = append(fields, field)
+		}
+	}
+	add("title", task.Title != "", existing != nil && task.Title == existing.Title)
+	add("description", task.Description != "", existing != nil && task.Description == existing.Description)
+	add("due_date", !task.DueDate.IsZero(), existing != nil && task.DueDate.Equal(existing.DueDate))
+	add("status", task.Status != "", existing != nil && task.Status == existing.Status)
+	add("labels", task.Labels != nil, existing != nil && slices.Equal(task.Labels, existing.Labels))
+	add("assignee", task.Assignee != "", existing != nil && task.Assignee == existing.Assignee)
+	return fields
+}
+
+func (ts *taskUsecase) policiesFor(action string) []domain.Policy {
+	return policiesFor(ts.policies, action)
+}
+
+// policiesFor returns the policies of source about action.
+func policiesFor(source PolicySource, action string) []domain.Policy {
+	if source == nil {
+		return nil
+	}
+	var out []domain.Policy
+	for _, policy := range source.Policies() {
+		if slices.Contains(policy.Actions, action) {
+			out = append(out, policy)
+		}
+	}
+	return out
+}
+
+// authorize returns ErrAccessDenied, with the reason, if the policies keep
+// user from doing action with task.
+func (ts *taskUsecase) authorize(user *domain.User, action string, task *domain.Task, changes []string) error {
+	policies := ts.policiesFor(action)
+	if len(policies) == 0 {
+		return nil
+	}
+	decision := evaluatePolicies(policies, accessRequest{subject: user, action: action, resource: task, changes: changes})
+	if !decision.Allowed {
+		return fmt.Errorf("%w: %s", errs.ErrAccessDenied, decision.Reason)
+	}
+	return nil
+}
+
+func (ts *taskUsecase) visible(user *domain.User, tasks []*domain.Task) []*domain.Task {
+	return visibleTasks(ts.policies, user, tasks)
+}
+
+// visibleTasks drops the tasks the policies keep user from reading.
+func visibleTasks(source PolicySource, user *domain.User, tasks []*domain.Task) []*domain.Task {
+	policies := policiesFor(source, domain.PermTaskRead)
+	if len(policies) == 0 {
+		return tasks
+	}
+	return slices.DeleteFunc(tasks, func(task *domain.Task) bool {
+		return !evaluatePolicies(policies, accessRequest{subject: user, action: domain.PermTaskRead, resource: task}).Allowed
+	})
+}
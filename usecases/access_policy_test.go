@@ -0,0 +1,157 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+)
+
+// assigneesEditStatus lets users change only the status of the tasks
+// assigned to them.
+var assigneesEditStatus = domain.Policy{
+	ID:      "assignees-edit-status",
+	Effect:  domain.EffectAllow,
+	Actions: []string{domain.PermTaskUpdate},
+	Target:  []domain.PolicyCondition{{Attribute: "subject.role", Operator: domain.OpIn, Values: []string{domain.RoleUser}}},
+	Conditions: []domain.PolicyCondition{
+		{Attribute: "resource.assignee", Operator: domain.OpIn, Ref: "subject.id"},
+		{Attribute: "changes", Operator: domain.OpSubset, Values: []string{"status"}},
+	},
+}
+
+// hideConfidential keeps users from seeing tasks labelled confidential.
+var hideConfidential = domain.Policy{
+	ID:         "hide-confidential",
+	Effect:     domain.EffectDeny,
+	Actions:    []string{domain.PermTaskRead},
+	Target:     []domain.PolicyCondition{{Attribute: "subject.role", Operator: domain.OpNotIn, Values: []string{domain.RoleAdmin}}},
+	Conditions: []domain.PolicyCondition{{Attribute: "resource.labels", Operator: domain.OpIn, Values: []string{"confidential"}}},
+}
+
+func (s *TaskUsecaseTestSuite) TestUpdateTask_PolicyAllowsAssigneeToChangeStatus() {
+	s.policies.list = []domain.Policy{assigneesEditStatus}
+	existing := &domain.Task{ID: "t1", Title: "Ship", Status: "Pending", Assignee: s.user.ID}
+	patch := domain.Task{Title: "Ship", Status: "Completed"}
+	s.mockTaskRepo.On("GetByID", "t1").Return(existing, nil).Once()
+	s.mockTaskRepo.On("Update", "t1", patch).Return(&domain.Task{ID: "t1", Status: "Completed"}, nil).Once()
+
+	_, err := s.taskUsecase.UpdateTask(s.user, "t1", patch)
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestUpdateTask_PolicyDeniesOtherFields() {
+	s.policies.list = []domain.Policy{assigneesEditStatus}
+	existing := &domain.Task{ID: "t1", Title: "Ship", Status: "Pending", Assignee: s.user.ID}
+	s.mockTaskRepo.On("GetByID", "t1").Return(existing, nil).Once()
+
+	_, err := s.taskUsecase.UpdateTask(s.user, "t1", domain.Task{Title: "Renamed"})
+
+	s.Require().ErrorIs(err, errs.ErrAccessDenied)
+	s.Assert().Contains(err.Error(), "changes [title] is not subset [status]")
+	s.mockTaskRepo.AssertNotCalled(s.T(), "Update", "t1", domain.Task{Title: "Renamed"})
+}
+
+func (s *TaskUsecaseTestSuite) TestUpdateTask_PolicyDeniesUnassignedTask() {
+	s.policies.list = []domain.Policy{assigneesEditStatus}
+	s.mockTaskRepo.On("GetByID", "t1").Return(&domain.Task{ID: "t1", Status: "Pending"}, nil).Once()
+
+	_, err := s.taskUsecase.UpdateTask(s.user, "t1", domain.Task{Status: "Completed"})
+
+	s.Require().ErrorIs(err, errs.ErrAccessDenied)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestUpdateTask_PolicyDoesNotTargetAdmins() {
+	s.policies.list = []domain.Policy{assigneesEditStatus}
+	admin := &domain.User{ID: "a1", Role: domain.RoleAdmin}
+	s.mockTaskRepo.On("GetByID", "t1").Return(&domain.Task{ID: "t1"}, nil).Once()
+	s.mockTaskRepo.On("Update", "t1", domain.Task{Title: "Renamed"}).Return(&domain.Task{ID: "t1"}, nil).Once()
+
+	_, err := s.taskUsecase.UpdateTask(admin, "t1", domain.Task{Title: "Renamed"})
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestGetTasks_PolicyHidesDeniedTasks() {
+	s.policies.list = []domain.Policy{hideConfidential}
+	s.mockTaskRepo.On("GetAll").Return([]*domain.Task{
+		{ID: "t1", Labels: []string{"work"}},
+		{ID: "t2", Labels: []string{"work", "confidential"}},
+	}, nil).Once()
+
+	tasks, err := s.taskUsecase.GetTasks(s.user)
+
+	s.Require().NoError(err)
+	s.Require().Len(tasks, 1)
+	s.Assert().Equal("t1", tasks[0].ID)
+}
+
+func (s *TaskUsecaseTestSuite) TestGetTaskByID_DeniedTaskIsNotFound() {
+	s.policies.list = []domain.Policy{hideConfidential}
+	s.mockTaskRepo.On("GetByID", "t2").Return(&domain.Task{ID: "t2", Labels: []string{"confidential"}}, nil).Once()
+
+	_, err := s.taskUsecase.GetTaskByID(s.user, "t2")
+
+	s.Assert().ErrorIs(err, errs.ErrTaskNotFound)
+}
+
+func (s *TaskUsecaseTestSuite) TestBulkTasks_PolicyDeniedOperationFails() {
+	s.policies.list = []domain.Policy{assigneesEditStatus}
+	ops := []domain.BulkOperation{
+		{Op: domain.BulkCreate, Task: domain.Task{Title: "New"}},
+		{Op: domain.BulkUpdate, ID: "t2", Task: domain.Task{Title: "Renamed"}},
+	}
+	s.mockTaskRepo.On("GetByID", "t2").Return(&domain.Task{ID: "t2", Assignee: s.user.ID}, nil).Once()
+	s.mockTaskRepo.On("BulkWrite", ops[:1], false).Return([]*domain.BulkResult{
+		{Index: 0, Op: domain.BulkCreate, ID: "t1", Status: domain.BulkStatusOK},
+	}, nil).Once()
+
+	results, err := s.taskUsecase.BulkTasks(s.user, ops, false)
+
+	s.Require().NoError(err)
+	s.Require().Len(results, 2)
+	s.Assert().Equal(domain.BulkStatusOK, results[0].Status)
+	s.Assert().ErrorIs(results[1].Err, errs.ErrAccessDenied)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestExplainAccess_TracesEveryPolicy() {
+	s.policies.list = []domain.Policy{assigneesEditStatus, hideConfidential}
+	s.mockTaskRepo.On("GetByID", "t1").Return(&domain.Task{ID: "t1", Status: "Pending", Assignee: "someone-else"}, nil).Once()
+
+	decision, err := s.taskUsecase.ExplainAccess(s.user, domain.PermTaskUpdate, "t1", domain.Task{Status: "Completed"})
+
+	s.Require().NoError(err)
+	s.Assert().False(decision.Allowed)
+	s.Assert().Equal(`the conditions of policy "assignees-edit-status" do not hold: resource.assignee [someone-else] is not in subject.id [u1]`, decision.Reason)
+	s.Require().Len(decision.Policies, 1)
+	s.Assert().True(decision.Policies[0].Applies)
+	s.Assert().False(decision.Policies[0].Matched)
+	s.Assert().Equal("resource.assignee [someone-else] is not in subject.id [u1]", decision.Policies[0].Failed)
+}
+
+func (s *TaskUsecaseTestSuite) TestExplainAccess_NoPolicyApplies() {
+	decision, err := s.taskUsecase.ExplainAccess(s.user, domain.PermTaskCreate, "", domain.Task{Title: "New"})
+
+	s.Require().NoError(err)
+	s.Assert().True(decision.Allowed)
+	s.Assert().Empty(decision.Policies)
+}
+
+func (s *TaskUsecaseTestSuite) TestValidatePolicies() {
+	s.Assert().NoError(usecases.ValidatePolicies([]domain.Policy{assigneesEditStatus, hideConfidential}))
+
+	duplicate := []domain.Policy{hideConfidential, hideConfidential}
+	s.Assert().ErrorContains(usecases.ValidatePolicies(duplicate), "defined twice")
+
+	badAttribute := hideConfidential
+	badAttribute.Conditions = []domain.PolicyCondition{{Attribute: "resource.owner", Operator: domain.OpIn}}
+	s.Assert().ErrorContains(usecases.ValidatePolicies([]domain.Policy{badAttribute}), `unknown attribute "resource.owner"`)
+
+	badAction := hideConfidential
+	badAction.Actions = []string{domain.PermUserPromote}
+	s.Assert().ErrorContains(usecases.ValidatePolicies([]domain.Policy{badAction}), "unknown action")
+}
@@ -0,0 +1,126 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// APITokenPrefix starts every API token, which tells them apart from JWTs
+// and lets secret scanners find leaked ones.
+const APITokenPrefix = "tm_"
+
+const (
+	maxAPITokensPerUser = 50
+	// lastUsedResolution limits how often the use of a token is written
+	// down; scripts may send many requests a second.
+	lastUsedResolution = time.Minute
+)
+
+// APITokenRepository keeps the users' API tokens.
+type APITokenRepository interface {
+	Create(token *domain.APIToken) error
+	ListByUser(userID string) ([]*domain.APIToken, error)
+	// GetByHash returns ErrAPITokenNotFound if no token has tokenHash.
+	GetByHash(tokenHash string) (*domain.APIToken, error)
+	// Delete returns ErrAPITokenNotFound unless the user has a token with id.
+	Delete(userID, id string) error
+	// DeleteByUser drops every token of a user.
+	DeleteByUser(userID string) error
+	UpdateLastUsed(id string, at time.Time) error
+}
+
+// CreateAPIToken stores token for the user and returns the secret to send
+// with requests. Like other secrets, it cannot be looked up again.
+func (u *userUsecase) CreateAPIToken(userID string, token *domain.APIToken) (string, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if !user.Activate(token.WorkspaceID) {
+		return "", errs.ErrWorkspaceNotFound
+	}
+	if slices.Contains(token.Scopes, domain.ScopeAdmin) && user.Role != domain.RoleAdmin {
+		return "", fmt.Errorf("%w: only admins can create tokens with the admin scope", errs.ErrInsufficientRole)
+	}
+	existing, err := u.apiTokenRepo.ListByUser(userID)
+	if err != nil {
+		return "", err
+	}
+	if len(existing) >= maxAPITokensPerUser {
+		return "", errs.ErrTooManyAPITokens
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	secret = APITokenPrefix + secret
+
+	token.UserID = userID
+	token.TokenHash = hashToken(secret)
+	token.CreatedAt = time.Now()
+	token.Scopes = slices.Compact(slices.Sorted(slices.Values(token.Scopes)))
+	if err := u.apiTokenRepo.Create(token); err != nil {
+		return "", err
+	}
+	log.Printf("INFO: User '%s' created API token %s (%s) with scopes %v", user.Username, token.ID, token.Name, token.Scopes)
+	return secret, nil
+}
+
+func (u *userUsecase) ListAPITokens(userID string) ([]*domain.APIToken, error) {
+	return u.apiTokenRepo.ListByUser(userID)
+}
+
+func (u *userUsecase) RevokeAPIToken(userID, tokenID string) error {
+	if err := u.apiTokenRepo.Delete(userID, tokenID); err != nil {
+		return err
+	}
+	log.Printf("INFO: User %s revoked API token %s", userID, tokenID)
+	return nil
+}
+
+// AuthenticateAPIToken returns the owner of an API token, activated in the
+// token's workspace, along with the token, whose scopes limit what it may do.
+func (u *userUsecase) AuthenticateAPIToken(secret string) (*domain.User, *domain.APIToken, error) {
+	if !strings.HasPrefix(secret, APITokenPrefix) {
+		return nil, nil, errs.ErrInvalidToken
+	}
+	token, err := u.apiTokenRepo.GetByHash(hashToken(secret))
+	if errors.Is(err, errs.ErrAPITokenNotFound) {
+		return nil, nil, errs.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if token.Expired(now) {
+		return nil, nil, errs.ErrTokenExpired
+	}
+
+	user, err := u.userRepo.GetByID(token.UserID)
+	if err != nil {
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrInvalidUserId) {
+			return nil, nil, errs.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if !user.Activate(token.WorkspaceID) {
+		return nil, nil, fmt.Errorf("%w: the owner left the workspace", errs.ErrInvalidToken)
+	}
+
+	if now.Sub(token.LastUsedAt) >= lastUsedResolution {
+		// not worth failing the request over
+		if err := u.apiTokenRepo.UpdateLastUsed(token.ID, now); err != nil {
+			log.Printf("WARN: Failed to record use of API token %s: %v", token.ID, err)
+		} else {
+			token.LastUsedAt = now
+		}
+	}
+	return user, token, nil
+}
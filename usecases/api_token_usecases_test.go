@@ -0,0 +1,131 @@
+package usecases_test
+
+import (
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *UserUsecaseTestSuite) TestCreateAPIToken() {
+	user := &domain.User{ID: "u1", Username: "ci", Memberships: memberOf("ws1", domain.RoleAdmin)}
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.mockTokenRepo.On("ListByUser", "u1").Return([]*domain.APIToken{}, nil).Once()
+	var stored *domain.APIToken
+	s.mockTokenRepo.On("Create", mock.AnythingOfType("*domain.APIToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*domain.APIToken)
+		stored.ID = "t1"
+	}).Return(nil).Once()
+
+	token := &domain.APIToken{Name: "deploy", WorkspaceID: "ws1", Scopes: []string{"tasks:write", "admin", "tasks:write"}}
+	secret, err := s.userUsecase.CreateAPIToken("u1", token)
+
+	s.Require().NoError(err)
+	s.Assert().True(strings.HasPrefix(secret, usecases.APITokenPrefix))
+	s.Assert().Equal("u1", stored.UserID)
+	s.Assert().Equal("ws1", stored.WorkspaceID)
+	s.Assert().Equal(sha256Hex(secret), stored.TokenHash, "only the hash is stored")
+	s.Assert().Equal([]string{"admin", "tasks:write"}, stored.Scopes)
+	s.Assert().WithinDuration(time.Now(), stored.CreatedAt, time.Second)
+	s.Assert().Equal("t1", token.ID)
+	s.mockTokenRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestCreateAPIToken_AdminScopeNeedsAdmin() {
+	// admin elsewhere is not enough
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "ci", Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleUser},
+		{WorkspaceID: "ws2", Role: domain.RoleAdmin},
+	}}, nil).Once()
+
+	_, err := s.userUsecase.CreateAPIToken("u1", &domain.APIToken{Name: "deploy", WorkspaceID: "ws1", Scopes: []string{domain.ScopeAdmin}})
+
+	s.Assert().ErrorIs(err, errs.ErrInsufficientRole)
+	s.mockTokenRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestCreateAPIToken_TooMany() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "ci", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	s.mockTokenRepo.On("ListByUser", "u1").Return(make([]*domain.APIToken, 50), nil).Once()
+
+	_, err := s.userUsecase.CreateAPIToken("u1", &domain.APIToken{Name: "one more", WorkspaceID: "ws1", Scopes: []string{domain.ScopeTasksRead}})
+
+	s.Assert().ErrorIs(err, errs.ErrTooManyAPITokens)
+	s.mockTokenRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestCreateAPIToken_NotAMember() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "ci", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+
+	_, err := s.userUsecase.CreateAPIToken("u1", &domain.APIToken{Name: "deploy", WorkspaceID: "ws2", Scopes: []string{domain.ScopeTasksRead}})
+
+	s.Assert().ErrorIs(err, errs.ErrWorkspaceNotFound)
+	s.mockTokenRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestAuthenticateAPIToken() {
+	user := &domain.User{ID: "u1", Username: "ci", Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleAdmin},
+		{WorkspaceID: "ws2", Role: domain.RoleUser},
+	}}
+	stored := &domain.APIToken{ID: "t1", UserID: "u1", WorkspaceID: "ws2", Scopes: []string{domain.ScopeTasksRead}}
+	s.mockTokenRepo.On("GetByHash", sha256Hex("tm_secret")).Return(stored, nil).Once()
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.mockTokenRepo.On("UpdateLastUsed", "t1", mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	gotUser, gotToken, err := s.userUsecase.AuthenticateAPIToken("tm_secret")
+
+	s.Require().NoError(err)
+	s.Assert().Equal(user, gotUser)
+	s.Assert().Equal("ws2", gotUser.WorkspaceID, "the token acts in its own workspace")
+	s.Assert().Equal(domain.RoleUser, gotUser.Role)
+	s.Assert().Equal(stored, gotToken)
+	s.Assert().WithinDuration(time.Now(), gotToken.LastUsedAt, time.Second)
+	s.mockTokenRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestAuthenticateAPIToken_RecentlyUsed() {
+	stored := &domain.APIToken{ID: "t1", UserID: "u1", WorkspaceID: "ws1", LastUsedAt: time.Now().Add(-10 * time.Second)}
+	s.mockTokenRepo.On("GetByHash", sha256Hex("tm_secret")).Return(stored, nil).Once()
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+
+	_, _, err := s.userUsecase.AuthenticateAPIToken("tm_secret")
+
+	s.Require().NoError(err)
+	s.mockTokenRepo.AssertNotCalled(s.T(), "UpdateLastUsed", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestAuthenticateAPIToken_Rejects() {
+	s.mockTokenRepo.On("GetByHash", sha256Hex("tm_unknown")).Return(nil, errs.ErrAPITokenNotFound).Once()
+	s.mockTokenRepo.On("GetByHash", sha256Hex("tm_expired")).Return(&domain.APIToken{
+		ID: "t1", UserID: "u1", ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil).Once()
+	s.mockTokenRepo.On("GetByHash", sha256Hex("tm_orphan")).Return(&domain.APIToken{ID: "t2", UserID: "gone"}, nil).Once()
+	s.mockUserRepo.On("GetByID", "gone").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockTokenRepo.On("GetByHash", sha256Hex("tm_left")).Return(&domain.APIToken{ID: "t3", UserID: "u1", WorkspaceID: "ws2"}, nil).Once()
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+
+	cases := map[string]error{
+		"not-a-token": errs.ErrInvalidToken,
+		"tm_unknown":  errs.ErrInvalidToken,
+		"tm_expired":  errs.ErrTokenExpired,
+		"tm_orphan":   errs.ErrInvalidToken,
+		"tm_left":     errs.ErrInvalidToken,
+	}
+	for secret, want := range cases {
+		_, _, err := s.userUsecase.AuthenticateAPIToken(secret)
+		s.Assert().ErrorIsf(err, want, secret)
+	}
+	s.mockTokenRepo.AssertNotCalled(s.T(), "UpdateLastUsed", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRevokeAPIToken_OtherUsersToken() {
+	s.mockTokenRepo.On("Delete", "u1", "t9").Return(errs.ErrAPITokenNotFound).Once()
+
+	err := s.userUsecase.RevokeAPIToken("u1", "t9")
+
+	s.Assert().ErrorIs(err, errs.ErrAPITokenNotFound)
+}
@@ -0,0 +1,164 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+)
+
+const maxBulkOperations = 1000
+
+// BulkTasks applies a list of create, update and delete operations. Invalid
+// operations are reported per item; with atomic set, any failure rolls the
+// whole request back and the remaining items are reported as skipped.
+// Operations the policies do not let user do fail like invalid ones.
+func (ts *taskUsecase) BulkTasks(user *domain.User, ops []domain.BulkOperation, atomic bool) ([]*domain.BulkResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: no operations given", errs.ErrInvalidBulkRequest)
+	}
+	if len(ops) > maxBulkOperations {
+		return nil, fmt.Errorf("%w: at most %d operations are allowed per request", errs.ErrInvalidBulkRequest, maxBulkOperations)
+	}
+
+	results := make([]*domain.BulkResult, len(ops))
+	valid := make([]domain.BulkOperation, 0, len(ops))
+	positions := make([]int, 0, len(ops))
+	for i, op := range ops {
+		err := validateBulkOperation(op)
+		if err == nil {
+			err = ts.authorizeBulkOperation(user, op)
+		}
+		if err != nil {
+			results[i] = &domain.BulkResult{Index: i, Op: op.Op, ID: op.ID, Status: domain.BulkStatusFailed, Err: err}
+			continue
+		}
+		valid = append(valid, op)
+		positions = append(positions, i)
+	}
+
+	if atomic && len(valid) < len(ops) {
+		return skipRemaining(ops, results), errs.ErrBulkAborted
+	}
+
+	var repoResults []*domain.BulkResult
+	var err error
+	if len(valid) > 0 {
+		repoResults, err = ts.tasks(user).BulkWrite(valid, atomic)
+	}
+	for _, result := range repoResults {
+		result.Index = positions[result.Index]
+		results[result.Index] = result
+	}
+
+	if errors.Is(err, errs.ErrBulkAborted) {
+		return skipRemaining(ops, results), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// PatchTasks applies the non-empty fields of patch to every task matching the
+// filter query and returns how many tasks matched and changed. If the
+// policies keep user from changing any of them, none are changed.
+func (ts *taskUsecase) PatchTasks(user *domain.User, query string, patch domain.Task) (int64, int64, error) {
+	filter, err := ParseFilterQuery(query)
+	if err != nil {
+		return 0, 0, err
+	}
+	if isEmptyPatch(patch) {
+		return 0, 0, fmt.Errorf("%w: patch has no fields to change", errs.ErrInvalidBulkRequest)
+	}
+	if patch.Status != "" && !isValidStatus(patch.Status) {
+		return 0, 0, fmt.Errorf("%w: unknown status %q", errs.ErrInvalidBulkRequest, patch.Status)
+	}
+	if len(ts.policiesFor(domain.PermTaskUpdate)) > 0 {
+		err := ts.tasks(user).Stream(filter, func(task *domain.Task) error {
+			if err := ts.authorize(user, domain.PermTaskUpdate, task, taskFields(patch, task)); err != nil {
+				return fmt.Errorf("task %s: %w", task.ID, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return ts.tasks(user).UpdateMany(filter, patch)
+}
+
+// authorizeBulkOperation checks op against the policies. The tasks to
+// update or delete are only looked up if there are policies about it.
+func (ts *taskUsecase) authorizeBulkOperation(user *domain.User, op domain.BulkOperation) error {
+	switch op.Op {
+	case domain.BulkCreate:
+		return ts.authorize(user, domain.PermTaskCreate, &op.Task, taskFields(op.Task, nil))
+	case domain.BulkUpdate:
+		if len(ts.policiesFor(domain.PermTaskUpdate)) == 0 {
+			return nil
+		}
+		existing, err := ts.GetTaskByID(user, op.ID)
+		if err != nil {
+			return err
+		}
+		return ts.authorize(user, domain.PermTaskUpdate, existing, taskFields(op.Task, existing))
+	case domain.BulkDelete:
+		if len(ts.policiesFor(domain.PermTaskDelete)) == 0 {
+			return nil
+		}
+		existing, err := ts.GetTaskByID(user, op.ID)
+		if err != nil {
+			return err
+		}
+		return ts.authorize(user, domain.PermTaskDelete, existing, nil)
+	}
+	return nil
+}
+
+func validateBulkOperation(op domain.BulkOperation) error {
+	switch op.Op {
+	case domain.BulkCreate:
+		if op.Task.Title == "" {
+			return fmt.Errorf("%w: title is required", errs.ErrInvalidBulkRequest)
+		}
+	case domain.BulkUpdate:
+		if op.ID == "" {
+			return fmt.Errorf("%w: id is required", errs.ErrInvalidBulkRequest)
+		}
+		if isEmptyPatch(op.Task) {
+			return fmt.Errorf("%w: update has no fields to change", errs.ErrInvalidBulkRequest)
+		}
+	case domain.BulkDelete:
+		if op.ID == "" {
+			return fmt.Errorf("%w: id is required", errs.ErrInvalidBulkRequest)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown operation %q (expected create, update or delete)", errs.ErrInvalidBulkRequest, op.Op)
+	}
+
+	if op.Task.Status != "" && !isValidStatus(op.Task.Status) {
+		return fmt.Errorf("%w: unknown status %q", errs.ErrInvalidBulkRequest, op.Task.Status)
+	}
+	return nil
+}
+
+func isEmptyPatch(patch domain.Task) bool {
+	return patch.Title == "" && patch.Description == "" && patch.Status == "" && patch.DueDate.IsZero() && patch.Labels == nil && patch.Assignee == ""
+}
+
+func isValidStatus(status string) bool {
+	return status == domain.StatusPending || status == domain.StatusInProgress || status == domain.StatusCompleted
+}
+
+// skipRemaining marks every operation without a failure as skipped, since
+// nothing was applied.
+func skipRemaining(ops []domain.BulkOperation, results []*domain.BulkResult) []*domain.BulkResult {
+	for i, op := range ops {
+		if results[i] == nil || results[i].Status != domain.BulkStatusFailed {
+			results[i] = &domain.BulkResult{Index: i, Op: op.Op, ID: op.ID, Status: domain.BulkStatusSkipped}
+		}
+	}
+	return results
+}
@@ -0,0 +1,83 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+)
+
+func (s *TaskUsecaseTestSuite) TestBulkTasks_ReportsInvalidItemsAndAppliesTheRest() {
+	ops := []domain.BulkOperation{
+		{Op: domain.BulkCreate, Task: domain.Task{Title: "New"}},
+		{Op: domain.BulkUpdate, Task: domain.Task{Title: "Missing id"}},
+		{Op: domain.BulkDelete, ID: "t2"},
+	}
+	valid := []domain.BulkOperation{ops[0], ops[2]}
+	s.mockTaskRepo.On("BulkWrite", valid, false).Return([]*domain.BulkResult{
+		{Index: 0, Op: domain.BulkCreate, ID: "t1", Status: domain.BulkStatusOK},
+		{Index: 1, Op: domain.BulkDelete, ID: "t2", Status: domain.BulkStatusFailed, Err: errs.ErrTaskNotFound},
+	}, nil).Once()
+
+	results, err := s.taskUsecase.BulkTasks(s.user, ops, false)
+
+	s.Require().NoError(err)
+	s.Require().Len(results, 3)
+	s.Assert().Equal(domain.BulkStatusOK, results[0].Status)
+	s.Assert().Equal(domain.BulkStatusFailed, results[1].Status)
+	s.Assert().ErrorIs(results[1].Err, errs.ErrInvalidBulkRequest)
+	s.Assert().Equal(2, results[2].Index)
+	s.Assert().ErrorIs(results[2].Err, errs.ErrTaskNotFound)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestBulkTasks_AtomicAbortsBeforeWriting() {
+	ops := []domain.BulkOperation{
+		{Op: domain.BulkCreate, Task: domain.Task{Title: "New"}},
+		{Op: "archive", ID: "t2"},
+	}
+
+	results, err := s.taskUsecase.BulkTasks(s.user, ops, true)
+
+	s.Require().ErrorIs(err, errs.ErrBulkAborted)
+	s.Assert().Equal(domain.BulkStatusSkipped, results[0].Status)
+	s.Assert().Equal(domain.BulkStatusFailed, results[1].Status)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "BulkWrite")
+}
+
+func (s *TaskUsecaseTestSuite) TestBulkTasks_AtomicRollbackMarksAppliedItemsSkipped() {
+	ops := []domain.BulkOperation{
+		{Op: domain.BulkCreate, Task: domain.Task{Title: "New"}},
+		{Op: domain.BulkDelete, ID: "gone"},
+	}
+	s.mockTaskRepo.On("BulkWrite", ops, true).Return([]*domain.BulkResult{
+		{Index: 0, Op: domain.BulkCreate, ID: "t1", Status: domain.BulkStatusOK},
+		{Index: 1, Op: domain.BulkDelete, ID: "gone", Status: domain.BulkStatusFailed, Err: errs.ErrTaskNotFound},
+	}, errs.ErrBulkAborted).Once()
+
+	results, err := s.taskUsecase.BulkTasks(s.user, ops, true)
+
+	s.Require().ErrorIs(err, errs.ErrBulkAborted)
+	s.Assert().Equal(domain.BulkStatusSkipped, results[0].Status)
+	s.Assert().Equal(domain.BulkStatusFailed, results[1].Status)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestPatchTasks_Success() {
+	patch := domain.Task{Status: domain.StatusCompleted}
+	filter := &domain.Filter{Kind: domain.FilterCompare, Field: domain.FieldLabel, Op: domain.OpEq, Value: "sprint-1"}
+	s.mockTaskRepo.On("UpdateMany", filter, patch).Return(int64(4), int64(3), nil).Once()
+
+	matched, modified, err := s.taskUsecase.PatchTasks(s.user, "label:sprint-1", patch)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(int64(4), matched)
+	s.Assert().Equal(int64(3), modified)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestPatchTasks_EmptyPatch() {
+
+	_, _, err := s.taskUsecase.PatchTasks(s.user, "label:sprint-1", domain.Task{})
+
+	s.Require().ErrorIs(err, errs.ErrInvalidBulkRequest)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "UpdateMany")
+}
@@ -0,0 +1,93 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// CreateCalendarToken issues a new secret for the user's iCal feed URL of
+// a workspace, replacing (and so revoking) any previous one. Only a hash is
+// stored, so the token is returned here once and cannot be looked up again.
+func (u *userUsecase) CreateCalendarToken(workspaceID, userID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.userRepo.SetCalendarToken(workspaceID, userID, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (u *userUsecase) RevokeCalendarToken(workspaceID, userID string) error {
+	return u.userRepo.SetCalendarToken(workspaceID, userID, "")
+}
+
+// GetUserByCalendarToken finds the owner of a feed token, activated in the
+// workspace of the feed. Unknown and revoked tokens both return
+// ErrInvalidCalendarToken.
+func (u *userUsecase) GetUserByCalendarToken(token string) (*domain.User, error) {
+	if token == "" {
+		return nil, errs.ErrInvalidCalendarToken
+	}
+	tokenHash := hashToken(token)
+	user, err := u.userRepo.GetByCalendarToken(tokenHash)
+	if errors.Is(err, errs.ErrUserNotFound) {
+		return nil, errs.ErrInvalidCalendarToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, membership := range user.Memberships {
+		if membership.CalendarTokenHash == tokenHash {
+			user.Activate(membership.WorkspaceID)
+		}
+	}
+	// the feeds of disabled users go away with them
+	if user.WorkspaceID == "" || user.Disabled {
+		return nil, errs.ErrInvalidCalendarToken
+	}
+	return user, nil
+}
+
+// newToken returns 256 random bits, URL-safe encoded.
+func newToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashToken is how secret tokens are stored. They are random, so unlike
+// passwords they need no salt or slow hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CalendarTasks returns the tasks that have a due date, optionally narrowed
+// down by a filter query.
+func (ts *taskUsecase) CalendarTasks(user *domain.User, query string) ([]*domain.Task, error) {
+	filter := &domain.Filter{Kind: domain.FilterCompare, Field: domain.FieldDue, Op: domain.OpGt, Value: time.Time{}}
+	if query != "" {
+		userFilter, err := ParseFilterQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		filter = &domain.Filter{Kind: domain.FilterAnd, Children: []*domain.Filter{filter, userFilter}}
+	}
+	tasks, err := ts.tasks(user).Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	return ts.visible(user, tasks), nil
+}
@@ -0,0 +1,76 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *UserUsecaseTestSuite) TestCreateCalendarToken_StoresOnlyHash() {
+	var storedHash string
+	s.mockUserRepo.On("SetCalendarToken", "ws1", "u1", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		storedHash = args.String(2)
+	}).Return(nil).Once()
+
+	token, err := s.userUsecase.CreateCalendarToken("ws1", "u1")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(token)
+	s.Assert().NotEqual(token, storedHash)
+	s.Assert().Len(storedHash, 64)
+
+	user := &domain.User{ID: "u1", Username: "alice", Memberships: []domain.Membership{
+		{WorkspaceID: "ws0", Role: domain.RoleAdmin, CalendarTokenHash: "other"},
+		{WorkspaceID: "ws1", Role: domain.RoleUser, CalendarTokenHash: storedHash},
+	}}
+	s.mockUserRepo.On("GetByCalendarToken", storedHash).Return(user, nil).Once()
+
+	found, err := s.userUsecase.GetUserByCalendarToken(token)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(user, found)
+	s.Assert().Equal("ws1", found.WorkspaceID, "The feed should show the workspace the token was created in")
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestGetUserByCalendarToken_Unknown() {
+	s.mockUserRepo.On("GetByCalendarToken", mock.AnythingOfType("string")).Return(nil, errs.ErrUserNotFound).Once()
+
+	_, err := s.userUsecase.GetUserByCalendarToken("revoked")
+
+	s.Require().ErrorIs(err, errs.ErrInvalidCalendarToken)
+}
+
+func (s *UserUsecaseTestSuite) TestGetUserByCalendarToken_DisabledMember() {
+	user := &domain.User{ID: "u1", Username: "alice"}
+	s.mockUserRepo.On("GetByCalendarToken", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		user.Memberships = []domain.Membership{{WorkspaceID: "ws1", Role: domain.RoleUser, Disabled: true, CalendarTokenHash: args.String(0)}}
+	}).Return(user, nil).Once()
+
+	_, err := s.userUsecase.GetUserByCalendarToken("feed")
+
+	s.Require().ErrorIs(err, errs.ErrInvalidCalendarToken)
+}
+
+func (s *UserUsecaseTestSuite) TestRevokeCalendarToken_ClearsHash() {
+	s.mockUserRepo.On("SetCalendarToken", "ws1", "u1", "").Return(nil).Once()
+
+	err := s.userUsecase.RevokeCalendarToken("ws1", "u1")
+
+	s.Require().NoError(err)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestCalendarTasks_OnlyTasksWithDueDate() {
+	hasDue := &domain.Filter{Kind: domain.FilterCompare, Field: domain.FieldDue, Op: domain.OpGt, Value: time.Time{}}
+	label := &domain.Filter{Kind: domain.FilterCompare, Field: domain.FieldLabel, Op: domain.OpEq, Value: "backend"}
+	expected := &domain.Filter{Kind: domain.FilterAnd, Children: []*domain.Filter{hasDue, label}}
+	s.mockTaskRepo.On("Find", expected).Return([]*domain.Task{}, nil).Once()
+
+	_, err := s.taskUsecase.CalendarTasks(s.user, "label:backend")
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
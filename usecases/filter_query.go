@@ -0,0 +1,313 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+	"unicode"
+)
+
+// ParseFilterQuery parses a task filter expression such as
+//
+//	status:"In Progress" AND due<2026-11-01 AND (label:backend OR label:api)
+//
+// Comparisons are joined with AND, OR and NOT (AND binds tighter than OR and
+// may be left out), and can be grouped with parentheses.
+func ParseFilterQuery(q string) (*domain.Filter, error) {
+	p := &filterParser{input: q}
+	p.skipSpace()
+	if p.eof() {
+		return nil, fmt.Errorf("%w: query is empty", errs.ErrInvalidQuery)
+	}
+
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); !p.eof() {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return filter, nil
+}
+
+type filterParser struct {
+	input string
+	pos   int
+}
+
+func (p *filterParser) parseOr() (*domain.Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*domain.Filter{left}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &domain.Filter{Kind: domain.FilterOr, Children: children}, nil
+}
+
+func (p *filterParser) parseAnd() (*domain.Filter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*domain.Filter{left}
+	for {
+		if p.keyword("AND") {
+			// explicit AND
+		} else if p.skipSpace(); p.eof() || p.peek() == ')' || p.atKeyword("OR") {
+			break
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &domain.Filter{Kind: domain.FilterAnd, Children: children}, nil
+}
+
+func (p *filterParser) parseNot() (*domain.Filter, error) {
+	if p.keyword("NOT") {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &domain.Filter{Kind: domain.FilterNot, Children: []*domain.Filter{child}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (*domain.Filter, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("expected a condition")
+	}
+
+	if p.peek() == '(' {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.eof() || p.peek() != ')' {
+			return nil, p.errorf("expected \")\"")
+		}
+		p.pos++
+		return filter, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (*domain.Filter, error) {
+	start := p.pos
+	for !p.eof() && (unicode.IsLetter(rune(p.peek())) || p.peek() == '_') {
+		p.pos++
+	}
+	field := strings.ToLower(p.input[start:p.pos])
+	if field == "" {
+		return nil, p.errorf("expected a field name")
+	}
+	if _, ok := filterFieldOps[field]; !ok {
+		p.pos = start
+		return nil, p.errorf("unknown field %q (expected one of status, title, description, due, label, overdue)", field)
+	}
+
+	opStart := p.pos
+	op := p.parseOperator()
+	if op == "" {
+		return nil, p.errorf("expected an operator (:, =, !=, <, <=, >, >=) after %q", field)
+	}
+	if !strings.Contains(filterFieldOps[field], " "+op+" ") {
+		p.pos = opStart
+		return nil, p.errorf("operator %q is not supported for %s", op, field)
+	}
+
+	valueStart := p.pos
+	raw, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, p.errorf("expected a value after %q", field+op)
+	}
+
+	filter, err := buildComparison(field, op, raw)
+	if err != nil {
+		p.pos = valueStart
+		return nil, p.errorf("%v", err)
+	}
+	return filter, nil
+}
+
+// filterFieldOps lists the operators each field accepts, space separated
+// and padded so that lookups can match whole operators.
+var filterFieldOps = map[string]string{
+	domain.FieldStatus:      " : = != ",
+	domain.FieldTitle:       " : = != ",
+	domain.FieldDescription: " : = != ",
+	domain.FieldDue:         " : = != < <= > >= ",
+	domain.FieldLabel:       " : = != ",
+	domain.FieldOverdue:     " : = != ",
+}
+
+func (p *filterParser) parseOperator() string {
+	for _, op := range []string{"!=", "<=", ">=", ":", "=", "<", ">"} {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseValue reads a "quoted string" or a bare word that runs until
+// whitespace or a closing parenthesis.
+func (p *filterParser) parseValue() (string, error) {
+	if !p.eof() && p.peek() == '"' {
+		start := p.pos
+		p.pos++
+		var b strings.Builder
+		for !p.eof() && p.peek() != '"' {
+			if p.peek() == '\\' && p.pos+1 < len(p.input) {
+				p.pos++
+			}
+			b.WriteByte(p.peek())
+			p.pos++
+		}
+		if p.eof() {
+			p.pos = start
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+		return b.String(), nil
+	}
+
+	start := p.pos
+	for !p.eof() && !unicode.IsSpace(rune(p.peek())) && p.peek() != ')' {
+		p.pos++
+	}
+	return p.input[start:p.pos], nil
+}
+
+func buildComparison(field, op, raw string) (*domain.Filter, error) {
+	if op == domain.OpContains && field != domain.FieldTitle && field != domain.FieldDescription {
+		op = domain.OpEq
+	}
+	compare := &domain.Filter{Kind: domain.FilterCompare, Field: field, Op: op, Value: raw}
+
+	switch field {
+	case domain.FieldStatus:
+		status, ok := canonicalStatus(raw)
+		if !ok {
+			return nil, fmt.Errorf("unknown status %q (expected %q, %q or %q)", raw, domain.StatusPending, domain.StatusInProgress, domain.StatusCompleted)
+		}
+		compare.Value = status
+
+	case domain.FieldOverdue:
+		switch strings.ToLower(raw) {
+		case "true", "yes":
+			compare.Value = true
+		case "false", "no":
+			compare.Value = false
+		default:
+			return nil, fmt.Errorf("overdue must be true or false, got %q", raw)
+		}
+
+	case domain.FieldDue:
+		if day, err := time.Parse(time.DateOnly, raw); err == nil {
+			return dayComparison(op, day), nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", raw)
+		}
+		compare.Value = t
+	}
+	return compare, nil
+}
+
+// dayComparison turns a comparison against a whole day into one against the
+// day's bounds, so that due=2026-11-01 matches any time on that day.
+func dayComparison(op string, day time.Time) *domain.Filter {
+	next := day.AddDate(0, 0, 1)
+	cmp := func(op string, t time.Time) *domain.Filter {
+		return &domain.Filter{Kind: domain.FilterCompare, Field: domain.FieldDue, Op: op, Value: t}
+	}
+
+	switch op {
+	case domain.OpLt:
+		return cmp(domain.OpLt, day)
+	case domain.OpLte:
+		return cmp(domain.OpLt, next)
+	case domain.OpGt:
+		return cmp(domain.OpGte, next)
+	case domain.OpGte:
+		return cmp(domain.OpGte, day)
+	case domain.OpNe:
+		return &domain.Filter{Kind: domain.FilterOr, Children: []*domain.Filter{cmp(domain.OpLt, day), cmp(domain.OpGte, next)}}
+	default:
+		return &domain.Filter{Kind: domain.FilterAnd, Children: []*domain.Filter{cmp(domain.OpGte, day), cmp(domain.OpLt, next)}}
+	}
+}
+
+func canonicalStatus(s string) (string, bool) {
+	for _, status := range []string{domain.StatusPending, domain.StatusInProgress, domain.StatusCompleted} {
+		if strings.EqualFold(s, status) || strings.EqualFold(s, strings.ReplaceAll(status, " ", "")) {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// keyword consumes kw if it is the next word in the input.
+func (p *filterParser) keyword(kw string) bool {
+	p.skipSpace()
+	if !p.atKeyword(kw) {
+		return false
+	}
+	p.pos += len(kw)
+	return true
+}
+
+func (p *filterParser) atKeyword(kw string) bool {
+	rest := p.rest()
+	if len(rest) < len(kw) || !strings.EqualFold(rest[:len(kw)], kw) {
+		return false
+	}
+	if len(rest) == len(kw) {
+		return true
+	}
+	next := rune(rest[len(kw)])
+	return unicode.IsSpace(next) || next == '('
+}
+
+func (p *filterParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(rune(p.peek())) {
+		p.pos++
+	}
+}
+
+func (p *filterParser) eof() bool    { return p.pos >= len(p.input) }
+func (p *filterParser) peek() byte   { return p.input[p.pos] }
+func (p *filterParser) rest() string { return p.input[p.pos:] }
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at column %d", errs.ErrInvalidQuery, fmt.Sprintf(format, args...), p.pos+1)
+}
@@ -0,0 +1,84 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterQueryTestSuite struct {
+	suite.Suite
+}
+
+func TestFilterQuery(t *testing.T) {
+	suite.Run(t, new(FilterQueryTestSuite))
+}
+
+func compare(field, op string, value any) *domain.Filter {
+	return &domain.Filter{Kind: domain.FilterCompare, Field: field, Op: op, Value: value}
+}
+
+func (s *FilterQueryTestSuite) TestParse_AndWithQuotedStatusAndDate() {
+	filter, err := usecases.ParseFilterQuery(`status:"In Progress" AND due<2026-11-01 AND label:backend`)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(&domain.Filter{Kind: domain.FilterAnd, Children: []*domain.Filter{
+		compare(domain.FieldStatus, domain.OpEq, domain.StatusInProgress),
+		compare(domain.FieldDue, domain.OpLt, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)),
+		compare(domain.FieldLabel, domain.OpEq, "backend"),
+	}}, filter)
+}
+
+func (s *FilterQueryTestSuite) TestParse_PrecedenceAndGrouping() {
+	filter, err := usecases.ParseFilterQuery(`title:deploy label:api OR NOT (overdue:true OR status:completed)`)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(&domain.Filter{Kind: domain.FilterOr, Children: []*domain.Filter{
+		{Kind: domain.FilterAnd, Children: []*domain.Filter{
+			compare(domain.FieldTitle, domain.OpContains, "deploy"),
+			compare(domain.FieldLabel, domain.OpEq, "api"),
+		}},
+		{Kind: domain.FilterNot, Children: []*domain.Filter{
+			{Kind: domain.FilterOr, Children: []*domain.Filter{
+				compare(domain.FieldOverdue, domain.OpEq, true),
+				compare(domain.FieldStatus, domain.OpEq, domain.StatusCompleted),
+			}},
+		}},
+	}}, filter)
+}
+
+func (s *FilterQueryTestSuite) TestParse_DueOnDayBecomesRange() {
+	filter, err := usecases.ParseFilterQuery(`due=2026-11-01`)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(&domain.Filter{Kind: domain.FilterAnd, Children: []*domain.Filter{
+		compare(domain.FieldDue, domain.OpGte, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)),
+		compare(domain.FieldDue, domain.OpLt, time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)),
+	}}, filter)
+}
+
+func (s *FilterQueryTestSuite) TestParse_Errors() {
+	cases := map[string]string{
+		``:                        "invalid query: query is empty",
+		`priority:high`:           `invalid query: unknown field "priority" (expected one of status, title, description, due, label, overdue) at column 1`,
+		`status:Done`:             `invalid query: unknown status "Done" (expected "Pending", "In Progress" or "Completed") at column 8`,
+		`due<tomorrow`:            `invalid query: invalid date "tomorrow" (expected YYYY-MM-DD or RFC 3339) at column 5`,
+		`label<api`:               `invalid query: operator "<" is not supported for label at column 6`,
+		`due<`:                    `invalid query: expected a value after "due<" at column 5`,
+		`(label:api`:              `invalid query: expected ")" at column 11`,
+		`title:"unterminated`:     `invalid query: unterminated string at column 7`,
+		`label:api AND`:           `invalid query: expected a condition at column 14`,
+		`label:api) OR label:web`: `invalid query: unexpected ") OR label:web" at column 10`,
+	}
+
+	for query, message := range cases {
+		_, err := usecases.ParseFilterQuery(query)
+		s.Require().Error(err, query)
+		s.Assert().ErrorIs(err, errs.ErrInvalidQuery, query)
+		s.Assert().Equal(message, err.Error(), query)
+	}
+}
@@ -0,0 +1,23 @@
+package usecases
+
+import (
+	"task-manager/domain"
+	"time"
+)
+
+// IdempotencyStore keeps the requests made with idempotency keys, which
+// replicas share if they use the same store.
+type IdempotencyStore interface {
+	// Begin claims record.Key for the request of record until
+	// record.ExpiresAt. If the key is taken, it returns the record of the
+	// earlier request instead, and nil otherwise.
+	Begin(record *domain.IdempotencyRecord, now time.Time) (*domain.IdempotencyRecord, error)
+	// Complete stores the response to the request that made claim, which is
+	// replayed until expiresAt. It does nothing if the claim expired and
+	// another request claimed the key since: claims are told apart by their
+	// CreatedAt and Fingerprint.
+	Complete(claim *domain.IdempotencyRecord, response domain.IdempotentResponse, expiresAt time.Time) error
+	// Release gives up claim without a response, so that the request can be
+	// retried. Like Complete, it leaves the claims of other requests alone.
+	Release(claim *domain.IdempotencyRecord) error
+}
@@ -0,0 +1,208 @@
+package usecases
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+const maxImportRows = 10000
+
+// ImportFields are the task fields that import columns can be mapped to.
+var ImportFields = []string{"title", "description", "due_date", "status", "labels"}
+
+var importDateLayouts = []string{time.RFC3339, time.DateTime, time.DateOnly}
+
+// ExportTasks passes every task matching the optional filter query to fn,
+// one at a time, without loading them all into memory.
+func (ts *taskUsecase) ExportTasks(user *domain.User, query string, fn func(*domain.Task) error) error {
+	var filter *domain.Filter
+	if query != "" {
+		var err error
+		if filter, err = ParseFilterQuery(query); err != nil {
+			return err
+		}
+	}
+	if len(ts.policiesFor(domain.PermTaskRead)) == 0 {
+		return ts.tasks(user).Stream(filter, fn)
+	}
+	return ts.tasks(user).Stream(filter, func(task *domain.Task) error {
+		if len(ts.visible(user, []*domain.Task{task})) == 0 {
+			return nil
+		}
+		return fn(task)
+	})
+}
+
+// ImportTasks validates every row, skips rows that duplicate an existing
+// task or an earlier row (same title and due date), and creates the rest
+// unless DryRun is set. Row problems, including rows the policies do not
+// let user create, are reported per line rather than failing the whole
+// import.
+func (ts *taskUsecase) ImportTasks(user *domain.User, rows []domain.ImportRow, opts domain.ImportOptions) (*domain.ImportReport, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file has no rows", errs.ErrInvalidImport)
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("%w: at most %d rows can be imported at once", errs.ErrInvalidImport, maxImportRows)
+	}
+	for column, field := range opts.Mapping {
+		if !slices.Contains(ImportFields, field) {
+			return nil, fmt.Errorf("%w: column %q is mapped to unknown field %q", errs.ErrInvalidImport, column, field)
+		}
+	}
+
+	report := &domain.ImportReport{Total: len(rows), Rows: make([]*domain.ImportRowResult, 0, len(rows))}
+	// the valid rows by position, and their titles
+	tasks := make([]*domain.Task, len(rows))
+	var titles []string
+
+	for i, row := range rows {
+		result := &domain.ImportRowResult{Line: row.Line, Status: domain.ImportRowValid}
+		report.Rows = append(report.Rows, result)
+
+		task, problems := parseImportRow(row, opts.Mapping)
+		if len(problems) > 0 {
+			result.Status, result.Errors = domain.ImportRowFailed, problems
+			continue
+		}
+
+		if err := ts.authorize(user, domain.PermTaskCreate, task, taskFields(*task, nil)); err != nil {
+			result.Status, result.Errors = domain.ImportRowFailed, []string{err.Error()}
+			continue
+		}
+		tasks[i] = task
+		titles = append(titles, task.Title)
+	}
+
+	// line of the first row with a given key, 0 for tasks already stored;
+	// only the tasks sharing a title with a row can be duplicates
+	seen := make(map[string]int)
+	if len(titles) > 0 {
+		existing, err := ts.tasks(user).FindByTitles(slices.Compact(slices.Sorted(slices.Values(titles))))
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range existing {
+			seen[duplicateKey(task)] = 0
+		}
+	}
+
+	var creates []domain.BulkOperation
+	var pending []*domain.ImportRowResult
+	for i, task := range tasks {
+		if task == nil {
+			continue
+		}
+		row, result := rows[i], report.Rows[i]
+
+		key := duplicateKey(task)
+		if line, duplicate := seen[key]; duplicate {
+			result.Status = domain.ImportRowDuplicate
+			if line == 0 {
+				result.Errors = []string{"a task with the same title and due date already exists"}
+			} else {
+				result.Errors = []string{fmt.Sprintf("same title and due date as line %d", line)}
+			}
+			continue
+		}
+		seen[key] = row.Line
+
+		creates = append(creates, domain.BulkOperation{Op: domain.BulkCreate, Task: *task})
+		pending = append(pending, result)
+	}
+
+	if !opts.DryRun && len(creates) > 0 {
+		results, err := ts.tasks(user).BulkWrite(creates, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, created := range results {
+			row := pending[created.Index]
+			if created.Status == domain.BulkStatusOK {
+				row.Status, row.TaskID = domain.ImportRowCreated, created.ID
+			} else {
+				row.Status, row.Errors = domain.ImportRowFailed, []string{created.Err.Error()}
+			}
+		}
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case domain.ImportRowCreated:
+			report.Created++
+		case domain.ImportRowValid:
+			report.Valid++
+		case domain.ImportRowDuplicate:
+			report.Duplicates++
+		case domain.ImportRowFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// parseImportRow maps the row's columns onto task fields and returns every
+// problem found rather than stopping at the first one.
+func parseImportRow(row domain.ImportRow, mapping map[string]string) (*domain.Task, []string) {
+	values := make(map[string]string)
+	for column, value := range row.Values {
+		field, ok := mapping[column]
+		if !ok {
+			field = strings.ToLower(strings.TrimSpace(column))
+		}
+		if slices.Contains(ImportFields, field) {
+			values[field] = strings.TrimSpace(value)
+		}
+	}
+
+	task := &domain.Task{Title: values["title"], Description: values["description"]}
+	problems := slices.Clone(row.Errors)
+
+	if task.Title == "" {
+		problems = append(problems, "title is required")
+	}
+
+	if raw := values["due_date"]; raw != "" {
+		parsed := false
+		for _, layout := range importDateLayouts {
+			if due, err := time.Parse(layout, raw); err == nil {
+				// JSON exports write the zero time for tasks without a due date
+				task.DueDate, parsed = due, true
+				break
+			}
+		}
+		if !parsed {
+			problems = append(problems, fmt.Sprintf("due_date %q is not a date (use RFC 3339 or YYYY-MM-DD)", raw))
+		}
+	}
+
+	if raw := values["status"]; raw != "" {
+		status, ok := canonicalStatus(raw)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("status %q is not one of %q, %q or %q", raw, domain.StatusPending, domain.StatusInProgress, domain.StatusCompleted))
+		}
+		task.Status = status
+	}
+
+	if raw := values["labels"]; raw != "" {
+		for _, label := range strings.FieldsFunc(raw, func(r rune) bool { return r == ';' || r == ',' }) {
+			if label = strings.TrimSpace(label); label != "" {
+				task.Labels = append(task.Labels, label)
+			}
+		}
+	}
+
+	return task, problems
+}
+
+func duplicateKey(task *domain.Task) string {
+	due := ""
+	if !task.DueDate.IsZero() {
+		due = task.DueDate.UTC().Format(time.DateOnly)
+	}
+	return strings.ToLower(strings.TrimSpace(task.Title)) + "|" + due
+}
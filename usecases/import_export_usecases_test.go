@@ -0,0 +1,87 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *TaskUsecaseTestSuite) TestImportTasks_ReportsRowsAndCreatesValidOnes() {
+	existing := []*domain.Task{{ID: "old", Title: "Write docs", DueDate: time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)}}
+	rows := []domain.ImportRow{
+		{Line: 2, Values: map[string]string{"Name": "Ship release", "Deadline": "2026-11-03", "Tags": "backend; api", "status": "in progress"}},
+		{Line: 3, Values: map[string]string{"Name": "write docs", "Deadline": "2026-11-01"}},
+		{Line: 4, Values: map[string]string{"Name": "Ship release", "Deadline": "2026-11-03T10:00:00Z"}},
+		{Line: 5, Values: map[string]string{"Name": "", "Deadline": "soon", "status": "Done"}},
+	}
+	opts := domain.ImportOptions{Mapping: map[string]string{"Name": "title", "Deadline": "due_date", "Tags": "labels"}}
+
+	// each title is looked up once, as written in its first row
+	s.mockTaskRepo.On("FindByTitles", []string{"Ship release", "write docs"}).Return(existing, nil).Once()
+	expectedCreate := []domain.BulkOperation{{Op: domain.BulkCreate, Task: domain.Task{
+		Title:   "Ship release",
+		DueDate: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC),
+		Status:  domain.StatusInProgress,
+		Labels:  []string{"backend", "api"},
+	}}}
+	s.mockTaskRepo.On("BulkWrite", expectedCreate, false).Return([]*domain.BulkResult{
+		{Index: 0, Op: domain.BulkCreate, ID: "new", Status: domain.BulkStatusOK},
+	}, nil).Once()
+
+	report, err := s.taskUsecase.ImportTasks(s.user, rows, opts)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(4, report.Total)
+	s.Assert().Equal(1, report.Created)
+	s.Assert().Equal(2, report.Duplicates)
+	s.Assert().Equal(1, report.Failed)
+	s.Assert().Equal("new", report.Rows[0].TaskID)
+	s.Assert().Equal([]string{"a task with the same title and due date already exists"}, report.Rows[1].Errors)
+	s.Assert().Equal([]string{"same title and due date as line 2"}, report.Rows[2].Errors)
+	s.Assert().Len(report.Rows[3].Errors, 3)
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestImportTasks_DryRunWritesNothing() {
+	rows := []domain.ImportRow{{Line: 1, Values: map[string]string{"title": "Ship release"}}}
+	s.mockTaskRepo.On("FindByTitles", []string{"Ship release"}).Return([]*domain.Task{}, nil).Once()
+
+	report, err := s.taskUsecase.ImportTasks(s.user, rows, domain.ImportOptions{DryRun: true})
+
+	s.Require().NoError(err)
+	s.Assert().Equal(1, report.Valid)
+	s.Assert().Equal(domain.ImportRowValid, report.Rows[0].Status)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "BulkWrite", mock.Anything, mock.Anything)
+}
+
+func (s *TaskUsecaseTestSuite) TestImportTasks_ExportedUndatedTask() {
+	// JSON exports write the zero time for tasks without a due date
+	rows := []domain.ImportRow{{Line: 1, Values: map[string]string{"title": "Someday", "due_date": "0001-01-01T00:00:00Z", "status": "Pending"}}}
+	s.mockTaskRepo.On("FindByTitles", []string{"Someday"}).Return([]*domain.Task{}, nil).Once()
+
+	report, err := s.taskUsecase.ImportTasks(s.user, rows, domain.ImportOptions{DryRun: true})
+
+	s.Require().NoError(err)
+	s.Assert().Equal(1, report.Valid)
+	s.Assert().Empty(report.Rows[0].Errors)
+}
+
+func (s *TaskUsecaseTestSuite) TestImportTasks_UnknownMappingTarget() {
+	rows := []domain.ImportRow{{Line: 1, Values: map[string]string{"Name": "Ship release"}}}
+
+	_, err := s.taskUsecase.ImportTasks(s.user, rows, domain.ImportOptions{Mapping: map[string]string{"Name": "priority"}})
+
+	s.Require().ErrorIs(err, errs.ErrInvalidImport)
+}
+
+func (s *TaskUsecaseTestSuite) TestImportTasks_NoValidRowsLooksNothingUp() {
+	rows := []domain.ImportRow{{Line: 1, Values: map[string]string{"title": "", "due_date": "soon"}}}
+
+	report, err := s.taskUsecase.ImportTasks(s.user, rows, domain.ImportOptions{})
+
+	s.Require().NoError(err)
+	s.Assert().Equal(1, report.Failed)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "FindByTitles", mock.Anything)
+}
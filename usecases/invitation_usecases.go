@@ -0,0 +1,53 @@
+package usecases
+
+import (
+	"log"
+	"task-manager/domain"
+	"time"
+)
+
+// CreateInvitation issues an invitation to actor's workspace and returns
+// the token to send to the invitee. Like other secrets, it cannot be looked
+// up again.
+func (a *userAdminUsecase) CreateInvitation(actor *domain.User, invitation *domain.Invitation) (string, error) {
+	if invitation.Role == "" {
+		invitation.Role = domain.RoleUser
+	}
+	role, err := a.roles.role(actor.WorkspaceID, invitation.Role)
+	if err != nil {
+		return "", err
+	}
+	if err := a.roles.checkGrantable(actor, role.Permissions); err != nil {
+		return "", err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	invitation.WorkspaceID = actor.WorkspaceID
+	invitation.Role = role.Name
+	invitation.CreatedBy = actor.ID
+	invitation.TokenHash = hashToken(token)
+	invitation.CreatedAt = time.Now()
+	if invitation.ExpiresAt.IsZero() {
+		invitation.ExpiresAt = invitation.CreatedAt.Add(invitationTTL)
+	}
+	if err := a.invitationRepo.Create(invitation); err != nil {
+		return "", err
+	}
+	log.Printf("INFO: User '%s' invited someone to workspace %s as '%s'", actor.Username, actor.WorkspaceID, role.Name)
+	return token, nil
+}
+
+func (a *userAdminUsecase) ListInvitations(actor *domain.User) ([]*domain.Invitation, error) {
+	return a.invitationRepo.ListByWorkspace(actor.WorkspaceID, time.Now())
+}
+
+func (a *userAdminUsecase) RevokeInvitation(actor *domain.User, id string) error {
+	if err := a.invitationRepo.Delete(actor.WorkspaceID, id); err != nil {
+		return err
+	}
+	log.Printf("INFO: User '%s' revoked invitation %s", actor.Username, id)
+	return nil
+}
@@ -0,0 +1,158 @@
+package usecases
+
+import (
+	"log"
+	"net"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// LoginAttemptRepository keeps the failed-login counters that lockouts are
+// decided from.
+type LoginAttemptRepository interface {
+	// Get returns the counters of key, which are zero if it has none.
+	Get(key string) (*domain.LoginAttempts, error)
+	// RecordFailure counts a failed login at the given time. The count
+	// starts over when the previous failure is older than window.
+	RecordFailure(key string, at time.Time, window time.Duration) (*domain.LoginAttempts, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// LockoutPolicy locks a key once it reaches Threshold failed logins within
+// Window. The first lockout lasts BaseLockout and every further failure
+// doubles it, up to MaxLockout.
+type LockoutPolicy struct {
+	// Threshold of zero disables the lockout.
+	Threshold   int
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// lockout returns how long to lock a key after its failures-th failure.
+func (p LockoutPolicy) lockout(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	d := p.BaseLockout
+	for i := p.Threshold; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxLockout)
+}
+
+// AuthConfig holds the rules for setting passwords, for locking out
+// repeated failed logins, per account and per client IP, and for who may
+// register.
+type AuthConfig struct {
+	PasswordPolicy PasswordPolicy
+	AccountLockout LockoutPolicy
+	IPLockout      LockoutPolicy
+	// PasswordResetTTL is how long a password reset token can be used.
+	PasswordResetTTL time.Duration
+	Registration     RegistrationPolicy
+}
+
+var DefaultAuthConfig = AuthConfig{
+	PasswordPolicy:   DefaultPasswordPolicy,
+	AccountLockout:   LockoutPolicy{Threshold: 5, Window: 24 * time.Hour, BaseLockout: time.Minute, MaxLockout: time.Hour},
+	IPLockout:        LockoutPolicy{Threshold: 20, Window: 24 * time.Hour, BaseLockout: time.Minute, MaxLockout: time.Hour},
+	PasswordResetTTL: time.Hour,
+	Registration:     RegistrationPolicy{Mode: RegistrationOpen},
+}
+
+// accountLockoutKey is the same for every way of writing a username.
+func accountLockoutKey(username string) string {
+	return "account:" + domain.Canonical(username)
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// normalizeIP returns ip in canonical form, or "" if it is not an address.
+func normalizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	return parsed.String()
+}
+
+// checkLocked reports kind, with the time left, while key is locked out.
+func (u *userUsecase) checkLocked(key string, policy LockoutPolicy, kind *errs.AppError, now time.Time) error {
+	if policy.Threshold <= 0 {
+		return nil
+	}
+	attempts, err := u.attemptRepo.Get(key)
+	if err != nil {
+		return err
+	}
+	if now.Before(attempts.LockedUntil) {
+		return &errs.RetryError{Err: kind, After: attempts.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+func (u *userUsecase) recordFailure(key string, policy LockoutPolicy, now time.Time) error {
+	if policy.Threshold <= 0 {
+		return nil
+	}
+	attempts, err := u.attemptRepo.RecordFailure(key, now, policy.Window)
+	if err != nil {
+		return err
+	}
+	if d := policy.lockout(attempts.Failures); d > 0 {
+		log.Printf("WARN: locking out %s for %v after %d failed logins", key, d, attempts.Failures)
+		return u.attemptRepo.Lock(key, now.Add(d))
+	}
+	return nil
+}
+
+// loginFailed counts a failed login against both the account and the
+// address it came from.
+func (u *userUsecase) loginFailed(username, ip string, now time.Time) error {
+	if err := u.recordFailure(accountLockoutKey(username), u.config.AccountLockout, now); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return u.recordFailure(ipLockoutKey(ip), u.config.IPLockout, now)
+}
+
+// loginSucceeded forgets the failed logins of an account.
+func (u *userUsecase) loginSucceeded(username string) error {
+	if u.config.AccountLockout.Threshold <= 0 {
+		return nil
+	}
+	return u.attemptRepo.Reset(accountLockoutKey(username))
+}
+
+// UnlockUser lifts the lockout of an account and forgets its failed logins.
+// Admins can only unlock the members of their workspace.
+func (u *userUsecase) UnlockUser(workspaceID, userID string) error {
+	user, err := member(u.userRepo, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	log.Printf("INFO: unlocking account '%s'", user.Username)
+	return u.attemptRepo.Reset(accountLockoutKey(user.Username))
+}
+
+// UnlockIP lifts the lockout of a client address. Addresses are shared by
+// every workspace, so only the operators of the app can unlock them.
+func (u *userUsecase) UnlockIP(ip string) error {
+	ip = normalizeIP(ip)
+	if ip == "" {
+		return &errs.ValidationError{Fields: []errs.FieldError{{
+			Field:   "ip",
+			Code:    "ip",
+			Message: "must be an IPv4 or IPv6 address",
+		}}}
+	}
+	log.Printf("INFO: unlocking address %s", ip)
+	return u.attemptRepo.Reset(ipLockoutKey(ip))
+}
@@ -0,0 +1,264 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// OTPService implements time-based one-time passwords (RFC 6238).
+type OTPService interface {
+	GenerateSecret() (string, error)
+	// ProvisioningURI returns the otpauth:// URI that adds secret to an
+	// authenticator app.
+	ProvisioningURI(secret, account string) string
+	// Validate checks code at the given time and returns the time step it
+	// belongs to.
+	Validate(secret, code string, at time.Time) (step int64, ok bool)
+}
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeLength is in characters, without the separating dash.
+	recoveryCodeLength = 10
+)
+
+func (u *userUsecase) StartMFAEnrollment(userID string) (*domain.MFAEnrollment, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFA.Enabled {
+		return nil, errs.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := u.otpSvc.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.SetMFA(user.ID, domain.MFA{PendingSecret: secret}); err != nil {
+		return nil, err
+	}
+	return &domain.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: u.otpSvc.ProvisioningURI(secret, user.Username),
+	}, nil
+}
+
+// ConfirmMFAEnrollment checks that the user's authenticator produces the
+// right codes before MFA is required at login.
+func (u *userUsecase) ConfirmMFAEnrollment(userID, code string) ([]string, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFA.Enabled {
+		return nil, errs.ErrMFAAlreadyEnabled
+	}
+	if user.MFA.PendingSecret == "" {
+		return nil, fmt.Errorf("%w: start an enrollment first", errs.ErrMFANotEnabled)
+	}
+
+	step, ok := u.otpSvc.Validate(user.MFA.PendingSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, errs.ErrInvalidMFACode
+	}
+	codes, hashes, err := u.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	mfa := domain.MFA{
+		Enabled:            true,
+		Secret:             user.MFA.PendingSecret,
+		LastStep:           step,
+		RecoveryCodeHashes: hashes,
+	}
+	if err := u.userRepo.SetMFA(user.ID, mfa); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: User '%s' enabled two-factor authentication", user.Username)
+	return codes, nil
+}
+
+func (u *userUsecase) DisableMFA(userID, code string) error {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFA.Enabled {
+		return errs.ErrMFANotEnabled
+	}
+	if _, err := u.verifySecondFactor(user, code); err != nil {
+		return err
+	}
+
+	if err := u.userRepo.SetMFA(user.ID, domain.MFA{}); err != nil {
+		return err
+	}
+	log.Printf("INFO: User '%s' disabled two-factor authentication", user.Username)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user.
+func (u *userUsecase) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFA.Enabled {
+		return nil, errs.ErrMFANotEnabled
+	}
+	step, err := u.verifySecondFactor(user, code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := u.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	mfa := user.MFA
+	mfa.LastStep = max(mfa.LastStep, step)
+	mfa.RecoveryCodeHashes = hashes
+	if err := u.userRepo.SetMFA(user.ID, mfa); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (u *userUsecase) VerifyMFALogin(challenge, code, ip string) (string, error) {
+	userID, err := u.jwtSvc.ParseMFAChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrInvalidUserId) {
+			return "", errs.ErrInvalidMFAChallenge
+		}
+		return "", err
+	}
+	if !user.MFA.Enabled {
+		return "", errs.ErrInvalidMFAChallenge
+	}
+	if err := enterWorkspace(user); err != nil {
+		return "", err
+	}
+
+	// codes are guessed against the same lockouts as passwords
+	now := time.Now()
+	ip = normalizeIP(ip)
+	if ip != "" {
+		if err := u.checkLocked(ipLockoutKey(ip), u.config.IPLockout, errs.ErrTooManyLoginFailures, now); err != nil {
+			return "", err
+		}
+	}
+	if err := u.checkLocked(accountLockoutKey(user.Username), u.config.AccountLockout, errs.ErrAccountLocked, now); err != nil {
+		return "", err
+	}
+
+	if _, err := u.verifySecondFactor(user, code); err != nil {
+		if errors.Is(err, errs.ErrInvalidMFACode) {
+			log.Printf("WARN: Login failed for username '%s': invalid second factor", user.Username)
+			if err := u.loginFailed(user.Username, ip, now); err != nil {
+				return "", err
+			}
+		}
+		return "", err
+	}
+	if err := u.loginSucceeded(user.Username); err != nil {
+		return "", err
+	}
+
+	log.Printf("INFO: User '%s' (ID: %s, Role: %s) successfully authenticated with a second factor", user.Username, user.ID, user.Role)
+	return u.jwtSvc.GenerateJWT(user)
+}
+
+// verifySecondFactor accepts a TOTP code that was not used before or an
+// unused recovery code, which is then spent. It returns the TOTP step that
+// is now the last one used.
+func (u *userUsecase) verifySecondFactor(user *domain.User, code string) (int64, error) {
+	code = strings.TrimSpace(code)
+	if step, ok := u.otpSvc.Validate(user.MFA.Secret, code, time.Now()); ok {
+		advanced, err := u.userRepo.AdvanceMFAStep(user.ID, step)
+		if err != nil {
+			return 0, err
+		}
+		if !advanced {
+			return 0, fmt.Errorf("%w: code was already used", errs.ErrInvalidMFACode)
+		}
+		return step, nil
+	}
+
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeLength {
+		return 0, errs.ErrInvalidMFACode
+	}
+	for _, hash := range user.MFA.RecoveryCodeHashes {
+		if u.passwordSvc.Compare(hash, code) != nil {
+			continue
+		}
+		used, err := u.userRepo.UseRecoveryCode(user.ID, hash)
+		if err != nil {
+			return 0, err
+		}
+		if !used {
+			break
+		}
+		log.Printf("INFO: User '%s' used a recovery code, %d left", user.Username, len(user.MFA.RecoveryCodeHashes)-1)
+		return user.MFA.LastStep, nil
+	}
+	return 0, errs.ErrInvalidMFACode
+}
+
+// newRecoveryCodes returns fresh codes formatted for the user, along with
+// the hashes to store.
+func (u *userUsecase) newRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for range recoveryCodeCount {
+		secret := make([]byte, 8)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errs.ErrUnexpected, err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(secret))[:recoveryCodeLength]
+
+		hash, err := u.passwordSvc.Hash(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func (u *userUsecase) MFAEnrollmentRequired(user *domain.User) (bool, error) {
+	if user.MFA.Enabled {
+		return false, nil
+	}
+	settings, err := u.settingsRepo.GetSecuritySettings(user.WorkspaceID)
+	if err != nil {
+		return false, err
+	}
+	return settings.RequireAdminMFA, nil
+}
+
+func (u *userUsecase) GetSecuritySettings(workspaceID string) (*domain.SecuritySettings, error) {
+	return u.settingsRepo.GetSecuritySettings(workspaceID)
+}
+
+func (u *userUsecase) UpdateSecuritySettings(workspaceID string, settings *domain.SecuritySettings) error {
+	log.Printf("INFO: Updating security settings of workspace %s: require admin MFA = %t", workspaceID, settings.RequireAdminMFA)
+	return u.settingsRepo.SaveSecuritySettings(workspaceID, settings)
+}
@@ -0,0 +1,199 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// mfaUser returns a user with MFA enabled and one recovery code,
+// "abcde-fghij".
+func (s *UserUsecaseTestSuite) mfaUser() *domain.User {
+	recoveryHash, err := s.passwordService.Hash("abcdefghij")
+	s.Require().NoError(err)
+	passwordHash, err := s.passwordService.Hash("correctpassword")
+	s.Require().NoError(err)
+	return &domain.User{
+		ID:           "u1",
+		Username:     "testuser",
+		PasswordHash: passwordHash,
+		WorkspaceID:  "ws1",
+		Role:         domain.RoleAdmin,
+		Memberships:  memberOf("ws1", domain.RoleAdmin),
+		MFA: domain.MFA{
+			Enabled:            true,
+			Secret:             "SECRET",
+			LastStep:           100,
+			RecoveryCodeHashes: []string{recoveryHash},
+		},
+	}
+}
+
+func (s *UserUsecaseTestSuite) TestStartMFAEnrollment() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "testuser"}, nil).Once()
+	s.mockOTP.On("GenerateSecret").Return("SECRET", nil).Once()
+	s.mockOTP.On("ProvisioningURI", "SECRET", "testuser").Return("otpauth://totp/x").Once()
+	s.mockUserRepo.On("SetMFA", "u1", domain.MFA{PendingSecret: "SECRET"}).Return(nil).Once()
+
+	enrollment, err := s.userUsecase.StartMFAEnrollment("u1")
+
+	s.Require().NoError(err)
+	s.Assert().Equal(&domain.MFAEnrollment{Secret: "SECRET", ProvisioningURI: "otpauth://totp/x"}, enrollment)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestStartMFAEnrollment_AlreadyEnabled() {
+	s.mockUserRepo.On("GetByID", "u1").Return(s.mfaUser(), nil).Once()
+
+	_, err := s.userUsecase.StartMFAEnrollment("u1")
+
+	s.Assert().ErrorIs(err, errs.ErrMFAAlreadyEnabled)
+}
+
+func (s *UserUsecaseTestSuite) TestConfirmMFAEnrollment_StoresHashedRecoveryCodes() {
+	user := &domain.User{ID: "u1", Username: "testuser", MFA: domain.MFA{PendingSecret: "SECRET"}}
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.mockOTP.On("Validate", "SECRET", "123456", mock.Anything).Return(int64(42), true).Once()
+
+	var stored domain.MFA
+	s.mockUserRepo.On("SetMFA", "u1", mock.AnythingOfType("domain.MFA")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(domain.MFA)
+	}).Return(nil).Once()
+
+	codes, err := s.userUsecase.ConfirmMFAEnrollment("u1", " 123456 ")
+
+	s.Require().NoError(err)
+	s.Assert().Len(codes, 10)
+	s.Assert().Regexp(`^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+	s.Assert().True(stored.Enabled)
+	s.Assert().Equal("SECRET", stored.Secret)
+	s.Assert().Empty(stored.PendingSecret)
+	s.Assert().Equal(int64(42), stored.LastStep)
+	s.Require().Len(stored.RecoveryCodeHashes, 10)
+	s.Assert().NotContains(stored.RecoveryCodeHashes, codes[0])
+	s.Assert().NoError(s.passwordService.Compare(stored.RecoveryCodeHashes[0], codes[0][:5]+codes[0][6:]))
+}
+
+func (s *UserUsecaseTestSuite) TestConfirmMFAEnrollment_WrongCode() {
+	user := &domain.User{ID: "u1", MFA: domain.MFA{PendingSecret: "SECRET"}}
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.mockOTP.On("Validate", "SECRET", "000000", mock.Anything).Return(int64(0), false).Once()
+
+	_, err := s.userUsecase.ConfirmMFAEnrollment("u1", "000000")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidMFACode)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetMFA", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_WithMFAReturnsChallenge() {
+	user := s.mfaUser()
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockUserRepo.On("GetByUsername", "testuser").Return(user, nil).Once()
+
+	result, err := s.userUsecase.Login("testuser", "correctpassword", "203.0.113.7")
+
+	s.Require().NoError(err)
+	s.Assert().Empty(result.Token)
+	s.Require().NotEmpty(result.ChallengeToken)
+	// failures are only forgotten after the second factor
+	s.mockAttemptRepo.AssertNotCalled(s.T(), "Reset", mock.Anything)
+
+	userID, err := s.jwtService.ParseMFAChallenge(result.ChallengeToken)
+	s.Require().NoError(err)
+	s.Assert().Equal("u1", userID)
+}
+
+func (s *UserUsecaseTestSuite) challenge(user *domain.User) string {
+	challenge, err := s.jwtService.GenerateMFAChallenge(user)
+	s.Require().NoError(err)
+	return challenge
+}
+
+func (s *UserUsecaseTestSuite) TestVerifyMFALogin_TOTP() {
+	user := s.mfaUser()
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockOTP.On("Validate", "SECRET", "123456", mock.Anything).Return(int64(101), true).Once()
+	s.mockUserRepo.On("AdvanceMFAStep", "u1", int64(101)).Return(true, nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:testuser").Return(nil).Once()
+
+	token, err := s.userUsecase.VerifyMFALogin(s.challenge(user), "123456", "203.0.113.7")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(token)
+	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockAttemptRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestVerifyMFALogin_ReplayedTOTPCountsAsFailure() {
+	user := s.mfaUser()
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockOTP.On("Validate", "SECRET", "123456", mock.Anything).Return(int64(100), true).Once()
+	s.mockUserRepo.On("AdvanceMFAStep", "u1", int64(100)).Return(false, nil).Once()
+	s.mockAttemptRepo.On("RecordFailure", "account:testuser", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
+	s.mockAttemptRepo.On("RecordFailure", "ip:203.0.113.7", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
+
+	_, err := s.userUsecase.VerifyMFALogin(s.challenge(user), "123456", "203.0.113.7")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidMFACode)
+	s.mockAttemptRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestVerifyMFALogin_RecoveryCodeIsSpent() {
+	user := s.mfaUser()
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockOTP.On("Validate", "SECRET", "ABCDE-FGHIJ", mock.Anything).Return(int64(0), false).Once()
+	s.mockUserRepo.On("UseRecoveryCode", "u1", user.MFA.RecoveryCodeHashes[0]).Return(true, nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:testuser").Return(nil).Once()
+
+	token, err := s.userUsecase.VerifyMFALogin(s.challenge(user), "ABCDE-FGHIJ", "203.0.113.7")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(token)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestVerifyMFALogin_InvalidChallenge() {
+	// an access token is not a challenge
+	accessToken, err := s.jwtService.GenerateJWT(s.mfaUser())
+	s.Require().NoError(err)
+
+	_, err = s.userUsecase.VerifyMFALogin(accessToken, "123456", "203.0.113.7")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidMFAChallenge)
+	s.mockUserRepo.AssertNotCalled(s.T(), "GetByID", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestDisableMFA() {
+	s.mockUserRepo.On("GetByID", "u1").Return(s.mfaUser(), nil).Once()
+	s.mockOTP.On("Validate", "SECRET", "123456", mock.Anything).Return(int64(101), true).Once()
+	s.mockUserRepo.On("AdvanceMFAStep", "u1", int64(101)).Return(true, nil).Once()
+	s.mockUserRepo.On("SetMFA", "u1", domain.MFA{}).Return(nil).Once()
+
+	s.Require().NoError(s.userUsecase.DisableMFA("u1", "123456"))
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestMFAEnrollmentRequired() {
+	s.mockSettingsRepo.On("GetSecuritySettings", "ws1").Return(&domain.SecuritySettings{RequireAdminMFA: true}, nil)
+	s.mockSettingsRepo.On("GetSecuritySettings", "ws2").Return(&domain.SecuritySettings{}, nil)
+
+	admin := &domain.User{WorkspaceID: "ws1", Role: domain.RoleAdmin}
+	required, err := s.userUsecase.MFAEnrollmentRequired(admin)
+	s.Require().NoError(err)
+	s.Assert().True(required)
+
+	// each workspace decides for itself
+	admin = &domain.User{WorkspaceID: "ws2", Role: domain.RoleAdmin}
+	required, err = s.userUsecase.MFAEnrollmentRequired(admin)
+	s.Require().NoError(err)
+	s.Assert().False(required)
+
+	required, err = s.userUsecase.MFAEnrollmentRequired(s.mfaUser())
+	s.Require().NoError(err)
+	s.Assert().False(required, "admins with MFA are fine")
+}
@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Notifier struct {
+	mock.Mock
+}
+
+func (m *Notifier) Notify(n domain.Notification) error {
+	args := m.Called(n)
+	return args.Error(0)
+}
@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OTPService struct {
+	mock.Mock
+}
+
+func (m *OTPService) GenerateSecret() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *OTPService) ProvisioningURI(secret, account string) string {
+	args := m.Called(secret, account)
+	return args.String(0)
+}
+
+func (m *OTPService) Validate(secret, code string, at time.Time) (int64, bool) {
+	args := m.Called(secret, code, at)
+	return args.Get(0).(int64), args.Bool(1)
+}
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RoleUsecase struct {
+	mock.Mock
+}
+
+func (m *RoleUsecase) Permissions(user *domain.User) ([]string, error) {
+	args := m.Called(user)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *RoleUsecase) ListRoles(workspaceID string) ([]*domain.Role, error) {
+	args := m.Called(workspaceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Role), args.Error(1)
+}
+
+func (m *RoleUsecase) CreateRole(actor *domain.User, role *domain.Role) error {
+	args := m.Called(actor, role)
+	return args.Error(0)
+}
+
+func (m *RoleUsecase) UpdateRole(actor *domain.User, role *domain.Role) error {
+	args := m.Called(actor, role)
+	return args.Error(0)
+}
+
+func (m *RoleUsecase) DeleteRole(actor *domain.User, name string) error {
+	args := m.Called(actor, name)
+	return args.Error(0)
+}
+
+func (m *RoleUsecase) AssignRole(actor *domain.User, userID, role string) error {
+	args := m.Called(actor, userID, role)
+	return args.Error(0)
+}
@@ -0,0 +1,27 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type SSOUsecase struct {
+	mock.Mock
+}
+
+func (m *SSOUsecase) Start(linkUserID string) (*domain.SSOStart, error) {
+	args := m.Called(linkUserID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SSOStart), args.Error(1)
+}
+
+func (m *SSOUsecase) Callback(sealedRequest, state, code string) (*domain.SSOResult, error) {
+	args := m.Called(sealedRequest, state, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SSOResult), args.Error(1)
+}
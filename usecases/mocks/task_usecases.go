@@ -10,35 +10,92 @@ type TaskUsecase struct {
 	mock.Mock
 }
 
-func (m *TaskUsecase) CreateTask(task *domain.Task) (*domain.Task, error) {
-	args := m.Called(task)
+func (m *TaskUsecase) CreateTask(user *domain.User, task *domain.Task) (*domain.Task, error) {
+	args := m.Called(user, task)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.Task), args.Error(1)
 }
-func (m *TaskUsecase) GetTasks() ([]*domain.Task, error) {
-	args := m.Called()
+func (m *TaskUsecase) GetTasks(user *domain.User) ([]*domain.Task, error) {
+	args := m.Called(user)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*domain.Task), args.Error(1)
 }
-func (m *TaskUsecase) GetTaskByID(id string) (*domain.Task, error) {
-	args := m.Called(id)
+func (m *TaskUsecase) GetTaskByID(user *domain.User, id string) (*domain.Task, error) {
+	args := m.Called(user, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.Task), args.Error(1)
 }
-func (m *TaskUsecase) UpdateTask(id string, task domain.Task) (*domain.Task, error) {
-	args := m.Called(id, task)
+func (m *TaskUsecase) UpdateTask(user *domain.User, id string, task domain.Task) (*domain.Task, error) {
+	args := m.Called(user, id, task)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.Task), args.Error(1)
 }
-func (m *TaskUsecase) DeleteTask(id string) error {
-	args := m.Called(id)
+func (m *TaskUsecase) DeleteTask(user *domain.User, id string) error {
+	args := m.Called(user, id)
 	return args.Error(0)
 }
+func (m *TaskUsecase) SearchTasks(user *domain.User, q string, limit int) ([]*domain.SearchResult, error) {
+	args := m.Called(user, q, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SearchResult), args.Error(1)
+}
+func (m *TaskUsecase) FilterTasks(user *domain.User, query string) ([]*domain.Task, error) {
+	args := m.Called(user, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Task), args.Error(1)
+}
+func (m *TaskUsecase) BulkTasks(user *domain.User, ops []domain.BulkOperation, atomic bool) ([]*domain.BulkResult, error) {
+	args := m.Called(user, ops, atomic)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BulkResult), args.Error(1)
+}
+func (m *TaskUsecase) PatchTasks(user *domain.User, query string, patch domain.Task) (int64, int64, error) {
+	args := m.Called(user, query, patch)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+func (m *TaskUsecase) ExportTasks(user *domain.User, query string, fn func(*domain.Task) error) error {
+	args := m.Called(user, query, fn)
+	if tasks, ok := args.Get(0).([]*domain.Task); ok {
+		for _, task := range tasks {
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+func (m *TaskUsecase) ImportTasks(user *domain.User, rows []domain.ImportRow, opts domain.ImportOptions) (*domain.ImportReport, error) {
+	args := m.Called(user, rows, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ImportReport), args.Error(1)
+}
+func (m *TaskUsecase) CalendarTasks(user *domain.User, query string) ([]*domain.Task, error) {
+	args := m.Called(user, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Task), args.Error(1)
+}
+func (m *TaskUsecase) ExplainAccess(user *domain.User, action, taskID string, changes domain.Task) (*domain.AccessDecision, error) {
+	args := m.Called(user, action, taskID, changes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AccessDecision), args.Error(1)
+}
@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type UserAdminUsecase struct {
+	mock.Mock
+}
+
+func (m *UserAdminUsecase) ListUsers(actor *domain.User, query domain.UserQuery) (*domain.UserPage, error) {
+	args := m.Called(actor, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserPage), args.Error(1)
+}
+
+func (m *UserAdminUsecase) GetUser(actor *domain.User, id string) (*domain.User, error) {
+	args := m.Called(actor, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *UserAdminUsecase) UpdateUser(actor *domain.User, id string, update domain.UserUpdate) (*domain.User, error) {
+	args := m.Called(actor, id, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *UserAdminUsecase) DeleteUser(actor *domain.User, id string) error {
+	args := m.Called(actor, id)
+	return args.Error(0)
+}
+
+func (m *UserAdminUsecase) Demote(actor *domain.User, id string) error {
+	args := m.Called(actor, id)
+	return args.Error(0)
+}
+
+func (m *UserAdminUsecase) CreateInvitation(actor *domain.User, invitation *domain.Invitation) (string, error) {
+	args := m.Called(actor, invitation)
+	return args.String(0), args.Error(1)
+}
+
+func (m *UserAdminUsecase) ListInvitations(actor *domain.User) ([]*domain.Invitation, error) {
+	args := m.Called(actor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invitation), args.Error(1)
+}
+
+func (m *UserAdminUsecase) RevokeInvitation(actor *domain.User, id string) error {
+	args := m.Called(actor, id)
+	return args.Error(0)
+}
@@ -10,16 +10,39 @@ type UserUsecase struct {
 	mock.Mock
 }
 
-func (m *UserUsecase) Register(user *domain.User) error {
-	args := m.Called(user)
+func (m *UserUsecase) Register(user *domain.User, invitation string) error {
+	args := m.Called(user, invitation)
+	return args.Error(0)
+}
+func (m *UserUsecase) RequestInvitation(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+func (m *UserUsecase) Bootstrap(user *domain.User, workspaceName string) error {
+	args := m.Called(user, workspaceName)
 	return args.Error(0)
 }
-func (m *UserUsecase) Login(username, password string) (string, error) {
-	args := m.Called(username, password)
+func (m *UserUsecase) Login(username, password, ip string) (*domain.LoginResult, error) {
+	args := m.Called(username, password, ip)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LoginResult), args.Error(1)
+}
+func (m *UserUsecase) VerifyMFALogin(challenge, code, ip string) (string, error) {
+	args := m.Called(challenge, code, ip)
 	return args.String(0), args.Error(1)
 }
-func (m *UserUsecase) Promote(id string) error {
-	args := m.Called(id)
+func (m *UserUsecase) Promote(workspaceID, id string) error {
+	args := m.Called(workspaceID, id)
+	return args.Error(0)
+}
+func (m *UserUsecase) UnlockUser(workspaceID, userID string) error {
+	args := m.Called(workspaceID, userID)
+	return args.Error(0)
+}
+func (m *UserUsecase) UnlockIP(ip string) error {
+	args := m.Called(ip)
 	return args.Error(0)
 }
 func (m *UserUsecase) GetUserByID(id string) (*domain.User, error) {
@@ -29,3 +52,99 @@ func (m *UserUsecase) GetUserByID(id string) (*domain.User, error) {
 	}
 	return args.Get(0).(*domain.User), args.Error(1)
 }
+func (m *UserUsecase) CreateCalendarToken(workspaceID, userID string) (string, error) {
+	args := m.Called(workspaceID, userID)
+	return args.String(0), args.Error(1)
+}
+func (m *UserUsecase) RevokeCalendarToken(workspaceID, userID string) error {
+	args := m.Called(workspaceID, userID)
+	return args.Error(0)
+}
+func (m *UserUsecase) GetUserByCalendarToken(token string) (*domain.User, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+func (m *UserUsecase) StartMFAEnrollment(userID string) (*domain.MFAEnrollment, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAEnrollment), args.Error(1)
+}
+func (m *UserUsecase) ConfirmMFAEnrollment(userID, code string) ([]string, error) {
+	args := m.Called(userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *UserUsecase) DisableMFA(userID, code string) error {
+	args := m.Called(userID, code)
+	return args.Error(0)
+}
+func (m *UserUsecase) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	args := m.Called(userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *UserUsecase) MFAEnrollmentRequired(user *domain.User) (bool, error) {
+	args := m.Called(user)
+	return args.Bool(0), args.Error(1)
+}
+func (m *UserUsecase) GetSecuritySettings(workspaceID string) (*domain.SecuritySettings, error) {
+	args := m.Called(workspaceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SecuritySettings), args.Error(1)
+}
+func (m *UserUsecase) UpdateSecuritySettings(workspaceID string, settings *domain.SecuritySettings) error {
+	args := m.Called(workspaceID, settings)
+	return args.Error(0)
+}
+func (m *UserUsecase) ChangePassword(user *domain.User, currentPassword, newPassword string) (string, error) {
+	args := m.Called(user, currentPassword, newPassword)
+	return args.String(0), args.Error(1)
+}
+func (m *UserUsecase) RequestPasswordReset(username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+func (m *UserUsecase) ResetPassword(token, newPassword string) error {
+	args := m.Called(token, newPassword)
+	return args.Error(0)
+}
+func (m *UserUsecase) CreateAPIToken(userID string, token *domain.APIToken) (string, error) {
+	args := m.Called(userID, token)
+	return args.String(0), args.Error(1)
+}
+func (m *UserUsecase) ListAPITokens(userID string) ([]*domain.APIToken, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.APIToken), args.Error(1)
+}
+func (m *UserUsecase) RevokeAPIToken(userID, tokenID string) error {
+	args := m.Called(userID, tokenID)
+	return args.Error(0)
+}
+func (m *UserUsecase) AuthenticateAPIToken(secret string) (*domain.User, *domain.APIToken, error) {
+	args := m.Called(secret)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*domain.User), args.Get(1).(*domain.APIToken), args.Error(2)
+}
+func (m *UserUsecase) UpdateProfile(userID string, update domain.ProfileUpdate) (*domain.User, error) {
+	args := m.Called(userID, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type WorkspaceUsecase struct {
+	mock.Mock
+}
+
+func (m *WorkspaceUsecase) ListWorkspaces(user *domain.User) ([]*domain.Workspace, error) {
+	args := m.Called(user)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Workspace), args.Error(1)
+}
+
+func (m *WorkspaceUsecase) CreateWorkspace(user *domain.User, name string) (*domain.Workspace, error) {
+	args := m.Called(user, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Workspace), args.Error(1)
+}
+
+func (m *WorkspaceUsecase) JoinWorkspace(user *domain.User, invitation string) (*domain.Workspace, error) {
+	args := m.Called(user, invitation)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Workspace), args.Error(1)
+}
+
+func (m *WorkspaceUsecase) SwitchWorkspace(user *domain.User, workspaceID string) (string, error) {
+	args := m.Called(user, workspaceID)
+	return args.String(0), args.Error(1)
+}
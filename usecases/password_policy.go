@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+	"task-manager/errs"
+	"unicode"
+)
+
+// PasswordPolicy decides which passwords may be set.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength is in bytes; bcrypt ignores everything after 72.
+	MaxLength int
+	// MinClasses is how many of lowercase letters, uppercase letters,
+	// digits and symbols a password must mix.
+	MinClasses int
+	// AllowUsername permits a password equal to the username.
+	AllowUsername bool
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:  8,
+	MaxLength:  72,
+	MinClasses: 2,
+}
+
+// BreachedPasswordChecker tells whether a password appears in a list of
+// passwords exposed in known data breaches.
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// Check lists every rule of the policy that password breaks.
+func (p PasswordPolicy) Check(username, password string) []errs.FieldError {
+	var problems []errs.FieldError
+	add := func(code, msg string) {
+		problems = append(problems, errs.FieldError{Field: "password", Code: code, Message: msg})
+	}
+
+	if len(password) < p.MinLength {
+		add("min", fmt.Sprintf("must have at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add("max", fmt.Sprintf("must have at most %d bytes", p.MaxLength))
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		add("classes", fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses))
+	}
+	if !p.AllowUsername && password != "" && strings.EqualFold(password, username) {
+		add("same_as_username", "must not be the username")
+	}
+	return problems
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, symbol} {
+		if has {
+			classes++
+		}
+	}
+	return classes
+}
+
+// checkPassword applies the password policy and the breached-password list
+// to a password that is about to be set for username.
+func (u *userUsecase) checkPassword(username, password string) error {
+	problems := u.config.PasswordPolicy.Check(username, password)
+
+	if u.breached != nil && len(problems) == 0 {
+		breached, err := u.breached.IsBreached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			problems = append(problems, errs.FieldError{
+				Field:   "password",
+				Code:    "breached",
+				Message: "appears in a known data breach, choose another one",
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &errs.ValidationError{Fields: problems}
+	}
+	return nil
+}
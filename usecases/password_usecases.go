@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// PasswordResetRepository keeps the outstanding password reset tokens.
+type PasswordResetRepository interface {
+	Create(reset *domain.PasswordReset) error
+	// Get returns the unexpired reset with tokenHash without using it up, or
+	// ErrInvalidResetToken if there is none.
+	Get(tokenHash string, now time.Time) (*domain.PasswordReset, error)
+	// Consume removes and returns the unexpired reset with tokenHash, so
+	// that a token works only once. It returns ErrInvalidResetToken if
+	// there is none.
+	Consume(tokenHash string, now time.Time) (*domain.PasswordReset, error)
+	// DeleteByUser drops every outstanding reset of a user.
+	DeleteByUser(userID string) error
+}
+
+func (u *userUsecase) ChangePassword(current *domain.User, currentPassword, newPassword string) (string, error) {
+	user, err := u.userRepo.GetByID(current.ID)
+	if err != nil {
+		return "", err
+	}
+	// the new token keeps the session in the same workspace
+	if !user.Activate(current.WorkspaceID) {
+		return "", errs.ErrWorkspaceNotFound
+	}
+	if err := u.passwordSvc.Compare(user.PasswordHash, currentPassword); err != nil {
+		log.Printf("WARN: Password change refused for user '%s': invalid current password", user.Username)
+		return "", err
+	}
+	if err := u.setPassword(user, newPassword); err != nil {
+		return "", err
+	}
+
+	log.Printf("INFO: User '%s' changed their password", user.Username)
+	return u.jwtSvc.GenerateJWT(user)
+}
+
+func (u *userUsecase) RequestPasswordReset(username string) error {
+	user, err := u.userRepo.GetByUsername(username)
+	if errors.Is(err, errs.ErrUserNotFound) {
+		log.Printf("WARN: Password reset requested for unknown username '%s'", username)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the token goes to the mailbox the user proved they own; answering the
+	// same as for unknown users keeps accounts from being probed
+	if user.Profile.Email == "" || !user.Profile.EmailVerified {
+		log.Printf("WARN: Password reset requested for user '%s', who has no verified email", user.Username)
+		return nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reset := &domain.PasswordReset{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(u.config.PasswordResetTTL),
+	}
+	if err := u.resetRepo.Create(reset); err != nil {
+		return err
+	}
+
+	log.Printf("INFO: Password reset requested for user '%s'", user.Username)
+	return u.notifier.Notify(domain.Notification{
+		Kind:       domain.NotificationPasswordReset,
+		Recipients: []string{user.Profile.Email},
+		Subject:    "Reset your password",
+		Body: fmt.Sprintf("Use this token to choose a new password before %s: %s\n\nIf you did not ask to reset your password, ignore this message.",
+			reset.ExpiresAt.Format(time.RFC3339), token),
+		CreatedAt: now,
+	})
+}
+
+// ResetPassword only uses the token up once the new password is accepted,
+// so that a user who picks a rejected one can try another.
+func (u *userUsecase) ResetPassword(token, newPassword string) error {
+	reset, err := u.resetRepo.Get(hashToken(token), time.Now())
+	if err != nil {
+		return err
+	}
+	user, err := u.userRepo.GetByID(reset.UserID)
+	if err != nil {
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrInvalidUserId) {
+			return errs.ErrInvalidResetToken
+		}
+		return err
+	}
+	if err := u.checkNewPassword(user, newPassword); err != nil {
+		return err
+	}
+	// another request may have used the token in the meantime
+	if _, err := u.resetRepo.Consume(reset.TokenHash, time.Now()); err != nil {
+		return err
+	}
+	if err := u.storePassword(user, newPassword); err != nil {
+		return err
+	}
+
+	// proving access to the account also lifts its lockout
+	if err := u.loginSucceeded(user.Username); err != nil {
+		return err
+	}
+	log.Printf("INFO: User '%s' reset their password", user.Username)
+	return nil
+}
+
+// setPassword checks and stores a new password.
+func (u *userUsecase) setPassword(user *domain.User, password string) error {
+	if err := u.checkNewPassword(user, password); err != nil {
+		return err
+	}
+	return u.storePassword(user, password)
+}
+
+// checkNewPassword checks a password against the policy and the breached
+// passwords, reporting problems under new_password.
+func (u *userUsecase) checkNewPassword(user *domain.User, password string) error {
+	if err := u.checkPassword(user.Username, password); err != nil {
+		return renameField(err, "password", "new_password")
+	}
+	return nil
+}
+
+// storePassword stores a checked password, which signs out the user's
+// sessions and voids their other reset tokens and their API tokens, so that
+// none of them outlives the password they were obtained with.
+// user.TokenVersion is updated to match.
+func (u *userUsecase) storePassword(user *domain.User, password string) error {
+	hash, err := u.passwordSvc.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdatePassword(user.ID, hash); err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.TokenVersion++
+	if err := u.apiTokenRepo.DeleteByUser(user.ID); err != nil {
+		return err
+	}
+	return u.resetRepo.DeleteByUser(user.ID)
+}
+
+// renameField reports the field errors of err under the name the request
+// used.
+func renameField(err error, from, to string) error {
+	var validationErr *errs.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	for i := range validationErr.Fields {
+		if validationErr.Fields[i].Field == from {
+			validationErr.Fields[i].Field = to
+		}
+	}
+	return validationErr
+}
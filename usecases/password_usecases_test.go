@@ -0,0 +1,156 @@
+package usecases_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *UserUsecaseTestSuite) userWithPassword(password string) *domain.User {
+	hash, err := s.passwordService.Hash(password)
+	s.Require().NoError(err)
+	return &domain.User{ID: "u1", Username: "testuser", PasswordHash: hash, Role: domain.RoleUser, TokenVersion: 2, Memberships: memberOf("ws1", domain.RoleUser)}
+}
+
+// session is the user u1 as the auth middleware hands them over.
+var session = &domain.User{ID: "u1", Username: "testuser", WorkspaceID: "ws1", Role: domain.RoleUser}
+
+func sha256Hex(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *UserUsecaseTestSuite) TestChangePassword() {
+	user := s.userWithPassword("oldpassword1")
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	var hash string
+	s.mockUserRepo.On("UpdatePassword", "u1", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		hash = args.String(1)
+	}).Return(nil).Once()
+	s.mockResetRepo.On("DeleteByUser", "u1").Return(nil).Once()
+	s.mockTokenRepo.On("DeleteByUser", "u1").Return(nil).Once()
+
+	token, err := s.userUsecase.ChangePassword(session, "oldpassword1", "newpassword2")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(token)
+	s.Assert().Equal(3, user.TokenVersion, "the returned token carries the new version")
+	s.Assert().Equal("ws1", user.WorkspaceID, "the returned token stays in the workspace")
+	s.Assert().NoError(s.passwordService.Compare(hash, "newpassword2"))
+	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockResetRepo.AssertExpectations(s.T())
+	s.mockTokenRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestChangePassword_WrongCurrentPassword() {
+	s.mockUserRepo.On("GetByID", "u1").Return(s.userWithPassword("oldpassword1"), nil).Once()
+
+	_, err := s.userUsecase.ChangePassword(session, "guess", "newpassword2")
+
+	s.Assert().ErrorIs(err, errs.ErrIncorrectPassword)
+	s.mockUserRepo.AssertNotCalled(s.T(), "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestChangePassword_PolicyReportsNewPassword() {
+	s.mockUserRepo.On("GetByID", "u1").Return(s.userWithPassword("oldpassword1"), nil).Once()
+
+	_, err := s.userUsecase.ChangePassword(session, "oldpassword1", "short")
+
+	var validationErr *errs.ValidationError
+	s.Require().ErrorAs(err, &validationErr)
+	s.Assert().Equal("new_password", validationErr.Fields[0].Field)
+	s.mockUserRepo.AssertNotCalled(s.T(), "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRequestPasswordReset() {
+	s.mockUserRepo.On("GetByUsername", "testuser").Return(&domain.User{
+		ID: "u1", Username: "testuser", Profile: domain.Profile{Email: "test@example.com", EmailVerified: true},
+	}, nil).Once()
+
+	var reset *domain.PasswordReset
+	s.mockResetRepo.On("Create", mock.AnythingOfType("*domain.PasswordReset")).Run(func(args mock.Arguments) {
+		reset = args.Get(0).(*domain.PasswordReset)
+	}).Return(nil).Once()
+	var sent domain.Notification
+	s.mockNotifier.On("Notify", mock.AnythingOfType("domain.Notification")).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(domain.Notification)
+	}).Return(nil).Once()
+
+	s.Require().NoError(s.userUsecase.RequestPasswordReset("testuser"))
+
+	s.Assert().Equal("u1", reset.UserID)
+	s.Assert().WithinDuration(time.Now().Add(time.Hour), reset.ExpiresAt, time.Minute)
+	s.Assert().Equal(domain.NotificationPasswordReset, sent.Kind)
+	s.Assert().Equal([]string{"test@example.com"}, sent.Recipients)
+
+	// the notification carries the token and the database only its hash
+	match := regexp.MustCompile(`: (\S+)\n`).FindStringSubmatch(sent.Body)
+	s.Require().Len(match, 2, sent.Body)
+	token := match[1]
+	s.Assert().Equal(sha256Hex(token), reset.TokenHash)
+	s.Assert().NotContains(sent.Subject, token)
+}
+
+func (s *UserUsecaseTestSuite) TestRequestPasswordReset_UnknownUser() {
+	s.mockUserRepo.On("GetByUsername", "nobody").Return(nil, errs.ErrUserNotFound).Once()
+
+	s.Assert().NoError(s.userUsecase.RequestPasswordReset("nobody"))
+	s.mockResetRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+	s.mockNotifier.AssertNotCalled(s.T(), "Notify", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRequestPasswordReset_UnverifiedEmail() {
+	s.mockUserRepo.On("GetByUsername", "testuser").Return(&domain.User{
+		ID: "u1", Username: "testuser", Profile: domain.Profile{Email: "test@example.com"},
+	}, nil).Once()
+
+	s.Assert().NoError(s.userUsecase.RequestPasswordReset("testuser"))
+	s.mockResetRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+	s.mockNotifier.AssertNotCalled(s.T(), "Notify", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestResetPassword() {
+	reset := &domain.PasswordReset{TokenHash: sha256Hex("reset-token"), UserID: "u1"}
+	s.mockResetRepo.On("Get", sha256Hex("reset-token"), mock.AnythingOfType("time.Time")).Return(reset, nil).Once()
+	s.mockResetRepo.On("Consume", sha256Hex("reset-token"), mock.AnythingOfType("time.Time")).Return(reset, nil).Once()
+	s.mockUserRepo.On("GetByID", "u1").Return(s.userWithPassword("forgotten1"), nil).Once()
+	s.mockUserRepo.On("UpdatePassword", "u1", mock.AnythingOfType("string")).Return(nil).Once()
+	s.mockResetRepo.On("DeleteByUser", "u1").Return(nil).Once()
+	s.mockTokenRepo.On("DeleteByUser", "u1").Return(nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:testuser").Return(nil).Once()
+
+	s.Require().NoError(s.userUsecase.ResetPassword("reset-token", "newpassword2"))
+
+	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockResetRepo.AssertExpectations(s.T())
+	s.mockAttemptRepo.AssertExpectations(s.T())
+	s.mockTokenRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestResetPassword_InvalidToken() {
+	s.mockResetRepo.On("Get", sha256Hex("used-token"), mock.AnythingOfType("time.Time")).Return(nil, errs.ErrInvalidResetToken).Once()
+
+	err := s.userUsecase.ResetPassword("used-token", "newpassword2")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidResetToken)
+	s.mockUserRepo.AssertNotCalled(s.T(), "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestResetPassword_RejectedPasswordKeepsTheToken() {
+	reset := &domain.PasswordReset{TokenHash: sha256Hex("reset-token"), UserID: "u1"}
+	s.mockResetRepo.On("Get", sha256Hex("reset-token"), mock.AnythingOfType("time.Time")).Return(reset, nil).Once()
+	s.mockUserRepo.On("GetByID", "u1").Return(s.userWithPassword("forgotten1"), nil).Once()
+
+	err := s.userUsecase.ResetPassword("reset-token", "short")
+
+	var validationErr *errs.ValidationError
+	s.Require().ErrorAs(err, &validationErr)
+	s.Assert().Equal("new_password", validationErr.Fields[0].Field)
+	s.mockResetRepo.AssertNotCalled(s.T(), "Consume", mock.Anything, mock.Anything)
+	s.mockUserRepo.AssertNotCalled(s.T(), "UpdatePassword", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,37 @@
+package usecases
+
+import (
+	"strings"
+	"task-manager/domain"
+)
+
+func (u *userUsecase) UpdateProfile(userID string, update domain.ProfileUpdate) (*domain.User, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := user.Profile
+	set := func(field *string, value *string) {
+		if value != nil {
+			*field = strings.TrimSpace(*value)
+		}
+	}
+	set(&profile.DisplayName, update.DisplayName)
+	set(&profile.Email, update.Email)
+	set(&profile.TimeZone, update.TimeZone)
+	set(&profile.Locale, update.Locale)
+	// nothing proves a new address is the user's
+	if domain.Canonical(profile.Email) != domain.Canonical(user.Profile.Email) {
+		profile.EmailVerified = false
+	}
+	if profile == user.Profile {
+		return user, nil
+	}
+
+	if err := u.userRepo.SetProfile(userID, profile); err != nil {
+		return nil, err
+	}
+	user.Profile = profile
+	return user, nil
+}
@@ -0,0 +1,50 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *UserUsecaseTestSuite) TestUpdateProfile_ChangesOnlyGivenFields() {
+	user := &domain.User{ID: "u1", Username: "testuser", Profile: domain.Profile{DisplayName: "Test", Locale: "en-US"}}
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+	want := domain.Profile{DisplayName: "Test User", TimeZone: "Europe/Berlin"}
+	s.mockUserRepo.On("SetProfile", "u1", want).Return(nil).Once()
+
+	name, tz, locale := " Test User ", "Europe/Berlin", ""
+	updated, err := s.userUsecase.UpdateProfile("u1", domain.ProfileUpdate{DisplayName: &name, TimeZone: &tz, Locale: &locale})
+
+	s.Require().NoError(err)
+	s.Assert().Equal(want, updated.Profile)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestUpdateProfile_Unchanged() {
+	user := &domain.User{ID: "u1", Username: "testuser", Profile: domain.Profile{Email: "test@example.com"}}
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Once()
+
+	email := "test@example.com"
+	_, err := s.userUsecase.UpdateProfile("u1", domain.ProfileUpdate{Email: &email})
+
+	s.Require().NoError(err)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetProfile", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestUpdateProfile_NewEmailIsNotVerified() {
+	user := &domain.User{ID: "u1", Username: "testuser", Profile: domain.Profile{Email: "test@example.com", EmailVerified: true}}
+	s.mockUserRepo.On("GetByID", "u1").Return(user, nil).Twice()
+	s.mockUserRepo.On("SetProfile", "u1", domain.Profile{Email: "Test@Example.com", EmailVerified: true}).Return(nil).Once()
+	s.mockUserRepo.On("SetProfile", "u1", domain.Profile{Email: "other@example.com"}).Return(nil).Once()
+
+	// writing the same address differently keeps it verified
+	email := "Test@Example.com"
+	_, err := s.userUsecase.UpdateProfile("u1", domain.ProfileUpdate{Email: &email})
+	s.Require().NoError(err)
+
+	email = "other@example.com"
+	updated, err := s.userUsecase.UpdateProfile("u1", domain.ProfileUpdate{Email: &email})
+	s.Require().NoError(err)
+	s.Assert().False(updated.Profile.EmailVerified)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
@@ -0,0 +1,14 @@
+package usecases
+
+import (
+	"task-manager/domain"
+	"time"
+)
+
+// RateLimitStore keeps the token buckets of rate limits, which replicas
+// share if they use the same store.
+type RateLimitStore interface {
+	// Take takes a token at now from the bucket of key, which fills up as
+	// limit says.
+	Take(key string, limit domain.RateLimit, now time.Time) (domain.RateLimitStatus, error)
+}
@@ -0,0 +1,222 @@
+package usecases
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// Registration modes decide who may create an account with POST /register.
+// Invitations from admins are accepted in every mode but closed.
+const (
+	// RegistrationOpen lets anyone register.
+	RegistrationOpen = "open"
+	// RegistrationInviteOnly needs an invitation from an admin.
+	RegistrationInviteOnly = "invite-only"
+	// RegistrationDomainAllowlist needs an invitation, which people with an
+	// email address at one of the allowed domains can send themselves.
+	RegistrationDomainAllowlist = "domain-allowlist"
+	// RegistrationClosed refuses everyone. Accounts come from single
+	// sign-on, if SSOConfig.Provision allows it, or the bootstrap command.
+	RegistrationClosed = "closed"
+)
+
+// RegistrationModes lists the valid registration modes.
+var RegistrationModes = []string{RegistrationOpen, RegistrationInviteOnly, RegistrationDomainAllowlist, RegistrationClosed}
+
+// RegistrationPolicy decides who may register.
+type RegistrationPolicy struct {
+	Mode string
+	// AllowedDomains are the email domains of the domain-allowlist mode,
+	// such as "example.com". Subdomains are not included.
+	AllowedDomains []string
+}
+
+// invitationTTL is how long invitations can be used unless the admin
+// issuing one says otherwise.
+const invitationTTL = 7 * 24 * time.Hour
+
+// allowsEmail tells whether email is at one of the allowed domains.
+func (p RegistrationPolicy) allowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domainName := strings.ToLower(email[at+1:])
+	return slices.ContainsFunc(p.AllowedDomains, func(allowed string) bool {
+		return strings.EqualFold(allowed, domainName)
+	})
+}
+
+// check refuses registrations the mode does not allow.
+func (p RegistrationPolicy) check(invited bool) error {
+	switch p.Mode {
+	case RegistrationOpen:
+		return nil
+	case RegistrationInviteOnly, RegistrationDomainAllowlist:
+		if !invited {
+			return errs.ErrInvitationRequired
+		}
+		return nil
+	default:
+		return errs.ErrRegistrationClosed
+	}
+}
+
+// InvitationRepository keeps the outstanding invitations.
+type InvitationRepository interface {
+	// Create stores an invitation. It keeps invitation.ID if it is set, so
+	// that a consumed invitation can be put back.
+	Create(invitation *domain.Invitation) error
+	// ListByWorkspace returns the invitations to a workspace that have not
+	// expired at now, oldest first.
+	ListByWorkspace(workspaceID string, now time.Time) ([]*domain.Invitation, error)
+	// Consume removes and returns the unexpired invitation with tokenHash,
+	// so that it works only once. It returns ErrInvalidInvitation if there
+	// is none.
+	Consume(tokenHash string, now time.Time) (*domain.Invitation, error)
+	// Delete returns ErrInvitationNotFound unless the workspace has an
+	// invitation with id.
+	Delete(workspaceID, id string) error
+}
+
+func (u *userUsecase) Register(user *domain.User, invitation string) error {
+	if err := u.config.Registration.check(invitation != ""); err != nil {
+		return err
+	}
+	if err := u.checkPassword(user.Username, user.Password); err != nil {
+		return err
+	}
+
+	// check if username already exists
+	exist, err := u.userRepo.CheckUsername(user.Username)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return errs.ErrUsernameExists
+	}
+
+	hashedPassword, err := u.passwordSvc.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hashedPassword
+
+	if invitation != "" {
+		return u.registerInvited(user, invitation)
+	}
+	return u.createWithOwnWorkspace(user, user.Username+"'s workspace")
+}
+
+// registerInvited creates a user with an invitation, which is used up
+// unless creating the user fails.
+func (u *userUsecase) registerInvited(user *domain.User, token string) error {
+	invitation, err := u.invitationRepo.Consume(hashToken(token), time.Now())
+	if err != nil {
+		return err
+	}
+	if invitation.WorkspaceID == "" {
+		// the invitation was emailed there, which proves the address
+		user.Profile.Email = invitation.Email
+		user.Profile.EmailVerified = true
+		err = u.createWithOwnWorkspace(user, user.Username+"'s workspace")
+	} else {
+		user.Memberships = append(user.Memberships, domain.Membership{
+			WorkspaceID: invitation.WorkspaceID,
+			Role:        invitation.Role,
+			JoinedAt:    time.Now(),
+		})
+		user.Activate(invitation.WorkspaceID)
+		err = u.userRepo.Create(user)
+	}
+	if err != nil {
+		if err := u.invitationRepo.Create(invitation); err != nil {
+			log.Printf("WARN: Failed to restore invitation %s: %v", invitation.ID, err)
+		}
+		return err
+	}
+	log.Printf("INFO: User '%s' registered with invitation %s", user.Username, invitation.ID)
+	return nil
+}
+
+// createWithOwnWorkspace creates user as the admin of a new workspace.
+func (u *userUsecase) createWithOwnWorkspace(user *domain.User, workspaceName string) error {
+	workspace, err := createOwnWorkspace(u.workspaceRepo, user, workspaceName)
+	if err != nil {
+		return err
+	}
+	if err := u.userRepo.Create(user); err != nil {
+		discardWorkspace(u.workspaceRepo, workspace)
+		return err
+	}
+	return nil
+}
+
+// RequestInvitation sends an invitation to an email address at one of the
+// allowed domains, which proves that the registrant owns it.
+func (u *userUsecase) RequestInvitation(email string) error {
+	policy := u.config.Registration
+	if policy.Mode != RegistrationDomainAllowlist {
+		if policy.Mode == RegistrationClosed {
+			return errs.ErrRegistrationClosed
+		}
+		return errs.ErrInvitationRequired
+	}
+	if !policy.allowsEmail(email) {
+		return errs.ErrEmailDomainNotAllowed
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	invitation := &domain.Invitation{
+		Email:     email,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(invitationTTL),
+	}
+	if err := u.invitationRepo.Create(invitation); err != nil {
+		return err
+	}
+
+	log.Printf("INFO: Invitation %s sent to %s", invitation.ID, email)
+	return u.notifier.Notify(domain.Notification{
+		Kind:       domain.NotificationInvitation,
+		Recipients: []string{email},
+		Subject:    "Your invitation to Task Manager",
+		Body: fmt.Sprintf("Use this invitation to register before %s: %s\n\nIf you did not ask for it, ignore this message.",
+			invitation.ExpiresAt.Format(time.RFC3339), token),
+		CreatedAt: now,
+	})
+}
+
+// Bootstrap creates the first admin of an app that has no users yet, in a
+// workspace named workspaceName. It ignores the registration mode.
+func (u *userUsecase) Bootstrap(user *domain.User, workspaceName string) error {
+	if err := u.checkPassword(user.Username, user.Password); err != nil {
+		return err
+	}
+	hashedPassword, err := u.passwordSvc.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hashedPassword
+
+	workspace, err := createOwnWorkspace(u.workspaceRepo, user, workspaceName)
+	if err != nil {
+		return err
+	}
+	if err := u.userRepo.CreateFirst(user); err != nil {
+		discardWorkspace(u.workspaceRepo, workspace)
+		return err
+	}
+	log.Printf("INFO: User '%s' was created as the first admin", user.Username)
+	return nil
+}
@@ -0,0 +1,155 @@
+package usecases_test
+
+import (
+	"regexp"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/usecases"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// withRegistration rebuilds the usecase with another registration policy.
+func (s *UserUsecaseTestSuite) withRegistration(policy usecases.RegistrationPolicy) {
+	config := testAuthConfig
+	config.Registration = policy
+	s.configure(config)
+}
+
+func (s *UserUsecaseTestSuite) TestRegister_RefusedByMode() {
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationInviteOnly})
+	err := s.userUsecase.Register(&domain.User{Username: "alice", Password: "password123"}, "")
+	s.Assert().ErrorIs(err, errs.ErrInvitationRequired)
+
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationClosed})
+	err = s.userUsecase.Register(&domain.User{Username: "alice", Password: "password123"}, "invite")
+	s.Assert().ErrorIs(err, errs.ErrRegistrationClosed, "closed refuses invitations too")
+
+	s.mockUserRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+	s.mockInviteRepo.AssertNotCalled(s.T(), "Consume", mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRegister_WithInvitationJoinsTheWorkspace() {
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationInviteOnly})
+	s.mockUserRepo.On("CheckUsername", "alice").Return(false, nil).Once()
+	s.mockInviteRepo.On("Consume", sha256Hex("invite"), mock.Anything).Return(&domain.Invitation{
+		ID: "i1", WorkspaceID: "ws1", Role: "editor",
+	}, nil).Once()
+	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+
+	user := &domain.User{Username: "alice", Password: "password123"}
+	err := s.userUsecase.Register(user, "invite")
+
+	s.Require().NoError(err)
+	s.Assert().Equal("ws1", user.WorkspaceID)
+	s.Assert().Equal("editor", user.Role)
+	s.Assert().Len(user.Memberships, 1)
+	s.mockWorkspaceRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRegister_RestoresInvitationOnFailure() {
+	invitation := &domain.Invitation{ID: "i1", WorkspaceID: "ws1", Role: domain.RoleUser}
+	s.mockUserRepo.On("CheckUsername", "alice").Return(false, nil).Once()
+	s.mockInviteRepo.On("Consume", sha256Hex("invite"), mock.Anything).Return(invitation, nil).Once()
+	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(errs.ErrUsernameExists).Once()
+	s.mockInviteRepo.On("Create", invitation).Return(nil).Once()
+
+	err := s.userUsecase.Register(&domain.User{Username: "alice", Password: "password123"}, "invite")
+
+	s.Require().ErrorIs(err, errs.ErrUsernameExists)
+	s.mockInviteRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestRegister_InvalidInvitation() {
+	s.mockUserRepo.On("CheckUsername", "alice").Return(false, nil).Once()
+	s.mockInviteRepo.On("Consume", sha256Hex("used"), mock.Anything).Return(nil, errs.ErrInvalidInvitation).Once()
+
+	err := s.userUsecase.Register(&domain.User{Username: "alice", Password: "password123"}, "used")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidInvitation)
+	s.mockUserRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRequestInvitation_AllowedDomain() {
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationDomainAllowlist, AllowedDomains: []string{"example.com"}})
+	var invitation *domain.Invitation
+	s.mockInviteRepo.On("Create", mock.AnythingOfType("*domain.Invitation")).Run(func(args mock.Arguments) {
+		invitation = args.Get(0).(*domain.Invitation)
+	}).Return(nil).Once()
+	var sent domain.Notification
+	s.mockNotifier.On("Notify", mock.AnythingOfType("domain.Notification")).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(domain.Notification)
+	}).Return(nil).Once()
+
+	s.Require().NoError(s.userUsecase.RequestInvitation("alice@Example.com"))
+
+	s.Assert().Empty(invitation.WorkspaceID)
+	s.Assert().Equal("alice@Example.com", invitation.Email)
+	s.Assert().True(invitation.ExpiresAt.After(invitation.CreatedAt))
+	s.Assert().Equal(domain.NotificationInvitation, sent.Kind)
+	s.Assert().Equal([]string{"alice@Example.com"}, sent.Recipients)
+
+	// the notification carries the token and the database only its hash
+	match := regexp.MustCompile(`: (\S+)\n`).FindStringSubmatch(sent.Body)
+	s.Require().Len(match, 2, sent.Body)
+	s.Assert().Equal(sha256Hex(match[1]), invitation.TokenHash)
+	s.mockNotifier.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestRequestInvitation_Refused() {
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationDomainAllowlist, AllowedDomains: []string{"example.com"}})
+	s.Assert().ErrorIs(s.userUsecase.RequestInvitation("alice@mail.example.com"), errs.ErrEmailDomainNotAllowed)
+
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationInviteOnly})
+	s.Assert().ErrorIs(s.userUsecase.RequestInvitation("alice@example.com"), errs.ErrInvitationRequired)
+
+	s.mockInviteRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestRegister_WithRequestedInvitationGetsOwnWorkspace() {
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationDomainAllowlist, AllowedDomains: []string{"example.com"}})
+	s.mockUserRepo.On("CheckUsername", "alice").Return(false, nil).Once()
+	s.mockInviteRepo.On("Consume", sha256Hex("invite"), mock.Anything).Return(&domain.Invitation{ID: "i1", Email: "alice@example.com"}, nil).Once()
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws2"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+
+	user := &domain.User{Username: "alice", Password: "password123"}
+	s.Require().NoError(s.userUsecase.Register(user, "invite"))
+
+	s.Assert().Equal("ws2", user.WorkspaceID)
+	s.Assert().Equal(domain.RoleAdmin, user.Role)
+	s.Assert().Equal("alice@example.com", user.Profile.Email)
+	s.Assert().True(user.Profile.EmailVerified, "the invitation was emailed there")
+}
+
+func (s *UserUsecaseTestSuite) TestBootstrap() {
+	s.withRegistration(usecases.RegistrationPolicy{Mode: usecases.RegistrationClosed})
+	s.mockWorkspaceRepo.On("Create", mock.MatchedBy(func(ws *domain.Workspace) bool {
+		return ws.Name == "Acme"
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws1"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("CreateFirst", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+
+	user := &domain.User{Username: "root", Password: "password123"}
+	s.Require().NoError(s.userUsecase.Bootstrap(user, "Acme"))
+
+	s.Assert().Equal(domain.RoleAdmin, user.Role)
+	s.Assert().NotEmpty(user.PasswordHash)
+	s.mockWorkspaceRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestBootstrap_AlreadyBootstrapped() {
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws1"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("CreateFirst", mock.AnythingOfType("*domain.User")).Return(errs.ErrAlreadyBootstrapped).Once()
+	s.mockWorkspaceRepo.On("Delete", "ws1").Return(nil).Once()
+
+	err := s.userUsecase.Bootstrap(&domain.User{Username: "root", Password: "password123"}, "Acme")
+
+	s.Assert().ErrorIs(err, errs.ErrAlreadyBootstrapped)
+	s.mockWorkspaceRepo.AssertExpectations(s.T())
+}
@@ -0,0 +1,215 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"task-manager/domain"
+	"time"
+)
+
+const reminderLeaseName = "reminder-scheduler"
+
+// ReminderScheduler periodically looks for tasks that are close to or past
+// their due date and emits notifications about them.
+type ReminderScheduler interface {
+	// Run blocks and ticks every configured interval until ctx is done.
+	Run(ctx context.Context)
+	// Tick runs a single pass if this replica holds the scheduler lease.
+	Tick(now time.Time) error
+}
+
+// Notifier delivers notifications to their recipients.
+type Notifier interface {
+	Notify(n domain.Notification) error
+}
+
+// LeaseRepository hands out named, time-limited leases so that only one
+// replica runs a given background job at a time.
+type LeaseRepository interface {
+	Acquire(name, holder string, ttl time.Duration) (bool, error)
+	Release(name, holder string) error
+}
+
+type ReminderConfig struct {
+	// Offsets before the due date at which a reminder is sent, e.g. 24h and 1h.
+	Offsets []time.Duration
+	// EscalateAfter is how long a task may stay overdue before admins are
+	// notified. Zero disables escalation.
+	EscalateAfter time.Duration
+	Interval      time.Duration
+	LeaseTTL      time.Duration
+}
+
+type reminderScheduler struct {
+	taskRepo  TaskRepository
+	userRepo  UserRepository
+	leaseRepo LeaseRepository
+	notifier  Notifier
+	config    ReminderConfig
+	holder    string
+}
+
+func NewReminderScheduler(tr TaskRepository, ur UserRepository, lr LeaseRepository, n Notifier, config ReminderConfig, holder string) ReminderScheduler {
+	offsets := append([]time.Duration(nil), config.Offsets...)
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	config.Offsets = offsets
+
+	return &reminderScheduler{
+		taskRepo:  tr,
+		userRepo:  ur,
+		leaseRepo: lr,
+		notifier:  n,
+		config:    config,
+		holder:    holder,
+	}
+}
+
+func (rs *reminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(rs.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := rs.Tick(time.Now()); err != nil {
+			log.Printf("ERROR: reminder scheduler tick failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := rs.leaseRepo.Release(reminderLeaseName, rs.holder); err != nil {
+				log.Printf("WARN: failed to release reminder scheduler lease: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rs *reminderScheduler) Tick(now time.Time) error {
+	leader, err := rs.leaseRepo.Acquire(reminderLeaseName, rs.holder, rs.config.LeaseTTL)
+	if err != nil {
+		return err
+	}
+	if !leader {
+		return nil
+	}
+
+	horizon := now
+	if len(rs.config.Offsets) > 0 {
+		horizon = now.Add(rs.config.Offsets[0])
+	}
+
+	tasks, err := rs.taskRepo.GetDueBefore(horizon)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if err := rs.process(task, now); err != nil {
+			log.Printf("ERROR: reminder scheduler failed on task %s: %v", task.ID, err)
+		}
+	}
+	return nil
+}
+
+func (rs *reminderScheduler) process(task *domain.Task, now time.Time) error {
+	if now.Before(task.DueDate) {
+		return rs.remind(task, now)
+	}
+
+	// tasks are only marked once their notification is out, so that one
+	// that failed is sent again on the next tick
+	if !task.Overdue {
+		err := rs.notifier.Notify(domain.Notification{
+			Kind:      domain.NotificationOverdue,
+			TaskID:    task.ID,
+			Subject:   fmt.Sprintf("Task %q is overdue", task.Title),
+			Body:      fmt.Sprintf("Task %q was due at %s.", task.Title, task.DueDate.Format(time.RFC3339)),
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := rs.taskRepo.MarkOverdue(task.ID); err != nil {
+			return err
+		}
+	}
+
+	if rs.config.EscalateAfter > 0 && task.EscalatedAt.IsZero() && !now.Before(task.DueDate.Add(rs.config.EscalateAfter)) {
+		return rs.escalate(task, now)
+	}
+	return nil
+}
+
+// remind sends at most one reminder per tick. When several offsets are
+// crossed at once (e.g. a task created an hour before its due date) only the
+// closest one is announced and the rest are marked as sent.
+func (rs *reminderScheduler) remind(task *domain.Task, now time.Time) error {
+	var due []time.Duration
+	for _, offset := range rs.config.Offsets {
+		if now.Before(task.DueDate.Add(-offset)) || reminderSent(task, offset) {
+			continue
+		}
+		due = append(due, offset)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	closest := due[len(due)-1]
+	err := rs.notifier.Notify(domain.Notification{
+		Kind:      domain.NotificationDueReminder,
+		TaskID:    task.ID,
+		Subject:   fmt.Sprintf("Task %q is due in %s", task.Title, closest),
+		Body:      fmt.Sprintf("Task %q is due at %s.", task.Title, task.DueDate.Format(time.RFC3339)),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, offset := range due {
+		if err := rs.taskRepo.MarkReminderSent(task.ID, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escalate notifies the admins of the task's workspace.
+func (rs *reminderScheduler) escalate(task *domain.Task, now time.Time) error {
+	admins, err := rs.userRepo.GetByRole(task.WorkspaceID, domain.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	recipients := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		if !admin.Disabled {
+			recipients = append(recipients, admin.ID)
+		}
+	}
+
+	err = rs.notifier.Notify(domain.Notification{
+		Kind:       domain.NotificationEscalation,
+		Recipients: recipients,
+		TaskID:     task.ID,
+		Subject:    fmt.Sprintf("Task %q has been overdue for %s", task.Title, rs.config.EscalateAfter),
+		Body:       fmt.Sprintf("Task %q was due at %s and is still %s.", task.Title, task.DueDate.Format(time.RFC3339), task.Status),
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+	return rs.taskRepo.MarkEscalated(task.ID, now)
+}
+
+func reminderSent(task *domain.Task, offset time.Duration) bool {
+	for _, sent := range task.RemindersSent {
+		if sent == offset {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/repositories/mocks"
+	"task-manager/usecases"
+	usecaseMocks "task-manager/usecases/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReminderSchedulerTestSuite struct {
+	suite.Suite
+	mockTaskRepo  *mocks.TaskRepository
+	mockUserRepo  *mocks.UserRepository
+	mockLeaseRepo *mocks.LeaseRepository
+	mockNotifier  *usecaseMocks.Notifier
+	scheduler     usecases.ReminderScheduler
+	now           time.Time
+}
+
+func (s *ReminderSchedulerTestSuite) SetupTest() {
+	s.mockTaskRepo = new(mocks.TaskRepository)
+	s.mockUserRepo = new(mocks.UserRepository)
+	s.mockLeaseRepo = new(mocks.LeaseRepository)
+	s.mockNotifier = new(usecaseMocks.Notifier)
+	s.scheduler = usecases.NewReminderScheduler(
+		s.mockTaskRepo,
+		s.mockUserRepo,
+		s.mockLeaseRepo,
+		s.mockNotifier,
+		usecases.ReminderConfig{
+			Offsets:       []time.Duration{time.Hour, 24 * time.Hour},
+			EscalateAfter: 48 * time.Hour,
+			Interval:      time.Minute,
+			LeaseTTL:      3 * time.Minute,
+		},
+		"replica-1",
+	)
+	s.now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func TestReminderScheduler(t *testing.T) {
+	suite.Run(t, new(ReminderSchedulerTestSuite))
+}
+
+func (s *ReminderSchedulerTestSuite) acquireLease(leader bool) {
+	s.mockLeaseRepo.On("Acquire", "reminder-scheduler", "replica-1", 3*time.Minute).Return(leader, nil).Once()
+}
+
+func (s *ReminderSchedulerTestSuite) assertAll() {
+	s.mockTaskRepo.AssertExpectations(s.T())
+	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockLeaseRepo.AssertExpectations(s.T())
+	s.mockNotifier.AssertExpectations(s.T())
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_NotLeader_DoesNothing() {
+	s.acquireLease(false)
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "GetDueBefore", mock.Anything)
+	s.assertAll()
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_SendsClosestReminderOnce() {
+	task := &domain.Task{ID: "t1", Title: "Ship it", DueDate: s.now.Add(30 * time.Minute), Status: domain.StatusPending}
+	s.acquireLease(true)
+	s.mockTaskRepo.On("GetDueBefore", s.now.Add(24*time.Hour)).Return([]*domain.Task{task}, nil).Once()
+	s.mockNotifier.On("Notify", mock.MatchedBy(func(n domain.Notification) bool {
+		return n.Kind == domain.NotificationDueReminder && n.TaskID == "t1"
+	})).Return(nil).Once()
+	s.mockTaskRepo.On("MarkReminderSent", "t1", 24*time.Hour).Return(nil).Once()
+	s.mockTaskRepo.On("MarkReminderSent", "t1", time.Hour).Return(nil).Once()
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.assertAll()
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_SkipsRemindersAlreadySent() {
+	task := &domain.Task{
+		ID:            "t1",
+		DueDate:       s.now.Add(2 * time.Hour),
+		RemindersSent: []time.Duration{24 * time.Hour},
+	}
+	s.acquireLease(true)
+	s.mockTaskRepo.On("GetDueBefore", s.now.Add(24*time.Hour)).Return([]*domain.Task{task}, nil).Once()
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.mockNotifier.AssertNotCalled(s.T(), "Notify", mock.Anything)
+	s.assertAll()
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_FlagsOverdueTask() {
+	task := &domain.Task{ID: "t1", DueDate: s.now.Add(-time.Hour), Status: domain.StatusInProgress}
+	s.acquireLease(true)
+	s.mockTaskRepo.On("GetDueBefore", s.now.Add(24*time.Hour)).Return([]*domain.Task{task}, nil).Once()
+	s.mockTaskRepo.On("MarkOverdue", "t1").Return(nil).Once()
+	s.mockNotifier.On("Notify", mock.MatchedBy(func(n domain.Notification) bool {
+		return n.Kind == domain.NotificationOverdue
+	})).Return(nil).Once()
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "MarkEscalated", mock.Anything, mock.Anything)
+	s.assertAll()
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_FailedOverdueNotificationIsRetried() {
+	task := &domain.Task{ID: "t1", DueDate: s.now.Add(-time.Hour), Status: domain.StatusInProgress}
+	s.acquireLease(true)
+	s.mockTaskRepo.On("GetDueBefore", s.now.Add(24*time.Hour)).Return([]*domain.Task{task}, nil).Once()
+	s.mockNotifier.On("Notify", mock.Anything).Return(errs.ErrUnexpected).Once()
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "MarkOverdue", mock.Anything)
+	s.assertAll()
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_FailedEscalationIsRetried() {
+	task := &domain.Task{ID: "t1", WorkspaceID: "ws1", DueDate: s.now.Add(-72 * time.Hour), Overdue: true}
+	s.acquireLease(true)
+	s.mockTaskRepo.On("GetDueBefore", s.now.Add(24*time.Hour)).Return([]*domain.Task{task}, nil).Once()
+	s.mockUserRepo.On("GetByRole", "ws1", domain.RoleAdmin).Return([]*domain.User{{ID: "a1", Role: domain.RoleAdmin}}, nil).Once()
+	s.mockNotifier.On("Notify", mock.Anything).Return(errs.ErrUnexpected).Once()
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "MarkEscalated", mock.Anything, mock.Anything)
+	s.assertAll()
+}
+
+func (s *ReminderSchedulerTestSuite) TestTick_EscalatesToAdminsOfTheWorkspace() {
+	task := &domain.Task{ID: "t1", WorkspaceID: "ws1", DueDate: s.now.Add(-72 * time.Hour), Overdue: true}
+	admins := []*domain.User{
+		{ID: "a1", Role: domain.RoleAdmin},
+		{ID: "a2", Role: domain.RoleAdmin},
+		{ID: "a3", Role: domain.RoleAdmin, Disabled: true},
+	}
+	s.acquireLease(true)
+	s.mockTaskRepo.On("GetDueBefore", s.now.Add(24*time.Hour)).Return([]*domain.Task{task}, nil).Once()
+	s.mockUserRepo.On("GetByRole", "ws1", domain.RoleAdmin).Return(admins, nil).Once()
+	s.mockNotifier.On("Notify", mock.MatchedBy(func(n domain.Notification) bool {
+		return n.Kind == domain.NotificationEscalation && len(n.Recipients) == 2
+	})).Return(nil).Once()
+	s.mockTaskRepo.On("MarkEscalated", "t1", s.now).Return(nil).Once()
+
+	err := s.scheduler.Tick(s.now)
+
+	s.Require().NoError(err)
+	s.assertAll()
+}
@@ -0,0 +1,294 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// RoleRepository keeps the roles of each workspace, by name. Built-in
+// roles are only stored once they are changed.
+type RoleRepository interface {
+	List(workspaceID string) ([]*domain.Role, error)
+	// GetByName returns ErrRoleNotFound if the workspace has no role
+	// called name.
+	GetByName(workspaceID, name string) (*domain.Role, error)
+	// Create returns ErrRoleExists if the name is taken.
+	Create(role *domain.Role) error
+	// Save stores role, replacing the workspace's role of the same name.
+	Save(role *domain.Role) error
+	Delete(workspaceID, name string) error
+}
+
+// RoleUsecase manages the roles of the actor's workspace and tells what
+// users may do there. Nobody can grant or take away permissions they do not
+// have themselves, so that managing roles does not lead to more access.
+type RoleUsecase interface {
+	// Permissions returns the sorted permissions of the user's role in
+	// their active workspace.
+	Permissions(user *domain.User) ([]string, error)
+	// ListRoles returns the roles of a workspace, sorted by name.
+	ListRoles(workspaceID string) ([]*domain.Role, error)
+	CreateRole(actor *domain.User, role *domain.Role) error
+	// UpdateRole replaces the description and permissions of a role.
+	UpdateRole(actor *domain.User, role *domain.Role) error
+	// DeleteRole refuses to delete built-in roles and roles users have.
+	DeleteRole(actor *domain.User, name string) error
+	AssignRole(actor *domain.User, userID, role string) error
+}
+
+type roleUsecase struct {
+	roleRepo  RoleRepository
+	userRepo  UserRepository
+	leaseRepo LeaseRepository
+}
+
+// adminLeaseTTL bounds how long a crashed request can block changes to the
+// admins of its workspace.
+const adminLeaseTTL = 30 * time.Second
+
+func NewRoleUsecase(rr RoleRepository, ur UserRepository, lr LeaseRepository) RoleUsecase {
+	return &roleUsecase{roleRepo: rr, userRepo: ur, leaseRepo: lr}
+}
+
+// Users whose role was deleted behind our back have no permissions.
+func (r *roleUsecase) Permissions(user *domain.User) ([]string, error) {
+	role, err := r.role(user.WorkspaceID, user.Role)
+	if err != nil {
+		if errors.Is(err, errs.ErrRoleNotFound) {
+			log.Printf("WARN: User '%s' has the unknown role '%s'", user.Username, user.Role)
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return role.Permissions, nil
+}
+
+// The built-in roles are listed whether or not they were ever changed.
+func (r *roleUsecase) ListRoles(workspaceID string) ([]*domain.Role, error) {
+	stored, err := r.roleRepo.List(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*domain.Role, 0, len(stored)+2)
+	for _, role := range domain.BuiltInRoles() {
+		changed := slices.ContainsFunc(stored, func(s *domain.Role) bool { return s.Name == role.Name })
+		if !changed || role.Name == domain.RoleAdmin {
+			role.WorkspaceID = workspaceID
+			roles = append(roles, role)
+		}
+	}
+	for _, role := range stored {
+		if role.Name != domain.RoleAdmin {
+			roles = append(roles, role)
+		}
+	}
+	slices.SortFunc(roles, func(a, b *domain.Role) int { return strings.Compare(a.Name, b.Name) })
+	return roles, nil
+}
+
+func (r *roleUsecase) CreateRole(actor *domain.User, role *domain.Role) error {
+	role.WorkspaceID = actor.WorkspaceID
+	if builtInRole(role.WorkspaceID, role.Name) != nil {
+		return errs.ErrRoleExists
+	}
+	role.Permissions = normalizePermissions(role.Permissions)
+	if err := r.checkGrantable(actor, role.Permissions); err != nil {
+		return err
+	}
+	role.BuiltIn = false
+	role.UpdatedAt = time.Now()
+	if err := r.roleRepo.Create(role); err != nil {
+		return err
+	}
+	log.Printf("INFO: User '%s' created role '%s' with %v", actor.Username, role.Name, role.Permissions)
+	return nil
+}
+
+func (r *roleUsecase) UpdateRole(actor *domain.User, role *domain.Role) error {
+	role.WorkspaceID = actor.WorkspaceID
+	existing, err := r.role(role.WorkspaceID, role.Name)
+	if err != nil {
+		return err
+	}
+	// the admin role always has every permission
+	if existing.Name == domain.RoleAdmin {
+		return fmt.Errorf("%w: the admin role has every permission", errs.ErrBuiltInRole)
+	}
+
+	role.Permissions = normalizePermissions(role.Permissions)
+	changed := make([]string, 0)
+	for _, p := range domain.Permissions {
+		if existing.Has(p) != role.Has(p) {
+			changed = append(changed, p)
+		}
+	}
+	if err := r.checkGrantable(actor, changed); err != nil {
+		return err
+	}
+	role.BuiltIn = existing.BuiltIn
+	role.UpdatedAt = time.Now()
+	if err := r.roleRepo.Save(role); err != nil {
+		return err
+	}
+	log.Printf("INFO: User '%s' changed role '%s' to %v", actor.Username, role.Name, role.Permissions)
+	return nil
+}
+
+func (r *roleUsecase) DeleteRole(actor *domain.User, name string) error {
+	role, err := r.role(actor.WorkspaceID, name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return errs.ErrBuiltInRole
+	}
+	users, err := r.userRepo.GetByRole(actor.WorkspaceID, name)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("%w: %d users have it", errs.ErrRoleInUse, len(users))
+	}
+	if err := r.roleRepo.Delete(actor.WorkspaceID, name); err != nil {
+		return err
+	}
+	log.Printf("INFO: User '%s' deleted role '%s'", actor.Username, name)
+	return nil
+}
+
+// AssignRole needs the permissions of both the user's current role and the
+// new one, so that nobody can demote those with more access than them. The
+// last admin keeps the admin role.
+func (r *roleUsecase) AssignRole(actor *domain.User, userID, roleName string) error {
+	role, err := r.role(actor.WorkspaceID, roleName)
+	if err != nil {
+		return err
+	}
+	user, err := member(r.userRepo, actor.WorkspaceID, userID)
+	if err != nil {
+		return err
+	}
+	current, err := r.Permissions(user)
+	if err != nil {
+		return err
+	}
+	if err := r.checkGrantable(actor, append(slices.Clone(current), role.Permissions...)); err != nil {
+		return err
+	}
+	assign := func() error {
+		if err := r.userRepo.SetRole(actor.WorkspaceID, userID, role.Name); err != nil {
+			return err
+		}
+		log.Printf("INFO: User '%s' gave user '%s' the role '%s'", actor.Username, user.Username, role.Name)
+		return nil
+	}
+	if role.Name == domain.RoleAdmin {
+		return assign()
+	}
+	return r.keepAdmin(user, assign)
+}
+
+// keepAdmin runs change, which demotes, disables or removes user, unless
+// user is the only enabled admin of their active workspace, as that would
+// leave nobody to manage it; then it returns ErrLastAdmin. Changes to an
+// admin hold the workspace's admin lease from the check until they are
+// made, so that two admins removing each other cannot both see the other
+// one still there.
+func (r *roleUsecase) keepAdmin(user *domain.User, change func() error) error {
+	if user.Role != domain.RoleAdmin || user.Disabled {
+		return change()
+	}
+
+	lease := "admins:" + user.WorkspaceID
+	holder, err := newToken()
+	if err != nil {
+		return err
+	}
+	acquired, err := r.leaseRepo.Acquire(lease, holder, adminLeaseTTL)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return errs.ErrAdminChangeInProgress
+	}
+	defer func() {
+		if err := r.leaseRepo.Release(lease, holder); err != nil {
+			log.Printf("WARN: failed to release lease %s: %v", lease, err)
+		}
+	}()
+
+	admins, err := r.userRepo.GetByRole(user.WorkspaceID, domain.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(admins, func(admin *domain.User) bool { return admin.ID != user.ID && !admin.Disabled }) {
+		return fmt.Errorf("%w: '%s' is the only enabled admin", errs.ErrLastAdmin, user.Username)
+	}
+	return change()
+}
+
+// role returns a role of a workspace. The built-in roles are in every
+// workspace without being stored, and the admin role is never changed.
+func (r *roleUsecase) role(workspaceID, name string) (*domain.Role, error) {
+	builtIn := builtInRole(workspaceID, name)
+	if builtIn != nil && name == domain.RoleAdmin {
+		return builtIn, nil
+	}
+	role, err := r.roleRepo.GetByName(workspaceID, name)
+	if errors.Is(err, errs.ErrRoleNotFound) && builtIn != nil {
+		return builtIn, nil
+	}
+	return role, err
+}
+
+func builtInRole(workspaceID, name string) *domain.Role {
+	for _, role := range domain.BuiltInRoles() {
+		if role.Name == name {
+			role.WorkspaceID = workspaceID
+			return role
+		}
+	}
+	return nil
+}
+
+// member returns a member of a workspace, activated there. Users who are
+// not members are not found.
+func member(ur UserRepository, workspaceID, id string) (*domain.User, error) {
+	user, err := ur.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Activate(workspaceID) {
+		return nil, errs.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// checkGrantable returns ErrForbidden unless actor has every one of
+// permissions.
+func (r *roleUsecase) checkGrantable(actor *domain.User, permissions []string) error {
+	granted, err := r.Permissions(actor)
+	if err != nil {
+		return err
+	}
+	missing := make([]string, 0)
+	for _, p := range permissions {
+		if !slices.Contains(granted, p) && !slices.Contains(missing, p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: you do not have %s", errs.ErrForbidden, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func normalizePermissions(permissions []string) []string {
+	return slices.Compact(slices.Sorted(slices.Values(permissions)))
+}
@@ -0,0 +1,201 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/repositories/mocks"
+	"task-manager/usecases"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type RoleUsecaseTestSuite struct {
+	suite.Suite
+	mockRoleRepo  *mocks.RoleRepository
+	mockUserRepo  *mocks.UserRepository
+	mockLeaseRepo *mocks.LeaseRepository
+	roleUsecase   usecases.RoleUsecase
+	admin         *domain.User
+	manager       *domain.User
+}
+
+func (s *RoleUsecaseTestSuite) SetupTest() {
+	s.mockRoleRepo = new(mocks.RoleRepository)
+	s.mockUserRepo = new(mocks.UserRepository)
+	s.mockLeaseRepo = new(mocks.LeaseRepository)
+	s.roleUsecase = usecases.NewRoleUsecase(s.mockRoleRepo, s.mockUserRepo, s.mockLeaseRepo)
+
+	// managers run the task list but cannot change settings
+	s.admin = &domain.User{ID: "a1", Username: "admin", WorkspaceID: "ws1", Role: domain.RoleAdmin, Memberships: memberOf("ws1", domain.RoleAdmin)}
+	s.manager = &domain.User{ID: "m1", Username: "manager", WorkspaceID: "ws1", Role: "manager", Memberships: memberOf("ws1", "manager")}
+	// the user role was never changed, so only its built-in definition exists
+	s.mockRoleRepo.On("GetByName", "ws1", domain.RoleUser).Return(nil, errs.ErrRoleNotFound).Maybe()
+	s.mockRoleRepo.On("GetByName", "ws1", "manager").Return(&domain.Role{
+		WorkspaceID: "ws1",
+		Name:        "manager",
+		Permissions: []string{domain.PermRoleManage, domain.PermTaskCreate, domain.PermTaskRead, domain.PermUserPromote},
+	}, nil).Maybe()
+}
+
+func TestRoleUsecase(t *testing.T) {
+	suite.Run(t, new(RoleUsecaseTestSuite))
+}
+
+func (s *RoleUsecaseTestSuite) TestPermissions_UnknownRole() {
+	s.mockRoleRepo.On("GetByName", "ws1", "gone").Return(nil, errs.ErrRoleNotFound).Once()
+
+	permissions, err := s.roleUsecase.Permissions(&domain.User{WorkspaceID: "ws1", Role: "gone"})
+
+	s.Require().NoError(err)
+	s.Assert().Empty(permissions)
+}
+
+func (s *RoleUsecaseTestSuite) TestPermissions_RolesBelongToWorkspace() {
+	s.mockRoleRepo.On("GetByName", "ws2", "manager").Return(nil, errs.ErrRoleNotFound).Once()
+
+	permissions, err := s.roleUsecase.Permissions(&domain.User{WorkspaceID: "ws2", Role: "manager"})
+
+	s.Require().NoError(err)
+	s.Assert().Empty(permissions, "The manager role of another workspace should not apply")
+}
+
+func (s *RoleUsecaseTestSuite) TestListRoles() {
+	s.mockRoleRepo.On("List", "ws1").Return([]*domain.Role{
+		{WorkspaceID: "ws1", Name: domain.RoleUser, Permissions: []string{domain.PermTaskRead}, BuiltIn: true},
+		{WorkspaceID: "ws1", Name: "writer", Permissions: []string{domain.PermTaskCreate}},
+	}, nil).Once()
+
+	roles, err := s.roleUsecase.ListRoles("ws1")
+
+	s.Require().NoError(err)
+	s.Require().Len(roles, 3)
+	s.Assert().Equal(domain.RoleAdmin, roles[0].Name)
+	s.Assert().Equal("ws1", roles[0].WorkspaceID)
+	s.Assert().Equal([]string{domain.PermTaskRead}, roles[1].Permissions, "A changed built-in role should be listed as stored")
+	s.Assert().Equal("writer", roles[2].Name)
+}
+
+func (s *RoleUsecaseTestSuite) TestCreateRole() {
+	s.mockRoleRepo.On("Create", mock.MatchedBy(func(r *domain.Role) bool {
+		return r.WorkspaceID == "ws1" && r.Name == "writer" && !r.BuiltIn && !r.UpdatedAt.IsZero()
+	})).Return(nil).Once()
+
+	role := &domain.Role{Name: "writer", Permissions: []string{domain.PermTaskRead, domain.PermTaskCreate, domain.PermTaskRead}, BuiltIn: true}
+	err := s.roleUsecase.CreateRole(s.manager, role)
+
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{domain.PermTaskCreate, domain.PermTaskRead}, role.Permissions)
+	s.mockRoleRepo.AssertExpectations(s.T())
+}
+
+func (s *RoleUsecaseTestSuite) TestCreateRole_BuiltInName() {
+	err := s.roleUsecase.CreateRole(s.manager, &domain.Role{Name: domain.RoleUser, Permissions: []string{domain.PermTaskRead}})
+
+	s.Require().ErrorIs(err, errs.ErrRoleExists)
+	s.mockRoleRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestCreateRole_CannotGrantMissingPermissions() {
+	role := &domain.Role{Name: "ops", Permissions: []string{domain.PermTaskRead, domain.PermSettingsManage}}
+
+	err := s.roleUsecase.CreateRole(s.manager, role)
+
+	s.Require().ErrorIs(err, errs.ErrForbidden)
+	s.Assert().ErrorContains(err, domain.PermSettingsManage)
+	s.mockRoleRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestUpdateRole_AdminRoleIsFixed() {
+	err := s.roleUsecase.UpdateRole(s.admin, &domain.Role{Name: domain.RoleAdmin, Permissions: []string{domain.PermTaskRead}})
+
+	s.Require().ErrorIs(err, errs.ErrBuiltInRole)
+	s.mockRoleRepo.AssertNotCalled(s.T(), "Save", mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestUpdateRole_BuiltInUserRole() {
+	s.mockRoleRepo.On("Save", mock.MatchedBy(func(r *domain.Role) bool {
+		return r.WorkspaceID == "ws1" && r.Name == domain.RoleUser && r.BuiltIn && r.Has(domain.PermTaskCreate)
+	})).Return(nil).Once()
+
+	err := s.roleUsecase.UpdateRole(s.manager, &domain.Role{Name: domain.RoleUser, Permissions: []string{domain.PermTaskRead, domain.PermTaskCreate}})
+
+	s.Require().NoError(err)
+	s.mockRoleRepo.AssertExpectations(s.T())
+}
+
+func (s *RoleUsecaseTestSuite) TestUpdateRole_CannotRemoveMissingPermissions() {
+	s.mockRoleRepo.On("GetByName", "ws1", "auditor").Return(&domain.Role{
+		Name:        "auditor",
+		Permissions: []string{domain.PermSettingsManage, domain.PermTaskRead},
+	}, nil).Once()
+
+	err := s.roleUsecase.UpdateRole(s.manager, &domain.Role{Name: "auditor", Permissions: []string{domain.PermTaskRead}})
+
+	s.Require().ErrorIs(err, errs.ErrForbidden)
+	s.mockRoleRepo.AssertNotCalled(s.T(), "Save", mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestDeleteRole() {
+	s.mockRoleRepo.On("GetByName", "ws1", "writer").Return(&domain.Role{WorkspaceID: "ws1", Name: "writer"}, nil)
+	s.mockUserRepo.On("GetByRole", "ws1", "writer").Return([]*domain.User{}, nil).Once()
+	s.mockRoleRepo.On("Delete", "ws1", "writer").Return(nil).Once()
+
+	s.Require().NoError(s.roleUsecase.DeleteRole(s.manager, "writer"))
+	s.mockRoleRepo.AssertExpectations(s.T())
+}
+
+func (s *RoleUsecaseTestSuite) TestDeleteRole_Refused() {
+	s.Assert().ErrorIs(s.roleUsecase.DeleteRole(s.manager, domain.RoleUser), errs.ErrBuiltInRole)
+
+	s.mockUserRepo.On("GetByRole", "ws1", "manager").Return([]*domain.User{s.manager}, nil).Once()
+	s.Assert().ErrorIs(s.roleUsecase.DeleteRole(s.manager, "manager"), errs.ErrRoleInUse)
+
+	s.mockRoleRepo.AssertNotCalled(s.T(), "Delete", mock.Anything, mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestAssignRole() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	s.mockUserRepo.On("SetRole", "ws1", "u1", "manager").Return(nil).Once()
+
+	s.Require().NoError(s.roleUsecase.AssignRole(s.manager, "u1", "manager"))
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *RoleUsecaseTestSuite) TestAssignRole_NotAMember() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Memberships: memberOf("ws2", domain.RoleUser)}, nil).Once()
+
+	err := s.roleUsecase.AssignRole(s.manager, "u1", "manager")
+
+	s.Assert().ErrorIs(err, errs.ErrUserNotFound)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestAssignRole_CannotRaiseOrLowerBeyondOwnPermissions() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	err := s.roleUsecase.AssignRole(s.manager, "u1", domain.RoleAdmin)
+	s.Assert().ErrorIs(err, errs.ErrForbidden)
+
+	s.mockUserRepo.On("GetByID", "a1").Return(s.admin, nil).Once()
+	err = s.roleUsecase.AssignRole(s.manager, "a1", domain.RoleUser)
+	s.Assert().ErrorIs(err, errs.ErrForbidden)
+
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *RoleUsecaseTestSuite) TestAssignRole_LastAdminKeepsRole() {
+	s.mockUserRepo.On("GetByID", "a1").Return(s.admin, nil).Once()
+	s.mockLeaseRepo.On("Acquire", "admins:ws1", mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(true, nil).Once()
+	s.mockLeaseRepo.On("Release", "admins:ws1", mock.AnythingOfType("string")).Return(nil).Once()
+	s.mockUserRepo.On("GetByRole", "ws1", domain.RoleAdmin).Return([]*domain.User{
+		s.admin,
+		{ID: "a2", WorkspaceID: "ws1", Role: domain.RoleAdmin, Disabled: true},
+	}, nil).Once()
+
+	err := s.roleUsecase.AssignRole(s.admin, "a1", domain.RoleUser)
+
+	s.Assert().ErrorIs(err, errs.ErrLastAdmin)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+	s.mockLeaseRepo.AssertExpectations(s.T())
+}
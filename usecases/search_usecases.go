@@ -0,0 +1,256 @@
+package usecases
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+
+	titleBoost       = 3.0
+	descriptionBoost = 1.0
+	phraseBonus      = 2.0
+
+	snippetRadius = 60
+)
+
+func (ts *taskUsecase) SearchTasks(user *domain.User, q string, limit int) ([]*domain.SearchResult, error) {
+	query := parseSearchQuery(q)
+	if query.IsEmpty() {
+		return nil, errs.ErrEmptySearchQuery
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	// the store only does a coarse match, so fetch extra candidates and
+	// let the ranking below decide which ones make the cut
+	query.Limit = limit * 5
+
+	tasks, err := ts.tasks(user).Search(query)
+	if err != nil {
+		return nil, err
+	}
+	tasks = ts.visible(user, tasks)
+
+	results := make([]*domain.SearchResult, 0, len(tasks))
+	for _, task := range tasks {
+		results = append(results, &domain.SearchResult{
+			Task:  task,
+			Score: scoreField(task.Title, query)*titleBoost + scoreField(task.Description, query)*descriptionBoost,
+			Highlights: map[string]string{
+				"title":       highlight(task.Title, query),
+				"description": highlight(task.Description, query),
+			},
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// parseSearchQuery splits q into terms, "quoted phrases" and prefix* terms.
+func parseSearchQuery(q string) domain.SearchQuery {
+	var query domain.SearchQuery
+
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			var phrase string
+			if end < 0 {
+				phrase, q = q[1:], ""
+			} else {
+				phrase, q = q[1:end+1], q[end+2:]
+			}
+			if phrase = strings.Join(tokenize(phrase), " "); phrase != "" {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(q, unicode.IsSpace)
+		if end < 0 {
+			end = len(q)
+		}
+		word := q[:end]
+		q = q[end:]
+
+		prefix := strings.HasSuffix(word, "*")
+		for _, token := range tokenize(word) {
+			if prefix {
+				query.Prefixes = append(query.Prefixes, token)
+			} else {
+				query.Terms = append(query.Terms, token)
+			}
+		}
+	}
+	return query
+}
+
+// tokenize lower-cases s and splits it into words.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// scoreField counts how often the query matches text. Terms also match
+// words that start with them so that stemmed matches from the store
+// ("run" finding "running") are not ranked at zero.
+func scoreField(text string, query domain.SearchQuery) float64 {
+	words := tokenize(text)
+	score := 0.0
+	for _, word := range words {
+		for _, term := range query.Terms {
+			if word == term {
+				score++
+			} else if strings.HasPrefix(word, term) {
+				score += 0.5
+			}
+		}
+		for _, prefix := range query.Prefixes {
+			if strings.HasPrefix(word, prefix) {
+				score++
+			}
+		}
+	}
+
+	joined := " " + strings.Join(words, " ") + " "
+	for _, phrase := range query.Phrases {
+		score += float64(strings.Count(joined, " "+phrase+" ")) * phraseBonus
+	}
+	return score
+}
+
+type span struct{ start, end int }
+
+// highlight returns an HTML-escaped snippet of text around the first match,
+// with every match wrapped in <mark>. Text without matches is returned as a
+// plain (escaped, shortened) snippet.
+func highlight(text string, query domain.SearchQuery) string {
+	spans := matchSpans(text, query)
+
+	start, end := 0, len(text)
+	if len(spans) > 0 {
+		start = max(0, spans[0].start-snippetRadius)
+		end = min(len(text), spans[0].end+snippetRadius)
+	} else {
+		end = min(len(text), 2*snippetRadius)
+	}
+	start, end = runeBoundary(text, start), runeBoundary(text, end)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, sp := range spans {
+		if sp.start < pos || sp.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:sp.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[sp.start:sp.end]))
+		b.WriteString("</mark>")
+		pos = sp.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// matchSpans finds the byte ranges of words (and phrases) in text that match
+// the query, sorted and non-overlapping.
+func matchSpans(text string, query domain.SearchQuery) []span {
+	type word struct {
+		span
+		lower string
+	}
+
+	var words []word
+	inWord := false
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWordRune && !inWord {
+			words = append(words, word{span: span{start: i}})
+		}
+		if !isWordRune && inWord {
+			words[len(words)-1].end = i
+		}
+		inWord = isWordRune
+	}
+	if inWord {
+		words[len(words)-1].end = len(text)
+	}
+	for i := range words {
+		words[i].lower = strings.ToLower(text[words[i].start:words[i].end])
+	}
+
+	prefixes := make([]string, 0, len(query.Terms)+len(query.Prefixes))
+	prefixes = append(prefixes, query.Terms...)
+	prefixes = append(prefixes, query.Prefixes...)
+
+	var spans []span
+	for i := 0; i < len(words); i++ {
+		matched := false
+		for _, phrase := range query.Phrases {
+			parts := strings.Split(phrase, " ")
+			if i+len(parts) > len(words) {
+				continue
+			}
+			ok := true
+			for j, part := range parts {
+				if words[i+j].lower != part {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				spans = append(spans, span{words[i].start, words[i+len(parts)-1].end})
+				i += len(parts) - 1
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(words[i].lower, prefix) {
+				spans = append(spans, words[i].span)
+				break
+			}
+		}
+	}
+	return spans
+}
+
+func runeBoundary(s string, i int) int {
+	for i > 0 && i < len(s) && !isRuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
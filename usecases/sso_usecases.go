@@ -0,0 +1,270 @@
+package usecases
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"task-manager/domain"
+	"task-manager/errs"
+)
+
+// IdentityProvider is an OpenID Connect provider that users log in at with
+// the authorization code flow.
+type IdentityProvider interface {
+	// AuthCodeURL returns the URL to send the user to. codeChallenge is the
+	// S256 PKCE challenge.
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems an authorization code and returns the claims of the
+	// ID token once its signature, issuer, audience, expiry and nonce are
+	// verified.
+	Exchange(code, codeVerifier, nonce string) (*domain.IdentityClaims, error)
+}
+
+// SSOConfig tells how users provisioned from an identity provider are set up.
+type SSOConfig struct {
+	// UsernameClaim names the claim new users get their username from.
+	// The email claim is used when it is missing.
+	UsernameClaim string
+	// RoleClaim names a claim holding a string or a list of strings, such
+	// as "groups". Members of Workspace with one of AdminValues in it are
+	// admins there. Roles are only ever raised from claims, never lowered,
+	// and only in Workspace: other workspaces are run by their own admins.
+	RoleClaim   string
+	AdminValues []string
+	// Workspace is the ID of the workspace that the identity provider's
+	// role claims apply to. Without it, claims raise no roles.
+	Workspace string
+	// Provision creates accounts for identities seen for the first time.
+	// Without it, only identities linked to an account can log in. It is
+	// set apart from the registration mode, which only covers POST /register.
+	Provision bool
+}
+
+var DefaultSSOConfig = SSOConfig{UsernameClaim: "preferred_username"}
+
+type SSOUsecase interface {
+	// Start begins a login at the identity provider, or linking an
+	// identity to linkUserID when it is set.
+	Start(linkUserID string) (*domain.SSOStart, error)
+	// Callback finishes what Start began, given the sealed request and the
+	// state and code the provider sent back.
+	Callback(sealedRequest, state, code string) (*domain.SSOResult, error)
+}
+
+// SSORequestSealer protects an SSORequest while the browser holds it.
+type SSORequestSealer interface {
+	SealSSORequest(req *domain.SSORequest) (string, error)
+	OpenSSORequest(sealed string) (*domain.SSORequest, error)
+}
+
+type ssoUsecase struct {
+	provider      IdentityProvider
+	userRepo      UserRepository
+	workspaceRepo WorkspaceRepository
+	jwtSvc        JWTService
+	sealer        SSORequestSealer
+	config        SSOConfig
+}
+
+// NewSSOUsecase creates the single sign-on usecase. provider may be nil when
+// no identity provider is configured.
+func NewSSOUsecase(provider IdentityProvider, ur UserRepository, wr WorkspaceRepository, js JWTService, sealer SSORequestSealer, config SSOConfig) SSOUsecase {
+	return &ssoUsecase{
+		provider:      provider,
+		userRepo:      ur,
+		workspaceRepo: wr,
+		jwtSvc:        js,
+		sealer:        sealer,
+		config:        config,
+	}
+}
+
+func (s *ssoUsecase) Start(linkUserID string) (*domain.SSOStart, error) {
+	if s.provider == nil {
+		return nil, errs.ErrSSONotConfigured
+	}
+
+	req := &domain.SSORequest{LinkUserID: linkUserID}
+	for _, value := range []*string{&req.State, &req.Nonce, &req.CodeVerifier} {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		*value = token
+	}
+
+	authURL, err := s.provider.AuthCodeURL(req.State, req.Nonce, pkceChallenge(req.CodeVerifier))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.sealer.SealSSORequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SSOStart{AuthURL: authURL, Request: sealed}, nil
+}
+
+func (s *ssoUsecase) Callback(sealedRequest, state, code string) (*domain.SSOResult, error) {
+	if s.provider == nil {
+		return nil, errs.ErrSSONotConfigured
+	}
+
+	req, err := s.sealer.OpenSSORequest(sealedRequest)
+	if err != nil {
+		return nil, err
+	}
+	// the state ties the callback to the browser that started the login
+	if subtle.ConstantTimeCompare([]byte(req.State), []byte(state)) != 1 {
+		return nil, fmt.Errorf("%w: state does not match", errs.ErrInvalidSSOState)
+	}
+
+	claims, err := s.provider.Exchange(code, req.CodeVerifier, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity := domain.ExternalIdentity{Issuer: claims.Issuer, Subject: claims.Subject}
+
+	if req.LinkUserID != "" {
+		if err := s.link(req.LinkUserID, identity); err != nil {
+			return nil, err
+		}
+		return &domain.SSOResult{Linked: true, Identity: identity}, nil
+	}
+
+	user, err := s.userRepo.GetByIdentity(identity.Issuer, identity.Subject)
+	if errors.Is(err, errs.ErrUserNotFound) {
+		user, err = s.provision(claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := enterWorkspace(user); err != nil {
+		return nil, err
+	}
+	if err := s.promote(user, claims.Claims); err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO: User '%s' (ID: %s, Role: %s) successfully authenticated with single sign-on", user.Username, user.ID, user.Role)
+	token, err := s.jwtSvc.GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SSOResult{Token: token, Identity: identity}, nil
+}
+
+// promote makes a member of the identity provider's workspace an admin of
+// it if their role claim says so.
+func (s *ssoUsecase) promote(user *domain.User, claims map[string]any) error {
+	if s.config.Workspace == "" || s.config.role(claims) != domain.RoleAdmin {
+		return nil
+	}
+	membership := user.Membership(s.config.Workspace)
+	if membership == nil || membership.Role == domain.RoleAdmin {
+		return nil
+	}
+
+	log.Printf("INFO: Promoting user '%s' to admin of workspace %s from identity provider claims", user.Username, s.config.Workspace)
+	if err := s.userRepo.SetRole(s.config.Workspace, user.ID, domain.RoleAdmin); err != nil {
+		return err
+	}
+	membership.Role = domain.RoleAdmin
+	user.Activate(user.WorkspaceID)
+	return nil
+}
+
+func (s *ssoUsecase) link(userID string, identity domain.ExternalIdentity) error {
+	owner, err := s.userRepo.GetByIdentity(identity.Issuer, identity.Subject)
+	switch {
+	case err == nil && owner.ID == userID:
+		return nil
+	case err == nil:
+		return fmt.Errorf("%w: it is linked to another account", errs.ErrIdentityConflict)
+	case !errors.Is(err, errs.ErrUserNotFound):
+		return err
+	}
+
+	if err := s.userRepo.LinkIdentity(userID, identity); err != nil {
+		return err
+	}
+	log.Printf("INFO: Linked identity %s of %s to user %s", identity.Subject, identity.Issuer, userID)
+	return nil
+}
+
+// provision creates the user for an identity seen for the first time, with
+// a workspace of their own like users who register.
+func (s *ssoUsecase) provision(claims *domain.IdentityClaims) (*domain.User, error) {
+	if !s.config.Provision {
+		return nil, fmt.Errorf("%w: single sign-on only logs in accounts the identity is linked to", errs.ErrRegistrationClosed)
+	}
+	username := stringClaim(claims.Claims, s.config.UsernameClaim)
+	if username == "" {
+		username = stringClaim(claims.Claims, "email")
+	}
+	if username == "" {
+		return nil, fmt.Errorf("%w: the identity provider sent no username", errs.ErrSSOFailed)
+	}
+
+	// taking over a local account needs its password, see link
+	exist, err := s.userRepo.CheckUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return nil, fmt.Errorf("%w: username %q is taken, log in with its password and link the identity instead", errs.ErrIdentityConflict, username)
+	}
+
+	user := &domain.User{
+		Username:   username,
+		Identities: []domain.ExternalIdentity{{Issuer: claims.Issuer, Subject: claims.Subject}},
+	}
+	workspace, err := createOwnWorkspace(s.workspaceRepo, user, user.Username+"'s workspace")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		discardWorkspace(s.workspaceRepo, workspace)
+		return nil, err
+	}
+	log.Printf("INFO: Provisioned user '%s' with workspace %s from %s", user.Username, workspace.ID, claims.Issuer)
+	return user, nil
+}
+
+// role maps the role claim to a role.
+func (c SSOConfig) role(claims map[string]any) string {
+	if c.RoleClaim == "" {
+		return domain.RoleUser
+	}
+	var values []string
+	switch v := claims[c.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+	for _, value := range values {
+		if slices.Contains(c.AdminValues, value) {
+			return domain.RoleAdmin
+		}
+	}
+	return domain.RoleUser
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// pkceChallenge derives the S256 code challenge of RFC 7636.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
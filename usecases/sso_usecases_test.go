@@ -0,0 +1,249 @@
+package usecases_test
+
+import (
+	"net/url"
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"task-manager/infrastructure/oidctest"
+	"task-manager/repositories/mocks"
+	"task-manager/usecases"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// SSOUsecaseTestSuite logs in at a stand-in identity provider.
+type SSOUsecaseTestSuite struct {
+	suite.Suite
+	idp               *oidctest.Server
+	mockUserRepo      *mocks.UserRepository
+	mockWorkspaceRepo *mocks.WorkspaceRepository
+	jwtService        *infrastructure.JWTServiceV5
+	provider          usecases.IdentityProvider
+	ssoUsecase        usecases.SSOUsecase
+}
+
+var testSSOConfig = usecases.SSOConfig{
+	UsernameClaim: "preferred_username",
+	RoleClaim:     "groups",
+	AdminValues:   []string{"task-admins"},
+	Workspace:     "ws1",
+	Provision:     true,
+}
+
+func (s *SSOUsecaseTestSuite) SetupTest() {
+	s.idp = oidctest.NewServer("task-manager", "")
+	s.mockUserRepo = new(mocks.UserRepository)
+	s.mockWorkspaceRepo = new(mocks.WorkspaceRepository)
+	keys, err := infrastructure.NewKeyring(nil, infrastructure.DefaultKeyringConfig)
+	s.Require().NoError(err)
+	s.jwtService = infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
+	s.provider = infrastructure.NewOIDCProvider(infrastructure.OIDCConfig{
+		Issuer:      s.idp.Issuer(),
+		ClientID:    "task-manager",
+		RedirectURL: "http://app.example/auth/oidc/callback",
+	}, nil)
+	s.ssoUsecase = usecases.NewSSOUsecase(s.provider, s.mockUserRepo, s.mockWorkspaceRepo, s.jwtService, s.jwtService, testSSOConfig)
+}
+
+func (s *SSOUsecaseTestSuite) TearDownTest() {
+	s.idp.Close()
+}
+
+func TestSSOUsecase(t *testing.T) {
+	suite.Run(t, new(SSOUsecaseTestSuite))
+}
+
+// login goes through the whole flow as linkUserID, or as nobody for a
+// plain login.
+func (s *SSOUsecaseTestSuite) login(linkUserID string) (*domain.SSOResult, error) {
+	start, err := s.ssoUsecase.Start(linkUserID)
+	s.Require().NoError(err)
+	code, state, err := s.idp.Authorize(start.AuthURL)
+	s.Require().NoError(err)
+	return s.ssoUsecase.Callback(start.Request, state, code)
+}
+
+func (s *SSOUsecaseTestSuite) identity(subject string) domain.ExternalIdentity {
+	return domain.ExternalIdentity{Issuer: s.idp.Issuer(), Subject: subject}
+}
+
+func (s *SSOUsecaseTestSuite) TestStart_UsesPKCE() {
+	start, err := s.ssoUsecase.Start("")
+	s.Require().NoError(err)
+
+	authURL, err := url.Parse(start.AuthURL)
+	s.Require().NoError(err)
+	s.Assert().Equal("S256", authURL.Query().Get("code_challenge_method"))
+	s.Assert().NotEmpty(authURL.Query().Get("code_challenge"))
+
+	req, err := s.jwtService.OpenSSORequest(start.Request)
+	s.Require().NoError(err)
+	s.Assert().Equal(req.State, authURL.Query().Get("state"))
+	s.Assert().Equal(req.Nonce, authURL.Query().Get("nonce"))
+	s.Assert().NotContains(start.AuthURL, req.CodeVerifier, "the verifier never leaves the browser's cookie")
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_LinkedUser() {
+	user := &domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws1", domain.RoleUser)}
+	s.idp.SetUser(map[string]any{"sub": "idp-1", "preferred_username": "alice"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-1").Return(user, nil).Once()
+
+	result, err := s.login("")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(result.Token)
+	s.Assert().False(result.Linked)
+	s.Assert().Equal("ws1", user.WorkspaceID)
+	s.mockUserRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_ProvisionsNewUser() {
+	s.idp.SetUser(map[string]any{"sub": "idp-2", "preferred_username": "bob", "groups": []string{"staff"}})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-2").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("CheckUsername", "bob").Return(false, nil).Once()
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws2"
+	}).Return(nil).Once()
+
+	var created *domain.User
+	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*domain.User)
+		created.ID = "u2"
+	}).Return(nil).Once()
+
+	result, err := s.login("")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(result.Token)
+	s.Assert().Equal("bob", created.Username)
+	s.Assert().Equal("ws2", created.WorkspaceID)
+	s.Assert().Equal(domain.RoleAdmin, created.Role, "provisioned users own a workspace of their own")
+	s.Assert().Empty(created.PasswordHash, "provisioned users have no local password")
+	s.Assert().Equal([]domain.ExternalIdentity{s.identity("idp-2")}, created.Identities)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_ProvisionFailureDiscardsWorkspace() {
+	s.idp.SetUser(map[string]any{"sub": "idp-3", "preferred_username": "carol"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-3").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("CheckUsername", "carol").Return(false, nil).Once()
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws3"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(errs.ErrIdentityConflict).Once()
+	s.mockWorkspaceRepo.On("Delete", "ws3").Return(nil).Once()
+
+	_, err := s.login("")
+
+	s.Require().ErrorIs(err, errs.ErrIdentityConflict)
+	s.mockWorkspaceRepo.AssertExpectations(s.T())
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_ProvisioningOff() {
+	config := testSSOConfig
+	config.Provision = false
+	s.ssoUsecase = usecases.NewSSOUsecase(s.provider, s.mockUserRepo, s.mockWorkspaceRepo, s.jwtService, s.jwtService, config)
+	s.idp.SetUser(map[string]any{"sub": "idp-4", "preferred_username": "dave"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-4").Return(nil, errs.ErrUserNotFound).Once()
+
+	_, err := s.login("")
+
+	s.Require().ErrorIs(err, errs.ErrRegistrationClosed)
+	s.mockWorkspaceRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+	s.mockUserRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_PromotesFromClaims() {
+	user := &domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws1", domain.RoleUser)}
+	s.idp.SetUser(map[string]any{"sub": "idp-1", "groups": "task-admins"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-1").Return(user, nil).Once()
+	s.mockUserRepo.On("SetRole", "ws1", "u1", domain.RoleAdmin).Return(nil).Once()
+
+	_, err := s.login("")
+
+	s.Require().NoError(err)
+	s.Assert().Equal(domain.RoleAdmin, user.Role)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_ClaimsDoNotPromoteInOtherWorkspaces() {
+	// invited as a plain member of someone else's workspace
+	user := &domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws9", domain.RoleUser)}
+	s.idp.SetUser(map[string]any{"sub": "idp-1", "groups": []string{"task-admins"}})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-1").Return(user, nil).Once()
+
+	_, err := s.login("")
+
+	s.Require().NoError(err)
+	s.Assert().Equal("ws9", user.WorkspaceID)
+	s.Assert().Equal(domain.RoleUser, user.Role)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_UsernameTakenByLocalAccount() {
+	s.idp.SetUser(map[string]any{"sub": "idp-4", "preferred_username": "admin"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-4").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("CheckUsername", "admin").Return(true, nil).Once()
+
+	_, err := s.login("")
+
+	s.Assert().ErrorIs(err, errs.ErrIdentityConflict)
+	s.mockUserRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_LinksIdentity() {
+	s.idp.SetUser(map[string]any{"sub": "idp-5"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-5").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("LinkIdentity", "u1", s.identity("idp-5")).Return(nil).Once()
+
+	result, err := s.login("u1")
+
+	s.Require().NoError(err)
+	s.Assert().True(result.Linked)
+	s.Assert().Empty(result.Token)
+	s.Assert().Equal(s.identity("idp-5"), result.Identity)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_IdentityLinkedToAnotherUser() {
+	s.idp.SetUser(map[string]any{"sub": "idp-6"})
+	s.mockUserRepo.On("GetByIdentity", s.idp.Issuer(), "idp-6").Return(&domain.User{ID: "u9"}, nil).Once()
+
+	_, err := s.login("u1")
+
+	s.Assert().ErrorIs(err, errs.ErrIdentityConflict)
+	s.mockUserRepo.AssertNotCalled(s.T(), "LinkIdentity", mock.Anything, mock.Anything)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_StateMismatch() {
+	start, err := s.ssoUsecase.Start("")
+	s.Require().NoError(err)
+	code, _, err := s.idp.Authorize(start.AuthURL)
+	s.Require().NoError(err)
+
+	// a callback forged for someone else's browser
+	_, err = s.ssoUsecase.Callback(start.Request, "forged-state", code)
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidSSOState)
+}
+
+func (s *SSOUsecaseTestSuite) TestCallback_TamperedRequest() {
+	start, err := s.ssoUsecase.Start("")
+	s.Require().NoError(err)
+
+	_, err = s.ssoUsecase.Callback(start.Request+"x", "state", "code")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidSSOState)
+}
+
+func (s *SSOUsecaseTestSuite) TestNotConfigured() {
+	sso := usecases.NewSSOUsecase(nil, s.mockUserRepo, s.mockWorkspaceRepo, s.jwtService, s.jwtService, testSSOConfig)
+
+	_, err := sso.Start("")
+	s.Assert().ErrorIs(err, errs.ErrSSONotConfigured)
+	_, err = sso.Callback("sealed", "state", "code")
+	s.Assert().ErrorIs(err, errs.ErrSSONotConfigured)
+}
@@ -1,54 +1,166 @@
 package usecases
 
 import (
+	"fmt"
+	"slices"
 	"task-manager/domain"
+	"task-manager/errs"
+	"time"
 )
 
+// TaskUsecase manages tasks on behalf of user, whose access the policies
+// may narrow beyond what their role allows. Tasks they may not read are
+// left out of lists and reported as not found.
 type TaskUsecase interface {
-	CreateTask(task *domain.Task) (*domain.Task, error)
-	GetTasks() ([]*domain.Task, error)
-	GetTaskByID(id string) (*domain.Task, error)
-	UpdateTask(id string, updatedTask domain.Task) (*domain.Task, error)
-	DeleteTask(id string) error
+	CreateTask(user *domain.User, task *domain.Task) (*domain.Task, error)
+	GetTasks(user *domain.User) ([]*domain.Task, error)
+	FilterTasks(user *domain.User, query string) ([]*domain.Task, error)
+	GetTaskByID(user *domain.User, id string) (*domain.Task, error)
+	UpdateTask(user *domain.User, id string, updatedTask domain.Task) (*domain.Task, error)
+	DeleteTask(user *domain.User, id string) error
+	SearchTasks(user *domain.User, q string, limit int) ([]*domain.SearchResult, error)
+	BulkTasks(user *domain.User, ops []domain.BulkOperation, atomic bool) ([]*domain.BulkResult, error)
+	PatchTasks(user *domain.User, query string, patch domain.Task) (matched int64, modified int64, err error)
+	ExportTasks(user *domain.User, query string, fn func(*domain.Task) error) error
+	ImportTasks(user *domain.User, rows []domain.ImportRow, opts domain.ImportOptions) (*domain.ImportReport, error)
+	CalendarTasks(user *domain.User, query string) ([]*domain.Task, error)
+	// ExplainAccess tells whether the policies let user do action with the
+	// task with taskID, and why. changes are the fields a create or update
+	// would set; creates have no taskID.
+	ExplainAccess(user *domain.User, action, taskID string, changes domain.Task) (*domain.AccessDecision, error)
 }
 
-// TaskRepository defines the interface for task data operations.
+// TaskRepository defines the interface for task data operations. The
+// repository handed to NewTaskUsecase sees the tasks of every workspace,
+// which only background jobs need; requests go through InWorkspace.
 type TaskRepository interface {
+	// InWorkspace returns a repository that only sees, changes and creates
+	// the tasks of a workspace.
+	InWorkspace(workspaceID string) TaskRepository
 	Create(task *domain.Task) (*domain.Task, error)
 	GetAll() ([]*domain.Task, error)
+	Find(filter *domain.Filter) ([]*domain.Task, error)
 	GetByID(id string) (*domain.Task, error)
 	Update(id string, updatedTask domain.Task) (*domain.Task, error)
 	Delete(id string) error
+	GetDueBefore(t time.Time) ([]*domain.Task, error)
+	MarkReminderSent(id string, offset time.Duration) error
+	MarkOverdue(id string) error
+	MarkEscalated(id string, at time.Time) error
+	Search(query domain.SearchQuery) ([]*domain.Task, error)
+	// BulkWrite returns one result per operation, indexed by position in ops.
+	// With atomic set it either applies every operation or none, returning
+	// errs.ErrBulkAborted in the latter case.
+	BulkWrite(ops []domain.BulkOperation, atomic bool) ([]*domain.BulkResult, error)
+	UpdateMany(filter *domain.Filter, patch domain.Task) (matched int64, modified int64, err error)
+	// FindByTitles returns the tasks whose title is one of titles, ignoring
+	// case.
+	FindByTitles(titles []string) ([]*domain.Task, error)
+	// Stream calls fn for every task matching filter (all tasks if nil) and
+	// stops at the first error fn returns.
+	Stream(filter *domain.Filter, fn func(*domain.Task) error) error
 }
 
 type taskUsecase struct {
 	taskRepo TaskRepository
+	policies PolicySource
 }
 
-func NewTaskUsecase(ur TaskRepository) TaskUsecase {
+// NewTaskUsecase creates the task usecase. ps may be nil when there are no
+// access policies.
+func NewTaskUsecase(tr TaskRepository, ps PolicySource) TaskUsecase {
 	return &taskUsecase{
-		taskRepo: ur,
+		taskRepo: tr,
+		policies: ps,
 	}
 }
 
-func (ts *taskUsecase) CreateTask(task *domain.Task) (*domain.Task, error) {
-	return ts.taskRepo.Create(task)
+// tasks is the repository for the tasks of the user's workspace.
+func (ts *taskUsecase) tasks(user *domain.User) TaskRepository {
+	return ts.taskRepo.InWorkspace(user.WorkspaceID)
 }
 
-func (ts *taskUsecase) GetTasks() ([]*domain.Task, error) {
+func (ts *taskUsecase) CreateTask(user *domain.User, task *domain.Task) (*domain.Task, error) {
+	if err := ts.authorize(user, domain.PermTaskCreate, task, taskFields(*task, nil)); err != nil {
+		return nil, err
+	}
+	return ts.tasks(user).Create(task)
+}
 
-	return ts.taskRepo.GetAll()
+func (ts *taskUsecase) GetTasks(user *domain.User) ([]*domain.Task, error) {
+	tasks, err := ts.tasks(user).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return ts.visible(user, tasks), nil
+}
+
+func (ts *taskUsecase) FilterTasks(user *domain.User, query string) ([]*domain.Task, error) {
+	filter, err := ParseFilterQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := ts.tasks(user).Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	return ts.visible(user, tasks), nil
 }
 
-func (ts *taskUsecase) GetTaskByID(id string) (*domain.Task, error) {
-	return ts.taskRepo.GetByID(id)
+func (ts *taskUsecase) GetTaskByID(user *domain.User, id string) (*domain.Task, error) {
+	task, err := ts.tasks(user).GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ts.authorize(user, domain.PermTaskRead, task, nil); err != nil {
+		return nil, errs.ErrTaskNotFound
+	}
+	return task, nil
 }
 
-func (ts *taskUsecase) UpdateTask(id string, updatedTask domain.Task) (*domain.Task, error) {
+func (ts *taskUsecase) UpdateTask(user *domain.User, id string, updatedTask domain.Task) (*domain.Task, error) {
+	if len(ts.policiesFor(domain.PermTaskUpdate)) > 0 {
+		existing, err := ts.GetTaskByID(user, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := ts.authorize(user, domain.PermTaskUpdate, existing, taskFields(updatedTask, existing)); err != nil {
+			return nil, err
+		}
+	}
+	return ts.tasks(user).Update(id, updatedTask)
+}
 
-	return ts.taskRepo.Update(id, updatedTask)
+func (ts *taskUsecase) DeleteTask(user *domain.User, id string) error {
+	if len(ts.policiesFor(domain.PermTaskDelete)) > 0 {
+		existing, err := ts.GetTaskByID(user, id)
+		if err != nil {
+			return err
+		}
+		if err := ts.authorize(user, domain.PermTaskDelete, existing, nil); err != nil {
+			return err
+		}
+	}
+	return ts.tasks(user).Delete(id)
 }
 
-func (ts *taskUsecase) DeleteTask(id string) error {
-	return ts.taskRepo.Delete(id)
+func (ts *taskUsecase) ExplainAccess(user *domain.User, action, taskID string, changes domain.Task) (*domain.AccessDecision, error) {
+	if !slices.Contains(PolicyActions, action) {
+		return nil, fmt.Errorf("%w: unknown action %q", errs.ErrValidation, action)
+	}
+	req := accessRequest{subject: user, action: action}
+	if action == domain.PermTaskCreate {
+		req.resource = &changes
+		req.changes = taskFields(changes, nil)
+	} else {
+		task, err := ts.tasks(user).GetByID(taskID)
+		if err != nil {
+			return nil, err
+		}
+		req.resource = task
+		if action == domain.PermTaskUpdate {
+			req.changes = taskFields(changes, task)
+		}
+	}
+	return evaluatePolicies(ts.policiesFor(action), req), nil
 }
@@ -14,12 +14,25 @@ import (
 type TaskUsecaseTestSuite struct {
 	suite.Suite
 	mockTaskRepo *mocks.TaskRepository
+	policies     *staticPolicies
 	taskUsecase  usecases.TaskUsecase
+	user         *domain.User
+}
+
+// staticPolicies are the access policies of a test.
+type staticPolicies struct {
+	list []domain.Policy
+}
+
+func (p *staticPolicies) Policies() []domain.Policy {
+	return p.list
 }
 
 func (s *TaskUsecaseTestSuite) SetupTest() {
 	s.mockTaskRepo = new(mocks.TaskRepository)
-	s.taskUsecase = usecases.NewTaskUsecase(s.mockTaskRepo)
+	s.policies = &staticPolicies{}
+	s.taskUsecase = usecases.NewTaskUsecase(s.mockTaskRepo, s.policies)
+	s.user = &domain.User{ID: "u1", Username: "alice", WorkspaceID: "ws1", Role: domain.RoleUser}
 }
 
 func TestTaskUsecase(t *testing.T) {
@@ -31,10 +44,11 @@ func (s *TaskUsecaseTestSuite) TestCreateTask_Success() {
 	inputTask := &domain.Task{Title: "New Task"}
 	s.mockTaskRepo.On("Create", inputTask).Return(inputTask, nil).Once()
 
-	createdTask, err := s.taskUsecase.CreateTask(inputTask)
+	createdTask, err := s.taskUsecase.CreateTask(s.user, inputTask)
 
 	s.Require().NoError(err)
 	s.Assert().Equal(inputTask, createdTask)
+	s.Assert().Equal("ws1", s.mockTaskRepo.Workspace, "tasks are created in the user's workspace")
 	s.mockTaskRepo.AssertExpectations(s.T())
 }
 
@@ -46,7 +60,7 @@ func (s *TaskUsecaseTestSuite) TestGetTasks_Success() {
 	}
 	s.mockTaskRepo.On("GetAll").Return(expectedTasks, nil).Once()
 
-	tasks, err := s.taskUsecase.GetTasks()
+	tasks, err := s.taskUsecase.GetTasks(s.user)
 
 	s.Require().NoError(err)
 	s.Assert().Len(tasks, 2)
@@ -60,7 +74,7 @@ func (s *TaskUsecaseTestSuite) TestGetTaskByID_Success() {
 	expectedTask := &domain.Task{ID: taskID, Title: "Found Task"}
 	s.mockTaskRepo.On("GetByID", taskID).Return(expectedTask, nil).Once()
 
-	task, err := s.taskUsecase.GetTaskByID(taskID)
+	task, err := s.taskUsecase.GetTaskByID(s.user, taskID)
 
 	s.Require().NoError(err)
 	s.Assert().Equal(expectedTask, task)
@@ -72,7 +86,7 @@ func (s *TaskUsecaseTestSuite) TestGetTaskByID_NotFound() {
 	taskID := "nonexistent"
 	s.mockTaskRepo.On("GetByID", taskID).Return(nil, errs.ErrTaskNotFound).Once()
 
-	task, err := s.taskUsecase.GetTaskByID(taskID)
+	task, err := s.taskUsecase.GetTaskByID(s.user, taskID)
 
 	s.Require().Error(err)
 	s.Assert().ErrorIs(err, errs.ErrTaskNotFound, "Expected a specific task not found error")
@@ -88,7 +102,7 @@ func (s *TaskUsecaseTestSuite) TestUpdateTask_Success() {
 
 	s.mockTaskRepo.On("Update", taskID, taskUpdate).Return(expectedUpdatedTask, nil).Once()
 
-	updatedTask, err := s.taskUsecase.UpdateTask(taskID, taskUpdate)
+	updatedTask, err := s.taskUsecase.UpdateTask(s.user, taskID, taskUpdate)
 
 	s.Require().NoError(err)
 	s.Assert().Equal(expectedUpdatedTask, updatedTask)
@@ -100,7 +114,7 @@ func (s *TaskUsecaseTestSuite) TestDeleteTask_Success() {
 	taskID := "task123"
 	s.mockTaskRepo.On("Delete", taskID).Return(nil).Once()
 
-	err := s.taskUsecase.DeleteTask(taskID)
+	err := s.taskUsecase.DeleteTask(s.user, taskID)
 
 	s.Require().NoError(err)
 	s.mockTaskRepo.AssertExpectations(s.T())
@@ -112,9 +126,39 @@ func (s *TaskUsecaseTestSuite) TestDeleteTask_Failure() {
 	expectedErr := errors.New("database error")
 	s.mockTaskRepo.On("Delete", taskID).Return(expectedErr).Once()
 
-	err := s.taskUsecase.DeleteTask(taskID)
+	err := s.taskUsecase.DeleteTask(s.user, taskID)
 
 	s.Require().Error(err)
 	s.Assert().Equal(expectedErr, err)
 	s.mockTaskRepo.AssertExpectations(s.T())
 }
+
+func (s *TaskUsecaseTestSuite) TestSearchTasks_RanksTitleMatchesFirst() {
+	inDescription := &domain.Task{ID: "1", Title: "Weekly sync", Description: "prepare the deploy checklist"}
+	inTitle := &domain.Task{ID: "2", Title: "Deploy backend", Description: "after review"}
+	expectedQuery := domain.SearchQuery{
+		Terms:    []string{"deploy"},
+		Phrases:  []string{"code review"},
+		Prefixes: []string{"back"},
+		Limit:    50,
+	}
+	s.mockTaskRepo.On("Search", expectedQuery).Return([]*domain.Task{inDescription, inTitle}, nil).Once()
+
+	results, err := s.taskUsecase.SearchTasks(s.user, `deploy "Code Review" back*`, 10)
+
+	s.Require().NoError(err)
+	s.Require().Len(results, 2)
+	s.Assert().Equal("2", results[0].Task.ID)
+	s.Assert().Equal("<mark>Deploy</mark> <mark>backend</mark>", results[0].Highlights["title"])
+	s.Assert().Equal("prepare the <mark>deploy</mark> checklist", results[1].Highlights["description"])
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *TaskUsecaseTestSuite) TestSearchTasks_EmptyQuery() {
+
+	results, err := s.taskUsecase.SearchTasks(s.user, `  "" * `, 10)
+
+	s.Require().ErrorIs(err, errs.ErrEmptySearchQuery)
+	s.Assert().Nil(results)
+	s.mockTaskRepo.AssertNotCalled(s.T(), "Search")
+}
@@ -0,0 +1,174 @@
+package usecases
+
+import (
+	"fmt"
+	"log"
+	"task-manager/domain"
+	"task-manager/errs"
+)
+
+const (
+	defaultUsersPerPage = 20
+	maxUsersPerPage     = 100
+)
+
+// UserAdminUsecase lets admins manage the members of their workspace. As
+// with roles, nobody can change a user who has permissions they do not
+// have themselves.
+type UserAdminUsecase interface {
+	ListUsers(actor *domain.User, query domain.UserQuery) (*domain.UserPage, error)
+	GetUser(actor *domain.User, id string) (*domain.User, error)
+	// UpdateUser renames a user or disables them in the workspace. Users
+	// who are members of other workspaces too cannot be renamed.
+	UpdateUser(actor *domain.User, id string, update domain.UserUpdate) (*domain.User, error)
+	// DeleteUser removes a user from the workspace, and deletes the
+	// account if it was their only one.
+	DeleteUser(actor *domain.User, id string) error
+	// Demote gives a user the user role.
+	Demote(actor *domain.User, id string) error
+
+	// CreateInvitation invites someone to join the actor's workspace, by
+	// registering or with the account they have, and returns the token of
+	// the invitation. Nobody joins a workspace without accepting one.
+	CreateInvitation(actor *domain.User, invitation *domain.Invitation) (string, error)
+	// ListInvitations returns the unused, unexpired invitations to the
+	// actor's workspace.
+	ListInvitations(actor *domain.User) ([]*domain.Invitation, error)
+	RevokeInvitation(actor *domain.User, id string) error
+}
+
+type userAdminUsecase struct {
+	userRepo       UserRepository
+	invitationRepo InvitationRepository
+	roles          *roleUsecase
+}
+
+func NewUserAdminUsecase(ur UserRepository, rr RoleRepository, ir InvitationRepository, lr LeaseRepository) UserAdminUsecase {
+	return &userAdminUsecase{userRepo: ur, invitationRepo: ir, roles: &roleUsecase{roleRepo: rr, userRepo: ur, leaseRepo: lr}}
+}
+
+func (a *userAdminUsecase) ListUsers(actor *domain.User, query domain.UserQuery) (*domain.UserPage, error) {
+	query.WorkspaceID = actor.WorkspaceID
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PerPage <= 0 {
+		query.PerPage = defaultUsersPerPage
+	}
+	if query.PerPage > maxUsersPerPage {
+		query.PerPage = maxUsersPerPage
+	}
+	users, total, err := a.userRepo.List(query)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.UserPage{Users: users, Total: total, Page: query.Page, PerPage: query.PerPage}, nil
+}
+
+func (a *userAdminUsecase) GetUser(actor *domain.User, id string) (*domain.User, error) {
+	return member(a.userRepo, actor.WorkspaceID, id)
+}
+
+// UpdateUser checks every change before making any.
+func (a *userAdminUsecase) UpdateUser(actor *domain.User, id string, update domain.UserUpdate) (*domain.User, error) {
+	user, err := a.managed(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	rename := update.Username != nil && *update.Username != user.Username
+	if rename && len(user.Memberships) > 1 {
+		return nil, fmt.Errorf("%w: '%s' is a member of other workspaces too", errs.ErrForbidden, user.Username)
+	}
+	// the user may keep their username and only change how it is written
+	if rename && domain.Canonical(*update.Username) != domain.Canonical(user.Username) {
+		exist, err := a.userRepo.CheckUsername(*update.Username)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			return nil, errs.ErrUsernameExists
+		}
+	}
+	toggle := update.Disabled != nil && *update.Disabled != user.Disabled
+
+	apply := func() error {
+		if rename {
+			if err := a.userRepo.SetUsername(id, *update.Username); err != nil {
+				return err
+			}
+			log.Printf("INFO: User '%s' renamed user '%s' to '%s'", actor.Username, user.Username, *update.Username)
+			user.Username = *update.Username
+		}
+		if toggle {
+			if err := a.userRepo.SetDisabled(actor.WorkspaceID, id, *update.Disabled); err != nil {
+				return err
+			}
+			log.Printf("INFO: User '%s' set user '%s' to disabled=%t", actor.Username, user.Username, *update.Disabled)
+			user.Disabled = *update.Disabled
+		}
+		return nil
+	}
+	if toggle && *update.Disabled {
+		err = a.roles.keepAdmin(user, apply)
+	} else {
+		err = apply()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *userAdminUsecase) DeleteUser(actor *domain.User, id string) error {
+	user, err := a.managed(actor, id)
+	if err != nil {
+		return err
+	}
+	return a.roles.keepAdmin(user, func() error {
+		if len(user.Memberships) > 1 {
+			if err := a.userRepo.RemoveMembership(actor.WorkspaceID, id); err != nil {
+				return err
+			}
+			log.Printf("INFO: User '%s' removed user '%s' from workspace %s", actor.Username, user.Username, actor.WorkspaceID)
+			return nil
+		}
+		if err := a.userRepo.Delete(id); err != nil {
+			return err
+		}
+		log.Printf("INFO: User '%s' deleted user '%s'", actor.Username, user.Username)
+		return nil
+	})
+}
+
+func (a *userAdminUsecase) Demote(actor *domain.User, id string) error {
+	user, err := a.managed(actor, id)
+	if err != nil {
+		return err
+	}
+	if user.Role == domain.RoleUser {
+		return nil
+	}
+	return a.roles.keepAdmin(user, func() error {
+		if err := a.userRepo.SetRole(actor.WorkspaceID, id, domain.RoleUser); err != nil {
+			return err
+		}
+		log.Printf("INFO: User '%s' demoted user '%s' from '%s'", actor.Username, user.Username, user.Role)
+		return nil
+	})
+}
+
+// managed returns the member with id if actor has every permission they do.
+func (a *userAdminUsecase) managed(actor *domain.User, id string) (*domain.User, error) {
+	user, err := member(a.userRepo, actor.WorkspaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := a.roles.Permissions(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.roles.checkGrantable(actor, permissions); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
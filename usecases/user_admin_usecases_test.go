@@ -0,0 +1,210 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/repositories/mocks"
+	"task-manager/usecases"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserAdminUsecaseTestSuite struct {
+	suite.Suite
+	mockUserRepo   *mocks.UserRepository
+	mockRoleRepo   *mocks.RoleRepository
+	mockInviteRepo *mocks.InvitationRepository
+	mockLeaseRepo  *mocks.LeaseRepository
+	usecase        usecases.UserAdminUsecase
+	admin          *domain.User
+	helpdesk       *domain.User
+}
+
+func (s *UserAdminUsecaseTestSuite) SetupTest() {
+	s.mockUserRepo = new(mocks.UserRepository)
+	s.mockLeaseRepo = new(mocks.LeaseRepository)
+	s.mockRoleRepo = new(mocks.RoleRepository)
+	s.mockInviteRepo = new(mocks.InvitationRepository)
+	s.usecase = usecases.NewUserAdminUsecase(s.mockUserRepo, s.mockRoleRepo, s.mockInviteRepo, s.mockLeaseRepo)
+
+	// the helpdesk manages users but cannot touch admins
+	s.admin = &domain.User{ID: "a1", Username: "admin", WorkspaceID: "ws1", Role: domain.RoleAdmin, Memberships: memberOf("ws1", domain.RoleAdmin)}
+	s.helpdesk = &domain.User{ID: "h1", Username: "helpdesk", WorkspaceID: "ws1", Role: "helpdesk", Memberships: memberOf("ws1", "helpdesk")}
+	s.mockRoleRepo.On("GetByName", "ws1", domain.RoleUser).Return(nil, errs.ErrRoleNotFound).Maybe()
+	s.mockRoleRepo.On("GetByName", "ws1", "helpdesk").Return(&domain.Role{
+		WorkspaceID: "ws1",
+		Name:        "helpdesk",
+		Permissions: []string{domain.PermTaskRead, domain.PermUserManage},
+	}, nil).Maybe()
+}
+
+// expectAdminLease expects a change to an admin of ws1, which holds the
+// workspace's admin lease while it checks and writes.
+func (s *UserAdminUsecaseTestSuite) expectAdminLease() {
+	s.mockLeaseRepo.On("Acquire", "admins:ws1", mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(true, nil).Once()
+	s.mockLeaseRepo.On("Release", "admins:ws1", mock.AnythingOfType("string")).Return(nil).Once()
+}
+
+func TestUserAdminUsecase(t *testing.T) {
+	suite.Run(t, new(UserAdminUsecaseTestSuite))
+}
+
+func (s *UserAdminUsecaseTestSuite) TestListUsers_ClampsPageSize() {
+	query := domain.UserQuery{WorkspaceID: "ws1", Search: "ali", Page: 1, PerPage: 100}
+	s.mockUserRepo.On("List", query).Return([]*domain.User{{ID: "u1", Username: "alice"}}, int64(101), nil).Once()
+
+	page, err := s.usecase.ListUsers(s.helpdesk, domain.UserQuery{WorkspaceID: "ws2", Search: "ali", PerPage: 500})
+
+	s.Require().NoError(err)
+	s.Assert().Equal(int64(101), page.Total)
+	s.Assert().Equal(1, page.Page)
+	s.Assert().Equal(100, page.PerPage)
+	s.Assert().Len(page.Users, 1)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestGetUser_OnlyMembers() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws2", domain.RoleAdmin)}, nil).Once()
+
+	_, err := s.usecase.GetUser(s.helpdesk, "u1")
+
+	s.Assert().ErrorIs(err, errs.ErrUserNotFound)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestUpdateUser_DisablesAndRenames() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	s.mockUserRepo.On("CheckUsername", "alicia").Return(false, nil).Once()
+	s.mockUserRepo.On("SetUsername", "u1", "alicia").Return(nil).Once()
+	s.mockUserRepo.On("SetDisabled", "ws1", "u1", true).Return(nil).Once()
+
+	username, disabled := "alicia", true
+	user, err := s.usecase.UpdateUser(s.helpdesk, "u1", domain.UserUpdate{Username: &username, Disabled: &disabled})
+
+	s.Require().NoError(err)
+	s.Assert().Equal("alicia", user.Username)
+	s.Assert().True(user.Disabled)
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *UserAdminUsecaseTestSuite) TestUpdateUser_ChangesCaseOfUsername() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	s.mockUserRepo.On("SetUsername", "u1", "Alice").Return(nil).Once()
+
+	username := "Alice"
+	user, err := s.usecase.UpdateUser(s.helpdesk, "u1", domain.UserUpdate{Username: &username})
+
+	s.Require().NoError(err)
+	s.Assert().Equal("Alice", user.Username)
+	s.mockUserRepo.AssertNotCalled(s.T(), "CheckUsername", mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestUpdateUser_CannotRenameMembersOfOtherWorkspaces() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "alice", Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleUser},
+		{WorkspaceID: "ws2", Role: domain.RoleAdmin},
+	}}, nil).Once()
+
+	username := "alicia"
+	_, err := s.usecase.UpdateUser(s.helpdesk, "u1", domain.UserUpdate{Username: &username})
+
+	s.Assert().ErrorIs(err, errs.ErrForbidden)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetUsername", mock.Anything, mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestUpdateUser_UsernameTaken() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "alice", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	s.mockUserRepo.On("CheckUsername", "bob").Return(true, nil).Once()
+
+	username, disabled := "bob", true
+	_, err := s.usecase.UpdateUser(s.helpdesk, "u1", domain.UserUpdate{Username: &username, Disabled: &disabled})
+
+	s.Assert().ErrorIs(err, errs.ErrUsernameExists)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetDisabled", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestUpdateUser_CannotDisableLastAdmin() {
+	s.mockUserRepo.On("GetByID", "a1").Return(s.admin, nil).Once()
+	s.expectAdminLease()
+	s.mockUserRepo.On("GetByRole", "ws1", domain.RoleAdmin).Return([]*domain.User{s.admin}, nil).Once()
+
+	disabled := true
+	_, err := s.usecase.UpdateUser(s.admin, "a1", domain.UserUpdate{Disabled: &disabled})
+
+	s.Assert().ErrorIs(err, errs.ErrLastAdmin)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetDisabled", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestDeleteUser_AnotherAdminRemains() {
+	s.mockUserRepo.On("GetByID", "a2").Return(&domain.User{ID: "a2", Username: "root", Memberships: memberOf("ws1", domain.RoleAdmin)}, nil).Once()
+	s.expectAdminLease()
+	s.mockUserRepo.On("GetByRole", "ws1", domain.RoleAdmin).Return([]*domain.User{s.admin, {ID: "a2", Role: domain.RoleAdmin}}, nil).Once()
+	s.mockUserRepo.On("Delete", "a2").Return(nil).Once()
+
+	s.Require().NoError(s.usecase.DeleteUser(s.admin, "a2"))
+	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockLeaseRepo.AssertExpectations(s.T())
+}
+
+func (s *UserAdminUsecaseTestSuite) TestDeleteUser_KeepsAccountWithOtherWorkspaces() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "alice", Memberships: []domain.Membership{
+		{WorkspaceID: "ws2", Role: domain.RoleAdmin},
+		{WorkspaceID: "ws1", Role: domain.RoleUser},
+	}}, nil).Once()
+	s.mockUserRepo.On("RemoveMembership", "ws1", "u1").Return(nil).Once()
+
+	s.Require().NoError(s.usecase.DeleteUser(s.helpdesk, "u1"))
+	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockUserRepo.AssertNotCalled(s.T(), "Delete", mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestDeleteUser_NeedsTheUsersPermissions() {
+	s.mockUserRepo.On("GetByID", "a1").Return(s.admin, nil).Once()
+
+	err := s.usecase.DeleteUser(s.helpdesk, "a1")
+
+	s.Assert().ErrorIs(err, errs.ErrForbidden)
+	s.mockUserRepo.AssertNotCalled(s.T(), "Delete", mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestDemote_LastAdmin() {
+	s.mockUserRepo.On("GetByID", "a1").Return(s.admin, nil).Once()
+	s.expectAdminLease()
+	s.mockUserRepo.On("GetByRole", "ws1", domain.RoleAdmin).Return([]*domain.User{s.admin}, nil).Once()
+
+	err := s.usecase.Demote(s.admin, "a1")
+
+	s.Assert().ErrorIs(err, errs.ErrLastAdmin)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestDemote_WhileAnotherAdminChanges() {
+	s.mockUserRepo.On("GetByID", "a1").Return(s.admin, nil).Once()
+	s.mockLeaseRepo.On("Acquire", "admins:ws1", mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(false, nil).Once()
+
+	err := s.usecase.Demote(s.admin, "a1")
+
+	s.Assert().ErrorIs(err, errs.ErrAdminChangeInProgress)
+	s.mockUserRepo.AssertNotCalled(s.T(), "GetByRole", mock.Anything, mock.Anything)
+	s.mockUserRepo.AssertNotCalled(s.T(), "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UserAdminUsecaseTestSuite) TestCreateInvitation() {
+	s.mockInviteRepo.On("Create", mock.MatchedBy(func(i *domain.Invitation) bool {
+		return i.WorkspaceID == "ws1" && i.Role == domain.RoleUser && i.CreatedBy == "h1" &&
+			i.TokenHash != "" && i.ExpiresAt.After(i.CreatedAt)
+	})).Return(nil).Once()
+
+	token, err := s.usecase.CreateInvitation(s.helpdesk, &domain.Invitation{})
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(token)
+	s.mockInviteRepo.AssertExpectations(s.T())
+}
+
+func (s *UserAdminUsecaseTestSuite) TestCreateInvitation_NeedsTheRolesPermissions() {
+	_, err := s.usecase.CreateInvitation(s.helpdesk, &domain.Invitation{Role: domain.RoleAdmin})
+
+	s.Assert().ErrorIs(err, errs.ErrForbidden)
+	s.mockInviteRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
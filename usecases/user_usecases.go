@@ -1,20 +1,86 @@
 package usecases
 
 import (
+	"errors"
 	"log"
+	"strings"
 	"task-manager/domain"
 	"task-manager/errs"
+	"time"
 )
 
 type UserUsecase interface {
-	Register(user *domain.User) error
-	Login(username, password string) (string, error)
-	Promote(userID string) error
+	// Register creates an account, as the registration mode allows. With
+	// an invitation, the user joins the workspace it is for.
+	Register(user *domain.User, invitation string) error
+	// RequestInvitation emails an invitation to register to an address at
+	// one of the allowed domains.
+	RequestInvitation(email string) error
+	// Bootstrap creates the first admin. It returns ErrAlreadyBootstrapped
+	// once there are users.
+	Bootstrap(user *domain.User, workspaceName string) error
+	// Login checks the credentials and returns a token, or a challenge for
+	// users with MFA. login is the username or a verified email address,
+	// and ip the client address, which is locked out separately from the
+	// account.
+	Login(login, password, ip string) (*domain.LoginResult, error)
+	// VerifyMFALogin exchanges a login challenge and a TOTP or recovery
+	// code for a token.
+	VerifyMFALogin(challenge, code, ip string) (string, error)
+	// Promote makes a member of a workspace an admin there.
+	Promote(workspaceID, userID string) error
+	// UnlockUser unlocks a member of the workspace.
+	UnlockUser(workspaceID, userID string) error
+	UnlockIP(ip string) error
 	GetUserByID(id string) (*domain.User, error)
+	CreateCalendarToken(workspaceID, userID string) (string, error)
+	RevokeCalendarToken(workspaceID, userID string) error
+	GetUserByCalendarToken(token string) (*domain.User, error)
+
+	StartMFAEnrollment(userID string) (*domain.MFAEnrollment, error)
+	// ConfirmMFAEnrollment turns MFA on and returns the recovery codes,
+	// which cannot be retrieved again.
+	ConfirmMFAEnrollment(userID, code string) ([]string, error)
+	DisableMFA(userID, code string) error
+	RegenerateRecoveryCodes(userID, code string) ([]string, error)
+	// MFAEnrollmentRequired tells whether user, whose role has admin
+	// permissions, must enable MFA before using routes that need more than
+	// reading tasks.
+	MFAEnrollmentRequired(user *domain.User) (bool, error)
+	GetSecuritySettings(workspaceID string) (*domain.SecuritySettings, error)
+	UpdateSecuritySettings(workspaceID string, settings *domain.SecuritySettings) error
+
+	// ChangePassword signs out every session of the user and returns a
+	// token for the current one, in the user's active workspace.
+	ChangePassword(user *domain.User, currentPassword, newPassword string) (string, error)
+	// RequestPasswordReset sends a reset token to the verified email of the
+	// user. Unknown usernames and users without a verified email are not
+	// reported, so that they cannot be probed.
+	RequestPasswordReset(username string) error
+	ResetPassword(token, newPassword string) error
+
+	// CreateAPIToken returns the secret of a new token, which cannot be
+	// retrieved again. The token acts in token.WorkspaceID.
+	CreateAPIToken(userID string, token *domain.APIToken) (string, error)
+	ListAPITokens(userID string) ([]*domain.APIToken, error)
+	RevokeAPIToken(userID, tokenID string) error
+	AuthenticateAPIToken(secret string) (*domain.User, *domain.APIToken, error)
+
+	// UpdateProfile changes the user's own profile and returns the user.
+	UpdateProfile(userID string, update domain.ProfileUpdate) (*domain.User, error)
 }
 
 type JWTService interface {
 	GenerateJWT(*domain.User) (string, error)
+	GenerateMFAChallenge(*domain.User) (string, error)
+	ParseMFAChallenge(challenge string) (userID string, err error)
+}
+
+// SigningKeyRepository shares the JWT signing keys between replicas.
+type SigningKeyRepository interface {
+	Create(key *domain.SigningKey) error
+	// List returns the keys that have not expired at now.
+	List(now time.Time) ([]domain.SigningKey, error)
 }
 
 type PasswordService interface {
@@ -24,80 +90,192 @@ type PasswordService interface {
 
 // UserRepository defines the interface for user data operations.
 type UserRepository interface {
+	// Create stores a new user along with their memberships. It returns
+	// ErrUsernameExists if another user has the username, even one created
+	// at the same moment, and ErrEmailExists if another user verified the
+	// same email address.
 	Create(user *domain.User) error
+	// CreateFirst creates a user only if there are none yet, and returns
+	// ErrAlreadyBootstrapped otherwise. Of two calls at the same moment,
+	// only one succeeds.
+	CreateFirst(user *domain.User) error
+	// GetByUsername finds the user whose username has the same canonical
+	// form as username, see domain.Canonical.
 	GetByUsername(username string) (*domain.User, error)
+	// GetByVerifiedEmail finds the user who verified email, compared like
+	// usernames, or returns ErrUserNotFound.
+	GetByVerifiedEmail(email string) (*domain.User, error)
 	GetByID(id string) (*domain.User, error)
-	UpdateUserStatus(id string) error
-	Count() (int64, error)
+	// GetByRole returns the members of a workspace with role, activated in
+	// that workspace.
+	GetByRole(workspaceID, role string) ([]*domain.User, error)
+	// SetRole changes the role of a member of a workspace. It returns
+	// ErrUserNotFound if the user is not a member.
+	SetRole(workspaceID, id, role string) error
+	// CheckUsername tells whether a user has a username with the same
+	// canonical form.
 	CheckUsername(username string) (exist bool, err error)
+	SetCalendarToken(workspaceID, id, tokenHash string) error
+	// GetByCalendarToken finds the user with a membership whose calendar
+	// token has tokenHash.
+	GetByCalendarToken(tokenHash string) (*domain.User, error)
+	SetMFA(id string, mfa domain.MFA) error
+	// AdvanceMFAStep records a used TOTP step, reporting false if it is not
+	// later than the last one.
+	AdvanceMFAStep(id string, step int64) (bool, error)
+	// UseRecoveryCode removes a recovery code hash, reporting false if it
+	// was not there.
+	UseRecoveryCode(id, codeHash string) (bool, error)
+	// UpdatePassword stores a new password hash and increments the user's
+	// token version.
+	UpdatePassword(id, passwordHash string) error
+	// GetByIdentity finds the user an external identity is linked to, or
+	// returns ErrUserNotFound.
+	GetByIdentity(issuer, subject string) (*domain.User, error)
+	// LinkIdentity adds an external identity to a user. It returns
+	// ErrIdentityConflict if another user has it.
+	LinkIdentity(id string, identity domain.ExternalIdentity) error
+	// List returns a page of the members of query.WorkspaceID matching
+	// query, activated in that workspace, and how many match in total.
+	List(query domain.UserQuery) ([]*domain.User, int64, error)
+	// SetUsername returns ErrUsernameExists if another user has username.
+	SetUsername(id, username string) error
+	// SetDisabled disables or enables a member of a workspace.
+	SetDisabled(workspaceID, id string, disabled bool) error
+	Delete(id string) error
+	// SetProfile replaces the user's profile. It returns ErrEmailExists if
+	// the email address is verified and another user verified it too.
+	SetProfile(id string, profile domain.Profile) error
+	// AddMembership returns ErrAlreadyMember if the user is a member of
+	// the workspace.
+	AddMembership(id string, membership domain.Membership) error
+	// RemoveMembership returns ErrUserNotFound if the user is not a member
+	// of the workspace.
+	RemoveMembership(workspaceID, id string) error
+}
+
+// SettingsRepository stores the settings admins change at runtime, which
+// each workspace has its own of.
+type SettingsRepository interface {
+	GetSecuritySettings(workspaceID string) (*domain.SecuritySettings, error)
+	SaveSecuritySettings(workspaceID string, settings *domain.SecuritySettings) error
 }
 
 type userUsecase struct {
-	userRepo    UserRepository
-	passwordSvc PasswordService
-	jwtSvc      JWTService
+	userRepo       UserRepository
+	workspaceRepo  WorkspaceRepository
+	attemptRepo    LoginAttemptRepository
+	settingsRepo   SettingsRepository
+	resetRepo      PasswordResetRepository
+	apiTokenRepo   APITokenRepository
+	invitationRepo InvitationRepository
+	passwordSvc    PasswordService
+	jwtSvc         JWTService
+	otpSvc         OTPService
+	notifier       Notifier
+	breached       BreachedPasswordChecker
+	config         AuthConfig
 }
 
-func NewUserUsecase(ur UserRepository, ps PasswordService, js JWTService) UserUsecase {
+// NewUserUsecase creates the user usecase. bc may be nil to skip the
+// breached-password check.
+func NewUserUsecase(ur UserRepository, wr WorkspaceRepository, ar LoginAttemptRepository, sr SettingsRepository, rr PasswordResetRepository, tr APITokenRepository, ir InvitationRepository, ps PasswordService, js JWTService, otp OTPService, n Notifier, bc BreachedPasswordChecker, config AuthConfig) UserUsecase {
 	return &userUsecase{
-		userRepo:    ur,
-		passwordSvc: ps,
-		jwtSvc:      js,
+		userRepo:       ur,
+		workspaceRepo:  wr,
+		attemptRepo:    ar,
+		settingsRepo:   sr,
+		resetRepo:      rr,
+		apiTokenRepo:   tr,
+		invitationRepo: ir,
+		passwordSvc:    ps,
+		jwtSvc:         js,
+		otpSvc:         otp,
+		notifier:       n,
+		breached:       bc,
+		config:         config,
 	}
 }
 
-func (u *userUsecase) Register(user *domain.User) error {
-	// check if username already exists
-	exist, err := u.userRepo.CheckUsername(user.Username)
-	if err != nil {
-		return err
+func (u *userUsecase) Login(login, password, ip string) (*domain.LoginResult, error) {
+
+	log.Printf("INFO: Login attempt for username: '%s'", login)
+
+	now := time.Now()
+	ip = normalizeIP(ip)
+	if ip != "" {
+		if err := u.checkLocked(ipLockoutKey(ip), u.config.IPLockout, errs.ErrTooManyLoginFailures, now); err != nil {
+			return nil, err
+		}
 	}
-	if exist {
-		return errs.ErrUsernameExists
+	if err := u.checkLocked(accountLockoutKey(login), u.config.AccountLockout, errs.ErrAccountLocked, now); err != nil {
+		log.Printf("WARN: Login refused for username '%s': account is locked", login)
+		return nil, err
 	}
 
-	hashedPassword, err := u.passwordSvc.Hash(user.Password)
+	user, err := u.findLogin(login)
 	if err != nil {
-		return err
+		// guesses at unknown usernames count against the address too
+		if errors.Is(err, errs.ErrUserNotFound) {
+			if err := u.loginFailed(login, ip, now); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	// logging in with the email address does not get around the lockout
+	// of the account
+	if domain.Canonical(login) != domain.Canonical(user.Username) {
+		if err := u.checkLocked(accountLockoutKey(user.Username), u.config.AccountLockout, errs.ErrAccountLocked, now); err != nil {
+			log.Printf("WARN: Login refused for username '%s': account is locked", user.Username)
+			return nil, err
+		}
 	}
-	user.PasswordHash = hashedPassword
 
-	// For the requirement: "If the database is empty, the first created user will be an admin."
-	count, err := u.userRepo.Count()
+	err = u.passwordSvc.Compare(user.PasswordHash, password)
 	if err != nil {
-		return err
-	}
-	if count == 0 {
-		user.Role = domain.RoleAdmin
-	} else {
-		user.Role = domain.RoleUser
+		log.Printf("WARN: Login failed for username '%s': invalid password", user.Username)
+		if err := u.loginFailed(user.Username, ip, now); err != nil {
+			return nil, err
+		}
+		return nil, err
 	}
 
-	err = u.userRepo.Create(user)
-	if err != nil {
-		return err
+	if err := enterWorkspace(user); err != nil {
+		log.Printf("WARN: Login refused for username '%s': %v", user.Username, err)
+		return nil, err
 	}
-	return nil
-}
 
-func (u *userUsecase) Login(username, password string) (string, error) {
+	// failed logins are only forgotten once every factor has passed
+	if user.MFA.Enabled {
+		log.Printf("INFO: User '%s' passed the password step, awaiting a second factor", user.Username)
+		challenge, err := u.jwtSvc.GenerateMFAChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.LoginResult{ChallengeToken: challenge}, nil
+	}
+	if err := u.loginSucceeded(user.Username); err != nil {
+		return nil, err
+	}
 
-	log.Printf("INFO: Login attempt for username: '%s'", username)
+	log.Printf("INFO: User '%s' (ID: %s, Role: %s) successfully authenticated", user.Username, user.ID, user.Role)
 
-	user, err := u.userRepo.GetByUsername(username)
+	token, err := u.jwtSvc.GenerateJWT(user)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return &domain.LoginResult{Token: token}, nil
+}
 
-	err = u.passwordSvc.Compare(user.PasswordHash, password)
-	if err != nil {
-		log.Printf("WARN: Login failed for username '%s': invalid password", username)
-		return "", err
+// findLogin finds the user with the username login, or else the one who
+// verified it as their email address.
+func (u *userUsecase) findLogin(login string) (*domain.User, error) {
+	user, err := u.userRepo.GetByUsername(login)
+	if !errors.Is(err, errs.ErrUserNotFound) || !strings.Contains(login, "@") {
+		return user, err
 	}
-
-	log.Printf("INFO: User '%s' (ID: %s, Role: %s) successfully authenticated", user.Username, user.ID, user.Role)
-
-	return u.jwtSvc.GenerateJWT(user)
+	return u.userRepo.GetByVerifiedEmail(login)
 }
 
 // This function will be called by the auth middleware
@@ -110,9 +288,9 @@ func (u *userUsecase) GetUserByID(id string) (*domain.User, error) {
 	return user, nil
 }
 
-func (u *userUsecase) Promote(userID string) error {
+func (u *userUsecase) Promote(workspaceID, userID string) error {
 	log.Printf("Attempting to promote user in usecase, %v", userID)
-	err := u.userRepo.UpdateUserStatus(userID)
+	err := u.userRepo.SetRole(workspaceID, userID, domain.RoleAdmin)
 	if err != nil {
 		return err
 	}
@@ -1,12 +1,15 @@
 package usecases_test
 
 import (
+	"strings"
 	"task-manager/domain"
 	"task-manager/errs"
 	"task-manager/infrastructure"
 	"task-manager/repositories/mocks"
 	"task-manager/usecases"
+	usecaseMocks "task-manager/usecases/mocks"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -14,18 +17,83 @@ import (
 
 type UserUsecaseTestSuite struct {
 	suite.Suite
-	mockUserRepo *mocks.UserRepository
+	mockUserRepo      *mocks.UserRepository
+	mockWorkspaceRepo *mocks.WorkspaceRepository
+	mockAttemptRepo   *mocks.LoginAttemptRepository
+	mockSettingsRepo  *mocks.SettingsRepository
+	mockResetRepo     *mocks.PasswordResetRepository
+	mockTokenRepo     *mocks.APITokenRepository
+	mockInviteRepo    *mocks.InvitationRepository
+	mockOTP           *usecaseMocks.OTPService
+	mockNotifier      *usecaseMocks.Notifier
 	// TODO: In a full test suite, these would also be mocks.
 	passwordService usecases.PasswordService
 	jwtService      usecases.JWTService
 	userUsecase     usecases.UserUsecase
 }
 
+// breachedList is a BreachedPasswordChecker over a fixed set of passwords.
+type breachedList map[string]bool
+
+func (l breachedList) IsBreached(password string) (bool, error) {
+	return l[password], nil
+}
+
+var testAuthConfig = usecases.AuthConfig{
+	PasswordPolicy: usecases.DefaultPasswordPolicy,
+	AccountLockout: usecases.LockoutPolicy{Threshold: 3, Window: time.Hour, BaseLockout: time.Minute, MaxLockout: 10 * time.Minute},
+	IPLockout:      usecases.LockoutPolicy{Threshold: 10, Window: time.Hour, BaseLockout: time.Minute, MaxLockout: 10 * time.Minute},
+
+	PasswordResetTTL: time.Hour,
+	Registration:     usecases.RegistrationPolicy{Mode: usecases.RegistrationOpen},
+}
+
 func (s *UserUsecaseTestSuite) SetupTest() {
 	s.mockUserRepo = new(mocks.UserRepository)
+	s.mockWorkspaceRepo = new(mocks.WorkspaceRepository)
+	s.mockAttemptRepo = new(mocks.LoginAttemptRepository)
+	s.mockSettingsRepo = new(mocks.SettingsRepository)
+	s.mockResetRepo = new(mocks.PasswordResetRepository)
+	s.mockTokenRepo = new(mocks.APITokenRepository)
+	s.mockInviteRepo = new(mocks.InvitationRepository)
+	s.mockOTP = new(usecaseMocks.OTPService)
+	s.mockNotifier = new(usecaseMocks.Notifier)
 	s.passwordService = infrastructure.NewBcryptService()
-	s.jwtService = infrastructure.NewJWTServiceV5()
-	s.userUsecase = usecases.NewUserUsecase(s.mockUserRepo, s.passwordService, s.jwtService)
+	keys, err := infrastructure.NewKeyring(nil, infrastructure.DefaultKeyringConfig)
+	s.Require().NoError(err)
+	s.jwtService = infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
+	s.configure(testAuthConfig)
+}
+
+// configure rebuilds the usecase with config and the suite's mocks.
+func (s *UserUsecaseTestSuite) configure(config usecases.AuthConfig) {
+	s.userUsecase = usecases.NewUserUsecase(
+		s.mockUserRepo,
+		s.mockWorkspaceRepo,
+		s.mockAttemptRepo,
+		s.mockSettingsRepo,
+		s.mockResetRepo,
+		s.mockTokenRepo,
+		s.mockInviteRepo,
+		s.passwordService,
+		s.jwtService,
+		s.mockOTP,
+		s.mockNotifier,
+		breachedList{"password1": true},
+		config,
+	)
+}
+
+// notLocked expects the lockout checks of a login from ip as username.
+func (s *UserUsecaseTestSuite) notLocked(username, ip string) {
+	s.mockAttemptRepo.On("Get", "ip:"+ip).Return(&domain.LoginAttempts{}, nil).Once()
+	s.mockAttemptRepo.On("Get", "account:"+username).Return(&domain.LoginAttempts{}, nil).Once()
+}
+
+// memberOf returns the memberships of a user who only belongs to
+// workspaceID.
+func memberOf(workspaceID, role string) []domain.Membership {
+	return []domain.Membership{{WorkspaceID: workspaceID, Role: role}}
 }
 
 func TestUserUsecase(t *testing.T) {
@@ -34,56 +102,93 @@ func TestUserUsecase(t *testing.T) {
 
 // Test cases
 
-func (s *UserUsecaseTestSuite) TestRegister_Success_FirstUserIsAdmin() {
+func (s *UserUsecaseTestSuite) TestRegister_CreatesOwnWorkspace() {
 	// Arrange
 	user := &domain.User{
-		Username:     "admin",
-		PasswordHash: "password123",
+		Username: "alice",
+		Password: "password123",
 	}
-	// Use mock.Anything because we don't care about the context value in this test.
 	s.mockUserRepo.On("CheckUsername", user.Username).Return(false, nil)
-	s.mockUserRepo.On("Count").Return(int64(0), nil).Once()
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws1"
+	}).Return(nil).Once()
 	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(nil).Once()
 
 	// Act
-	err := s.userUsecase.Register(user)
+	err := s.userUsecase.Register(user, "")
 
 	// Assert
 	s.Require().NoError(err)
-	s.Assert().Equal(domain.RoleAdmin, user.Role, "The first user should be an admin")
+	s.Assert().Equal("ws1", user.WorkspaceID)
+	s.Assert().Equal(domain.RoleAdmin, user.Role, "Users should be the admin of their own workspace")
+	s.Require().Len(user.Memberships, 1)
+	s.Assert().Equal(domain.RoleAdmin, user.Memberships[0].Role)
 
-	// Verify that the mock methods were called as expected
 	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockWorkspaceRepo.AssertExpectations(s.T())
 }
 
-func (s *UserUsecaseTestSuite) TestRegister_Success_SecondUserIsRegularUser() {
-	user := &domain.User{Username: "testuser", PasswordHash: "password123"}
-	// Arrange
-	s.mockUserRepo.On("Count").Return(int64(1), nil).Once()
+func (s *UserUsecaseTestSuite) TestRegister_DiscardsWorkspaceOnFailure() {
+	user := &domain.User{Username: "alice", Password: "password123"}
 	s.mockUserRepo.On("CheckUsername", user.Username).Return(false, nil)
-	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws1"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("Create", mock.AnythingOfType("*domain.User")).Return(errs.ErrUnexpected).Once()
+	s.mockWorkspaceRepo.On("Delete", "ws1").Return(nil).Once()
 
-	// Act
-	err := s.userUsecase.Register(user)
+	err := s.userUsecase.Register(user, "")
 
-	// Assert
-	s.Require().NoError(err)
-	s.Assert().Equal(domain.RoleUser, user.Role, "Subsequent users should have the 'user' role")
-	s.mockUserRepo.AssertExpectations(s.T())
+	s.Require().ErrorIs(err, errs.ErrUnexpected)
+	s.mockWorkspaceRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestRegister_PasswordPolicy() {
+	cases := map[string]struct {
+		username, password string
+		codes              []string
+	}{
+		"too short":        {"testuser", "abc1", []string{"min"}},
+		"one class":        {"testuser", "abcdefghij", []string{"classes"}},
+		"same as username": {"Testuser1", "testuser1", []string{"same_as_username"}},
+		"too long":         {"testuser", strings.Repeat("a1", 37), []string{"max"}},
+		"breached":         {"testuser", "password1", []string{"breached"}},
+	}
+	for name, tc := range cases {
+		s.Run(name, func() {
+			err := s.userUsecase.Register(&domain.User{Username: tc.username, Password: tc.password}, "")
+
+			var validationErr *errs.ValidationError
+			s.Require().ErrorAs(err, &validationErr)
+			var codes []string
+			for _, f := range validationErr.Fields {
+				s.Assert().Equal("password", f.Field)
+				codes = append(codes, f.Code)
+			}
+			s.Assert().Equal(tc.codes, codes)
+		})
+	}
+	// nothing is looked up or stored for a rejected password
+	s.mockUserRepo.AssertNotCalled(s.T(), "CheckUsername", mock.Anything)
+	s.mockUserRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
 }
 
 func (s *UserUsecaseTestSuite) TestLogin_Failure_UserNotFound() {
+	s.notLocked("nonexistent", "203.0.113.7")
 	// We configure the mock to return our specific application error
 	s.mockUserRepo.On("GetByUsername", "nonexistent").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockAttemptRepo.On("RecordFailure", "account:nonexistent", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
+	s.mockAttemptRepo.On("RecordFailure", "ip:203.0.113.7", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
 
 	// Act
-	token, err := s.userUsecase.Login("nonexistent", "password")
+	result, err := s.userUsecase.Login("nonexistent", "password", "203.0.113.7")
 
 	// Assert
 	s.Require().Error(err, "Expected an error for non-existent user")
 	s.Assert().Equal(errs.ErrUserNotFound, err, "Error should be ErrInvalidUserId")
-	s.Assert().Empty(token, "Token should be empty on failure")
+	s.Assert().Nil(result, "No token should be returned on failure")
 	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockAttemptRepo.AssertExpectations(s.T())
 }
 
 func (s *UserUsecaseTestSuite) TestLogin_Failure_WrongPassword() {
@@ -91,31 +196,190 @@ func (s *UserUsecaseTestSuite) TestLogin_Failure_WrongPassword() {
 	hashedPassword, _ := s.passwordService.Hash("correctpassword")
 	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword}
 
+	s.notLocked("testuser", "203.0.113.7")
 	s.mockUserRepo.On("GetByUsername", "testuser").Return(mockUser, nil).Once()
+	s.mockAttemptRepo.On("RecordFailure", "account:testuser", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
+	s.mockAttemptRepo.On("RecordFailure", "ip:203.0.113.7", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
 
 	// Act
-	token, err := s.userUsecase.Login("testuser", "wrongpassword")
+	result, err := s.userUsecase.Login("testuser", "wrongpassword", "203.0.113.7")
 
 	// Assert
 	s.Require().Error(err, "Expected an error for wrong password")
 	s.Assert().Equal(errs.ErrIncorrectPassword, err, "Error should be ErrIncorrectPassword")
-	s.Assert().Empty(token, "Token should be empty on failure")
+	s.Assert().Nil(result, "No token should be returned on failure")
 	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockAttemptRepo.AssertExpectations(s.T())
 }
 
 func (s *UserUsecaseTestSuite) TestLogin_Success() {
 	hashedPassword, _ := s.passwordService.Hash("correctpassword")
-	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword}
+	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword, Memberships: memberOf("ws1", domain.RoleUser)}
 
+	s.notLocked("testuser", "203.0.113.7")
 	s.mockUserRepo.On("GetByUsername", "testuser").Return(mockUser, nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:testuser").Return(nil).Once()
 
 	// Act
-	token, err := s.userUsecase.Login("testuser", "correctpassword")
+	result, err := s.userUsecase.Login("testuser", "correctpassword", "203.0.113.7")
 
 	// Assert
 	s.Require().NoError(err)
-	s.Assert().NotEmpty(token, "A JWT token should be returned on successful login")
+	s.Assert().NotEmpty(result.Token, "A JWT token should be returned on successful login")
+	s.Assert().Empty(result.ChallengeToken)
+	s.Assert().Equal("ws1", mockUser.WorkspaceID, "Logins should start in the user's workspace")
 	s.mockUserRepo.AssertExpectations(s.T())
+	s.mockAttemptRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_Disabled() {
+	hashedPassword, _ := s.passwordService.Hash("correctpassword")
+	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword, Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleUser, Disabled: true},
+	}}
+
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockUserRepo.On("GetByUsername", "testuser").Return(mockUser, nil).Once()
+
+	result, err := s.userUsecase.Login("testuser", "correctpassword", "203.0.113.7")
+
+	s.Assert().ErrorIs(err, errs.ErrAccountDisabled)
+	s.Assert().Nil(result)
+	s.mockAttemptRepo.AssertNotCalled(s.T(), "Reset", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_StartsInFirstEnabledWorkspace() {
+	hashedPassword, _ := s.passwordService.Hash("correctpassword")
+	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword, Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleAdmin, Disabled: true},
+		{WorkspaceID: "ws2", Role: domain.RoleUser},
+	}}
+
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockUserRepo.On("GetByUsername", "testuser").Return(mockUser, nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:testuser").Return(nil).Once()
+
+	_, err := s.userUsecase.Login("testuser", "correctpassword", "203.0.113.7")
+
+	s.Require().NoError(err)
+	s.Assert().Equal("ws2", mockUser.WorkspaceID)
+	s.Assert().Equal(domain.RoleUser, mockUser.Role)
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_NoWorkspace() {
+	hashedPassword, _ := s.passwordService.Hash("correctpassword")
+	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword}
+
+	s.notLocked("testuser", "203.0.113.7")
+	s.mockUserRepo.On("GetByUsername", "testuser").Return(mockUser, nil).Once()
+
+	_, err := s.userUsecase.Login("testuser", "correctpassword", "203.0.113.7")
+
+	s.Assert().ErrorIs(err, errs.ErrNoWorkspace)
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_LockoutGrowsWithEachFailure() {
+	hashedPassword, _ := s.passwordService.Hash("correctpassword")
+	mockUser := &domain.User{Username: "testuser", PasswordHash: hashedPassword}
+
+	// the 3rd failure locks for a minute, each later one doubles that up to the cap
+	for failures, lockout := range map[int]time.Duration{3: time.Minute, 4: 2 * time.Minute, 6: 8 * time.Minute, 9: 10 * time.Minute} {
+		s.Run(lockout.String(), func() {
+			s.SetupTest()
+			s.notLocked("testuser", "203.0.113.7")
+			s.mockUserRepo.On("GetByUsername", "testuser").Return(mockUser, nil).Once()
+			s.mockAttemptRepo.On("RecordFailure", "account:testuser", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: failures}, nil).Once()
+			s.mockAttemptRepo.On("RecordFailure", "ip:203.0.113.7", mock.Anything, time.Hour).Return(&domain.LoginAttempts{Failures: 1}, nil).Once()
+
+			before := time.Now()
+			s.mockAttemptRepo.On("Lock", "account:testuser", mock.MatchedBy(func(until time.Time) bool {
+				return !until.Before(before.Add(lockout)) && until.Before(before.Add(lockout+time.Minute))
+			})).Return(nil).Once()
+
+			_, err := s.userUsecase.Login("testuser", "wrongpassword", "203.0.113.7")
+
+			s.Assert().ErrorIs(err, errs.ErrIncorrectPassword)
+			s.mockAttemptRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_AccountLocked() {
+	s.mockAttemptRepo.On("Get", "ip:203.0.113.7").Return(&domain.LoginAttempts{}, nil).Once()
+	s.mockAttemptRepo.On("Get", "account:testuser").Return(&domain.LoginAttempts{LockedUntil: time.Now().Add(5 * time.Minute)}, nil).Once()
+
+	_, err := s.userUsecase.Login("testuser", "correctpassword", "203.0.113.7")
+
+	s.Require().ErrorIs(err, errs.ErrAccountLocked)
+	var retryErr *errs.RetryError
+	s.Require().ErrorAs(err, &retryErr)
+	s.Assert().InDelta(5*time.Minute, retryErr.After, float64(time.Second))
+	// a locked account does not even check the password
+	s.mockUserRepo.AssertNotCalled(s.T(), "GetByUsername", mock.Anything)
+	s.mockAttemptRepo.AssertNotCalled(s.T(), "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_WithVerifiedEmail() {
+	hashedPassword, _ := s.passwordService.Hash("correctpassword")
+	mockUser := &domain.User{Username: "Alice", PasswordHash: hashedPassword, Memberships: memberOf("ws1", domain.RoleUser)}
+
+	s.notLocked("alice@example.com", "203.0.113.7")
+	s.mockUserRepo.On("GetByUsername", "Alice@Example.com").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("GetByVerifiedEmail", "Alice@Example.com").Return(mockUser, nil).Once()
+	s.mockAttemptRepo.On("Get", "account:alice").Return(&domain.LoginAttempts{}, nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:alice").Return(nil).Once()
+
+	result, err := s.userUsecase.Login("Alice@Example.com", "correctpassword", "203.0.113.7")
+
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(result.Token)
+	s.mockAttemptRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_WithEmailOfLockedAccount() {
+	s.notLocked("alice@example.com", "203.0.113.7")
+	s.mockUserRepo.On("GetByUsername", "alice@example.com").Return(nil, errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("GetByVerifiedEmail", "alice@example.com").Return(&domain.User{Username: "alice"}, nil).Once()
+	s.mockAttemptRepo.On("Get", "account:alice").Return(&domain.LoginAttempts{LockedUntil: time.Now().Add(time.Minute)}, nil).Once()
+
+	_, err := s.userUsecase.Login("alice@example.com", "correctpassword", "203.0.113.7")
+
+	s.Require().ErrorIs(err, errs.ErrAccountLocked)
+	s.mockAttemptRepo.AssertNotCalled(s.T(), "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestLogin_IPLocked() {
+	s.mockAttemptRepo.On("Get", "ip:2001:db8::1").Return(&domain.LoginAttempts{LockedUntil: time.Now().Add(time.Minute)}, nil).Once()
+
+	_, err := s.userUsecase.Login("anyone", "whatever", "2001:0db8:0000::1")
+
+	s.Require().ErrorIs(err, errs.ErrTooManyLoginFailures)
+	s.mockAttemptRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestUnlockUser() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "testuser", Memberships: memberOf("ws1", domain.RoleUser)}, nil).Once()
+	s.mockAttemptRepo.On("Reset", "account:testuser").Return(nil).Once()
+
+	s.Require().NoError(s.userUsecase.UnlockUser("ws1", "u1"))
+	s.mockAttemptRepo.AssertExpectations(s.T())
+}
+
+func (s *UserUsecaseTestSuite) TestUnlockUser_OtherWorkspace() {
+	s.mockUserRepo.On("GetByID", "u1").Return(&domain.User{ID: "u1", Username: "testuser", Memberships: memberOf("ws2", domain.RoleUser)}, nil).Once()
+
+	err := s.userUsecase.UnlockUser("ws1", "u1")
+
+	s.Assert().ErrorIs(err, errs.ErrUserNotFound)
+	s.mockAttemptRepo.AssertNotCalled(s.T(), "Reset", mock.Anything)
+}
+
+func (s *UserUsecaseTestSuite) TestUnlockIP() {
+	s.mockAttemptRepo.On("Reset", "ip:203.0.113.7").Return(nil).Once()
+
+	s.Require().NoError(s.userUsecase.UnlockIP("203.0.113.7"))
+	s.Assert().ErrorIs(s.userUsecase.UnlockIP("not-an-ip"), errs.ErrValidation)
+	s.mockAttemptRepo.AssertExpectations(s.T())
 }
 
 func (s *UserUsecaseTestSuite) TestGetUserByID_Success() {
@@ -156,10 +420,10 @@ func (s *UserUsecaseTestSuite) TestPromote_Success() {
 	// Arrange
 	userIDToPromote := "user_to_promote_id"
 
-	s.mockUserRepo.On("UpdateUserStatus", userIDToPromote).Return(nil).Once()
+	s.mockUserRepo.On("SetRole", "ws1", userIDToPromote, domain.RoleAdmin).Return(nil).Once()
 
 	// Act
-	err := s.userUsecase.Promote(userIDToPromote)
+	err := s.userUsecase.Promote("ws1", userIDToPromote)
 
 	// Assert
 	s.Require().NoError(err, "Promote should not return an error on success")
@@ -169,10 +433,10 @@ func (s *UserUsecaseTestSuite) TestPromote_Success() {
 func (s *UserUsecaseTestSuite) TestPromote_UserNotFound() {
 	// Arrange
 	nonExistentID := "non_existent_id"
-	s.mockUserRepo.On("UpdateUserStatus", nonExistentID).Return(errs.ErrUserNotFound).Once()
+	s.mockUserRepo.On("SetRole", "ws1", nonExistentID, domain.RoleAdmin).Return(errs.ErrUserNotFound).Once()
 
 	// Act
-	err := s.userUsecase.Promote(nonExistentID)
+	err := s.userUsecase.Promote("ws1", nonExistentID)
 
 	// Assert
 	s.Require().Error(err, "Promote should return an error if the user is not found")
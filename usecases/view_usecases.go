@@ -0,0 +1,142 @@
+package usecases
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+type ViewUsecase interface {
+	CreateView(owner *domain.User, view *domain.View) (*domain.View, error)
+	GetViews(user *domain.User) ([]*domain.View, error)
+	GetView(user *domain.User, id string) (*domain.View, error)
+	UpdateView(user *domain.User, id string, updatedView domain.View) (*domain.View, error)
+	DeleteView(user *domain.User, id string) error
+	GetViewTasks(user *domain.User, id string) ([]*domain.Task, error)
+}
+
+// ViewRepository defines the interface for saved view data operations.
+type ViewRepository interface {
+	Create(view *domain.View) (*domain.View, error)
+	GetByID(id string) (*domain.View, error)
+	// GetVisibleTo returns the views of the workspace owned by or shared
+	// with the user.
+	GetVisibleTo(workspaceID, userID string) ([]*domain.View, error)
+	Update(id string, updatedView domain.View) (*domain.View, error)
+	Delete(id string) error
+}
+
+type viewUsecase struct {
+	viewRepo ViewRepository
+	taskRepo TaskRepository
+	policies PolicySource
+}
+
+// NewViewUsecase creates the saved view usecase. ps may be nil when there
+// are no access policies.
+func NewViewUsecase(vr ViewRepository, tr TaskRepository, ps PolicySource) ViewUsecase {
+	return &viewUsecase{
+		viewRepo: vr,
+		taskRepo: tr,
+		policies: ps,
+	}
+}
+
+func (vs *viewUsecase) CreateView(owner *domain.User, view *domain.View) (*domain.View, error) {
+	view.Name = strings.TrimSpace(view.Name)
+	if view.Name == "" {
+		return nil, fmt.Errorf("%w: view name is required", errs.ErrInvalidQuery)
+	}
+	if _, err := ParseFilterQuery(view.Query); err != nil {
+		return nil, err
+	}
+
+	view.WorkspaceID = owner.WorkspaceID
+	view.OwnerID = owner.ID
+	view.SharedWith = withoutUser(view.SharedWith, owner.ID)
+	view.CreatedAt = time.Now()
+	return vs.viewRepo.Create(view)
+}
+
+func (vs *viewUsecase) GetViews(user *domain.User) ([]*domain.View, error) {
+	return vs.viewRepo.GetVisibleTo(user.WorkspaceID, user.ID)
+}
+
+func (vs *viewUsecase) GetView(user *domain.User, id string) (*domain.View, error) {
+	view, err := vs.viewRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	// views of other workspaces and views that are not shared with the user
+	// are reported as missing so that their existence is not leaked
+	if view.WorkspaceID != user.WorkspaceID {
+		return nil, errs.ErrViewNotFound
+	}
+	if view.OwnerID != user.ID && !slices.Contains(view.SharedWith, user.ID) {
+		return nil, errs.ErrViewNotFound
+	}
+	return view, nil
+}
+
+func (vs *viewUsecase) UpdateView(user *domain.User, id string, updatedView domain.View) (*domain.View, error) {
+	view, err := vs.GetView(user, id)
+	if err != nil {
+		return nil, err
+	}
+	if view.OwnerID != user.ID {
+		return nil, errs.ErrForbidden
+	}
+
+	updatedView.Name = strings.TrimSpace(updatedView.Name)
+	if updatedView.Query != "" {
+		if _, err := ParseFilterQuery(updatedView.Query); err != nil {
+			return nil, err
+		}
+	}
+	if updatedView.SharedWith != nil {
+		updatedView.SharedWith = withoutUser(updatedView.SharedWith, user.ID)
+	}
+	return vs.viewRepo.Update(id, updatedView)
+}
+
+func (vs *viewUsecase) DeleteView(user *domain.User, id string) error {
+	view, err := vs.GetView(user, id)
+	if err != nil {
+		return err
+	}
+	if view.OwnerID != user.ID {
+		return errs.ErrForbidden
+	}
+	return vs.viewRepo.Delete(id)
+}
+
+func (vs *viewUsecase) GetViewTasks(user *domain.User, id string) ([]*domain.Task, error) {
+	view, err := vs.GetView(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := ParseFilterQuery(view.Query)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := vs.taskRepo.InWorkspace(user.WorkspaceID).Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	return visibleTasks(vs.policies, user, tasks), nil
+}
+
+// withoutUser drops the owner and duplicates from a share list.
+func withoutUser(userIDs []string, ownerID string) []string {
+	result := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id != ownerID && id != "" && !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
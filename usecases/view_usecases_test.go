@@ -0,0 +1,109 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/repositories/mocks"
+	"task-manager/usecases"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ViewUsecaseTestSuite struct {
+	suite.Suite
+	mockViewRepo *mocks.ViewRepository
+	mockTaskRepo *mocks.TaskRepository
+	viewUsecase  usecases.ViewUsecase
+	owner        *domain.User
+	teammate     *domain.User
+}
+
+func (s *ViewUsecaseTestSuite) SetupTest() {
+	s.mockViewRepo = new(mocks.ViewRepository)
+	s.mockTaskRepo = new(mocks.TaskRepository)
+	s.viewUsecase = usecases.NewViewUsecase(s.mockViewRepo, s.mockTaskRepo, nil)
+	s.owner = &domain.User{ID: "owner", WorkspaceID: "ws1", Role: domain.RoleUser}
+	s.teammate = &domain.User{ID: "teammate", WorkspaceID: "ws1", Role: domain.RoleUser}
+}
+
+func TestViewUsecase(t *testing.T) {
+	suite.Run(t, new(ViewUsecaseTestSuite))
+}
+
+func (s *ViewUsecaseTestSuite) TestCreateView_Success() {
+	view := &domain.View{Name: " Backend ", Query: "label:backend", SharedWith: []string{"teammate", "owner", "teammate"}}
+	s.mockViewRepo.On("Create", mock.MatchedBy(func(v *domain.View) bool {
+		return v.Name == "Backend" && v.WorkspaceID == "ws1" && v.OwnerID == "owner" && len(v.SharedWith) == 1 && v.SharedWith[0] == "teammate"
+	})).Return(view, nil).Once()
+
+	_, err := s.viewUsecase.CreateView(s.owner, view)
+
+	s.Require().NoError(err)
+	s.mockViewRepo.AssertExpectations(s.T())
+}
+
+func (s *ViewUsecaseTestSuite) TestCreateView_InvalidQuery() {
+	view := &domain.View{Name: "Broken", Query: "label<backend"}
+
+	_, err := s.viewUsecase.CreateView(s.owner, view)
+
+	s.Require().ErrorIs(err, errs.ErrInvalidQuery)
+	s.mockViewRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *ViewUsecaseTestSuite) TestGetViewTasks_SharedWithTeammate() {
+	view := &domain.View{ID: "v1", WorkspaceID: "ws1", OwnerID: "owner", Query: "label:backend", SharedWith: []string{"teammate"}}
+	tasks := []*domain.Task{{ID: "t1", Labels: []string{"backend"}}}
+	s.mockViewRepo.On("GetByID", "v1").Return(view, nil).Once()
+	s.mockTaskRepo.On("Find", &domain.Filter{Kind: domain.FilterCompare, Field: domain.FieldLabel, Op: domain.OpEq, Value: "backend"}).Return(tasks, nil).Once()
+
+	result, err := s.viewUsecase.GetViewTasks(s.teammate, "v1")
+
+	s.Require().NoError(err)
+	s.Assert().Equal(tasks, result)
+	s.mockViewRepo.AssertExpectations(s.T())
+	s.mockTaskRepo.AssertExpectations(s.T())
+}
+
+func (s *ViewUsecaseTestSuite) TestGetView_NotSharedIsNotFound() {
+	view := &domain.View{ID: "v1", WorkspaceID: "ws1", OwnerID: "owner", Query: "label:backend"}
+	s.mockViewRepo.On("GetByID", "v1").Return(view, nil).Once()
+
+	_, err := s.viewUsecase.GetView(s.teammate, "v1")
+
+	s.Require().ErrorIs(err, errs.ErrViewNotFound)
+	s.mockViewRepo.AssertExpectations(s.T())
+}
+
+func (s *ViewUsecaseTestSuite) TestGetViews_InTheWorkspace() {
+	views := []*domain.View{{ID: "v1", WorkspaceID: "ws1", OwnerID: "owner"}}
+	s.mockViewRepo.On("GetVisibleTo", "ws1", "owner").Return(views, nil).Once()
+
+	result, err := s.viewUsecase.GetViews(s.owner)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(views, result)
+	s.mockViewRepo.AssertExpectations(s.T())
+}
+
+func (s *ViewUsecaseTestSuite) TestGetView_OtherWorkspaceIsNotFound() {
+	view := &domain.View{ID: "v1", WorkspaceID: "ws2", OwnerID: "owner", Query: "label:backend"}
+	s.mockViewRepo.On("GetByID", "v1").Return(view, nil).Once()
+
+	_, err := s.viewUsecase.GetViewTasks(s.owner, "v1")
+
+	s.Require().ErrorIs(err, errs.ErrViewNotFound, "the owner switched to another workspace")
+	s.mockTaskRepo.AssertNotCalled(s.T(), "Find", mock.Anything)
+}
+
+func (s *ViewUsecaseTestSuite) TestDeleteView_OnlyOwner() {
+	view := &domain.View{ID: "v1", WorkspaceID: "ws1", OwnerID: "owner", Query: "label:backend", SharedWith: []string{"teammate"}}
+	s.mockViewRepo.On("GetByID", "v1").Return(view, nil).Once()
+
+	err := s.viewUsecase.DeleteView(s.teammate, "v1")
+
+	s.Require().ErrorIs(err, errs.ErrForbidden)
+	s.mockViewRepo.AssertNotCalled(s.T(), "Delete", mock.Anything)
+}
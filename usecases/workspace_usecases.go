@@ -0,0 +1,153 @@
+package usecases
+
+import (
+	"log"
+	"strings"
+	"task-manager/domain"
+	"task-manager/errs"
+	"time"
+)
+
+// WorkspaceRepository keeps the workspaces. Who belongs to them is kept
+// with the users.
+type WorkspaceRepository interface {
+	Create(workspace *domain.Workspace) error
+	// GetByID returns ErrWorkspaceNotFound if there is no workspace with id.
+	GetByID(id string) (*domain.Workspace, error)
+	// GetByIDs returns the workspaces with the given ids, sorted by name.
+	GetByIDs(ids []string) ([]*domain.Workspace, error)
+	Delete(id string) error
+}
+
+// WorkspaceUsecase lets users create the workspaces they work in and move
+// between them.
+type WorkspaceUsecase interface {
+	// ListWorkspaces returns the workspaces user is a member of.
+	ListWorkspaces(user *domain.User) ([]*domain.Workspace, error)
+	// CreateWorkspace creates a workspace with user as its admin.
+	CreateWorkspace(user *domain.User, name string) (*domain.Workspace, error)
+	// SwitchWorkspace returns a token for acting in another of the user's
+	// workspaces.
+	SwitchWorkspace(user *domain.User, workspaceID string) (string, error)
+	// JoinWorkspace makes user a member of the workspace they were invited
+	// to, with the role of the invitation, and returns the workspace.
+	JoinWorkspace(user *domain.User, invitation string) (*domain.Workspace, error)
+}
+
+type workspaceUsecase struct {
+	workspaceRepo  WorkspaceRepository
+	userRepo       UserRepository
+	invitationRepo InvitationRepository
+	jwtSvc         JWTService
+}
+
+func NewWorkspaceUsecase(wr WorkspaceRepository, ur UserRepository, ir InvitationRepository, js JWTService) WorkspaceUsecase {
+	return &workspaceUsecase{workspaceRepo: wr, userRepo: ur, invitationRepo: ir, jwtSvc: js}
+}
+
+func (w *workspaceUsecase) ListWorkspaces(user *domain.User) ([]*domain.Workspace, error) {
+	ids := make([]string, 0, len(user.Memberships))
+	for _, membership := range user.Memberships {
+		ids = append(ids, membership.WorkspaceID)
+	}
+	return w.workspaceRepo.GetByIDs(ids)
+}
+
+func (w *workspaceUsecase) CreateWorkspace(user *domain.User, name string) (*domain.Workspace, error) {
+	workspace := &domain.Workspace{Name: strings.TrimSpace(name), CreatedAt: time.Now()}
+	if err := w.workspaceRepo.Create(workspace); err != nil {
+		return nil, err
+	}
+	membership := domain.Membership{WorkspaceID: workspace.ID, Role: domain.RoleAdmin, JoinedAt: workspace.CreatedAt}
+	if err := w.userRepo.AddMembership(user.ID, membership); err != nil {
+		discardWorkspace(w.workspaceRepo, workspace)
+		return nil, err
+	}
+	log.Printf("INFO: User '%s' created workspace %s (%s)", user.Username, workspace.ID, workspace.Name)
+	return workspace, nil
+}
+
+func (w *workspaceUsecase) SwitchWorkspace(user *domain.User, workspaceID string) (string, error) {
+	if !user.Activate(workspaceID) {
+		return "", errs.ErrWorkspaceNotFound
+	}
+	if user.Disabled {
+		return "", errs.ErrAccountDisabled
+	}
+	log.Printf("INFO: User '%s' switched to workspace %s", user.Username, workspaceID)
+	return w.jwtSvc.GenerateJWT(user)
+}
+
+// JoinWorkspace uses up the invitation unless joining fails. Invitations
+// without a workspace are for registering only.
+func (w *workspaceUsecase) JoinWorkspace(user *domain.User, token string) (*domain.Workspace, error) {
+	invitation, err := w.invitationRepo.Consume(hashToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	workspace, err := w.join(user, invitation)
+	if err != nil {
+		if err := w.invitationRepo.Create(invitation); err != nil {
+			log.Printf("WARN: Failed to restore invitation %s: %v", invitation.ID, err)
+		}
+		return nil, err
+	}
+	log.Printf("INFO: User '%s' joined workspace %s with invitation %s", user.Username, workspace.ID, invitation.ID)
+	return workspace, nil
+}
+
+func (w *workspaceUsecase) join(user *domain.User, invitation *domain.Invitation) (*domain.Workspace, error) {
+	if invitation.WorkspaceID == "" {
+		return nil, errs.ErrInvalidInvitation
+	}
+	workspace, err := w.workspaceRepo.GetByID(invitation.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	membership := domain.Membership{WorkspaceID: workspace.ID, Role: invitation.Role, JoinedAt: time.Now()}
+	if err := w.userRepo.AddMembership(user.ID, membership); err != nil {
+		return nil, err
+	}
+	user.Memberships = append(user.Memberships, membership)
+	return workspace, nil
+}
+
+// createOwnWorkspace creates a workspace named name for a user who is about
+// to be created and makes them its admin: whoever starts a workspace owns
+// it. Discard the workspace if the user cannot be created after all.
+func createOwnWorkspace(wr WorkspaceRepository, user *domain.User, name string) (*domain.Workspace, error) {
+	workspace := &domain.Workspace{Name: name, CreatedAt: time.Now()}
+	if err := wr.Create(workspace); err != nil {
+		return nil, err
+	}
+	user.Memberships = append(user.Memberships, domain.Membership{
+		WorkspaceID: workspace.ID,
+		Role:        domain.RoleAdmin,
+		JoinedAt:    workspace.CreatedAt,
+	})
+	user.Activate(workspace.ID)
+	return workspace, nil
+}
+
+// discardWorkspace deletes a workspace nobody could be added to. Failing
+// to do so only leaves an empty workspace behind.
+func discardWorkspace(wr WorkspaceRepository, workspace *domain.Workspace) {
+	if err := wr.Delete(workspace.ID); err != nil {
+		log.Printf("WARN: Failed to delete the unused workspace %s: %v", workspace.ID, err)
+	}
+}
+
+// enterWorkspace activates the first workspace the user joined and is not
+// disabled in, which is where logins start.
+func enterWorkspace(user *domain.User) error {
+	for _, membership := range user.Memberships {
+		if !membership.Disabled {
+			user.Activate(membership.WorkspaceID)
+			return nil
+		}
+	}
+	if len(user.Memberships) > 0 {
+		return errs.ErrAccountDisabled
+	}
+	return errs.ErrNoWorkspace
+}
@@ -0,0 +1,145 @@
+package usecases_test
+
+import (
+	"task-manager/domain"
+	"task-manager/errs"
+	"task-manager/infrastructure"
+	"task-manager/repositories/mocks"
+	"task-manager/usecases"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkspaceUsecaseTestSuite struct {
+	suite.Suite
+	mockWorkspaceRepo *mocks.WorkspaceRepository
+	mockUserRepo      *mocks.UserRepository
+	mockInviteRepo    *mocks.InvitationRepository
+	jwtService        *infrastructure.JWTServiceV5
+	usecase           usecases.WorkspaceUsecase
+	user              *domain.User
+}
+
+func (s *WorkspaceUsecaseTestSuite) SetupTest() {
+	s.mockWorkspaceRepo = new(mocks.WorkspaceRepository)
+	s.mockUserRepo = new(mocks.UserRepository)
+	s.mockInviteRepo = new(mocks.InvitationRepository)
+	keys, err := infrastructure.NewKeyring(nil, infrastructure.DefaultKeyringConfig)
+	s.Require().NoError(err)
+	s.jwtService = infrastructure.NewJWTServiceV5(keys, infrastructure.DefaultJWTIssuer)
+	s.usecase = usecases.NewWorkspaceUsecase(s.mockWorkspaceRepo, s.mockUserRepo, s.mockInviteRepo, s.jwtService)
+
+	s.user = &domain.User{ID: "u1", Username: "alice", Memberships: []domain.Membership{
+		{WorkspaceID: "ws1", Role: domain.RoleAdmin},
+		{WorkspaceID: "ws2", Role: domain.RoleUser},
+		{WorkspaceID: "ws3", Role: domain.RoleUser, Disabled: true},
+	}}
+	s.user.Activate("ws1")
+}
+
+func TestWorkspaceUsecase(t *testing.T) {
+	suite.Run(t, new(WorkspaceUsecaseTestSuite))
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestListWorkspaces() {
+	workspaces := []*domain.Workspace{{ID: "ws2", Name: "Acme"}, {ID: "ws1", Name: "Home"}}
+	s.mockWorkspaceRepo.On("GetByIDs", []string{"ws1", "ws2", "ws3"}).Return(workspaces, nil).Once()
+
+	got, err := s.usecase.ListWorkspaces(s.user)
+
+	s.Require().NoError(err)
+	s.Assert().Equal(workspaces, got)
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestCreateWorkspace() {
+	s.mockWorkspaceRepo.On("Create", mock.MatchedBy(func(ws *domain.Workspace) bool {
+		return ws.Name == "Acme" && !ws.CreatedAt.IsZero()
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws4"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("AddMembership", "u1", mock.MatchedBy(func(m domain.Membership) bool {
+		return m.WorkspaceID == "ws4" && m.Role == domain.RoleAdmin
+	})).Return(nil).Once()
+
+	workspace, err := s.usecase.CreateWorkspace(s.user, "  Acme ")
+
+	s.Require().NoError(err)
+	s.Assert().Equal("ws4", workspace.ID)
+	s.Assert().Equal("ws1", s.user.WorkspaceID, "creating a workspace does not switch to it")
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestCreateWorkspace_DiscardedOnFailure() {
+	s.mockWorkspaceRepo.On("Create", mock.AnythingOfType("*domain.Workspace")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Workspace).ID = "ws4"
+	}).Return(nil).Once()
+	s.mockUserRepo.On("AddMembership", "u1", mock.Anything).Return(errs.ErrUserNotFound).Once()
+	s.mockWorkspaceRepo.On("Delete", "ws4").Return(nil).Once()
+
+	_, err := s.usecase.CreateWorkspace(s.user, "Acme")
+
+	s.Require().ErrorIs(err, errs.ErrUserNotFound)
+	s.mockWorkspaceRepo.AssertExpectations(s.T())
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestSwitchWorkspace() {
+	token, err := s.usecase.SwitchWorkspace(s.user, "ws2")
+
+	s.Require().NoError(err)
+	claims, err := s.jwtService.ParseJWT(token)
+	s.Require().NoError(err)
+	s.Assert().Equal("ws2", claims.WorkspaceID)
+	s.Assert().Equal(domain.RoleUser, claims.Role)
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestSwitchWorkspace_Refused() {
+	_, err := s.usecase.SwitchWorkspace(s.user, "ws3")
+	s.Assert().ErrorIs(err, errs.ErrAccountDisabled)
+
+	_, err = s.usecase.SwitchWorkspace(s.user, "ws9")
+	s.Assert().ErrorIs(err, errs.ErrWorkspaceNotFound)
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestJoinWorkspace() {
+	s.mockInviteRepo.On("Consume", sha256Hex("invite"), mock.Anything).Return(&domain.Invitation{
+		ID: "i1", WorkspaceID: "ws4", Role: "editor",
+	}, nil).Once()
+	s.mockWorkspaceRepo.On("GetByID", "ws4").Return(&domain.Workspace{ID: "ws4", Name: "Acme"}, nil).Once()
+	s.mockUserRepo.On("AddMembership", "u1", mock.MatchedBy(func(m domain.Membership) bool {
+		return m.WorkspaceID == "ws4" && m.Role == "editor" && !m.JoinedAt.IsZero()
+	})).Return(nil).Once()
+
+	workspace, err := s.usecase.JoinWorkspace(s.user, "invite")
+
+	s.Require().NoError(err)
+	s.Assert().Equal("ws4", workspace.ID)
+	s.Assert().Equal("editor", s.user.Membership("ws4").Role)
+	s.Assert().Equal("ws1", s.user.WorkspaceID, "joining a workspace does not switch to it")
+	s.mockUserRepo.AssertExpectations(s.T())
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestJoinWorkspace_RestoresInvitationOnFailure() {
+	invitation := &domain.Invitation{ID: "i1", WorkspaceID: "ws2", Role: domain.RoleUser}
+	s.mockInviteRepo.On("Consume", sha256Hex("invite"), mock.Anything).Return(invitation, nil).Once()
+	s.mockWorkspaceRepo.On("GetByID", "ws2").Return(&domain.Workspace{ID: "ws2"}, nil).Once()
+	s.mockUserRepo.On("AddMembership", "u1", mock.Anything).Return(errs.ErrAlreadyMember).Once()
+	s.mockInviteRepo.On("Create", invitation).Return(nil).Once()
+
+	_, err := s.usecase.JoinWorkspace(s.user, "invite")
+
+	s.Require().ErrorIs(err, errs.ErrAlreadyMember)
+	s.mockInviteRepo.AssertExpectations(s.T())
+}
+
+func (s *WorkspaceUsecaseTestSuite) TestJoinWorkspace_RegistrationInvitation() {
+	invitation := &domain.Invitation{ID: "i1", Email: "alice@example.com"}
+	s.mockInviteRepo.On("Consume", sha256Hex("invite"), mock.Anything).Return(invitation, nil).Once()
+	s.mockInviteRepo.On("Create", invitation).Return(nil).Once()
+
+	_, err := s.usecase.JoinWorkspace(s.user, "invite")
+
+	s.Assert().ErrorIs(err, errs.ErrInvalidInvitation)
+	s.mockUserRepo.AssertNotCalled(s.T(), "AddMembership", mock.Anything, mock.Anything)
+}